@@ -12,13 +12,15 @@
 #
 # PM fetch strategy:
 #   All vendor API calls go through the mcp-server (MCPClient).
-#   jira_project_key → jira_search_issues (list) + jira_get_issue (hydrate each)
+#   jira_project_key → jira_search_issues (list) + jira_get_issues (hydrate in batches)
 #   github_repo      → github_list_issues (list) + github_get_issue (hydrate each)
 #   Unknown ref_keys are skipped with a warning.
 #
 # Hydration: the list tools return minimal stubs (key/summary/state only).
-#   We call the get-detail tool per item to obtain description and updated_at.
-#   Cap: 50 Jira issues / 100 GitHub issues per sync call.
+#   Jira issues are hydrated _JIRA_BATCH_SIZE keys per jira_get_issues call;
+#   GitHub issues with one github_get_issue call per item.
+#   Cap: _JIRA_MAX_ISSUES (5000) Jira issues / _GITHUB_MAX_RESULTS (100) GitHub
+#   issues per sync call.
 
 import json
 import logging
@@ -35,7 +37,10 @@ from vectors import VectorStore
 
 logger = logging.getLogger("uvicorn.error")
 
-_JIRA_MAX_RESULTS = 50
+_JIRA_MAX_RESULTS = 100   # issues per search page
+_JIRA_MAX_ISSUES = 5000   # stop a single sync after this many issues
+_JIRA_BATCH_SIZE = 100    # keys per jira_get_issues call
+_JIRA_DETAIL_FIELDS = "summary,description,status,assignee,updated"
 _GITHUB_MAX_RESULTS = 100
 
 
@@ -170,7 +175,7 @@ class SyncStore:
 async def _fetch_jira(
     mcp: MCPClient, project_key: str, updated_since: str | None
 ) -> list[dict]:
-    """Fetch Jira issues via jira_search_issues + jira_get_issue per key.
+    """Fetch Jira issues via jira_search_issues + batched jira_get_issues.
 
     Returns a list of normalised item dicts with keys:
     id, title, body, status, assignee, url, updated_at.
@@ -180,32 +185,67 @@ async def _fetch_jira(
         jql_date = _jira_date(updated_since)
         jql = f'project = {project_key} AND updated >= "{jql_date}" ORDER BY updated ASC'
 
-    result = await mcp.call(
-        "jira_search_issues",
-        {"query": jql, "max_results": _JIRA_MAX_RESULTS},
-    )
-    stubs = result.get("issues", [])
+    # all_pages follows nextPageToken server-side up to max_items; keep going
+    # with the returned token so large projects are synced in full.
+    args = {
+        "query": jql,
+        "max_results": _JIRA_MAX_RESULTS,
+        "all_pages": True,
+        "max_items": _JIRA_MAX_ISSUES,
+    }
+    stubs: list[dict] = []
+    while True:
+        result = await mcp.call("jira_search_issues", args)
+        stubs.extend(result.get("issues", []))
+        token = result.get("next_page_token")
+        if not token:
+            break
+        if len(stubs) >= _JIRA_MAX_ISSUES:
+            logger.warning(
+                "Jira project %s has more than %d matching issues — syncing the first %d",
+                project_key, _JIRA_MAX_ISSUES, len(stubs),
+            )
+            break
+        args = {**args, "next_page_token": token}
     if not stubs:
         return []
 
+    keys = [s["key"] for s in stubs if s.get("key")]
+    details: dict[str, dict] = {}
+    for start in range(0, len(keys), _JIRA_BATCH_SIZE):
+        batch = keys[start:start + _JIRA_BATCH_SIZE]
+        try:
+            result = await mcp.call(
+                "jira_get_issues", {"keys": batch, "fields": _JIRA_DETAIL_FIELDS}
+            )
+        except MCPError as exc:
+            logger.warning(
+                "jira_get_issues(%s..%s) failed: %s — using stub data",
+                batch[0], batch[-1], exc,
+            )
+            continue
+        for issue in result.get("issues", []):
+            details[issue.get("key", "")] = issue
+        for err in result.get("errors", []):
+            logger.warning(
+                "jira_get_issues: %s failed: %s — using stub data",
+                err.get("key"), err.get("error"),
+            )
+
     items: list[dict] = []
     for stub in stubs:
         key = stub.get("key", "")
         if not key:
             continue
-        try:
-            detail = await mcp.call("jira_get_issue", {"key": key})
-        except MCPError as exc:
-            logger.warning("jira_get_issue(%s) failed: %s — using stub data", key, exc)
-            detail = {
-                "key": key,
-                "summary": stub.get("summary", ""),
-                "description": "",
-                "status": stub.get("status", ""),
-                "assignee": stub.get("assignee", ""),
-                "url": stub.get("url", ""),
-                "updated": "",
-            }
+        detail = details.get(key) or {
+            "key": key,
+            "summary": stub.get("summary", ""),
+            "description": "",
+            "status": stub.get("status", ""),
+            "assignee": stub.get("assignee", ""),
+            "url": stub.get("url", ""),
+            "updated": "",
+        }
         items.append({
             "id": detail.get("key", key),
             "title": detail.get("summary", ""),
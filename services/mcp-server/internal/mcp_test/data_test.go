@@ -0,0 +1,196 @@
+package mcp_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const issuesCSV = `key,status,points,assignee
+P-1,Done,3,ana
+P-2,In Progress,5,ben
+P-3,Done,8,ben
+P-4,To Do,,ana
+P-5,Done,2,cho
+`
+
+func queryRows(t *testing.T, got map[string]any) []map[string]any {
+	t.Helper()
+	raw, _ := got["rows"].([]any)
+	rows := make([]map[string]any, len(raw))
+	for i, r := range raw {
+		rows[i] = r.(map[string]any)
+	}
+	return rows
+}
+
+func TestDataQueryCSVFilterSelectSort(t *testing.T) {
+	writeWorkspace(t, map[string]string{"issues.csv": issuesCSV})
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "data_query", map[string]any{
+		"path":     "issues.csv",
+		"where":    `status = "Done" and points >= 3`,
+		"select":   "key,points",
+		"order_by": "points desc",
+	}))
+	rows := queryRows(t, got)
+	if len(rows) != 2 || rows[0]["key"] != "P-3" || rows[1]["key"] != "P-1" || rows[0]["assignee"] != nil {
+		t.Errorf("rows = %v", rows)
+	}
+	if got["scanned_rows"] != float64(5) || got["matched_rows"] != float64(2) {
+		t.Errorf("counts = %v", got)
+	}
+
+	got = resultJSON(t, toolCall(t, srv, "data_query", map[string]any{
+		"path":  "issues.csv",
+		"where": `(assignee in (ana, cho) or status contains progress) and not key = P-4`,
+		"limit": 2,
+	}))
+	rows = queryRows(t, got)
+	if len(rows) != 2 || rows[0]["key"] != "P-1" || rows[1]["key"] != "P-2" || got["truncated"] != true || got["matched_rows"] != float64(3) {
+		t.Errorf("or/in/not query = %v", got)
+	}
+}
+
+func TestDataQueryGroupBy(t *testing.T) {
+	writeWorkspace(t, map[string]string{"issues.csv": issuesCSV})
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "data_query", map[string]any{
+		"path":      "issues.csv",
+		"group_by":  "assignee",
+		"aggregate": "count,sum(points),max(points)",
+		"order_by":  "sum(points) desc",
+	}))
+	rows := queryRows(t, got)
+	if len(rows) != 3 || got["groups"] != float64(3) {
+		t.Fatalf("groups = %v", got)
+	}
+	if rows[0]["assignee"] != "ben" || rows[0]["count"] != float64(2) || rows[0]["sum(points)"] != float64(13) || rows[0]["max(points)"] != "8" {
+		t.Errorf("top group = %v", rows[0])
+	}
+	// P-4 has no points: it is counted but adds nothing to the sum.
+	if rows[1]["assignee"] != "ana" || rows[1]["count"] != float64(2) || rows[1]["sum(points)"] != float64(3) {
+		t.Errorf("second group = %v", rows[1])
+	}
+
+	got = resultJSON(t, toolCall(t, srv, "data_query", map[string]any{
+		"path": "issues.csv", "aggregate": "count,avg(points)", "where": "status = Closed",
+	}))
+	rows = queryRows(t, got)
+	if len(rows) != 1 || rows[0]["count"] != float64(0) || rows[0]["avg(points)"] != nil {
+		t.Errorf("aggregate over nothing = %v", got)
+	}
+}
+
+func TestDataQueryJSONAndJSONL(t *testing.T) {
+	var jsonl strings.Builder
+	for i := 1; i <= 1000; i++ {
+		fmt.Fprintf(&jsonl, `{"id": %d, "fields": {"team": "t%d", "estimate": %d}}`+"\n", i, i%3, i)
+	}
+	jsonl.WriteString("not json\n")
+	writeWorkspace(t, map[string]string{
+		"events.jsonl": jsonl.String(),
+		"export.json":  `{"meta": {"total": 2}, "data": {"items": [{"k": "a", "v": 1}, {"k": "b", "v": 2}]}}`,
+		"list.json":    `[{"k": "x"}, {"k": "y"}]`,
+	})
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "data_query", map[string]any{
+		"path":      "events.jsonl",
+		"where":     "fields.estimate > 990",
+		"group_by":  "fields.team",
+		"aggregate": "count,sum(fields.estimate)",
+		"order_by":  "fields.team",
+	}))
+	rows := queryRows(t, got)
+	if len(rows) != 3 || rows[0]["fields.team"] != "t0" || rows[0]["count"] != float64(3) || rows[0]["sum(fields.estimate)"] != float64(993+996+999) {
+		t.Errorf("jsonl groups = %v", got)
+	}
+	if got["bad_rows"] != float64(1) || got["scanned_rows"] != float64(1000) {
+		t.Errorf("bad row accounting = %v", got)
+	}
+
+	got = resultJSON(t, toolCall(t, srv, "data_query", map[string]any{"path": "export.json", "records_path": "data.items", "where": "v > 1"}))
+	if rows := queryRows(t, got); len(rows) != 1 || rows[0]["k"] != "b" {
+		t.Errorf("records_path query = %v", got)
+	}
+	got = resultJSON(t, toolCall(t, srv, "data_query", map[string]any{"path": "list.json", "order_by": "k desc"}))
+	if rows := queryRows(t, got); len(rows) != 2 || rows[0]["k"] != "y" {
+		t.Errorf("array query = %v", got)
+	}
+
+	// An object without records_path names its keys in the error.
+	if msg := toolErrorText(t, toolCall(t, srv, "data_query", map[string]any{"path": "export.json"})); !strings.Contains(msg, "meta, data") {
+		t.Errorf("error = %s", msg)
+	}
+	assertToolError(t, toolCall(t, srv, "data_query", map[string]any{"path": "list.json", "where": "k = "}))
+	assertToolError(t, toolCall(t, srv, "data_query", map[string]any{"path": "list.json", "aggregate": "median(k)"}))
+}
+
+// Every Unicode space separates where tokens, and multi-byte words such as
+// "à" (whose second byte is 0xA0) are read whole; either used to hang the
+// tokenizer.
+func TestDataQueryWhereUnicodeSpaces(t *testing.T) {
+	writeWorkspace(t, map[string]string{"issues.csv": issuesCSV})
+	srv := newServer()
+
+	done := make(chan map[string]any, 1)
+	go func() {
+		done <- resultJSON(t, toolCall(t, srv, "data_query", map[string]any{
+			"path":  "issues.csv",
+			"where": "status\r= Done\v\f and\u0085assignee != à\r",
+		}))
+	}()
+	select {
+	case got := <-done:
+		if got["matched_rows"] != float64(3) {
+			t.Errorf("query = %v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("where tokenizer did not return")
+	}
+	assertToolError(t, toolCall(t, srv, "data_query", map[string]any{"path": "issues.csv", "where": "\r"}))
+}
+
+func TestDataQueryRejectsInvalidDelimiter(t *testing.T) {
+	writeWorkspace(t, map[string]string{"issues.csv": issuesCSV})
+	srv := newServer()
+
+	for _, d := range []string{`"`, "\n", "\r", ";;"} {
+		msg := toolErrorText(t, toolCall(t, srv, "data_query", map[string]any{"path": "issues.csv", "delimiter": d}))
+		if !strings.Contains(msg, "invalid delimiter") {
+			t.Errorf("delimiter %q: error = %s", d, msg)
+		}
+	}
+	got := resultJSON(t, toolCall(t, srv, "data_query", map[string]any{"path": "issues.csv", "delimiter": ","}))
+	if got["matched_rows"] != float64(5) {
+		t.Errorf("comma delimiter = %v", got)
+	}
+}
+
+// order_by may name a column that select leaves out, including when the
+// streaming top-N buffer is trimmed.
+func TestDataQueryOrderByUnselectedColumn(t *testing.T) {
+	var csv strings.Builder
+	csv.WriteString("key,points\n")
+	for i := 1; i <= 300; i++ {
+		fmt.Fprintf(&csv, "P-%d,%d\n", i, (i*37)%301)
+	}
+	writeWorkspace(t, map[string]string{"many.csv": csv.String()})
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "data_query", map[string]any{
+		"path": "many.csv", "select": "key", "order_by": "points desc", "limit": 3,
+	}))
+	rows := queryRows(t, got)
+	// points 300, 299 and 298 belong to P-122, P-244 and P-65 (37·i mod 301).
+	if len(rows) != 3 || rows[0]["key"] != "P-122" || rows[1]["key"] != "P-244" || rows[2]["key"] != "P-65" {
+		t.Errorf("rows = %v", rows)
+	}
+	if _, ok := rows[0]["points"]; ok {
+		t.Errorf("unselected column returned: %v", rows[0])
+	}
+}
@@ -0,0 +1,775 @@
+package mcp_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mcp-server/internal/mcp"
+)
+
+// writeWorkspace points FILE_WORK_DIR at a fresh directory populated with
+// files (relative path → content) and returns it.
+func writeWorkspace(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("FILE_WORK_DIR", dir)
+	for rel, content := range files {
+		abs := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(abs, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// ---------------------------------------------------------------------------
+// file_search / file_glob
+// ---------------------------------------------------------------------------
+
+func TestFileSearchLiteralWithContext(t *testing.T) {
+	writeWorkspace(t, map[string]string{
+		"notes/a.md":    "intro\nTODO: fix (x)\noutro\n",
+		"notes/b.txt":   "todo: lower case\n",
+		"crawl/page.md": "TODO: ignored\n",
+		"data/blob.bin": "TODO\x00binary",
+	})
+
+	got := resultJSON(t, toolCall(t, newServer(), "file_search", map[string]any{
+		"pattern": "TODO: fix (x)", "exclude": "crawl", "context_lines": 1,
+	}))
+	matches, _ := got["matches"].([]any)
+	if len(matches) != 1 {
+		t.Fatalf("matches = %v", got["matches"])
+	}
+	m := matches[0].(map[string]any)
+	if m["path"] != "notes/a.md" || m["line"] != float64(2) {
+		t.Errorf("match = %v", m)
+	}
+	if before, after := m["before"].([]any), m["after"].([]any); len(before) != 1 || before[0] != "intro" || len(after) != 1 || after[0] != "outro" {
+		t.Errorf("context = %v / %v", m["before"], m["after"])
+	}
+
+	got = resultJSON(t, toolCall(t, newServer(), "file_search", map[string]any{
+		"pattern": "^todo", "regex": true, "ignore_case": true, "include": "*.md,*.txt", "exclude": "crawl",
+	}))
+	if got["match_count"] != float64(2) {
+		t.Errorf("regex matches = %v", got["matches"])
+	}
+}
+
+func TestFileSearchCapsMatches(t *testing.T) {
+	writeWorkspace(t, map[string]string{"log.txt": "x\nx\nx\nx\nx\n"})
+	got := resultJSON(t, toolCall(t, newServer(), "file_search", map[string]any{
+		"pattern": "x", "max_matches": 3,
+	}))
+	if got["match_count"] != float64(3) || got["truncated"] != true {
+		t.Errorf("result = %v", got)
+	}
+	// Exactly max_matches matches is not truncated.
+	got = resultJSON(t, toolCall(t, newServer(), "file_search", map[string]any{
+		"pattern": "x", "max_matches": 5,
+	}))
+	if got["match_count"] != float64(5) || got["truncated"] != false {
+		t.Errorf("exact result = %v", got)
+	}
+}
+
+// A line too long to scan stops that file's search but keeps the matches
+// found before it.
+func TestFileSearchKeepsMatchesBeforeLongLine(t *testing.T) {
+	writeWorkspace(t, map[string]string{"log.txt": "x one\n" + strings.Repeat("y", 2<<20) + "\nx two\n"})
+	got := resultJSON(t, toolCall(t, newServer(), "file_search", map[string]any{"pattern": "x"}))
+	errs, _ := got["errors"].([]any)
+	if got["match_count"] != float64(1) || len(errs) != 1 || !strings.HasPrefix(errs[0].(string), "log.txt: ") {
+		t.Errorf("result = %v", got)
+	}
+}
+
+func TestFileGlobRecursive(t *testing.T) {
+	writeWorkspace(t, map[string]string{
+		"report.md":          "a",
+		"docs/guide.md":      "b",
+		"docs/deep/api.md":   "c",
+		"docs/deep/data.csv": "d",
+	})
+
+	paths := func(args map[string]any) []string {
+		got := resultJSON(t, toolCall(t, newServer(), "file_glob", args))
+		var out []string
+		for _, f := range got["files"].([]any) {
+			out = append(out, f.(map[string]any)["path"].(string))
+		}
+		return out
+	}
+	if got := paths(map[string]any{"pattern": "*.md"}); len(got) != 3 {
+		t.Errorf("*.md = %v", got)
+	}
+	if got := paths(map[string]any{"pattern": "docs/**/*.md"}); len(got) != 2 {
+		t.Errorf("docs/**/*.md = %v", got)
+	}
+	if got := paths(map[string]any{"pattern": "docs/*/*.csv"}); len(got) != 1 || got[0] != "docs/deep/data.csv" {
+		t.Errorf("docs/*/*.csv = %v", got)
+	}
+	if got := paths(map[string]any{"pattern": "*.md", "exclude": "deep"}); len(got) != 2 {
+		t.Errorf("exclude deep = %v", got)
+	}
+}
+
+func TestFileSearchRejectsEscapingPath(t *testing.T) {
+	writeWorkspace(t, nil)
+	assertToolError(t, toolCall(t, newServer(), "file_search", map[string]any{"pattern": "root", "path": "../.."}))
+	assertToolError(t, toolCall(t, newServer(), "file_glob", map[string]any{"pattern": "*", "path": "../.."}))
+}
+
+// ---------------------------------------------------------------------------
+// Editing
+// ---------------------------------------------------------------------------
+
+func readWorkspaceFile(t *testing.T, dir, rel string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, rel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestFileEditRequiresUniqueMatch(t *testing.T) {
+	dir := writeWorkspace(t, map[string]string{"r.md": "status: draft\nowner: a\nstatus: draft\n"})
+	srv := newServer()
+
+	assertToolError(t, toolCall(t, srv, "file_edit", map[string]any{
+		"path": "r.md", "old_string": "status: draft", "new_string": "status: final",
+	}))
+	assertToolError(t, toolCall(t, srv, "file_edit", map[string]any{
+		"path": "r.md", "old_string": "missing", "new_string": "x",
+	}))
+	if got := readWorkspaceFile(t, dir, "r.md"); got != "status: draft\nowner: a\nstatus: draft\n" {
+		t.Fatalf("file changed by failed edits: %q", got)
+	}
+
+	resultJSON(t, toolCall(t, srv, "file_edit", map[string]any{
+		"path": "r.md", "old_string": "owner: a\nstatus: draft", "new_string": "owner: b\nstatus: final",
+	}))
+	got := resultJSON(t, toolCall(t, srv, "file_edit", map[string]any{
+		"path": "r.md", "old_string": "status:", "new_string": "state:", "replace_all": true,
+	}))
+	if got["replacements"] != float64(2) {
+		t.Errorf("replacements = %v", got["replacements"])
+	}
+	if got := readWorkspaceFile(t, dir, "r.md"); got != "state: draft\nowner: b\nstate: final\n" {
+		t.Errorf("content = %q", got)
+	}
+
+	// A missing new_string is an error; an empty one deletes the match.
+	assertToolError(t, toolCall(t, srv, "file_edit", map[string]any{"path": "r.md", "old_string": "owner: b\n"}))
+	resultJSON(t, toolCall(t, srv, "file_edit", map[string]any{"path": "r.md", "old_string": "owner: b\n", "new_string": ""}))
+	if got := readWorkspaceFile(t, dir, "r.md"); got != "state: draft\nstate: final\n" {
+		t.Errorf("after delete = %q", got)
+	}
+}
+
+func TestFileAppendInsertAndDeleteLines(t *testing.T) {
+	dir := writeWorkspace(t, map[string]string{"log.txt": "one\ntwo\nthree"})
+	srv := newServer()
+
+	resultJSON(t, toolCall(t, srv, "file_append", map[string]any{"path": "new/log.txt", "content": "first\n"}))
+	if got := readWorkspaceFile(t, dir, "new/log.txt"); got != "first\n" {
+		t.Errorf("append to new file = %q", got)
+	}
+
+	resultJSON(t, toolCall(t, srv, "file_insert_lines", map[string]any{"path": "log.txt", "line": 2, "content": "one-and-a-half"}))
+	resultJSON(t, toolCall(t, srv, "file_insert_lines", map[string]any{"path": "log.txt", "content": "four\n"}))
+	if got := readWorkspaceFile(t, dir, "log.txt"); got != "one\none-and-a-half\ntwo\nthree\nfour\n" {
+		t.Fatalf("after inserts = %q", got)
+	}
+
+	got := resultJSON(t, toolCall(t, srv, "file_delete_lines", map[string]any{"path": "log.txt", "start_line": 2, "end_line": 3}))
+	if got["lines_deleted"] != float64(2) {
+		t.Errorf("lines_deleted = %v", got["lines_deleted"])
+	}
+	if got := readWorkspaceFile(t, dir, "log.txt"); got != "one\nthree\nfour\n" {
+		t.Errorf("after delete = %q", got)
+	}
+	assertToolError(t, toolCall(t, srv, "file_delete_lines", map[string]any{"path": "log.txt", "start_line": 3, "end_line": 9}))
+}
+
+func TestFilePatchAppliesUnifiedDiff(t *testing.T) {
+	dir := writeWorkspace(t, map[string]string{
+		"main.txt": "header\nalpha\nbeta\ngamma\ndelta\nepsilon\nzeta\n",
+	})
+	// Line numbers are off by one, as if the file had gained a line since
+	// the diff was made; the hunks must still land on their context.
+	patch := `--- a/main.txt
++++ b/main.txt
+@@ -1,3 +1,3 @@
+ alpha
+-beta
++BETA
+ gamma
+@@ -5,2 +5,3 @@
+ epsilon
++epsilon-prime
+ zeta
+`
+	got := resultJSON(t, toolCall(t, newServer(), "file_patch", map[string]any{"path": "main.txt", "patch": patch}))
+	if got["hunks_applied"] != float64(2) {
+		t.Errorf("hunks_applied = %v", got["hunks_applied"])
+	}
+	want := "header\nalpha\nBETA\ngamma\ndelta\nepsilon\nepsilon-prime\nzeta\n"
+	if got := readWorkspaceFile(t, dir, "main.txt"); got != want {
+		t.Errorf("patched = %q, want %q", got, want)
+	}
+}
+
+func TestFilePatchLeavesFileUntouchedOnMismatch(t *testing.T) {
+	dir := writeWorkspace(t, map[string]string{"main.txt": "a\nb\nc\n"})
+	patch := "@@ -1,2 +1,2 @@\n a\n-b\n+B\n@@ -3,1 +3,1 @@\n-nope\n+yes\n"
+	assertToolError(t, toolCall(t, newServer(), "file_patch", map[string]any{"path": "main.txt", "patch": patch}))
+	if got := readWorkspaceFile(t, dir, "main.txt"); got != "a\nb\nc\n" {
+		t.Errorf("file changed: %q", got)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temp files left behind: %v", entries)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// History
+// ---------------------------------------------------------------------------
+
+func TestFileHistoryDiffAndRestore(t *testing.T) {
+	dir := writeWorkspace(t, map[string]string{"output/summary.txt": "draft by hand\n"})
+	srv := newServer()
+
+	// The pre-existing content is captured before the first overwrite.
+	resultJSON(t, toolCall(t, srv, "file_write", map[string]any{"path": "output/summary.txt", "content": "v1\nshared\n"}))
+	resultJSON(t, toolCall(t, srv, "file_edit", map[string]any{"path": "output/summary.txt", "old_string": "v1", "new_string": "v2"}))
+
+	hist := resultJSON(t, toolCall(t, srv, "file_history", map[string]any{"path": "output/summary.txt"}))
+	versions, _ := hist["versions"].([]any)
+	if len(versions) != 3 {
+		t.Fatalf("versions = %v", versions)
+	}
+	var ops []string
+	for _, v := range versions {
+		ops = append(ops, v.(map[string]any)["op"].(string))
+	}
+	if strings.Join(ops, ",") != "edit,write,external" {
+		t.Errorf("ops newest first = %v", ops)
+	}
+
+	diff := resultJSON(t, toolCall(t, srv, "file_diff", map[string]any{"path": "output/summary.txt"}))
+	if d, _ := diff["diff"].(string); !strings.Contains(d, "-v1\n+v2\n shared\n") {
+		t.Errorf("diff = %q", d)
+	}
+
+	resultJSON(t, toolCall(t, srv, "file_restore", map[string]any{"path": "output/summary.txt", "version": 1}))
+	if got := readWorkspaceFile(t, dir, "output/summary.txt"); got != "draft by hand\n" {
+		t.Errorf("restored = %q", got)
+	}
+	hist = resultJSON(t, toolCall(t, srv, "file_history", map[string]any{"path": "output/summary.txt"}))
+	if hist["count"] != float64(4) {
+		t.Errorf("restore should be recorded, count = %v", hist["count"])
+	}
+}
+
+func TestFileDiffOutputIsAPatch(t *testing.T) {
+	dir := writeWorkspace(t, nil)
+	srv := newServer()
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm"
+	resultJSON(t, toolCall(t, srv, "file_write", map[string]any{"path": "x.txt", "content": before}))
+	resultJSON(t, toolCall(t, srv, "file_write", map[string]any{"path": "x.txt", "content": after}))
+
+	diff := resultJSON(t, toolCall(t, srv, "file_diff", map[string]any{"path": "x.txt", "from": 1, "to": "2"}))
+	patch, _ := diff["diff"].(string)
+	if strings.Count(patch, "@@ -") != 2 {
+		t.Errorf("expected two hunks:\n%s", patch)
+	}
+
+	resultJSON(t, toolCall(t, srv, "file_restore", map[string]any{"path": "x.txt", "version": 1}))
+	resultJSON(t, toolCall(t, srv, "file_patch", map[string]any{"path": "x.txt", "patch": patch}))
+	if got := readWorkspaceFile(t, dir, "x.txt"); got != after {
+		t.Errorf("re-applied diff = %q, want %q", got, after)
+	}
+}
+
+func TestFileHistoryRetentionAndReservedDir(t *testing.T) {
+	writeWorkspace(t, nil)
+	t.Setenv("FILE_HISTORY_MAX_VERSIONS", "3")
+	srv := newServer()
+	for i := 1; i <= 5; i++ {
+		resultJSON(t, toolCall(t, srv, "file_write", map[string]any{"path": "n.txt", "content": strings.Repeat("x", i)}))
+	}
+	hist := resultJSON(t, toolCall(t, srv, "file_history", map[string]any{"path": "n.txt"}))
+	versions, _ := hist["versions"].([]any)
+	if len(versions) != 3 || versions[2].(map[string]any)["version"] != float64(3) {
+		t.Errorf("versions = %v", versions)
+	}
+	assertToolError(t, toolCall(t, srv, "file_restore", map[string]any{"path": "n.txt", "version": 1}))
+
+	assertToolError(t, toolCall(t, srv, "file_read", map[string]any{"path": ".history/logs"}))
+	list := resultJSON(t, toolCall(t, srv, "file_list", map[string]any{}))
+	if list["count"] != float64(1) {
+		t.Errorf("file_list should hide .history: %v", list["entries"])
+	}
+}
+
+func TestFileHistoryByteLimit(t *testing.T) {
+	dir := writeWorkspace(t, nil)
+	t.Setenv("FILE_HISTORY_MAX_BYTES", "100")
+	srv := newServer()
+	for i := 1; i <= 3; i++ {
+		resultJSON(t, toolCall(t, srv, "file_write", map[string]any{"path": "a.txt", "content": strings.Repeat(string(rune('0'+i)), 40)}))
+	}
+	// Three 40-byte versions exceed 100 bytes, so the oldest is dropped.
+	hist := resultJSON(t, toolCall(t, srv, "file_history", map[string]any{"path": "a.txt"}))
+	versions, _ := hist["versions"].([]any)
+	if len(versions) != 2 || versions[1].(map[string]any)["version"] != float64(2) {
+		t.Errorf("versions = %v", versions)
+	}
+	if objects, _ := os.ReadDir(filepath.Join(dir, ".history", "objects")); len(objects) != 2 {
+		t.Errorf("stored objects = %d, want 2", len(objects))
+	}
+
+	// A file's latest version is kept even when it alone is over the limit.
+	resultJSON(t, toolCall(t, srv, "file_write", map[string]any{"path": "b.txt", "content": strings.Repeat("b", 120)}))
+	hist = resultJSON(t, toolCall(t, srv, "file_history", map[string]any{"path": "b.txt"}))
+	if versions, _ := hist["versions"].([]any); len(versions) != 1 {
+		t.Errorf("b.txt versions = %v", versions)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Quotas and ranged reads
+// ---------------------------------------------------------------------------
+
+func TestFileQuotas(t *testing.T) {
+	writeWorkspace(t, map[string]string{"seed.txt": "12345"})
+	t.Setenv("FILE_MAX_BYTES", "10")
+	t.Setenv("FILE_WORKSPACE_MAX_BYTES", "20")
+	t.Setenv("FILE_MAX_COUNT", "3")
+	t.Setenv("FILE_ALLOWED_EXTENSIONS", "txt, .md")
+	srv := newServer()
+
+	write := func(path, content string) map[string]any {
+		return resultJSON(t, toolCall(t, srv, "file_write", map[string]any{"path": path, "content": content}))
+	}
+	assertToolError(t, toolCall(t, srv, "file_write", map[string]any{"path": "big.txt", "content": strings.Repeat("x", 11)}))
+	assertToolError(t, toolCall(t, srv, "file_write", map[string]any{"path": "run.sh", "content": "rm -rf /"}))
+
+	write("a.md", "0123456789")
+	// 5 + 10 + 6 > 20 total bytes.
+	assertToolError(t, toolCall(t, srv, "file_write", map[string]any{"path": "b.txt", "content": "012345"}))
+	// Overwriting counts the new size only.
+	write("a.md", "0")
+	write("b.txt", "0")
+	// seed.txt, a.md and b.txt fill the file count.
+	assertToolError(t, toolCall(t, srv, "file_append", map[string]any{"path": "c.txt", "content": "0"}))
+	write("b.txt", "01")
+}
+
+func TestFileReadRanges(t *testing.T) {
+	writeWorkspace(t, map[string]string{
+		"big.txt":  "line1\nline2\nline3\nline4\n",
+		"utf8.txt": "aé", // é is two bytes
+	})
+	t.Setenv("FILE_READ_MAX_BYTES", "8")
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "file_read", map[string]any{"path": "big.txt"}))
+	if got["content"] != "line1\nli" || got["truncated"] != true || got["next_offset"] != float64(8) || got["size"] != float64(24) {
+		t.Errorf("capped read = %v", got)
+	}
+	got = resultJSON(t, toolCall(t, srv, "file_read", map[string]any{"path": "big.txt", "offset": 18, "length": 100}))
+	if got["content"] != "line4\n" || got["truncated"] != false {
+		t.Errorf("offset read = %v", got)
+	}
+	got = resultJSON(t, toolCall(t, srv, "file_read", map[string]any{"path": "utf8.txt", "length": 2}))
+	if got["content"] != "a" || got["next_offset"] != float64(1) {
+		t.Errorf("split rune read = %v", got)
+	}
+	// A length shorter than the next character still returns all of it.
+	got = resultJSON(t, toolCall(t, srv, "file_read", map[string]any{"path": "utf8.txt", "offset": 1, "length": 1}))
+	if got["content"] != "é" || got["truncated"] != false {
+		t.Errorf("short rune read = %v", got)
+	}
+	assertToolError(t, toolCall(t, srv, "file_read", map[string]any{"path": "big.txt", "length": 0}))
+
+	t.Setenv("FILE_READ_MAX_BYTES", "1000")
+	got = resultJSON(t, toolCall(t, srv, "file_read", map[string]any{"path": "big.txt", "start_line": 2, "end_line": 3}))
+	if got["content"] != "line2\nline3\n" || got["end_line"] != float64(3) || got["next_line"] != float64(4) {
+		t.Errorf("line range = %v", got)
+	}
+	got = resultJSON(t, toolCall(t, srv, "file_read", map[string]any{"path": "big.txt", "start_line": 4}))
+	if got["content"] != "line4\n" || got["truncated"] != false {
+		t.Errorf("to end = %v", got)
+	}
+	assertToolError(t, toolCall(t, srv, "file_read", map[string]any{"path": "big.txt", "start_line": 9}))
+	assertToolError(t, toolCall(t, srv, "file_read", map[string]any{"path": "big.txt", "offset": 999}))
+}
+
+// A line longer than the read limit is clipped rather than read whole, and
+// next_offset picks it up with a byte-range read.
+func TestFileReadClipsLongLine(t *testing.T) {
+	writeWorkspace(t, map[string]string{"long.txt": "short\n" + strings.Repeat("x", 10000) + "\nend\n"})
+	t.Setenv("FILE_READ_MAX_BYTES", "8")
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "file_read", map[string]any{"path": "long.txt", "start_line": 1}))
+	if got["content"] != "short\n" || got["next_line"] != float64(2) || got["next_offset"] != nil {
+		t.Errorf("line before long line = %v", got)
+	}
+	got = resultJSON(t, toolCall(t, srv, "file_read", map[string]any{"path": "long.txt", "start_line": 2}))
+	if got["content"] != "xxxxxxxx" || got["truncated"] != true || got["next_offset"] != float64(14) || got["next_line"] != float64(3) {
+		t.Errorf("clipped line = %v", got)
+	}
+	got = resultJSON(t, toolCall(t, srv, "file_read", map[string]any{"path": "long.txt", "offset": 14}))
+	if got["content"] != "xxxxxxxx" || got["next_offset"] != float64(22) {
+		t.Errorf("continued read = %v", got)
+	}
+	got = resultJSON(t, toolCall(t, srv, "file_read", map[string]any{"path": "long.txt", "start_line": 3}))
+	if got["content"] != "end\n" || got["truncated"] != false {
+		t.Errorf("line after long line = %v", got)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// named workspaces
+// ---------------------------------------------------------------------------
+
+func TestNamedWorkspacesAreIsolated(t *testing.T) {
+	writeWorkspace(t, map[string]string{"shared.txt": "default"})
+	base := t.TempDir()
+	t.Setenv("FILE_WORKSPACES_DIR", base)
+	srv := newServer()
+
+	// A named workspace must be created before use.
+	assertToolError(t, toolCall(t, srv, "file_write", map[string]any{"workspace": "alpha", "path": "a.txt", "content": "x"}))
+	for _, name := range []string{"alpha", "beta"} {
+		got := resultJSON(t, toolCall(t, srv, "workspace_create", map[string]any{"name": name}))
+		if got["created"] != true {
+			t.Fatalf("create %s = %v", name, got)
+		}
+	}
+	if got := resultJSON(t, toolCall(t, srv, "workspace_create", map[string]any{"name": "alpha"})); got["created"] != false {
+		t.Errorf("re-create = %v", got)
+	}
+
+	assertNotToolError(t, toolCall(t, srv, "file_write", map[string]any{"workspace": "alpha", "path": "notes.md", "content": "alpha notes"}))
+	assertNotToolError(t, toolCall(t, srv, "file_write", map[string]any{"workspace": "beta", "path": "notes.md", "content": "beta notes"}))
+
+	got := resultJSON(t, toolCall(t, srv, "file_read", map[string]any{"workspace": "alpha", "path": "notes.md"}))
+	if got["content"] != "alpha notes" {
+		t.Errorf("alpha read = %v", got)
+	}
+	// Neither the default workspace nor a sibling is reachable.
+	assertToolError(t, toolCall(t, srv, "file_read", map[string]any{"workspace": "beta", "path": "shared.txt"}))
+	assertToolError(t, toolCall(t, srv, "file_read", map[string]any{"workspace": "alpha", "path": "../beta/notes.md"}))
+	assertToolError(t, toolCall(t, srv, "file_read", map[string]any{"path": "notes.md"}))
+	if _, err := os.Stat(filepath.Join(base, "alpha", "notes.md")); err != nil {
+		t.Errorf("file not stored in the workspace dir: %v", err)
+	}
+
+	for _, bad := range []string{"../escape", "a/b", ".hidden", ""} {
+		assertToolError(t, toolCall(t, srv, "workspace_create", map[string]any{"name": bad}))
+		assertToolError(t, toolCall(t, srv, "file_list", map[string]any{"workspace": bad + "x/.."}))
+	}
+
+	list := resultJSON(t, toolCall(t, srv, "workspace_list", map[string]any{}))
+	if list["count"] != float64(2) {
+		t.Errorf("workspace_list = %v", list)
+	}
+}
+
+func TestWorkspaceArchiveAndDelete(t *testing.T) {
+	base := t.TempDir()
+	t.Setenv("FILE_WORKSPACES_DIR", base)
+	srv := newServer()
+
+	assertNotToolError(t, toolCall(t, srv, "workspace_create", map[string]any{"name": "PROJ-1"}))
+	assertNotToolError(t, toolCall(t, srv, "file_write", map[string]any{"workspace": "PROJ-1", "path": "docs/spec.md", "content": "# Spec"}))
+
+	got := resultJSON(t, toolCall(t, srv, "workspace_archive", map[string]any{"name": "PROJ-1"}))
+	archive, _ := got["archive"].(string)
+	if files, _ := got["files"].(float64); files < 1 || !strings.HasPrefix(archive, "PROJ-1-") {
+		t.Fatalf("archive = %v", got)
+	}
+	if _, err := os.Stat(filepath.Join(base, "PROJ-1")); !os.IsNotExist(err) {
+		t.Errorf("live workspace still present: %v", err)
+	}
+	assertToolError(t, toolCall(t, srv, "file_read", map[string]any{"workspace": "PROJ-1", "path": "docs/spec.md"}))
+
+	// The archive holds the workspace files.
+	f, err := os.Open(filepath.Join(base, ".archive", archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	found := false
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		if hdr.Name == "docs/spec.md" {
+			body, _ := io.ReadAll(tr)
+			found = string(body) == "# Spec"
+		}
+	}
+	if !found {
+		t.Error("docs/spec.md missing from archive")
+	}
+
+	list := resultJSON(t, toolCall(t, srv, "workspace_list", map[string]any{"include_archived": true}))
+	archived, _ := list["archived"].([]any)
+	if list["count"] != float64(0) || len(archived) != 1 {
+		t.Errorf("workspace_list = %v", list)
+	}
+
+	// Delete an archive, then a live workspace.
+	assertToolError(t, toolCall(t, srv, "workspace_delete", map[string]any{"name": "other", "archive": archive}))
+	assertNotToolError(t, toolCall(t, srv, "workspace_delete", map[string]any{"name": "PROJ-1", "archive": archive}))
+	assertNotToolError(t, toolCall(t, srv, "workspace_create", map[string]any{"name": "PROJ-1"}))
+	assertNotToolError(t, toolCall(t, srv, "workspace_delete", map[string]any{"name": "PROJ-1"}))
+	assertToolError(t, toolCall(t, srv, "workspace_delete", map[string]any{"name": "PROJ-1"}))
+}
+
+// Archiving twice within a second keeps both archives.
+func TestWorkspaceArchiveNamesAreUnique(t *testing.T) {
+	t.Setenv("FILE_WORKSPACES_DIR", t.TempDir())
+	srv := newServer()
+
+	var names []string
+	for i := 0; i < 3; i++ {
+		assertNotToolError(t, toolCall(t, srv, "workspace_create", map[string]any{"name": "w"}))
+		got := resultJSON(t, toolCall(t, srv, "workspace_archive", map[string]any{"name": "w"}))
+		names = append(names, got["archive"].(string))
+	}
+	if names[0] == names[1] || names[1] == names[2] || names[0] == names[2] {
+		t.Errorf("archive names = %v", names)
+	}
+	list := resultJSON(t, toolCall(t, srv, "workspace_list", map[string]any{"include_archived": true}))
+	if archived, _ := list["archived"].([]any); len(archived) != 3 {
+		t.Errorf("archived = %v", list["archived"])
+	}
+	for _, name := range names {
+		assertNotToolError(t, toolCall(t, srv, "workspace_delete", map[string]any{"name": "w", "archive": name}))
+	}
+}
+
+// Usage tracked for a workspace does not outlive it.
+func TestWorkspaceQuotaResetsAfterDelete(t *testing.T) {
+	t.Setenv("FILE_WORKSPACES_DIR", t.TempDir())
+	t.Setenv("FILE_MAX_COUNT", "2")
+	srv := newServer()
+
+	write := func(path string) mcp.Response {
+		return toolCall(t, srv, "file_write", map[string]any{"workspace": "w", "path": path, "content": "x"})
+	}
+	assertNotToolError(t, toolCall(t, srv, "workspace_create", map[string]any{"name": "w"}))
+	assertNotToolError(t, write("a.txt"))
+	assertNotToolError(t, write("b.txt"))
+	assertNotToolError(t, write("a.txt"))
+	assertToolError(t, write("c.txt"))
+
+	assertNotToolError(t, toolCall(t, srv, "workspace_delete", map[string]any{"name": "w"}))
+	assertNotToolError(t, toolCall(t, srv, "workspace_create", map[string]any{"name": "w"}))
+	assertNotToolError(t, write("c.txt"))
+	assertNotToolError(t, write("d.txt"))
+}
+
+// ---------------------------------------------------------------------------
+// binary content and archives
+// ---------------------------------------------------------------------------
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestFileBase64AndMIME(t *testing.T) {
+	writeWorkspace(t, map[string]string{"notes": "plain words"})
+	srv := newServer()
+
+	bin := append(append([]byte{}, pngHeader...), 0xff, 0x00, 0xfe)
+	assertNotToolError(t, toolCall(t, srv, "file_write", map[string]any{
+		"path": "img/logo.png", "encoding": "base64", "content": base64.StdEncoding.EncodeToString(bin),
+	}))
+	got := resultJSON(t, toolCall(t, srv, "file_read", map[string]any{"path": "img/logo.png", "encoding": "base64"}))
+	if got["content"] != base64.StdEncoding.EncodeToString(bin) || got["encoding"] != "base64" {
+		t.Errorf("base64 read = %v", got)
+	}
+	got = resultJSON(t, toolCall(t, srv, "file_read", map[string]any{"path": "img/logo.png"}))
+	if _, ok := got["note"]; !ok {
+		t.Errorf("text read of binary file should carry a note: %v", got)
+	}
+	assertToolError(t, toolCall(t, srv, "file_write", map[string]any{"path": "x.bin", "encoding": "base64", "content": "not base64!"}))
+	assertToolError(t, toolCall(t, srv, "file_read", map[string]any{"path": "notes", "encoding": "hex"}))
+
+	mimes := map[string]string{}
+	for _, dir := range []string{".", "img"} {
+		list := resultJSON(t, toolCall(t, srv, "file_list", map[string]any{"path": dir}))
+		for _, e := range list["entries"].([]any) {
+			m := e.(map[string]any)
+			mime, _ := m["mime"].(string)
+			mimes[m["name"].(string)] = mime
+		}
+	}
+	if mimes["logo.png"] != "image/png" || !strings.HasPrefix(mimes["notes"], "text/plain") || mimes["img"] != "" {
+		t.Errorf("mime types = %v", mimes)
+	}
+}
+
+func TestFileZipUnzipRoundTrip(t *testing.T) {
+	writeWorkspace(t, map[string]string{
+		"report/summary.md":  "# Summary",
+		"report/data/a.csv":  "x,y\n1,2\n",
+		"report/scratch.tmp": "ignore me",
+	})
+	srv := newServer()
+
+	for _, out := range []string{"dist/report.zip", "dist/report.tar.gz"} {
+		got := resultJSON(t, toolCall(t, srv, "file_zip", map[string]any{"output": out, "paths": "report", "exclude": "*.tmp"}))
+		if got["files"] != float64(2) {
+			t.Fatalf("file_zip %s = %v", out, got)
+		}
+		dest := "unpacked/" + filepath.Ext(out)[1:]
+		got = resultJSON(t, toolCall(t, srv, "file_unzip", map[string]any{"path": out, "dest": dest}))
+		if got["files"] != float64(2) {
+			t.Fatalf("file_unzip %s = %v", out, got)
+		}
+		read := resultJSON(t, toolCall(t, srv, "file_read", map[string]any{"path": dest + "/report/data/a.csv"}))
+		if read["content"] != "x,y\n1,2\n" {
+			t.Errorf("%s: extracted content = %v", out, read)
+		}
+		// Existing files are not overwritten unless asked.
+		assertToolError(t, toolCall(t, srv, "file_unzip", map[string]any{"path": out, "dest": dest}))
+		assertNotToolError(t, toolCall(t, srv, "file_unzip", map[string]any{"path": out, "dest": dest, "overwrite": true}))
+	}
+	assertToolError(t, toolCall(t, srv, "file_zip", map[string]any{"output": "dist/report.rar"}))
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFileUnzipRejectsZipSlip(t *testing.T) {
+	dir := writeWorkspace(t, nil)
+	srv := newServer()
+
+	for i, name := range []string{"../evil.txt", "/etc/evil.txt", `..\evil.txt`, "a/../../evil.txt", ".history/objects/x"} {
+		archive := filepath.Join(dir, "in"+string(rune('0'+i))+".zip")
+		os.WriteFile(archive, zipArchive(t, map[string]string{"ok.txt": "fine", name: "pwned"}), 0o644)
+		res := toolCall(t, srv, "file_unzip", map[string]any{"path": filepath.Base(archive), "dest": "."})
+		assertToolError(t, res)
+		if _, err := os.Stat(filepath.Join(dir, "ok.txt")); err == nil {
+			t.Errorf("%q: safe entries were extracted before the unsafe one was rejected", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "evil.txt")); err == nil {
+		t.Error("zip slip wrote outside the workspace")
+	}
+
+	// Tar symlinks are skipped rather than created.
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"})
+	tw.WriteHeader(&tar.Header{Name: "file.txt", Typeflag: tar.TypeReg, Mode: 0o644, Size: 2})
+	tw.Write([]byte("hi"))
+	tw.Close()
+	gz.Close()
+	os.WriteFile(filepath.Join(dir, "links.tgz"), buf.Bytes(), 0o644)
+	got := resultJSON(t, toolCall(t, srv, "file_unzip", map[string]any{"path": "links.tgz"}))
+	if got["files"] != float64(1) || len(got["skipped"].([]any)) != 1 {
+		t.Errorf("tar with symlink = %v", got)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "links", "link")); err == nil {
+		t.Error("symlink entry was created")
+	}
+}
+
+func TestFileUnzipLimits(t *testing.T) {
+	dir := writeWorkspace(t, nil)
+	t.Setenv("FILE_UNZIP_MAX_BYTES", "1000")
+	t.Setenv("FILE_UNZIP_MAX_FILES", "2")
+	srv := newServer()
+
+	os.WriteFile(filepath.Join(dir, "bomb.zip"), zipArchive(t, map[string]string{"zeros": strings.Repeat("\x00", 100000)}), 0o644)
+	assertToolError(t, toolCall(t, srv, "file_unzip", map[string]any{"path": "bomb.zip"}))
+	if _, err := os.Stat(filepath.Join(dir, "bomb", "zeros")); err == nil {
+		t.Error("oversized entry was extracted")
+	}
+
+	os.WriteFile(filepath.Join(dir, "many.zip"), zipArchive(t, map[string]string{"a": "1", "b": "2", "c": "3"}), 0o644)
+	assertToolError(t, toolCall(t, srv, "file_unzip", map[string]any{"path": "many.zip"}))
+
+	// A tar header cannot understate its size, but the limit also applies
+	// to the bytes actually decompressed.
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range []string{"one", "two"} {
+		tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: 600})
+		tw.Write(bytes.Repeat([]byte("x"), 600))
+	}
+	tw.Close()
+	gz.Close()
+	os.WriteFile(filepath.Join(dir, "big.tar.gz"), buf.Bytes(), 0o644)
+	assertToolError(t, toolCall(t, srv, "file_unzip", map[string]any{"path": "big.tar.gz"}))
+}
+
+// An archive that fits the extraction limits but not the workspace quota is
+// refused before any file is written.
+func TestFileUnzipChecksQuotaFirst(t *testing.T) {
+	dir := writeWorkspace(t, nil)
+	archive := zipArchive(t, map[string]string{"a.txt": strings.Repeat("a", 100), "b.txt": strings.Repeat("b", 100)})
+	os.WriteFile(filepath.Join(dir, "pair.zip"), archive, 0o644)
+	t.Setenv("FILE_WORKSPACE_MAX_BYTES", fmt.Sprint(len(archive)+150))
+	srv := newServer()
+
+	msg := toolErrorText(t, toolCall(t, srv, "file_unzip", map[string]any{"path": "pair.zip"}))
+	if !strings.Contains(msg, "nothing was extracted") {
+		t.Errorf("error = %s", msg)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pair")); err == nil {
+		t.Error("files were extracted despite the quota")
+	}
+
+	t.Setenv("FILE_WORKSPACE_MAX_BYTES", "")
+	t.Setenv("FILE_MAX_BYTES", "50")
+	assertToolError(t, toolCall(t, srv, "file_unzip", map[string]any{"path": "pair.zip"}))
+	if _, err := os.Stat(filepath.Join(dir, "pair")); err == nil {
+		t.Error("files were extracted despite the per-file limit")
+	}
+}
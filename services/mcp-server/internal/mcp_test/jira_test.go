@@ -0,0 +1,1311 @@
+package mcp_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeJira serves handler as the Jira instance for servers created after the
+// call.
+func fakeJira(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	t.Setenv("JIRA_BASE_URL", srv.URL)
+	t.Setenv("JIRA_EMAIL", "bot@example.com")
+	t.Setenv("JIRA_API_TOKEN", "token")
+	return srv
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// ---------------------------------------------------------------------------
+// jira_search_issues
+// ---------------------------------------------------------------------------
+
+// pagedSearch serves total issues PROJ-1..PROJ-total from /search/jql using
+// numeric page tokens, and records each request body.
+func pagedSearch(total int, requests *[]map[string]any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/3/search/jql" {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		*requests = append(*requests, body)
+
+		start, _ := strconv.Atoi(fmt.Sprint(body["nextPageToken"]))
+		size := int(body["maxResults"].(float64))
+		var issues []map[string]any
+		for i := start; i < min(start+size, total); i++ {
+			issues = append(issues, map[string]any{
+				"key": fmt.Sprintf("PROJ-%d", i+1),
+				"fields": map[string]any{
+					"summary":  fmt.Sprintf("Issue %d", i+1),
+					"status":   map[string]any{"name": "To Do", "id": "1"},
+					"assignee": map[string]any{"displayName": "Ana", "accountId": "a1"},
+					"labels":   []any{"backend"},
+				},
+			})
+		}
+		resp := map[string]any{"issues": issues, "isLast": start+size >= total}
+		if start+size < total {
+			resp["nextPageToken"] = strconv.Itoa(start + size)
+		}
+		writeJSON(w, 200, resp)
+	}
+}
+
+func TestJiraSearchIssuesPaging(t *testing.T) {
+	var requests []map[string]any
+	fakeJira(t, pagedSearch(45, &requests))
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_search_issues", map[string]any{"query": "project = PROJ", "max_results": 20}))
+	issues := got["issues"].([]any)
+	if len(issues) != 20 || got["next_page_token"] != "20" {
+		t.Fatalf("first page = %d issues, token %v", len(issues), got["next_page_token"])
+	}
+	first := issues[0].(map[string]any)
+	if first["key"] != "PROJ-1" || first["status"] != "To Do" || first["assignee"] != "Ana" || first["url"] == nil {
+		t.Errorf("issue = %v", first)
+	}
+
+	got = resultJSON(t, toolCall(t, srv, "jira_search_issues", map[string]any{
+		"query": "project = PROJ", "max_results": 20, "next_page_token": "40", "fields": "summary,labels",
+	}))
+	issues = got["issues"].([]any)
+	if len(issues) != 5 || got["next_page_token"] != nil {
+		t.Errorf("last page = %v", got)
+	}
+	last := issues[0].(map[string]any)
+	if last["key"] != "PROJ-41" || last["status"] != nil || fmt.Sprint(last["labels"]) != "[backend]" {
+		t.Errorf("custom fields = %v", last)
+	}
+	if fields := fmt.Sprint(requests[len(requests)-1]["fields"]); fields != "[summary labels]" {
+		t.Errorf("requested fields = %s", fields)
+	}
+}
+
+func TestJiraSearchIssuesAllPages(t *testing.T) {
+	var requests []map[string]any
+	fakeJira(t, pagedSearch(45, &requests))
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_search_issues", map[string]any{"query": "project = PROJ", "all_pages": true, "max_results": 10}))
+	if got["count"] != float64(45) || got["pages"] != float64(5) || got["next_page_token"] != nil || got["truncated"] != nil {
+		t.Errorf("all pages = count %v pages %v token %v", got["count"], got["pages"], got["next_page_token"])
+	}
+
+	// The item cap stops paging and hands back a token to resume from.
+	requests = nil
+	got = resultJSON(t, toolCall(t, srv, "jira_search_issues", map[string]any{"query": "project = PROJ", "all_pages": true, "max_results": 10, "max_items": 25}))
+	if got["count"] != float64(25) || got["truncated"] != true || got["next_page_token"] != "25" {
+		t.Errorf("capped = count %v truncated %v token %v", got["count"], got["truncated"], got["next_page_token"])
+	}
+	if len(requests) != 3 || requests[2]["maxResults"] != float64(5) {
+		t.Errorf("requests = %v", requests)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// ADF ↔ Markdown
+// ---------------------------------------------------------------------------
+
+// issueStore serves PROJ-1 whose description is whatever was last PUT, and
+// records comment bodies posted to it.
+type issueStore struct {
+	description any
+	comments    []any
+}
+
+func (s *issueStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/rest/api/3/issue/PROJ-1":
+		writeJSON(w, 200, map[string]any{"key": "PROJ-1", "fields": map[string]any{
+			"summary": "Plan", "description": s.description,
+			"status": map[string]any{"name": "To Do"},
+		}})
+	case r.Method == "PUT" && r.URL.Path == "/rest/api/3/issue/PROJ-1":
+		s.description = body["fields"].(map[string]any)["description"]
+		w.WriteHeader(204)
+	case r.Method == "POST" && r.URL.Path == "/rest/api/3/issue/PROJ-1/comment":
+		s.comments = append(s.comments, body["body"])
+		writeJSON(w, 201, map[string]any{"id": "10001"})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// dropLocalIDs removes the generated task list ids so documents compare equal.
+func dropLocalIDs(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, c := range x {
+			if k == "localId" {
+				delete(x, k)
+			} else {
+				dropLocalIDs(c)
+			}
+		}
+	case []any:
+		for _, c := range x {
+			dropLocalIDs(c)
+		}
+	}
+	return v
+}
+
+func TestJiraMarkdownRoundTrip(t *testing.T) {
+	store := &issueStore{}
+	fakeJira(t, store)
+	srv := newServer()
+
+	md := strings.Join([]string{
+		"# Release plan",
+		"",
+		"Ship **v2** with _care_ and ~~no~~ `inline code`, see [the docs](https://example.com/docs).",
+		"Ping [@Ana](accountid:a1) before merging, not \\*now\\* or in 2024_Q1.",
+		"",
+		"- first item",
+		"- second item with **bold**",
+		"  - nested item",
+		"- [link **strong**](https://x.test)",
+		"",
+		"3. three",
+		"4. four",
+		"",
+		"- [ ] write tests",
+		"- [x] review",
+		"",
+		"> quoted text",
+		">",
+		"> second paragraph",
+		"",
+		"```go",
+		`fmt.Println("hi")`,
+		"```",
+		"",
+		"---",
+		"",
+		"| Name | Value |",
+		"| --- | --- |",
+		"| a\\|b | `x` |",
+		"| snake_case | 1<br>2 |",
+	}, "\n")
+
+	res := toolCall(t, srv, "jira_update_issue", map[string]any{"key": "PROJ-1", "description": md})
+	assertNotToolError(t, res)
+	doc := store.description.(map[string]any)
+	var types []string
+	for _, n := range doc["content"].([]any) {
+		types = append(types, n.(map[string]any)["type"].(string))
+	}
+	want := "heading paragraph bulletList orderedList taskList blockquote codeBlock rule table"
+	if got := strings.Join(types, " "); got != want {
+		t.Errorf("ADF blocks = %s, want %s", got, want)
+	}
+
+	got := resultJSON(t, toolCall(t, srv, "jira_get_issue", map[string]any{"key": "PROJ-1"}))
+	if got["description"] != md {
+		t.Errorf("round trip changed the Markdown:\n%s\nwant:\n%s", got["description"], md)
+	}
+}
+
+func TestJiraADFRoundTrip(t *testing.T) {
+	const adf = `{"type": "doc", "version": 1, "content": [
+		{"type": "heading", "attrs": {"level": 2}, "content": [{"type": "text", "text": "Notes"}]},
+		{"type": "paragraph", "content": [
+			{"type": "text", "text": "a", "marks": [{"type": "strong"}]},
+			{"type": "text", "text": "b", "marks": [{"type": "strong"}, {"type": "em"}]},
+			{"type": "text", "text": "c", "marks": [{"type": "em"}]},
+			{"type": "text", "text": " see "},
+			{"type": "text", "text": "main.go", "marks": [{"type": "link", "attrs": {"href": "https://x.test/main.go"}}, {"type": "code"}]},
+			{"type": "hardBreak"},
+			{"type": "mention", "attrs": {"id": "a1", "text": "@Ana"}},
+			{"type": "text", "text": " # not a heading"}
+		]},
+		{"type": "orderedList", "attrs": {"order": 3}, "content": [
+			{"type": "listItem", "content": [
+				{"type": "paragraph", "content": [{"type": "text", "text": "step"}]},
+				{"type": "codeBlock", "content": [{"type": "text", "text": "make\n` + "```" + `\nbuild"}]}
+			]}
+		]},
+		{"type": "taskList", "attrs": {"localId": "l1"}, "content": [
+			{"type": "taskItem", "attrs": {"localId": "t1", "state": "DONE"}, "content": [{"type": "text", "text": "done"}]},
+			{"type": "taskList", "attrs": {"localId": "l2"}, "content": [
+				{"type": "taskItem", "attrs": {"localId": "t2", "state": "TODO"}, "content": [{"type": "text", "text": "sub"}]}
+			]}
+		]},
+		{"type": "table", "content": [
+			{"type": "tableRow", "content": [
+				{"type": "tableHeader", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "k"}]}]},
+				{"type": "tableHeader", "content": [{"type": "paragraph"}]}
+			]},
+			{"type": "tableRow", "content": [
+				{"type": "tableCell", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "x|y", "marks": [{"type": "strike"}]}]}]},
+				{"type": "tableCell", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "1"}]}, {"type": "paragraph", "content": [{"type": "text", "text": "2"}]}]}
+			]}
+		]}
+	]}`
+	var original any
+	if err := json.Unmarshal([]byte(adf), &original); err != nil {
+		t.Fatal(err)
+	}
+	store := &issueStore{description: original}
+	fakeJira(t, store)
+	srv := newServer()
+
+	md := resultJSON(t, toolCall(t, srv, "jira_get_issue", map[string]any{"key": "PROJ-1"}))["description"].(string)
+	assertNotToolError(t, toolCall(t, srv, "jira_update_issue", map[string]any{"key": "PROJ-1", "description": md}))
+
+	// Round trip through JSON so numbers compare as float64 on both sides.
+	b, _ := json.Marshal(store.description)
+	var back any
+	json.Unmarshal(b, &back)
+	want, _ := json.Marshal(dropLocalIDs(original))
+	got, _ := json.Marshal(dropLocalIDs(back))
+	if string(got) != string(want) {
+		t.Errorf("ADF changed after Markdown round trip via:\n%s\ngot:  %s\nwant: %s", md, got, want)
+	}
+}
+
+func TestJiraAddCommentMarkdown(t *testing.T) {
+	store := &issueStore{}
+	fakeJira(t, store)
+	srv := newServer()
+
+	assertNotToolError(t, toolCall(t, srv, "jira_add_comment", map[string]any{"key": "PROJ-1", "body": "Done:\n\n- **a**\n- b"}))
+	if len(store.comments) != 1 {
+		t.Fatalf("comments = %v", store.comments)
+	}
+	got, _ := json.Marshal(store.comments[0])
+	want := `{"content":[{"content":[{"text":"Done:","type":"text"}],"type":"paragraph"},` +
+		`{"content":[{"content":[{"content":[{"marks":[{"type":"strong"}],"text":"a","type":"text"}],"type":"paragraph"}],"type":"listItem"},` +
+		`{"content":[{"content":[{"text":"b","type":"text"}],"type":"paragraph"}],"type":"listItem"}],"type":"bulletList"}],"type":"doc","version":1}`
+	if string(got) != want {
+		t.Errorf("comment ADF = %s", got)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Comments, changelog and worklogs
+// ---------------------------------------------------------------------------
+
+func TestJiraListComments(t *testing.T) {
+	var query url.Values
+	fakeJira(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/3/issue/PROJ-1/comment" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.Query()
+		writeJSON(w, 200, map[string]any{"startAt": 2, "total": 5, "comments": []any{
+			map[string]any{
+				"id": "100", "author": map[string]any{"displayName": "Ana", "accountId": "a1"},
+				"created": "2024-05-01T10:00:00.000+0000", "updated": "2024-05-01T10:00:00.000+0000",
+				"body": map[string]any{"type": "doc", "version": 1, "content": []any{
+					map[string]any{"type": "paragraph", "content": []any{
+						map[string]any{"type": "text", "text": "Looks ", "marks": nil},
+						map[string]any{"type": "text", "text": "good", "marks": []any{map[string]any{"type": "strong"}}},
+					}},
+				}},
+			},
+			map[string]any{"id": "101", "created": "2024-05-02T10:00:00.000+0000", "updated": "2024-05-03T10:00:00.000+0000"},
+		}})
+	}))
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_list_comments", map[string]any{"key": "PROJ-1", "start_at": 2, "max_results": 2, "newest_first": true}))
+	if query.Get("startAt") != "2" || query.Get("maxResults") != "2" || query.Get("orderBy") != "-created" {
+		t.Errorf("query = %v", query)
+	}
+	comments := got["comments"].([]any)
+	first := comments[0].(map[string]any)
+	if first["author"] != "Ana" || first["author_id"] != "a1" || first["body"] != "Looks **good**" || first["updated"] != nil {
+		t.Errorf("comment = %v", first)
+	}
+	if second := comments[1].(map[string]any); second["author"] != "" || second["updated"] == nil {
+		t.Errorf("anonymous edited comment = %v", second)
+	}
+	if got["total"] != float64(5) || got["next_start_at"] != float64(4) {
+		t.Errorf("paging = total %v next %v", got["total"], got["next_start_at"])
+	}
+}
+
+func TestJiraChangelogAndWorklogs(t *testing.T) {
+	fakeJira(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/3/issue/PROJ-1/changelog":
+			writeJSON(w, 200, map[string]any{"startAt": 0, "total": 2, "values": []any{
+				map[string]any{"author": map[string]any{"displayName": "Ana"}, "created": "2024-05-01T10:00:00.000+0000", "items": []any{
+					map[string]any{"field": "status", "fieldId": "status", "fromString": "To Do", "toString": "In Progress"},
+					map[string]any{"field": "assignee", "fieldId": "assignee", "to": "a2", "toString": "Bo"},
+				}},
+				map[string]any{"author": map[string]any{"displayName": "Bo"}, "created": "2024-05-02T10:00:00.000+0000", "items": []any{
+					map[string]any{"field": "labels", "fieldId": "labels", "toString": "urgent"},
+				}},
+			}})
+		case "/rest/api/3/issue/PROJ-1/worklog":
+			writeJSON(w, 200, map[string]any{"startAt": 0, "total": 2, "worklogs": []any{
+				map[string]any{"id": "1", "author": map[string]any{"displayName": "Bo"}, "started": "2024-05-02T09:00:00.000+0000", "timeSpent": "1h", "timeSpentSeconds": 3600},
+				map[string]any{"id": "2", "author": map[string]any{"displayName": "Bo"}, "started": "2024-05-03T09:00:00.000+0000", "timeSpent": "30m", "timeSpentSeconds": 1800,
+					"comment": map[string]any{"type": "doc", "version": 1, "content": []any{map[string]any{"type": "paragraph", "content": []any{map[string]any{"type": "text", "text": "review"}}}}}},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_get_changelog", map[string]any{"key": "PROJ-1", "fields": "status,assignee"}))
+	changes := got["changes"].([]any)
+	if len(changes) != 2 {
+		t.Fatalf("changes = %v", changes)
+	}
+	status := changes[0].(map[string]any)
+	if status["field"] != "status" || status["from"] != "To Do" || status["to"] != "In Progress" || status["author"] != "Ana" || status["created"] == nil {
+		t.Errorf("status change = %v", status)
+	}
+	if assignee := changes[1].(map[string]any); assignee["from"] != "" || assignee["to"] != "Bo" {
+		t.Errorf("assignee change = %v", assignee)
+	}
+	if got["next_start_at"] != nil {
+		t.Errorf("next_start_at = %v", got["next_start_at"])
+	}
+
+	got = resultJSON(t, toolCall(t, srv, "jira_list_worklogs", map[string]any{"key": "PROJ-1"}))
+	worklogs := got["worklogs"].([]any)
+	if len(worklogs) != 2 || got["total_seconds"] != float64(5400) || worklogs[1].(map[string]any)["comment"] != "review" {
+		t.Errorf("worklogs = %v", got)
+	}
+
+	assertToolError(t, toolCall(t, srv, "jira_list_worklogs", map[string]any{"key": "NOPE-1"}))
+}
+
+// ---------------------------------------------------------------------------
+// Links and hierarchy
+// ---------------------------------------------------------------------------
+
+func issueRef(key, summary, status string) map[string]any {
+	return map[string]any{"key": key, "fields": map[string]any{
+		"summary": summary, "status": map[string]any{"name": status}, "issuetype": map[string]any{"name": "Task"},
+	}}
+}
+
+func TestJiraLinkIssues(t *testing.T) {
+	var links []map[string]any
+	fakeJira(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/3/issueLinkType":
+			writeJSON(w, 200, map[string]any{"issueLinkTypes": []any{
+				map[string]any{"name": "Blocks", "inward": "is blocked by", "outward": "blocks"},
+				map[string]any{"name": "Relates", "inward": "relates to", "outward": "relates to"},
+			}})
+		case "/rest/api/3/issueLink":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			links = append(links, body)
+			w.WriteHeader(201)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	srv := newServer()
+
+	for _, tc := range []struct{ relation, inward, outward, typ string }{
+		{"blocks", "PROJ-1", "PROJ-2", "Blocks"},
+		{"Is Blocked By", "PROJ-2", "PROJ-1", "Blocks"},
+		{"relates", "PROJ-1", "PROJ-2", "Relates"},
+	} {
+		links = nil
+		assertNotToolError(t, toolCall(t, srv, "jira_link_issues", map[string]any{"key": "PROJ-1", "relation": tc.relation, "target": "PROJ-2"}))
+		if len(links) != 1 {
+			t.Fatalf("%s: links = %v", tc.relation, links)
+		}
+		got := links[0]
+		if fmt.Sprint(got["type"]) != "map[name:"+tc.typ+"]" ||
+			fmt.Sprint(got["inwardIssue"]) != "map[key:"+tc.inward+"]" ||
+			fmt.Sprint(got["outwardIssue"]) != "map[key:"+tc.outward+"]" {
+			t.Errorf("%s: payload = %v", tc.relation, got)
+		}
+	}
+
+	msg := toolErrorText(t, toolCall(t, srv, "jira_link_issues", map[string]any{"key": "PROJ-1", "relation": "clones", "target": "PROJ-2"}))
+	if !strings.Contains(msg, "is blocked by") {
+		t.Errorf("error should list relations: %s", msg)
+	}
+}
+
+func TestJiraGetIssueLinksAndTree(t *testing.T) {
+	fakeJira(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/3/issue/PROJ-5":
+			writeJSON(w, 200, map[string]any{"key": "PROJ-5", "fields": map[string]any{
+				"summary": "Story", "status": map[string]any{"name": "In Progress"}, "issuetype": map[string]any{"name": "Story"},
+				"parent":   issueRef("PROJ-1", "Epic", "To Do"),
+				"subtasks": []any{issueRef("PROJ-6", "Sub", "Done")},
+				"issuelinks": []any{
+					map[string]any{"id": "10", "type": map[string]any{"name": "Blocks", "inward": "is blocked by", "outward": "blocks"},
+						"inwardIssue": issueRef("PROJ-9", "Infra", "To Do")},
+					map[string]any{"id": "11", "type": map[string]any{"name": "Blocks", "inward": "is blocked by", "outward": "blocks"},
+						"outwardIssue": issueRef("PROJ-7", "Release", "To Do")},
+				},
+			}})
+		case "/rest/api/3/issue/PROJ-1":
+			writeJSON(w, 200, map[string]any{"key": "PROJ-1", "fields": map[string]any{
+				"summary": "Epic", "status": map[string]any{"name": "To Do"}, "issuetype": map[string]any{"name": "Epic"},
+			}})
+		case "/rest/api/3/search/jql":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["jql"] != "parent = PROJ-1 ORDER BY rank" {
+				writeJSON(w, 400, map[string]any{"errorMessages": []any{"bad jql"}})
+				return
+			}
+			story := issueRef("PROJ-5", "Story", "In Progress")
+			story["fields"].(map[string]any)["subtasks"] = []any{issueRef("PROJ-6", "Sub", "Done")}
+			writeJSON(w, 200, map[string]any{"isLast": true, "issues": []any{story, issueRef("PROJ-8", "Other", "Done")}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_get_issue", map[string]any{"key": "PROJ-5"}))
+	links := got["links"].([]any)
+	if len(links) != 2 {
+		t.Fatalf("links = %v", links)
+	}
+	if l := links[0].(map[string]any); l["relation"] != "is blocked by" || l["key"] != "PROJ-9" || l["status"] != "To Do" {
+		t.Errorf("inward link = %v", l)
+	}
+	if l := links[1].(map[string]any); l["relation"] != "blocks" || l["key"] != "PROJ-7" {
+		t.Errorf("outward link = %v", l)
+	}
+	if got["parent"].(map[string]any)["key"] != "PROJ-1" || len(got["subtasks"].([]any)) != 1 || got["issue_type"] != "Story" {
+		t.Errorf("hierarchy = parent %v subtasks %v", got["parent"], got["subtasks"])
+	}
+
+	tree := resultJSON(t, toolCall(t, srv, "jira_get_issue_tree", map[string]any{"key": "PROJ-1"}))
+	children := tree["children"].([]any)
+	if tree["issue_type"] != "Epic" || len(children) != 2 || tree["subtask_count"] != float64(1) {
+		t.Fatalf("tree = %v", tree)
+	}
+	story := children[0].(map[string]any)
+	if story["key"] != "PROJ-5" || story["subtasks"].([]any)[0].(map[string]any)["key"] != "PROJ-6" {
+		t.Errorf("story = %v", story)
+	}
+	if counts := tree["status_counts"].(map[string]any); counts["Done"] != float64(1) || counts["In Progress"] != float64(1) {
+		t.Errorf("status_counts = %v", counts)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Agile boards and sprints
+// ---------------------------------------------------------------------------
+
+// fakeAgile serves board 7 with active sprint 42, whose estimation field is
+// customfield_10016, and records move requests by path.
+func fakeAgile(t *testing.T, moves map[string][][]any) {
+	start := time.Now().Add(-72 * time.Hour).UTC().Format(time.RFC3339)
+	end := time.Now().Add(168 * time.Hour).UTC().Format(time.RFC3339)
+	sprint := map[string]any{"id": 42, "name": "Sprint 42", "state": "active", "startDate": start, "endDate": end, "originBoardId": 7, "goal": "Ship it"}
+	issue := func(key, status, category, assignee string, points any) map[string]any {
+		fields := map[string]any{
+			"summary": key, "status": map[string]any{"name": status, "statusCategory": map[string]any{"key": category}},
+			"customfield_10016": points,
+		}
+		if assignee != "" {
+			fields["assignee"] = map[string]any{"displayName": assignee}
+		}
+		return map[string]any{"key": key, "fields": fields}
+	}
+	fakeJira(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/agile/1.0/board":
+			writeJSON(w, 200, map[string]any{"total": 1, "values": []any{
+				map[string]any{"id": 7, "name": "PROJ board", "type": "scrum", "location": map[string]any{"projectKey": "PROJ"}},
+			}})
+		case "/rest/agile/1.0/board/7/sprint":
+			writeJSON(w, 200, map[string]any{"isLast": true, "values": []any{sprint}})
+		case "/rest/agile/1.0/sprint/42":
+			writeJSON(w, 200, sprint)
+		case "/rest/agile/1.0/board/7/configuration":
+			writeJSON(w, 200, map[string]any{"estimation": map[string]any{"type": "field", "field": map[string]any{"fieldId": "customfield_10016"}}})
+		case "/rest/agile/1.0/sprint/42/issue", "/rest/agile/1.0/backlog/issue":
+			if r.Method == "POST" {
+				var body map[string]any
+				json.NewDecoder(r.Body).Decode(&body)
+				moves[r.URL.Path] = append(moves[r.URL.Path], body["issues"].([]any))
+				w.WriteHeader(204)
+				return
+			}
+			if !strings.Contains(r.URL.Query().Get("fields"), "customfield_10016") {
+				t.Errorf("sprint issues requested without the points field: %s", r.URL.RawQuery)
+			}
+			writeJSON(w, 200, map[string]any{"total": 4, "issues": []any{
+				issue("PROJ-1", "Done", "done", "Ana", 5.0),
+				issue("PROJ-2", "In Review", "indeterminate", "Ana", 3.0),
+				issue("PROJ-3", "To Do", "new", "Bo", 2.0),
+				issue("PROJ-4", "To Do", "new", "", nil),
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestJiraBoardsAndSprintIssues(t *testing.T) {
+	fakeAgile(t, map[string][][]any{})
+	srv := newServer()
+
+	boards := resultJSON(t, toolCall(t, srv, "jira_list_boards", map[string]any{"project_key": "PROJ"}))["boards"].([]any)
+	if len(boards) != 1 || boards[0].(map[string]any)["id"] != float64(7) {
+		t.Fatalf("boards = %v", boards)
+	}
+	sprints := resultJSON(t, toolCall(t, srv, "jira_list_sprints", map[string]any{"board_id": 7}))["sprints"].([]any)
+	if len(sprints) != 1 || sprints[0].(map[string]any)["goal"] != "Ship it" {
+		t.Fatalf("sprints = %v", sprints)
+	}
+
+	got := resultJSON(t, toolCall(t, srv, "jira_get_sprint_issues", map[string]any{"sprint_id": "42"}))
+	issues := got["issues"].([]any)
+	if len(issues) != 4 || got["total_points"] != float64(10) || got["story_points_field"] != "customfield_10016" {
+		t.Fatalf("sprint issues = %v", got)
+	}
+	if first := issues[0].(map[string]any); first["story_points"] != float64(5) || first["status_category"] != "done" {
+		t.Errorf("issue = %v", first)
+	}
+	if last := issues[3].(map[string]any); last["story_points"] != nil || last["status_category"] != "to_do" {
+		t.Errorf("unestimated issue = %v", last)
+	}
+
+	assertToolError(t, toolCall(t, srv, "jira_get_sprint_issues", map[string]any{"sprint_id": "current"}))
+}
+
+func TestJiraSprintProgress(t *testing.T) {
+	fakeAgile(t, map[string][][]any{})
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_sprint_progress", map[string]any{"board_id": 7}))
+	if got["percent_done"] != float64(50) || got["total_points"] != float64(10) || got["unestimated_issues"] != float64(1) {
+		t.Errorf("progress = %v", got)
+	}
+	if counts := got["issues_by_status"].(map[string]any); counts["done"] != float64(1) || counts["in_progress"] != float64(1) || counts["to_do"] != float64(2) {
+		t.Errorf("issues_by_status = %v", counts)
+	}
+	if got["days_total"] != float64(10) || got["days_elapsed"] != float64(3) || got["days_remaining"] != float64(7) {
+		t.Errorf("days = %v/%v/%v", got["days_elapsed"], got["days_remaining"], got["days_total"])
+	}
+	byAssignee := got["by_assignee"].([]any)
+	if ana := byAssignee[0].(map[string]any); ana["assignee"] != "Ana" || ana["points"] != float64(8) || ana["points_done"] != float64(5) {
+		t.Errorf("by_assignee = %v", byAssignee)
+	}
+}
+
+func TestJiraMoveIssues(t *testing.T) {
+	moves := map[string][][]any{}
+	fakeAgile(t, moves)
+	srv := newServer()
+
+	keys := make([]string, 60)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("PROJ-%d", i+1)
+	}
+	got := resultJSON(t, toolCall(t, srv, "jira_move_issues", map[string]any{"issues": strings.Join(keys, ","), "sprint_id": 42}))
+	if got["moved"] != float64(60) {
+		t.Errorf("moved = %v", got)
+	}
+	if batches := moves["/rest/agile/1.0/sprint/42/issue"]; len(batches) != 2 || len(batches[0]) != 50 || len(batches[1]) != 10 {
+		t.Errorf("sprint batches = %v", batches)
+	}
+
+	got = resultJSON(t, toolCall(t, srv, "jira_move_issues", map[string]any{"issues": "PROJ-1"}))
+	if got["destination"] != "backlog" || len(moves["/rest/agile/1.0/backlog/issue"]) != 1 {
+		t.Errorf("backlog move = %v, %v", got, moves)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Transitions
+// ---------------------------------------------------------------------------
+
+// fakeWorkflow offers "Start" (no screen) and "Resolve" (a screen requiring
+// a resolution) on PROJ-1, and records transition and comment posts.
+func fakeWorkflow(t *testing.T, posted *[]map[string]any) {
+	fakeJira(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/api/3/issue/PROJ-1/transitions" && r.Method == "GET":
+			if r.URL.Query().Get("expand") != "transitions.fields" {
+				t.Errorf("transitions fetched without fields: %s", r.URL.RawQuery)
+			}
+			writeJSON(w, 200, map[string]any{"transitions": []any{
+				map[string]any{"id": "11", "name": "Start", "hasScreen": false,
+					"to": map[string]any{"name": "In Progress", "statusCategory": map[string]any{"key": "indeterminate"}}},
+				map[string]any{"id": "31", "name": "Resolve", "hasScreen": true,
+					"to": map[string]any{"name": "Done", "statusCategory": map[string]any{"key": "done"}},
+					"fields": map[string]any{
+						"resolution": map[string]any{"required": true, "name": "Resolution", "schema": map[string]any{"type": "resolution"},
+							"allowedValues": []any{map[string]any{"id": "1", "name": "Done"}, map[string]any{"id": "2", "name": "Won't Do"}}},
+						"customfield_10050": map[string]any{"required": false, "name": "Root cause", "schema": map[string]any{"type": "string"}},
+					}},
+			}})
+		case r.Method == "POST":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			body["path"] = r.URL.Path
+			*posted = append(*posted, body)
+			if strings.HasSuffix(r.URL.Path, "/comment") {
+				writeJSON(w, 201, map[string]any{"id": "1"})
+				return
+			}
+			w.WriteHeader(204)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestJiraListTransitions(t *testing.T) {
+	var posted []map[string]any
+	fakeWorkflow(t, &posted)
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_list_transitions", map[string]any{"key": "PROJ-1"}))
+	transitions := got["transitions"].([]any)
+	if len(transitions) != 2 {
+		t.Fatalf("transitions = %v", transitions)
+	}
+	resolve := transitions[1].(map[string]any)
+	if resolve["to"] != "Done" || resolve["to_category"] != "done" || resolve["has_screen"] != true {
+		t.Errorf("resolve = %v", resolve)
+	}
+	fields := resolve["fields"].([]any)
+	first := fields[0].(map[string]any)
+	if first["id"] != "resolution" || first["required"] != true || fmt.Sprint(first["allowed_values"]) != "[Done Won't Do]" {
+		t.Errorf("required field should be listed first with allowed values: %v", fields)
+	}
+}
+
+func TestJiraTransitionIssueValidatesFields(t *testing.T) {
+	var posted []map[string]any
+	fakeWorkflow(t, &posted)
+	srv := newServer()
+
+	msg := toolErrorText(t, toolCall(t, srv, "jira_transition_issue", map[string]any{"key": "PROJ-1", "transition": "Done"}))
+	if !strings.Contains(msg, "Resolution (resolution) is required; allowed: Done, Won't Do") {
+		t.Errorf("missing resolution error = %s", msg)
+	}
+	msg = toolErrorText(t, toolCall(t, srv, "jira_transition_issue", map[string]any{
+		"key": "PROJ-1", "transition": "Resolve", "resolution": "Fixed", "fields": map[string]any{"Sprint": "1"},
+	}))
+	if !strings.Contains(msg, `"Fixed" is not an allowed value for Resolution`) || !strings.Contains(msg, `Sprint is not on the "Resolve" transition screen`) {
+		t.Errorf("invalid values error = %s", msg)
+	}
+	if len(posted) != 0 {
+		t.Fatalf("nothing should be posted when validation fails: %v", posted)
+	}
+
+	got := resultJSON(t, toolCall(t, srv, "jira_transition_issue", map[string]any{
+		"key": "PROJ-1", "transition": "resolve", "resolution": "won't do",
+		"fields": `{"Root cause": "config"}`, "comment": "Not needed.",
+	}))
+	if got["status"] != "Done" || got["comment_added"] != true || fmt.Sprint(got["fields_set"]) != "[Resolution Root cause]" {
+		t.Errorf("result = %v", got)
+	}
+	if len(posted) != 1 {
+		t.Fatalf("posted = %v", posted)
+	}
+	body := posted[0]
+	if fmt.Sprint(body["transition"]) != "map[id:31]" ||
+		fmt.Sprint(body["fields"]) != "map[customfield_10050:config resolution:map[id:2]]" ||
+		body["update"] == nil {
+		t.Errorf("transition payload = %v", body)
+	}
+}
+
+func TestJiraCloseIssueWithoutScreenAddsComment(t *testing.T) {
+	var posted []map[string]any
+	fakeWorkflow(t, &posted)
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_close_issue", map[string]any{"key": "PROJ-1", "status": "In Progress", "comment": "Picking this up"}))
+	if got["transitioned_to"] != "Start" || got["comment_added"] != true {
+		t.Errorf("result = %v", got)
+	}
+	if len(posted) != 2 || posted[0]["update"] != nil || posted[1]["path"] != "/rest/api/3/issue/PROJ-1/comment" {
+		t.Errorf("posted = %v", posted)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Metadata and field names
+// ---------------------------------------------------------------------------
+
+// fakeMeta serves project PROJ with Task and Bug issue types, a field
+// catalogue where "Story Points" is customfield_10016 and "Team" is an
+// ambiguous name, and records create and edit bodies by method.
+func fakeMeta(t *testing.T, sent map[string]map[string]any) {
+	fakeJira(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/api/3/project/search":
+			writeJSON(w, 200, map[string]any{"total": 2, "values": []any{
+				map[string]any{"id": "1", "key": "PROJ", "name": "Project", "projectTypeKey": "software", "style": "classic"},
+				map[string]any{"id": "2", "key": "TEAM", "name": "Team", "projectTypeKey": "software", "style": "next-gen"},
+			}})
+		case r.URL.Path == "/rest/api/3/issue/createmeta/PROJ/issuetypes":
+			writeJSON(w, 200, map[string]any{"issueTypes": []any{
+				map[string]any{"id": "10001", "name": "Task"},
+				map[string]any{"id": "10004", "name": "Bug", "description": "A problem."},
+			}})
+		case r.URL.Path == "/rest/api/3/issue/createmeta/PROJ/issuetypes/10004":
+			writeJSON(w, 200, map[string]any{"fields": []any{
+				map[string]any{"fieldId": "project", "name": "Project", "required": true, "schema": map[string]any{"type": "project"}},
+				map[string]any{"fieldId": "summary", "name": "Summary", "required": true, "schema": map[string]any{"type": "string"}},
+				map[string]any{"fieldId": "priority", "name": "Priority", "required": true, "hasDefaultValue": true,
+					"schema": map[string]any{"type": "priority"}, "allowedValues": []any{map[string]any{"id": "2", "name": "High"}}},
+				map[string]any{"fieldId": "customfield_10030", "name": "Severity", "required": true,
+					"schema": map[string]any{"type": "option"}, "allowedValues": []any{map[string]any{"id": "1", "value": "S1"}, map[string]any{"id": "2", "value": "S2"}}},
+				map[string]any{"fieldId": "customfield_10016", "name": "Story Points", "schema": map[string]any{"type": "number"}},
+			}})
+		case r.URL.Path == "/rest/api/3/field":
+			writeJSON(w, 200, []any{
+				map[string]any{"id": "summary", "name": "Summary", "schema": map[string]any{"type": "string", "system": "summary"}},
+				map[string]any{"id": "customfield_10016", "name": "Story Points", "custom": true, "schema": map[string]any{"type": "number"}},
+				map[string]any{"id": "customfield_10040", "name": "Team", "custom": true, "schema": map[string]any{"type": "option"}},
+				map[string]any{"id": "customfield_10041", "name": "Team", "custom": true, "schema": map[string]any{"type": "string"}},
+				map[string]any{"id": "customfield_10050", "name": "Fix notes", "custom": true,
+					"schema": map[string]any{"type": "string", "custom": "com.atlassian.jira.plugin.system.customfieldtypes:textarea"}},
+			})
+		case r.URL.Path == "/rest/api/3/issue" && r.Method == "POST":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			sent["POST"] = body
+			writeJSON(w, 201, map[string]any{"id": "100", "key": "PROJ-9"})
+		case r.URL.Path == "/rest/api/3/issue/PROJ-9" && r.Method == "PUT":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			sent["PUT"] = body
+			w.WriteHeader(204)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(404)
+		}
+	}))
+}
+
+func TestJiraProjectsAndIssueTypes(t *testing.T) {
+	fakeMeta(t, map[string]map[string]any{})
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_list_projects", map[string]any{}))
+	projects := got["projects"].([]any)
+	if len(projects) != 2 || projects[1].(map[string]any)["team_managed"] != true || got["next_start_at"] != nil {
+		t.Errorf("projects = %v", got)
+	}
+
+	got = resultJSON(t, toolCall(t, srv, "jira_list_issue_types", map[string]any{"project_key": "PROJ"}))
+	if fmt.Sprint(got["issue_types"]) != "[map[id:10001 name:Task subtask:false] map[description:A problem. id:10004 name:Bug subtask:false]]" {
+		t.Errorf("issue types = %v", got["issue_types"])
+	}
+
+	got = resultJSON(t, toolCall(t, srv, "jira_get_create_fields", map[string]any{"project_key": "PROJ", "issue_type": "bug"}))
+	required, optional := got["required"].([]any), got["optional"].([]any)
+	if got["issue_type"] != "Bug" || len(required) != 2 || len(optional) != 2 {
+		t.Fatalf("create fields = %v", got)
+	}
+	if sev := required[1].(map[string]any); sev["name"] != "Severity" || fmt.Sprint(sev["allowed_values"]) != "[S1 S2]" {
+		t.Errorf("severity = %v", sev)
+	}
+
+	msg := toolErrorText(t, toolCall(t, srv, "jira_get_create_fields", map[string]any{"project_key": "PROJ", "issue_type": "Epic"}))
+	if !strings.Contains(msg, `issue type "Epic" does not exist in project PROJ. Available: Task, Bug`) {
+		t.Errorf("unknown type error = %s", msg)
+	}
+}
+
+func TestJiraListFields(t *testing.T) {
+	fakeMeta(t, map[string]map[string]any{})
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_list_fields", map[string]any{"query": "story"}))
+	if fmt.Sprint(got["fields"]) != "[map[custom:true id:customfield_10016 name:Story Points type:number]]" {
+		t.Errorf("fields = %v", got["fields"])
+	}
+	got = resultJSON(t, toolCall(t, srv, "jira_list_fields", map[string]any{"custom_only": true}))
+	if got["count"] != float64(4) {
+		t.Errorf("custom fields = %v", got)
+	}
+}
+
+func TestJiraCreateIssueWithFieldNames(t *testing.T) {
+	sent := map[string]map[string]any{}
+	fakeMeta(t, sent)
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_create_issue", map[string]any{
+		"project_key": "PROJ", "summary": "Crash on save", "issue_type": "Bug",
+		"labels": "backend, urgent", "priority": "High", "components": []any{"API"},
+		"due_date": "2026-11-01", "assignee": "5b10ac8d82e05b22cc7d4ef5",
+		"fields": map[string]any{"story points": "5", "Fix notes": "**check** it"},
+	}))
+	if got["key"] != "PROJ-9" {
+		t.Errorf("result = %v", got)
+	}
+	fields := sent["POST"]["fields"].(map[string]any)
+	want := map[string]string{
+		"issuetype":         "map[id:10004]",
+		"labels":            "[backend urgent]",
+		"priority":          "map[name:High]",
+		"components":        "[map[name:API]]",
+		"duedate":           "2026-11-01",
+		"assignee":          "map[accountId:5b10ac8d82e05b22cc7d4ef5]",
+		"customfield_10016": "5",
+	}
+	for k, v := range want {
+		if fmt.Sprint(fields[k]) != v {
+			t.Errorf("fields[%s] = %v, want %s", k, fields[k], v)
+		}
+	}
+	if notes, _ := fields["customfield_10050"].(map[string]any); notes["type"] != "doc" {
+		t.Errorf("textarea field should be ADF: %v", fields["customfield_10050"])
+	}
+
+	msg := toolErrorText(t, toolCall(t, srv, "jira_create_issue", map[string]any{
+		"project_key": "PROJ", "summary": "x", "fields": map[string]any{"Team": "Platform", "Size": 3},
+	}))
+	if !strings.Contains(msg, `field name "Team" is ambiguous; use one of the ids customfield_10040, customfield_10041`) ||
+		!strings.Contains(msg, `unknown field "Size"`) {
+		t.Errorf("field errors = %s", msg)
+	}
+	msg = toolErrorText(t, toolCall(t, srv, "jira_create_issue", map[string]any{"project_key": "PROJ", "summary": "x", "issue_type": "Story"}))
+	if !strings.Contains(msg, `issue type "Story" does not exist in project PROJ`) {
+		t.Errorf("issue type error = %s", msg)
+	}
+	msg = toolErrorText(t, toolCall(t, srv, "jira_create_issue", map[string]any{"project_key": "PROJ", "summary": "x", "due_date": "next week"}))
+	if !strings.Contains(msg, "due_date must be YYYY-MM-DD") {
+		t.Errorf("due date error = %s", msg)
+	}
+}
+
+func TestJiraUpdateIssueFields(t *testing.T) {
+	sent := map[string]map[string]any{}
+	fakeMeta(t, sent)
+	srv := newServer()
+
+	assertNotToolError(t, toolCall(t, srv, "jira_update_issue", map[string]any{
+		"key": "PROJ-9", "labels": "", "fields": `{"customfield_10016": 8}`,
+	}))
+	if fmt.Sprint(sent["PUT"]["fields"]) != "map[customfield_10016:8 labels:[]]" {
+		t.Errorf("update body = %v", sent["PUT"])
+	}
+	msg := toolErrorText(t, toolCall(t, srv, "jira_update_issue", map[string]any{"key": "PROJ-9"}))
+	if !strings.Contains(msg, "at least one field to change") {
+		t.Errorf("empty update error = %s", msg)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Users
+// ---------------------------------------------------------------------------
+
+// fakeUsers serves a directory with two Janes and an app account, and
+// records user searches, assignee changes, watcher posts and edits.
+func fakeUsers(t *testing.T, searches *int, sent map[string][]any) {
+	users := []any{
+		map[string]any{"accountId": "5b10ac8d82e05b22cc7d4e01", "accountType": "atlassian", "displayName": "Jane Doe", "emailAddress": "jane@example.com", "active": true},
+		map[string]any{"accountId": "5b10ac8d82e05b22cc7d4e02", "accountType": "atlassian", "displayName": "Jane Roe", "active": true},
+		map[string]any{"accountId": "5b10ac8d82e05b22cc7d4e03", "accountType": "atlassian", "displayName": "Jane Old", "active": false},
+		map[string]any{"accountId": "5b10ac8d82e05b22cc7d4e09", "accountType": "app", "displayName": "Jane Bot", "active": true},
+	}
+	record := func(r *http.Request) {
+		var body any
+		json.NewDecoder(r.Body).Decode(&body)
+		sent[r.Method+" "+r.URL.Path] = append(sent[r.Method+" "+r.URL.Path], body)
+	}
+	fakeJira(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/3/user/search":
+			*searches++
+			q := strings.ToLower(r.URL.Query().Get("query"))
+			matched := []any{}
+			for _, u := range users {
+				m := u.(map[string]any)
+				if strings.Contains(strings.ToLower(m["displayName"].(string)), q) || strings.EqualFold(fmt.Sprint(m["emailAddress"]), q) {
+					matched = append(matched, u)
+				}
+			}
+			writeJSON(w, 200, matched)
+		case "/rest/api/3/myself":
+			writeJSON(w, 200, map[string]any{"accountId": "5b10ac8d82e05b22cc7d4e00", "displayName": "Bot"})
+		case "/rest/api/3/issue/PROJ-1/assignee", "/rest/api/3/issue/PROJ-1/watchers", "/rest/api/3/issue/PROJ-1":
+			record(r)
+			w.WriteHeader(204)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(404)
+		}
+	}))
+}
+
+func TestJiraFindUser(t *testing.T) {
+	searches := 0
+	fakeUsers(t, &searches, map[string][]any{})
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_find_user", map[string]any{"query": "jane"}))
+	if got["count"] != float64(3) {
+		t.Fatalf("users = %v", got)
+	}
+	first := got["users"].([]any)[0].(map[string]any)
+	if first["account_id"] != "5b10ac8d82e05b22cc7d4e01" || first["email"] != "jane@example.com" {
+		t.Errorf("first user = %v", first)
+	}
+	resultJSON(t, toolCall(t, srv, "jira_find_user", map[string]any{"query": "Jane", "max_results": 1}))
+	if searches != 1 {
+		t.Errorf("repeat search should hit the cache, searched %d times", searches)
+	}
+}
+
+func TestJiraAssignIssue(t *testing.T) {
+	searches := 0
+	sent := map[string][]any{}
+	fakeUsers(t, &searches, sent)
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_assign_issue", map[string]any{"key": "PROJ-1", "assignee": "jane@example.com"}))
+	if got["account_id"] != "5b10ac8d82e05b22cc7d4e01" || got["assigned"] != true {
+		t.Errorf("assign = %v", got)
+	}
+	// "roe" narrows the search to a single active user.
+	resultJSON(t, toolCall(t, srv, "jira_assign_issue", map[string]any{"key": "PROJ-1", "assignee": "roe"}))
+	got = resultJSON(t, toolCall(t, srv, "jira_assign_issue", map[string]any{"key": "PROJ-1", "assignee": "none"}))
+	if got["assigned"] != false {
+		t.Errorf("unassign = %v", got)
+	}
+	puts := sent["PUT /rest/api/3/issue/PROJ-1/assignee"]
+	if fmt.Sprint(puts) != "[map[accountId:5b10ac8d82e05b22cc7d4e01] map[accountId:5b10ac8d82e05b22cc7d4e02] map[accountId:<nil>]]" {
+		t.Errorf("assignee puts = %v", puts)
+	}
+
+	msg := toolErrorText(t, toolCall(t, srv, "jira_assign_issue", map[string]any{"key": "PROJ-1", "assignee": "jane"}))
+	if !strings.Contains(msg, `"jane" matches several Jira users: Jane Doe <jane@example.com> (5b10ac8d82e05b22cc7d4e01), Jane Roe (5b10ac8d82e05b22cc7d4e02)`) {
+		t.Errorf("ambiguous error = %s", msg)
+	}
+	msg = toolErrorText(t, toolCall(t, srv, "jira_assign_issue", map[string]any{"key": "PROJ-1", "assignee": "Jane Bot"}))
+	if !strings.Contains(msg, `no active Jira user matches "Jane Bot"`) {
+		t.Errorf("app account error = %s", msg)
+	}
+
+	assertNotToolError(t, toolCall(t, srv, "jira_update_issue", map[string]any{"key": "PROJ-1", "assignee": "Jane Doe", "reporter": "me"}))
+	edits := sent["PUT /rest/api/3/issue/PROJ-1"]
+	if len(edits) != 1 || fmt.Sprint(edits[0]) != "map[fields:map[assignee:map[accountId:5b10ac8d82e05b22cc7d4e01] reporter:map[accountId:5b10ac8d82e05b22cc7d4e00]]]" {
+		t.Errorf("edit = %v", edits)
+	}
+}
+
+func TestJiraWatchIssue(t *testing.T) {
+	searches := 0
+	sent := map[string][]any{}
+	fakeUsers(t, &searches, sent)
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_watch_issue", map[string]any{"key": "PROJ-1"}))
+	if fmt.Sprint(got["watchers_added"]) != "[5b10ac8d82e05b22cc7d4e00]" {
+		t.Errorf("default watcher = %v", got)
+	}
+	got = resultJSON(t, toolCall(t, srv, "jira_watch_issue", map[string]any{"key": "PROJ-1", "users": "jane@example.com, nobody"}))
+	if fmt.Sprint(got["watchers_added"]) != "[5b10ac8d82e05b22cc7d4e01]" || got["errors"] == nil {
+		t.Errorf("watchers = %v", got)
+	}
+	if posts := sent["POST /rest/api/3/issue/PROJ-1/watchers"]; fmt.Sprint(posts) != "[5b10ac8d82e05b22cc7d4e00 5b10ac8d82e05b22cc7d4e01]" {
+		t.Errorf("watcher posts = %v", posts)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Bulk operations
+// ---------------------------------------------------------------------------
+
+// fakeBulk serves bulk fetches (PROJ-404 does not exist), edits (PROJ-2
+// rejects them) and a "Done" transition on every issue but PROJ-3. It
+// records bulk fetch bodies and the peak number of concurrent edits and
+// transitions.
+func fakeBulk(t *testing.T, fetches *[]map[string]any, peak *int) {
+	var mu sync.Mutex
+	inFlight := 0
+	track := func() func() {
+		mu.Lock()
+		inFlight++
+		*peak = max(*peak, inFlight)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		return func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}
+	}
+	fakeJira(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.Split(strings.TrimPrefix(r.URL.Path, "/rest/api/3/issue/"), "/")[0]
+		switch {
+		case r.URL.Path == "/rest/api/3/issue/bulkfetch":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			mu.Lock()
+			*fetches = append(*fetches, body)
+			mu.Unlock()
+			issues, errs := []any{}, []any{}
+			for _, k := range body["issueIdsOrKeys"].([]any) {
+				if k == "PROJ-404" {
+					errs = append(errs, map[string]any{"id": k, "errorMessage": "Issue does not exist or you do not have permission to see it."})
+					continue
+				}
+				issues = append(issues, map[string]any{"key": k, "fields": map[string]any{
+					"summary":     "Issue " + k.(string),
+					"status":      map[string]any{"name": "To Do"},
+					"description": map[string]any{"type": "doc", "version": 1, "content": []any{map[string]any{"type": "paragraph", "content": []any{map[string]any{"type": "text", "text": "Body", "marks": []any{map[string]any{"type": "strong"}}}}}}},
+				}})
+			}
+			writeJSON(w, 200, map[string]any{"issues": issues, "issueErrors": errs})
+		case r.URL.Path == "/rest/api/3/field":
+			writeJSON(w, 200, []any{map[string]any{"id": "customfield_10016", "name": "Story Points", "custom": true, "schema": map[string]any{"type": "number"}}})
+		case r.Method == "PUT":
+			defer track()()
+			if key == "PROJ-2" {
+				writeJSON(w, 400, map[string]any{"errors": map[string]any{"priority": "Priority is not on the edit screen"}})
+				return
+			}
+			w.WriteHeader(204)
+		case strings.HasSuffix(r.URL.Path, "/transitions") && r.Method == "GET":
+			transitions := []any{}
+			if key != "PROJ-3" {
+				transitions = append(transitions, map[string]any{"id": "31", "name": "Finish", "to": map[string]any{"name": "Done"}})
+			}
+			writeJSON(w, 200, map[string]any{"transitions": transitions})
+		case strings.HasSuffix(r.URL.Path, "/transitions"):
+			defer track()()
+			w.WriteHeader(204)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(404)
+		}
+	}))
+}
+
+func TestJiraGetIssues(t *testing.T) {
+	var fetches []map[string]any
+	peak := 0
+	fakeBulk(t, &fetches, &peak)
+	srv := newServer()
+
+	keys := []any{"proj-404"}
+	for i := 1; i <= 150; i++ {
+		keys = append(keys, fmt.Sprintf("PROJ-%d", i))
+	}
+	keys = append(keys, "PROJ-1")
+	got := resultJSON(t, toolCall(t, srv, "jira_get_issues", map[string]any{"keys": keys, "fields": "summary,status,description"}))
+	if got["count"] != float64(150) || len(fetches) != 2 {
+		t.Fatalf("count = %v, fetches = %d", got["count"], len(fetches))
+	}
+	first := got["issues"].([]any)[0].(map[string]any)
+	if first["key"] != "PROJ-1" || first["status"] != "To Do" || first["description"] != "**Body**" {
+		t.Errorf("first issue = %v", first)
+	}
+	if fmt.Sprint(got["errors"]) != "[map[error:Issue does not exist or you do not have permission to see it. key:PROJ-404]]" {
+		t.Errorf("errors = %v", got["errors"])
+	}
+	if fmt.Sprint(fetches[0]["fields"]) != "[summary status description]" {
+		t.Errorf("fetch body = %v", fetches[0])
+	}
+
+	msg := toolErrorText(t, toolCall(t, srv, "jira_get_issues", map[string]any{"keys": ""}))
+	if !strings.Contains(msg, `"keys"`) {
+		t.Errorf("missing keys error = %s", msg)
+	}
+}
+
+func TestJiraBulkUpdateReportsEachIssue(t *testing.T) {
+	t.Setenv("JIRA_BULK_CONCURRENCY", "2")
+	var fetches []map[string]any
+	peak := 0
+	fakeBulk(t, &fetches, &peak)
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_bulk_update", map[string]any{
+		"keys": "PROJ-1,PROJ-2,PROJ-3,PROJ-4,PROJ-5", "priority": "High",
+	}))
+	if got["succeeded"] != float64(4) || got["failed"] != float64(1) {
+		t.Fatalf("bulk update = %v", got)
+	}
+	results := got["results"].([]any)
+	failed := results[1].(map[string]any)
+	if failed["key"] != "PROJ-2" || failed["ok"] != false || !strings.Contains(failed["error"].(string), "Priority is not on the edit screen") {
+		t.Errorf("failed item = %v", failed)
+	}
+	if ok := results[0].(map[string]any); ok["key"] != "PROJ-1" || ok["ok"] != true || ok["updated"] != true {
+		t.Errorf("ok item = %v", ok)
+	}
+	if peak > 2 {
+		t.Errorf("peak concurrency = %d, want <= 2", peak)
+	}
+
+	got = resultJSON(t, toolCall(t, srv, "jira_bulk_update", map[string]any{
+		"updates": `[{"key": "PROJ-1", "fields": {"Story Points": 3}}, {"key": "PROJ-6", "fields": {"Size": 1}}]`,
+	}))
+	if got["succeeded"] != float64(1) || !strings.Contains(fmt.Sprint(got["results"]), `unknown field "Size"`) {
+		t.Errorf("per-issue updates = %v", got)
+	}
+
+	msg := toolErrorText(t, toolCall(t, srv, "jira_bulk_update", map[string]any{"keys": "PROJ-1", "fields": map[string]any{"Size": 1}}))
+	if !strings.Contains(msg, `unknown field "Size"`) {
+		t.Errorf("shared field error = %s", msg)
+	}
+}
+
+func TestJiraBulkTransition(t *testing.T) {
+	var fetches []map[string]any
+	peak := 0
+	fakeBulk(t, &fetches, &peak)
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_bulk_transition", map[string]any{"keys": []any{"PROJ-1", "PROJ-3", "PROJ-4"}, "transition": "Done"}))
+	if got["succeeded"] != float64(2) || got["failed"] != float64(1) {
+		t.Fatalf("bulk transition = %v", got)
+	}
+	results := got["results"].([]any)
+	if r := results[0].(map[string]any); r["status"] != "Done" || r["transitioned_to"] != "Finish" {
+		t.Errorf("first = %v", r)
+	}
+	if r := results[1].(map[string]any); r["key"] != "PROJ-3" || !strings.Contains(r["error"].(string), `no transition matching "Done"`) {
+		t.Errorf("second = %v", r)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Attachments
+// ---------------------------------------------------------------------------
+
+// fakeAttachments serves two attachments on PROJ-1, spec.md and a large
+// video, redirecting content requests the way Jira does, and records
+// uploads.
+func fakeAttachments(t *testing.T, uploads *[]*http.Request, uploaded *[]string) {
+	var srv *httptest.Server
+	srv = fakeJira(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/api/3/issue/PROJ-1" && r.URL.Query().Get("fields") == "attachment":
+			writeJSON(w, 200, map[string]any{"fields": map[string]any{"attachment": []any{
+				map[string]any{"id": "10001", "filename": "spec.md", "size": 14, "mimeType": "text/markdown",
+					"created": "2026-10-01T10:00:00.000+0000", "author": map[string]any{"displayName": "Jane Doe"}},
+				map[string]any{"id": "10002", "filename": "../demo.mp4", "size": 500 << 20, "mimeType": "video/mp4"},
+			}}})
+		case r.URL.Path == "/rest/api/3/attachment/content/10001":
+			http.Redirect(w, r, srv.URL+"/media/10001", http.StatusSeeOther)
+		case r.URL.Path == "/media/10001":
+			w.Write([]byte("# Spec\n\nBuild."))
+		case r.URL.Path == "/rest/api/3/issue/PROJ-1/attachments" && r.Method == "POST":
+			*uploads = append(*uploads, r)
+			file, header, err := r.FormFile("file")
+			if err != nil {
+				t.Errorf("upload form: %v", err)
+				w.WriteHeader(400)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			*uploaded = append(*uploaded, header.Filename+"|"+header.Header.Get("Content-Type")+"|"+string(data))
+			writeJSON(w, 200, []any{map[string]any{"id": "10003", "filename": header.Filename, "size": len(data), "mimeType": header.Header.Get("Content-Type")}})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.String())
+			w.WriteHeader(404)
+		}
+	}))
+}
+
+func TestJiraListAndDownloadAttachments(t *testing.T) {
+	t.Setenv("FILE_WORK_DIR", t.TempDir())
+	var uploads []*http.Request
+	var uploaded []string
+	fakeAttachments(t, &uploads, &uploaded)
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "jira_list_attachments", map[string]any{"key": "PROJ-1"}))
+	first := got["attachments"].([]any)[0].(map[string]any)
+	if got["count"] != float64(2) || first["filename"] != "spec.md" || first["author"] != "Jane Doe" {
+		t.Errorf("attachments = %v", got)
+	}
+
+	got = resultJSON(t, toolCall(t, srv, "jira_download_attachment", map[string]any{"key": "PROJ-1", "attachment": "SPEC.md"}))
+	if got["path"] != "jira-attachments/PROJ-1/spec.md" || got["bytes"] != float64(14) {
+		t.Errorf("download = %v", got)
+	}
+	read := resultJSON(t, toolCall(t, srv, "file_read", map[string]any{"path": "jira-attachments/PROJ-1/spec.md"}))
+	if read["content"] != "# Spec\n\nBuild." {
+		t.Errorf("saved content = %v", read)
+	}
+
+	msg := toolErrorText(t, toolCall(t, srv, "jira_download_attachment", map[string]any{"key": "PROJ-1", "attachment": "10002"}))
+	if !strings.Contains(msg, "over the download limit") {
+		t.Errorf("size limit error = %s", msg)
+	}
+	msg = toolErrorText(t, toolCall(t, srv, "jira_download_attachment", map[string]any{"key": "PROJ-1", "attachment": "10001", "path": "../escape.md"}))
+	if !strings.Contains(msg, "escapes the workspace") {
+		t.Errorf("traversal error = %s", msg)
+	}
+	msg = toolErrorText(t, toolCall(t, srv, "jira_download_attachment", map[string]any{"key": "PROJ-1", "attachment": "notes.txt"}))
+	if !strings.Contains(msg, `no attachment "notes.txt" on PROJ-1. Available: spec.md, ../demo.mp4`) {
+		t.Errorf("unknown attachment error = %s", msg)
+	}
+
+	t.Setenv("JIRA_ATTACHMENT_MAX_BYTES", "10")
+	msg = toolErrorText(t, toolCall(t, srv, "jira_download_attachment", map[string]any{"key": "PROJ-1", "attachment": "10001"}))
+	if !strings.Contains(msg, "attachment spec.md is 14 bytes, over the download limit of 10 bytes") {
+		t.Errorf("configured limit error = %s", msg)
+	}
+}
+
+func TestJiraUploadAttachment(t *testing.T) {
+	t.Setenv("FILE_WORK_DIR", t.TempDir())
+	var uploads []*http.Request
+	var uploaded []string
+	fakeAttachments(t, &uploads, &uploaded)
+	srv := newServer()
+
+	assertNotToolError(t, toolCall(t, srv, "file_write", map[string]any{"path": "reports/weekly.csv", "content": "a,b\n1,2\n"}))
+	got := resultJSON(t, toolCall(t, srv, "jira_upload_attachment", map[string]any{"key": "PROJ-1", "path": "reports/weekly.csv"}))
+	if got["id"] != "10003" || got["filename"] != "weekly.csv" || got["path"] != "reports/weekly.csv" {
+		t.Errorf("upload = %v", got)
+	}
+	if len(uploads) != 1 || uploads[0].Header.Get("X-Atlassian-Token") != "no-check" {
+		t.Fatalf("uploads = %v", uploads)
+	}
+	if !strings.HasPrefix(uploaded[0], "weekly.csv|text/csv") || !strings.HasSuffix(uploaded[0], "|a,b\n1,2\n") {
+		t.Errorf("uploaded part = %q", uploaded[0])
+	}
+
+	msg := toolErrorText(t, toolCall(t, srv, "jira_upload_attachment", map[string]any{"key": "PROJ-1", "path": "missing.pdf"}))
+	if !strings.Contains(msg, "open file failed") {
+		t.Errorf("missing file error = %s", msg)
+	}
+	t.Setenv("JIRA_ATTACHMENT_MAX_BYTES", "4")
+	msg = toolErrorText(t, toolCall(t, srv, "jira_upload_attachment", map[string]any{"key": "PROJ-1", "path": "reports/weekly.csv"}))
+	if !strings.Contains(msg, "over the upload limit of 4 bytes") {
+		t.Errorf("upload limit error = %s", msg)
+	}
+}
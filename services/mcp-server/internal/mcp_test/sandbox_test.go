@@ -0,0 +1,142 @@
+//go:build unix
+
+package mcp_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// The workspace must stay sealed even when its contents point elsewhere:
+// symlinks and hard links planted inside it (by a tool run, an unpacked
+// archive or another process) and paths swapped while a call is running.
+
+func outsideDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("top secret\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestSymlinkEscapesAreBlocked(t *testing.T) {
+	outside := outsideDir(t)
+	ws := writeWorkspace(t, map[string]string{"ok.txt": "fine\n"})
+	if err := os.Symlink(outside, filepath.Join(ws, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(ws, "linkfile.txt")); err != nil {
+		t.Fatal(err)
+	}
+	srv := newServer()
+
+	for _, call := range []struct {
+		tool string
+		args map[string]any
+	}{
+		{"file_read", map[string]any{"path": "linkdir/secret.txt"}},
+		{"file_read", map[string]any{"path": "linkfile.txt"}},
+		{"file_list", map[string]any{"path": "linkdir"}},
+		{"file_write", map[string]any{"path": "linkdir/planted.txt", "content": "x"}},
+		{"file_append", map[string]any{"path": "linkfile.txt", "content": "x"}},
+		{"file_edit", map[string]any{"path": "linkfile.txt", "old_string": "top", "new_string": "no"}},
+		{"file_search", map[string]any{"pattern": "secret", "path": "linkdir"}},
+	} {
+		t.Run(call.tool, func(t *testing.T) {
+			assertToolError(t, toolCall(t, srv, call.tool, call.args))
+		})
+	}
+
+	// Walking the tree does not follow the links either.
+	got := resultJSON(t, toolCall(t, srv, "file_search", map[string]any{"pattern": "top secret"}))
+	if got["match_count"] != float64(0) {
+		t.Errorf("file_search followed a symlink out: %v", got["matches"])
+	}
+
+	if _, err := os.Stat(filepath.Join(outside, "planted.txt")); err == nil {
+		t.Error("file_write created a file outside the workspace")
+	}
+	if b, _ := os.ReadFile(filepath.Join(outside, "secret.txt")); string(b) != "top secret\n" {
+		t.Errorf("outside file modified: %q", b)
+	}
+}
+
+func TestSymlinkInsideWorkspaceStillWorks(t *testing.T) {
+	ws := writeWorkspace(t, map[string]string{"docs/real.md": "hello\n"})
+	if err := os.Symlink("docs", filepath.Join(ws, "alias")); err != nil {
+		t.Fatal(err)
+	}
+	got := resultJSON(t, toolCall(t, newServer(), "file_read", map[string]any{"path": "alias/real.md"}))
+	if got["content"] != "hello\n" {
+		t.Errorf("content = %v", got["content"])
+	}
+}
+
+func TestHardlinkToOutsideFileIsNotRead(t *testing.T) {
+	outside := outsideDir(t)
+	ws := writeWorkspace(t, nil)
+	if err := os.Link(filepath.Join(outside, "secret.txt"), filepath.Join(ws, "alias.txt")); err != nil {
+		t.Skipf("hard links unsupported here: %v", err)
+	}
+	srv := newServer()
+
+	assertToolError(t, toolCall(t, srv, "file_read", map[string]any{"path": "alias.txt"}))
+	assertToolError(t, toolCall(t, srv, "file_edit", map[string]any{"path": "alias.txt", "old_string": "top", "new_string": "no"}))
+
+	// Overwriting replaces the link instead of writing through it.
+	resultJSON(t, toolCall(t, srv, "file_write", map[string]any{"path": "alias.txt", "content": "mine\n"}))
+	if b, _ := os.ReadFile(filepath.Join(outside, "secret.txt")); string(b) != "top secret\n" {
+		t.Errorf("outside file modified through hard link: %q", b)
+	}
+}
+
+// TestSymlinkSwapRaceIsBlocked flips a workspace directory between a real
+// directory and a symlink to the outside while writes and reads target it.
+// A check-then-use implementation loses this race; an os.Root-based one
+// cannot, because each operation resolves the path itself.
+func TestSymlinkSwapRaceIsBlocked(t *testing.T) {
+	outside := outsideDir(t)
+	ws := writeWorkspace(t, nil)
+	target := filepath.Join(ws, "swap")
+	srv := newServer()
+
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for !stop.Load() {
+			os.RemoveAll(target)
+			os.Mkdir(target, 0o755)
+			os.WriteFile(filepath.Join(target, "secret.txt"), []byte("inside\n"), 0o644)
+			os.RemoveAll(target)
+			os.Symlink(outside, target)
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		resp := toolCall(t, srv, "file_write", map[string]any{"path": fmt.Sprintf("swap/planted-%d.txt", i), "content": "x"})
+		assertNoRPCError(t, resp)
+		resp = toolCall(t, srv, "file_read", map[string]any{"path": "swap/secret.txt"})
+		if result, _ := resp.Result.(map[string]any); result["isError"] != true {
+			if strings.Contains(fmt.Sprint(result["content"]), "top secret") {
+				t.Fatal("file_read returned a file from outside the workspace")
+			}
+		}
+	}
+	stop.Store(true)
+	wg.Wait()
+
+	entries, _ := os.ReadDir(outside)
+	for _, e := range entries {
+		if e.Name() != "secret.txt" {
+			t.Errorf("file created outside the workspace: %s", e.Name())
+		}
+	}
+}
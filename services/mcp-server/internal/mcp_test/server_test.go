@@ -78,6 +78,50 @@ func assertNotToolError(t *testing.T, resp mcp.Response) {
 	}
 }
 
+// resultJSON decodes the JSON payload of a successful tool call.
+func resultJSON(t *testing.T, resp mcp.Response) map[string]any {
+	t.Helper()
+	assertNoRPCError(t, resp)
+	assertNotToolError(t, resp)
+	result, _ := resp.Result.(map[string]any)
+	content, _ := result["content"].([]any)
+	if len(content) == 0 {
+		t.Fatal("tool returned no content")
+	}
+	block, _ := content[0].(map[string]any)
+	text, _ := block["text"].(string)
+	var out map[string]any
+	if err := json.Unmarshal([]byte(text), &out); err != nil {
+		t.Fatalf("tool result is not a JSON object (raw=%s): %v", text, err)
+	}
+	return out
+}
+
+// assertToolError fails unless the tool call returned isError=true.
+func assertToolError(t *testing.T, resp mcp.Response) {
+	t.Helper()
+	assertNoRPCError(t, resp)
+	result, _ := resp.Result.(map[string]any)
+	if result["isError"] != true {
+		t.Fatalf("expected isError=true, got result: %v", result)
+	}
+}
+
+// toolErrorText asserts the call failed with a tool error and returns its
+// message.
+func toolErrorText(t *testing.T, resp mcp.Response) string {
+	t.Helper()
+	assertToolError(t, resp)
+	result, _ := resp.Result.(map[string]any)
+	content, _ := result["content"].([]any)
+	if len(content) == 0 {
+		return ""
+	}
+	block, _ := content[0].(map[string]any)
+	text, _ := block["text"].(string)
+	return text
+}
+
 // ---------------------------------------------------------------------------
 // Protocol
 // ---------------------------------------------------------------------------
@@ -111,8 +155,12 @@ func TestToolsListContainsAllTools(t *testing.T) {
 	}
 	for _, want := range []string{
 		"memory_set", "memory_get", "memory_list",
-		"web_search", "web_fetch",
-		"file_read", "file_write", "file_list",
+		"web_search", "web_fetch", "web_extract", "web_crawl",
+		"file_read", "file_write", "file_list", "file_search", "file_glob",
+		"file_edit", "file_append", "file_insert_lines", "file_delete_lines", "file_patch",
+		"file_history", "file_diff", "file_restore",
+		"file_zip", "file_unzip", "data_query",
+		"workspace_create", "workspace_list", "workspace_archive", "workspace_delete",
 		"http_request",
 	} {
 		if !nameSet[want] {
@@ -0,0 +1,948 @@
+package mcp_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mcp-server/internal/mcp"
+)
+
+// relaxPoliteness disables robots.txt checks and per-host rate limiting so
+// tests that count origin hits only see the requests they make.
+func relaxPoliteness(t *testing.T) {
+	t.Helper()
+	t.Setenv("WEB_RESPECT_ROBOTS", "false")
+	t.Setenv("WEB_DOMAIN_RPS", "1000")
+}
+
+// ---------------------------------------------------------------------------
+// Response cache
+// ---------------------------------------------------------------------------
+
+func TestWebFetchServedFromCache(t *testing.T) {
+	relaxPoliteness(t)
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	srv := newServer()
+	first := resultJSON(t, toolCall(t, srv, "web_fetch", map[string]any{"url": ts.URL + "/page?b=2&a=1"}))
+	if first["cached"] != false {
+		t.Errorf("first fetch cached = %v, want false", first["cached"])
+	}
+	// Same URL with reordered query and a fragment normalises to the same key.
+	second := resultJSON(t, toolCall(t, srv, "web_fetch", map[string]any{"url": ts.URL + "/page?a=1&b=2#top"}))
+	if second["cached"] != true || second["body"] != "hello" {
+		t.Errorf("second fetch = %v, want cached body", second)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("origin hit %d times, want 1", n)
+	}
+
+	resultJSON(t, toolCall(t, srv, "web_fetch", map[string]any{"url": ts.URL + "/page?a=1&b=2", "no_cache": true}))
+	if n := hits.Load(); n != 2 {
+		t.Errorf("no_cache: origin hit %d times, want 2", n)
+	}
+}
+
+func TestWebCacheDiskTierSurvivesRestart(t *testing.T) {
+	relaxPoliteness(t)
+	t.Setenv("WEB_CACHE_DIR", t.TempDir())
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte("persisted"))
+	}))
+	defer ts.Close()
+
+	resultJSON(t, toolCall(t, newServer(), "web_fetch", map[string]any{"url": ts.URL}))
+	// A fresh registry has an empty memory tier and must fall back to disk.
+	got := resultJSON(t, toolCall(t, newServer(), "web_fetch", map[string]any{"url": ts.URL}))
+	if got["cached"] != true || got["body"] != "persisted" {
+		t.Errorf("fetch after restart = %v, want cached body", got)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("origin hit %d times, want 1", n)
+	}
+}
+
+func TestWebFetchRevalidatesWithETag(t *testing.T) {
+	relaxPoliteness(t)
+	t.Setenv("WEB_CACHE_FETCH_TTL", "1ns") // every entry is immediately stale
+	var conditional atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("versioned"))
+	}))
+	defer ts.Close()
+
+	srv := newServer()
+	resultJSON(t, toolCall(t, srv, "web_fetch", map[string]any{"url": ts.URL}))
+	got := resultJSON(t, toolCall(t, srv, "web_fetch", map[string]any{"url": ts.URL}))
+	if got["cached"] != true || got["body"] != "versioned" || got["status"] != float64(200) {
+		t.Errorf("revalidated fetch = %v", got)
+	}
+	if conditional.Load() != 1 {
+		t.Errorf("expected one conditional request, got %d", conditional.Load())
+	}
+}
+
+func TestHTTPRequestCachesOnlyGET(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer ts.Close()
+
+	resps := multiRoundtrip(t, newServer(), []mcp.Request{
+		{JSONRPC: "2.0", ID: 1, Method: "tools/call",
+			Params: map[string]any{"name": "http_request", "arguments": map[string]any{"url": ts.URL}}},
+		{JSONRPC: "2.0", ID: 2, Method: "tools/call",
+			Params: map[string]any{"name": "http_request", "arguments": map[string]any{"url": ts.URL}}},
+		{JSONRPC: "2.0", ID: 3, Method: "tools/call",
+			Params: map[string]any{"name": "http_request", "arguments": map[string]any{
+				"url": ts.URL, "headers": map[string]any{"Authorization": "Bearer other"},
+			}}},
+		{JSONRPC: "2.0", ID: 4, Method: "tools/call",
+			Params: map[string]any{"name": "http_request", "arguments": map[string]any{"url": ts.URL, "method": "POST", "body": "{}"}}},
+	})
+	if len(resps) != 4 {
+		t.Fatalf("expected 4 responses, got %d", len(resps))
+	}
+	if got := resultJSON(t, resps[1]); got["cached"] != true {
+		t.Errorf("repeated GET cached = %v, want true", got["cached"])
+	}
+	if got := resultJSON(t, resps[2]); got["cached"] != false {
+		t.Errorf("GET with different headers cached = %v, want false", got["cached"])
+	}
+	if got := resultJSON(t, resps[3]); got["cached"] != false {
+		t.Errorf("POST cached = %v, want false", got["cached"])
+	}
+	if n := hits.Load(); n != 3 {
+		t.Errorf("origin hit %d times, want 3", n)
+	}
+}
+
+func TestHTTPRequestWriteInvalidatesCachedGET(t *testing.T) {
+	t.Setenv("WEB_CACHE_DIR", t.TempDir())
+	var version atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			version.Add(1)
+		}
+		fmt.Fprintf(w, `{"version":%d}`, version.Load())
+	}))
+	defer ts.Close()
+	srv := newServer()
+
+	get := func() map[string]any {
+		return resultJSON(t, toolCall(t, srv, "http_request", map[string]any{"url": ts.URL + "/item"}))
+	}
+	get()
+	for _, method := range []string{"PUT", "DELETE"} {
+		resultJSON(t, toolCall(t, srv, "http_request", map[string]any{"url": ts.URL + "/item", "method": method}))
+		got := get()
+		body, _ := got["body"].(map[string]any)
+		if got["cached"] != false || body["version"] != float64(version.Load()) {
+			t.Errorf("GET after %s = %v, want a fresh response", method, got)
+		}
+	}
+	// The disk tier is cleared too, so a restarted server refetches.
+	resultJSON(t, toolCall(t, srv, "http_request", map[string]any{"url": ts.URL + "/item", "method": "POST"}))
+	if got := resultJSON(t, toolCall(t, newServer(), "http_request", map[string]any{"url": ts.URL + "/item"})); got["cached"] != false {
+		t.Errorf("GET after POST and restart = %v, want a fresh response", got)
+	}
+}
+
+func TestHTTPRequestKeepsCredentialedResponsesOffDisk(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("WEB_CACHE_DIR", dir)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"secret":true}`))
+	}))
+	defer ts.Close()
+	srv := newServer()
+
+	for _, h := range []string{"Authorization", "Cookie"} {
+		args := map[string]any{"url": ts.URL, "headers": map[string]any{h: "x"}}
+		resultJSON(t, toolCall(t, srv, "http_request", args))
+		if got := resultJSON(t, toolCall(t, srv, "http_request", args)); got["cached"] != true {
+			t.Errorf("%s GET not cached in memory: %v", h, got)
+		}
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 0 {
+		t.Errorf("credentialed responses written to disk: %v", files)
+	}
+}
+
+// The disk budget covers files left by an earlier run, evicting the least
+// recently used first.
+func TestHTTPRequestDiskCacheEvictsAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("WEB_CACHE_DIR", dir)
+	t.Setenv("WEB_CACHE_DISK_MAX_BYTES", "2000")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write(bytes.Repeat([]byte("x"), 1000))
+	}))
+	defer ts.Close()
+
+	resultJSON(t, toolCall(t, newServer(), "http_request", map[string]any{"url": ts.URL + "/a"}))
+	resultJSON(t, toolCall(t, newServer(), "http_request", map[string]any{"url": ts.URL + "/b"}))
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 1 {
+		t.Errorf("disk tier holds %d files, want 1", len(files))
+	}
+	if got := resultJSON(t, toolCall(t, newServer(), "http_request", map[string]any{"url": ts.URL + "/b"})); got["cached"] != true {
+		t.Errorf("newest entry evicted: %v", got["cached"])
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Search providers
+// ---------------------------------------------------------------------------
+
+// writeSearchFixture points the fixture search provider at a temp file.
+func writeSearchFixture(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "search.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SEARCH_FIXTURE_FILE", path)
+}
+
+func TestWebSearchFixtureProvider(t *testing.T) {
+	writeSearchFixture(t, `{
+		"go generics": [
+			{"title": "Tutorial", "url": "https://go.dev/doc/tutorial/generics", "snippet": "Getting started"},
+			{"title": "Spec", "url": "https://go.dev/ref/spec", "snippet": "Type parameters"}
+		]
+	}`)
+	t.Setenv("SEARCH_PROVIDERS", "fixture")
+
+	srv := newServer()
+	got := resultJSON(t, toolCall(t, srv, "web_search", map[string]any{"query": "Go  Generics", "limit": 1}))
+	if got["provider"] != "fixture" || got["count"] != float64(1) {
+		t.Fatalf("search result = %v", got)
+	}
+	again := resultJSON(t, toolCall(t, srv, "web_search", map[string]any{"query": "go generics", "limit": 1}))
+	if again["cached"] != true {
+		t.Errorf("repeated search cached = %v, want true", again["cached"])
+	}
+}
+
+func TestWebSearchFallsBackOnErrorAndEmptyResults(t *testing.T) {
+	writeSearchFixture(t, `{"*": [{"title": "Fallback", "url": "https://example.com", "snippet": ""}]}`)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "json" {
+			t.Errorf("searxng format = %q, want json", r.URL.Query().Get("format"))
+		}
+		w.Write([]byte(`{"results": []}`))
+	}))
+	defer empty.Close()
+
+	for name, base := range map[string]string{"error": failing.URL, "empty": empty.URL} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("SEARXNG_URL", base)
+			t.Setenv("SEARCH_PROVIDERS", "searxng,fixture")
+			got := resultJSON(t, toolCall(t, newServer(), "web_search", map[string]any{"query": "anything"}))
+			if got["provider"] != "fixture" || got["count"] != float64(1) {
+				t.Errorf("search result = %v, want fixture fallback", got)
+			}
+		})
+	}
+}
+
+func TestWebSearchAllProvidersFail(t *testing.T) {
+	writeSearchFixture(t, `{"*": null}`)
+	t.Setenv("SEARCH_PROVIDERS", "fixture")
+	resp := toolCall(t, newServer(), "web_search", map[string]any{"query": "anything"})
+	assertToolError(t, resp)
+}
+
+func TestWebSearchSiteFiltersOffsetAndRank(t *testing.T) {
+	writeSearchFixture(t, `{"*": [
+		{"title": "A", "url": "https://www.go.dev/a"},
+		{"title": "B", "url": "https://pkg.go.dev/b"},
+		{"title": "C", "url": "https://example.com/c"},
+		{"title": "D", "url": "https://go.dev/d", "published": "2024-05-01T00:00:00Z"}
+	]}`)
+	t.Setenv("SEARCH_PROVIDERS", "fixture")
+	srv := newServer()
+
+	got := resultJSON(t, toolCall(t, srv, "web_search", map[string]any{
+		"query": "q", "site": "go.dev", "exclude_sites": []any{"pkg.go.dev"},
+	}))
+	results, _ := got["results"].([]any)
+	if len(results) != 2 {
+		t.Fatalf("filtered results = %v, want A and D", results)
+	}
+	first, _ := results[0].(map[string]any)
+	last, _ := results[1].(map[string]any)
+	if first["domain"] != "go.dev" || first["rank"] != float64(1) {
+		t.Errorf("first result = %v", first)
+	}
+	if last["published"] != "2024-05-01T00:00:00Z" {
+		t.Errorf("published date not propagated: %v", last)
+	}
+
+	page2 := resultJSON(t, toolCall(t, srv, "web_search", map[string]any{"query": "q", "limit": 2, "offset": 2}))
+	results, _ = page2["results"].([]any)
+	if len(results) != 2 {
+		t.Fatalf("page 2 = %v", results)
+	}
+	if r, _ := results[0].(map[string]any); r["title"] != "C" || r["rank"] != float64(3) {
+		t.Errorf("page 2 first result = %v, want C at rank 3", r)
+	}
+}
+
+func TestWebSearchMapsParametersToBackend(t *testing.T) {
+	var got url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query()
+		w.Write([]byte(`{"results": [{"title": "T", "url": "https://go.dev/x", "content": "c", "publishedDate": "2024-01-02"}]}`))
+	}))
+	defer ts.Close()
+	t.Setenv("SEARXNG_URL", ts.URL)
+	t.Setenv("SEARCH_PROVIDERS", "searxng")
+
+	resultJSON(t, toolCall(t, newServer(), "web_search", map[string]any{
+		"query": "release notes", "site": "go.dev", "exclude_sites": "example.com",
+		"freshness": "week", "language": "en", "region": "gb", "limit": 5, "offset": 10,
+	}))
+	want := map[string]string{
+		"q":          "release notes site:go.dev -site:example.com",
+		"time_range": "week",
+		"language":   "en-GB",
+		"pageno":     "2",
+	}
+	for k, v := range want {
+		if got.Get(k) != v {
+			t.Errorf("searxng %s = %q, want %q", k, got.Get(k), v)
+		}
+	}
+}
+
+// An offset inside a SearXNG page fetches that page and skips to the offset.
+func TestWebSearchUnalignedOffset(t *testing.T) {
+	var pageno string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pageno = r.URL.Query().Get("pageno")
+		var items []string
+		for i := 1; i <= 10; i++ {
+			items = append(items, fmt.Sprintf(`{"title": "R%d", "url": "https://example.org/%d", "content": "c"}`, i, i))
+		}
+		fmt.Fprintf(w, `{"results": [%s]}`, strings.Join(items, ","))
+	}))
+	defer ts.Close()
+	t.Setenv("SEARXNG_URL", ts.URL)
+	t.Setenv("SEARCH_PROVIDERS", "searxng")
+
+	got := resultJSON(t, toolCall(t, newServer(), "web_search", map[string]any{"query": "q", "limit": 2, "offset": 13}))
+	results, _ := got["results"].([]any)
+	if pageno != "2" || len(results) != 2 {
+		t.Fatalf("pageno = %s, results = %v", pageno, results)
+	}
+	if r, _ := results[0].(map[string]any); r["title"] != "R4" || r["rank"] != float64(14) {
+		t.Errorf("first result = %v, want R4 at rank 14", r)
+	}
+}
+
+func TestWebSearchRejectsUnknownFreshness(t *testing.T) {
+	writeSearchFixture(t, `{"*": []}`)
+	t.Setenv("SEARCH_PROVIDERS", "fixture")
+	assertToolError(t, toolCall(t, newServer(), "web_search", map[string]any{"query": "q", "freshness": "year"}))
+}
+
+// ---------------------------------------------------------------------------
+// Politeness
+// ---------------------------------------------------------------------------
+
+func TestWebFetchHonoursRobotsTxt(t *testing.T) {
+	t.Setenv("WEB_DOMAIN_RPS", "1000")
+	t.Setenv("WEB_USER_AGENT", "TestBot/2.0")
+	var userAgent atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nDisallow: /\n\nUser-agent: testbot\nDisallow: /private\nAllow: /private/ok$\n"))
+			return
+		}
+		userAgent.Store(r.UserAgent())
+		w.Write([]byte("page"))
+	}))
+	defer ts.Close()
+
+	srv := newServer()
+	resultJSON(t, toolCall(t, srv, "web_fetch", map[string]any{"url": ts.URL + "/public"}))
+	if userAgent.Load() != "TestBot/2.0" {
+		t.Errorf("User-Agent = %v, want configured value", userAgent.Load())
+	}
+	resultJSON(t, toolCall(t, srv, "web_fetch", map[string]any{"url": ts.URL + "/private/ok"}))
+
+	resp := toolCall(t, srv, "web_fetch", map[string]any{"url": ts.URL + "/private/secret"})
+	assertToolError(t, resp)
+	result, _ := resp.Result.(map[string]any)
+	content, _ := result["content"].([]any)
+	block, _ := content[0].(map[string]any)
+	if text, _ := block["text"].(string); !strings.Contains(text, "robots.txt") {
+		t.Errorf("error should mention robots.txt, got %q", text)
+	}
+}
+
+// A redirected robots.txt is followed rather than treated as missing.
+func TestWebFetchFollowsRobotsTxtRedirect(t *testing.T) {
+	t.Setenv("WEB_DOMAIN_RPS", "1000")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			http.Redirect(w, r, "/real-robots.txt", http.StatusMovedPermanently)
+		case "/real-robots.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+		default:
+			w.Write([]byte("page"))
+		}
+	}))
+	defer ts.Close()
+
+	srv := newServer()
+	assertNotToolError(t, toolCall(t, srv, "web_fetch", map[string]any{"url": ts.URL + "/public"}))
+	if msg := toolErrorText(t, toolCall(t, srv, "web_fetch", map[string]any{"url": ts.URL + "/private/page"})); !strings.Contains(msg, "robots.txt") {
+		t.Errorf("error = %q, want a robots.txt refusal", msg)
+	}
+}
+
+func TestWebFetchRateLimitsPerHost(t *testing.T) {
+	t.Setenv("WEB_RESPECT_ROBOTS", "false")
+	t.Setenv("WEB_DOMAIN_RPS", "10") // one request per 100ms
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	srv := newServer()
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		resultJSON(t, toolCall(t, srv, "web_fetch", map[string]any{"url": fmt.Sprintf("%s/p%d", ts.URL, i)}))
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("4 fetches took %v, want at least 300ms at 10 req/s", elapsed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Document extraction
+// ---------------------------------------------------------------------------
+
+// minimalPDF builds a one-page PDF whose content stream is Flate-compressed.
+func minimalPDF(t *testing.T, content string) []byte {
+	t.Helper()
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write([]byte(content))
+	zw.Close()
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	b.WriteString("1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n")
+	b.WriteString("2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj\n")
+	b.WriteString("3 0 obj << /Type /Page /Parent 2 0 R /Contents 4 0 R >> endobj\n")
+	fmt.Fprintf(&b, "4 0 obj << /Length %d /Filter /FlateDecode >>\nstream\n", z.Len())
+	b.Write(z.Bytes())
+	b.WriteString("\nendstream\nendobj\ntrailer << /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+// minimalDOCX builds a DOCX package with two paragraphs and a table.
+func minimalDOCX(t *testing.T) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	f, _ := zw.Create("word/document.xml")
+	f.Write([]byte(`<?xml version="1.0"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>Requirements</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Latency under </w:t></w:r><w:r><w:t>200ms</w:t></w:r></w:p>
+<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Owner</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Alice</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+</w:body></w:document>`))
+	zw.Close()
+	return b.Bytes()
+}
+
+func TestWebFetchExtractsDocuments(t *testing.T) {
+	relaxPoliteness(t)
+	docs := map[string]struct {
+		contentType string
+		body        []byte
+	}{
+		"/spec.pdf": {"application/pdf", minimalPDF(t,
+			"BT /F1 12 Tf 72 720 Td (Design \\(v2\\)) Tj 0 -14 Td [(Total) -250 (cost)] TJ ET")},
+		"/spec.docx":  {"application/octet-stream", minimalDOCX(t)},
+		"/data.csv":   {"text/csv", []byte("name,score\nada,3\ngrace,5\n")},
+		"/data":       {"application/json", []byte(`{"a":[1,2]}`)},
+		"/image.png":  {"image/png", []byte("\x89PNG\r\n\x1a\nrest")},
+		"/index.html": {"text/html; charset=utf-8", []byte("<p>hi</p>")},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, ok := docs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", d.contentType)
+		w.Write(d.body)
+	}))
+	defer ts.Close()
+
+	srv := newServer()
+	fetch := func(p string) map[string]any {
+		return resultJSON(t, toolCall(t, srv, "web_fetch", map[string]any{"url": ts.URL + p}))
+	}
+
+	if got := fetch("/spec.pdf"); got["format"] != "pdf" || got["body"] != "Design (v2)\nTotal cost" {
+		t.Errorf("pdf = %q (format %v)", got["body"], got["format"])
+	}
+	if got := fetch("/spec.docx"); got["format"] != "docx" || got["body"] != "Requirements\nLatency under 200ms\nOwner \tAlice" {
+		t.Errorf("docx = %q (format %v)", got["body"], got["format"])
+	}
+	csvResult := fetch("/data.csv")
+	rows, _ := csvResult["rows"].([]any)
+	if csvResult["format"] != "csv" || len(rows) != 3 {
+		t.Errorf("csv = %v", csvResult)
+	} else if last, _ := rows[2].([]any); len(last) != 2 || last[0] != "grace" {
+		t.Errorf("csv last row = %v", rows[2])
+	}
+	if got := fetch("/data"); got["format"] != "json" || got["body"] != "{\n  \"a\": [\n    1,\n    2\n  ]\n}" {
+		t.Errorf("json = %q", got["body"])
+	}
+	if got := fetch("/image.png"); got["format"] != "binary" || !strings.Contains(got["body"].(string), "not shown") {
+		t.Errorf("binary = %v", got)
+	}
+	if got := fetch("/index.html"); got["format"] != "html" || got["body"] != "<p>hi</p>" {
+		t.Errorf("html = %v", got)
+	}
+}
+
+// PDFs whose text needs ToUnicode maps are refused instead of returned as
+// glyph-code garbage.
+// withFont gives the page of a minimalPDF the font /F1 defined by font,
+// appending extra as further objects.
+func withFont(pdf []byte, font, extra string) []byte {
+	pdf = bytes.Replace(pdf, []byte("/Contents 4 0 R"), []byte("/Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R"), 1)
+	return bytes.Replace(pdf, []byte("trailer"), []byte("5 0 obj "+font+" endobj\n"+extra+"trailer"), 1)
+}
+
+func TestWebFetchDecodesPDFToUnicode(t *testing.T) {
+	relaxPoliteness(t)
+	cmap := "/CIDInit /ProcSet findresource begin 12 dict begin begincmap\n" +
+		"1 begincodespacerange <0000> <FFFF> endcodespacerange\n" +
+		"2 beginbfchar <0003> <0020> <0010> <00E9> endbfchar\n" +
+		"1 beginbfrange <0024> <0029> <0041> endbfrange\n" +
+		"1 beginbfrange <0044> <0045> [<0061> <0062>] endbfrange\n" +
+		"endcmap CMapName currentdict /CMap defineresource pop end end\n"
+	pdf := withFont(minimalPDF(t, "BT /F1 12 Tf 72 720 Td <002400250003004400450010> Tj 0 -14 Td [<0026> -120 <0027>] TJ ET"),
+		"<< /Type /Font /Subtype /Type0 /BaseFont /Foo /Encoding /Identity-H /DescendantFonts [7 0 R] /ToUnicode 6 0 R >>",
+		fmt.Sprintf("6 0 obj << /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(cmap), cmap))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(pdf)
+	}))
+	defer ts.Close()
+
+	got := resultJSON(t, toolCall(t, newServer(), "web_fetch", map[string]any{"url": ts.URL + "/doc.pdf"}))
+	if got["format"] != "pdf" || got["body"] != "AB abé\nCD" {
+		t.Errorf("pdf = %q (format %v)", got["body"], got["format"])
+	}
+}
+
+func TestWebFetchRefusesUndecodablePDFText(t *testing.T) {
+	relaxPoliteness(t)
+	cid := withFont(minimalPDF(t, "BT /F1 12 Tf 72 720 Td <00240025> Tj ET"),
+		"<< /Type /Font /Subtype /Type0 /Encoding /Identity-H >>", "")
+	docs := map[string][]byte{
+		"/cid.pdf":     cid,
+		"/garbled.pdf": minimalPDF(t, "BT /F1 12 Tf 72 720 Td (\\001\\002\\003\\004\\005a) Tj ET"),
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(docs[r.URL.Path])
+	}))
+	defer ts.Close()
+
+	srv := newServer()
+	for p := range docs {
+		msg := toolErrorText(t, toolCall(t, srv, "web_fetch", map[string]any{"url": ts.URL + p}))
+		if !strings.Contains(msg, "text not extractable") {
+			t.Errorf("%s: error = %s", p, msg)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// web_extract
+// ---------------------------------------------------------------------------
+
+const extractPage = `<!doctype html>
+<html><head><title>Release notes</title><base href="/docs/"></head>
+<body>
+<h1 id="top">Release notes</h1>
+<p>See <a href="install.html#linux">the <b>install</b> guide</a>, <a href="https://other.example/x">elsewhere</a>,
+<a href="mailto:team@example.com">mail</a> and <a href="#top">top</a>.</p>
+<h2>v2</h2>
+<h3>Fixes</h3>
+<table><caption>Timings</caption>
+<thead><tr><th>Step</th><th>Seconds</th></tr></thead>
+<tbody><tr><td>build</td><td>12</td></tr><tr><td>test</td><td>30</td></tr></tbody>
+</table>
+<h2>v1</h2>
+<table><tr><td>a</td><td>b</td></tr></table>
+<script>document.write('<a href="/hidden">x</a>')</script>
+</body></html>`
+
+func TestWebExtractOutlineLinksAndTables(t *testing.T) {
+	relaxPoliteness(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, extractPage)
+	}))
+	defer ts.Close()
+
+	got := resultJSON(t, toolCall(t, newServer(), "web_extract", map[string]any{"url": ts.URL + "/notes"}))
+	if got["title"] != "Release notes" {
+		t.Errorf("title = %v", got["title"])
+	}
+
+	outline, _ := got["outline"].([]any)
+	if len(outline) != 1 {
+		t.Fatalf("outline = %v", outline)
+	}
+	h1 := outline[0].(map[string]any)
+	children, _ := h1["children"].([]any)
+	if h1["id"] != "top" || len(children) != 2 {
+		t.Fatalf("h1 = %v", h1)
+	}
+	v2 := children[0].(map[string]any)
+	if v2["text"] != "v2" || len(v2["children"].([]any)) != 1 {
+		t.Errorf("v2 = %v", v2)
+	}
+
+	links, _ := got["links"].([]any)
+	want := []string{ts.URL + "/docs/install.html", "https://other.example/x"}
+	if len(links) != len(want) {
+		t.Fatalf("links = %v", links)
+	}
+	for i, w := range want {
+		if l := links[i].(map[string]any); l["url"] != w {
+			t.Errorf("link %d = %v, want %s", i, l, w)
+		}
+	}
+	if l := links[0].(map[string]any); l["text"] != "the install guide" {
+		t.Errorf("anchor text = %q", l["text"])
+	}
+
+	tables, _ := got["tables"].([]any)
+	if len(tables) != 2 {
+		t.Fatalf("tables = %v", tables)
+	}
+	timings := tables[0].(map[string]any)
+	rows, _ := timings["rows"].([]any)
+	if timings["caption"] != "Timings" || len(rows) != 2 || rows[1].(map[string]any)["Seconds"] != "30" {
+		t.Errorf("timings = %v", timings)
+	}
+	if plain := tables[1].(map[string]any); plain["columns"] != nil || len(plain["rows"].([]any)[0].([]any)) != 2 {
+		t.Errorf("headerless table = %v", plain)
+	}
+
+	sameOrigin := resultJSON(t, toolCall(t, newServer(), "web_extract",
+		map[string]any{"url": ts.URL + "/notes", "same_origin": true}))
+	if n := sameOrigin["link_count"]; n != float64(1) {
+		t.Errorf("same_origin link_count = %v", n)
+	}
+}
+
+// same_origin filters links before the 500-link cap, so same-origin links
+// after many external ones are still returned.
+func TestWebExtractSameOriginBeforeLinkCap(t *testing.T) {
+	relaxPoliteness(t)
+	var page strings.Builder
+	for i := 0; i < 600; i++ {
+		fmt.Fprintf(&page, `<a href="https://other.example/%d">x</a>`, i)
+	}
+	page.WriteString(`<a href="/a">a</a><a href="/b">b</a>`)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, page.String())
+	}))
+	defer ts.Close()
+
+	got := resultJSON(t, toolCall(t, newServer(), "web_extract", map[string]any{"url": ts.URL, "same_origin": true}))
+	if got["link_count"] != float64(2) || got["links_truncated"] != false {
+		t.Errorf("link_count = %v, links_truncated = %v", got["link_count"], got["links_truncated"])
+	}
+}
+
+// Behind a redirect to another origin, links resolve against the final URL
+// and same_origin keeps that origin's links.
+func TestWebExtractUsesFinalURL(t *testing.T) {
+	relaxPoliteness(t)
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<a href="guide">guide</a><a href="https://other.example/">other</a>`)
+	}))
+	defer site.Close()
+	start := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, site.URL+"/docs/", http.StatusMovedPermanently)
+	}))
+	defer start.Close()
+
+	got := resultJSON(t, toolCall(t, newServer(), "web_extract", map[string]any{"url": start.URL + "/", "same_origin": true}))
+	links, _ := got["links"].([]any)
+	if got["final_url"] != site.URL+"/docs/" || len(links) != 1 || links[0].(map[string]any)["url"] != site.URL+"/docs/guide" {
+		t.Errorf("final_url = %v, links = %v", got["final_url"], links)
+	}
+}
+
+func TestWebExtractRejectsNonHTML(t *testing.T) {
+	relaxPoliteness(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"a":1}`)
+	}))
+	defer ts.Close()
+
+	assertToolError(t, toolCall(t, newServer(), "web_extract", map[string]any{"url": ts.URL + "/data"}))
+}
+
+// ---------------------------------------------------------------------------
+// web_crawl
+// ---------------------------------------------------------------------------
+
+// docsSite serves a small documentation site: an index linking to two guide
+// pages and an external site, plus a sitemap listing a page nothing links to.
+func docsSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	var ts *httptest.Server
+	pages := map[string]string{
+		"/docs/":       `<title>Docs</title><nav><a href="/docs/a">A</a></nav><h1>Docs</h1><p>Start <a href="/docs/b">here</a> or <a href="https://example.org/">leave</a>. <a href="/blog/">Blog</a></p>`,
+		"/docs/a":      `<title>A</title><h1>Page A</h1><ul><li>one</li><li>two</li></ul><a href="/docs/">home</a>`,
+		"/docs/b":      `<title>B</title><h2>Page B</h2><p>Body of B.</p>`,
+		"/docs/orphan": `<title>Orphan</title><p>Only in the sitemap.</p>`,
+		"/blog/":       `<title>Blog</title>`,
+	}
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/sitemap.xml" {
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprintf(w, `<?xml version="1.0"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>%s/docs/orphan</loc></url></urlset>`, ts.URL)
+			return
+		}
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, page)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestWebCrawlWritesPagesAndIndex(t *testing.T) {
+	relaxPoliteness(t)
+	dir := t.TempDir()
+	t.Setenv("FILE_WORK_DIR", dir)
+	ts := docsSite(t)
+
+	got := resultJSON(t, toolCall(t, newServer(), "web_crawl", map[string]any{
+		"url": ts.URL + "/docs/", "path_prefix": "/docs/", "use_sitemap": true, "output_dir": "site",
+	}))
+	if got["page_count"] != float64(4) || got["sitemap_urls"] != float64(1) || got["stopped_reason"] != "queue exhausted" {
+		t.Fatalf("crawl result = %v", got)
+	}
+	pages, _ := got["pages"].([]any)
+	var paths []string
+	for _, p := range pages {
+		paths = append(paths, p.(map[string]any)["path"].(string))
+	}
+	want := []string{"site/docs/index.md", "site/docs/orphan.md", "site/docs/a.md", "site/docs/b.md"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+
+	a, err := os.ReadFile(filepath.Join(dir, "site/docs/a.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(a), "Source: "+ts.URL+"/docs/a\nTitle: A\n") ||
+		!strings.Contains(string(a), "# Page A\n\n- one\n- two\n") {
+		t.Errorf("a.md = %q", a)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "site/index.json")); err != nil {
+		t.Errorf("index.json not written: %v", err)
+	}
+}
+
+func TestWebCrawlStopsAtPageBudget(t *testing.T) {
+	relaxPoliteness(t)
+	t.Setenv("FILE_WORK_DIR", t.TempDir())
+	ts := docsSite(t)
+
+	got := resultJSON(t, toolCall(t, newServer(), "web_crawl", map[string]any{
+		"url": ts.URL + "/docs/", "max_pages": 2,
+	}))
+	if got["page_count"] != float64(2) || got["stopped_reason"] != "page budget reached" {
+		t.Errorf("crawl result = %v", got)
+	}
+	if got["pending_urls"].(float64) < 1 {
+		t.Errorf("expected pending URLs, got %v", got["pending_urls"])
+	}
+}
+
+// Links resolve against the page's final URL after redirects, every download
+// is capped at what remains of the byte budget, and at most five sitemaps
+// are fetched.
+func TestWebCrawlRedirectsBudgetAndSitemaps(t *testing.T) {
+	relaxPoliteness(t)
+	t.Setenv("FILE_WORK_DIR", t.TempDir())
+	var sitemaps atomic.Int32
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/sitemap.xml":
+			sitemaps.Add(1)
+			fmt.Fprint(w, `<?xml version="1.0"?><sitemapindex>`)
+			for i := 0; i < 10; i++ {
+				fmt.Fprintf(w, `<sitemap><loc>%s/sm/%d.xml</loc></sitemap>`, ts.URL, i)
+			}
+			fmt.Fprint(w, `</sitemapindex>`)
+		case strings.HasPrefix(r.URL.Path, "/sm/"):
+			sitemaps.Add(1)
+			fmt.Fprint(w, `<?xml version="1.0"?><urlset></urlset>`)
+		case r.URL.Path == "/start":
+			http.Redirect(w, r, "/docs/guide/", http.StatusFound)
+		case r.URL.Path == "/docs/guide/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<title>Guide</title><a href="next">next</a>`)
+		case r.URL.Path == "/docs/guide/next":
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, strings.Repeat("x", 5000))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	got := resultJSON(t, toolCall(t, newServer(), "web_crawl", map[string]any{
+		"url": ts.URL + "/start", "max_bytes": 1000, "use_sitemap": true,
+	}))
+	pages, _ := got["pages"].([]any)
+	if len(pages) != 2 || pages[0].(map[string]any)["url"] != ts.URL+"/docs/guide/" ||
+		pages[1].(map[string]any)["url"] != ts.URL+"/docs/guide/next" {
+		t.Fatalf("crawl result = %v", got)
+	}
+	if n := got["bytes_fetched"].(float64); n > 1000 {
+		t.Errorf("bytes_fetched = %v, over the 1000 byte budget", n)
+	}
+	if n := sitemaps.Load(); n != 5 {
+		t.Errorf("fetched %d sitemaps, want 5", n)
+	}
+}
+
+// When the start URL redirects to another origin the crawl stays on that
+// origin, and pages that redirect off it are skipped.
+func TestWebCrawlScopesToRedirectedOrigin(t *testing.T) {
+	relaxPoliteness(t)
+	t.Setenv("FILE_WORK_DIR", t.TempDir())
+	old := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<title>Old</title>`)
+	}))
+	defer old.Close()
+	var site *httptest.Server
+	site = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<title>Home</title><a href="/a">a</a><a href="/away">away</a><a href="%s/old">old</a>`, old.URL)
+		case "/a":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<title>A</title>`)
+		case "/away":
+			http.Redirect(w, r, old.URL+"/moved", http.StatusMovedPermanently)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer site.Close()
+	start := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, site.URL+"/", http.StatusFound)
+	}))
+	defer start.Close()
+
+	got := resultJSON(t, toolCall(t, newServer(), "web_crawl", map[string]any{"url": start.URL + "/"}))
+	pages, _ := got["pages"].([]any)
+	var urls []string
+	for _, p := range pages {
+		urls = append(urls, p.(map[string]any)["url"].(string))
+	}
+	if want := []string{site.URL + "/", site.URL + "/a"}; strings.Join(urls, ",") != strings.Join(want, ",") {
+		t.Errorf("pages = %v, want %v", urls, want)
+	}
+	errs, _ := got["errors"].([]any)
+	if len(errs) != 1 || !strings.Contains(errs[0].(map[string]any)["error"].(string), "out of scope") {
+		t.Errorf("errors = %v", errs)
+	}
+}
+
+func TestWebCrawlWriteFailureKeepsIndex(t *testing.T) {
+	relaxPoliteness(t)
+	dir := t.TempDir()
+	t.Setenv("FILE_WORK_DIR", dir)
+	t.Setenv("FILE_ALLOWED_EXTENSIONS", ".json")
+	ts := docsSite(t)
+
+	got := resultJSON(t, toolCall(t, newServer(), "web_crawl", map[string]any{
+		"url": ts.URL + "/docs/", "output_dir": "site",
+	}))
+	if got["page_count"] != float64(0) || !strings.HasPrefix(got["stopped_reason"].(string), "write failed: workspace quota exceeded") {
+		t.Fatalf("crawl result = %v", got)
+	}
+	if errs, _ := got["errors"].([]any); len(errs) != 1 {
+		t.Errorf("errors = %v", got["errors"])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "site/index.json")); err != nil {
+		t.Errorf("index.json not written: %v", err)
+	}
+}
+
+func TestWebCrawlRejectsOutputOutsideWorkspace(t *testing.T) {
+	t.Setenv("FILE_WORK_DIR", t.TempDir())
+	assertToolError(t, toolCall(t, newServer(), "web_crawl", map[string]any{
+		"url": "https://example.com/", "output_dir": "../../etc",
+	}))
+}
@@ -49,6 +49,19 @@ var (
 		},
 		[]string{"tool"},
 	)
+
+	// webCacheLookupsTotal counts response-cache lookups made by the web tools.
+	// outcome label: "hit" (served from cache), "revalidated" (stale entry
+	// confirmed by a 304) or "miss" (fetched from the network).
+	// Use sum by (outcome) (rate(mcp_server_web_cache_lookups_total[5m])) in
+	// Grafana for the hit ratio.
+	webCacheLookupsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcp_server_web_cache_lookups_total",
+			Help: "Web response cache lookups by tool and outcome",
+		},
+		[]string{"tool", "outcome"},
+	)
 )
 
 // RecordToolCall records a single tool invocation result.
@@ -66,6 +79,13 @@ func RecordToolCall(name string, err error, started time.Time) {
 	toolCallDurationSeconds.WithLabelValues(name).Observe(time.Since(started).Seconds())
 }
 
+// RecordCacheLookup records one response-cache lookup for a web tool.
+//
+// outcome is one of "hit", "revalidated" or "miss".
+func RecordCacheLookup(tool, outcome string) {
+	webCacheLookupsTotal.WithLabelValues(tool, outcome).Inc()
+}
+
 // Handler returns the Prometheus HTTP scrape handler.
 // Register it on the mux at /metrics in main.go.
 func Handler() http.Handler {
@@ -0,0 +1,1134 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Jira Cloud stores descriptions and comments as Atlassian Document Format
+// (ADF), a JSON tree of block and inline nodes. adfToMarkdown renders it as
+// GitHub-flavoured Markdown for the agent to read, and markdownToADF builds
+// ADF from the Markdown the agent writes. Paragraphs, headings, bullet,
+// ordered and task lists, code blocks, quotes, rules, tables, links,
+// mentions and the strong/em/strike/code marks survive a round trip in
+// either direction. Nodes Markdown cannot express (panels, media, colours)
+// are rendered as their nearest equivalent or as plain text.
+//
+// Mentions are written as links with an accountid: target, e.g.
+// "[@Ana](accountid:5b10ac8d82e05b22cc7d4ef5)".
+
+const mentionScheme = "accountid:"
+
+// adfMark is an inline mark the converter understands. Marks are kept in
+// markPriority order, which is also the order their delimiters nest in:
+// links outermost, code innermost.
+type adfMark struct {
+	typ  string
+	href string
+}
+
+var markPriority = map[string]int{"link": 0, "strong": 1, "em": 2, "strike": 3, "code": 4}
+
+func sortMarks(marks []adfMark) {
+	sort.SliceStable(marks, func(i, j int) bool { return markPriority[marks[i].typ] < markPriority[marks[j].typ] })
+}
+
+func (m adfMark) toADF() map[string]any {
+	if m.typ == "link" {
+		return map[string]any{"type": "link", "attrs": map[string]any{"href": m.href}}
+	}
+	return map[string]any{"type": m.typ}
+}
+
+// ---------------------------------------------------------------------------
+// ADF → Markdown
+// ---------------------------------------------------------------------------
+
+// adfToMarkdown renders an ADF document (or any ADF node) as Markdown.
+func adfToMarkdown(doc any) string {
+	n, ok := doc.(map[string]any)
+	if !ok {
+		return ""
+	}
+	if nodeType(n) == "doc" {
+		return strings.TrimSpace(renderBlocks(nodeContent(n)))
+	}
+	return strings.TrimSpace(renderBlock(n))
+}
+
+func nodeType(n map[string]any) string {
+	t, _ := n["type"].(string)
+	return t
+}
+
+func nodeContent(n map[string]any) []map[string]any {
+	raw, _ := n["content"].([]any)
+	out := make([]map[string]any, 0, len(raw))
+	for _, c := range raw {
+		if m, ok := c.(map[string]any); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func nodeAttr(n map[string]any, key string) any {
+	attrs, _ := n["attrs"].(map[string]any)
+	return attrs[key]
+}
+
+func nodeAttrString(n map[string]any, key string) string {
+	s, _ := nodeAttr(n, key).(string)
+	return s
+}
+
+func nodeAttrInt(n map[string]any, key string, def int) int {
+	if f, ok := nodeAttr(n, key).(float64); ok {
+		return int(f)
+	}
+	return def
+}
+
+func isListNode(t string) bool {
+	switch t {
+	case "bulletList", "orderedList", "taskList", "decisionList":
+		return true
+	}
+	return false
+}
+
+// renderBlocks renders sibling block nodes separated by blank lines.
+func renderBlocks(nodes []map[string]any) string { return joinBlocks(nodes, false) }
+
+// joinBlocks renders sibling blocks; when tight (inside a list item) a list
+// directly after a paragraph follows on the next line.
+func joinBlocks(nodes []map[string]any, tight bool) string {
+	var b strings.Builder
+	for i, n := range nodes {
+		s := renderBlock(n)
+		if s == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			if tight && isListNode(nodeType(n)) && i > 0 && nodeType(nodes[i-1]) == "paragraph" {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\n\n")
+			}
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+func renderBlock(n map[string]any) string {
+	switch nodeType(n) {
+	case "paragraph":
+		return escapeBlockStarts(renderInline(nodeContent(n)))
+	case "heading":
+		level := min(max(nodeAttrInt(n, "level", 1), 1), 6)
+		text := strings.ReplaceAll(renderInline(nodeContent(n)), "\n", " ")
+		return strings.TrimRight(strings.Repeat("#", level)+" "+text, " ")
+	case "bulletList", "orderedList", "taskList", "decisionList":
+		return renderList(n)
+	case "codeBlock":
+		var text strings.Builder
+		for _, c := range nodeContent(n) {
+			s, _ := c["text"].(string)
+			text.WriteString(s)
+		}
+		fence := "```"
+		for strings.Contains(text.String(), fence) {
+			fence += "`"
+		}
+		return fence + nodeAttrString(n, "language") + "\n" + text.String() + "\n" + fence
+	case "blockquote", "panel":
+		return prefixLines(renderBlocks(nodeContent(n)), "> ", ">")
+	case "rule":
+		return "---"
+	case "table":
+		return renderTable(n)
+	case "mediaSingle", "mediaGroup":
+		var parts []string
+		for _, c := range nodeContent(n) {
+			parts = append(parts, renderBlock(c))
+		}
+		return strings.Join(parts, " ")
+	case "media":
+		name := nodeAttrString(n, "alt")
+		if name == "" {
+			name = nodeAttrString(n, "id")
+		}
+		return "\\[attachment: " + escapeInline(name) + "\\]"
+	case "expand", "nestedExpand":
+		body := renderBlocks(nodeContent(n))
+		if title := nodeAttrString(n, "title"); title != "" {
+			return "**" + escapeInline(title) + "**\n\n" + body
+		}
+		return body
+	}
+	if content := nodeContent(n); len(content) > 0 {
+		if isInlineNode(nodeType(content[0])) {
+			return escapeBlockStarts(renderInline(content))
+		}
+		return renderBlocks(content)
+	}
+	if s, ok := n["text"].(string); ok {
+		return escapeInline(s)
+	}
+	return ""
+}
+
+func isInlineNode(t string) bool {
+	switch t {
+	case "text", "hardBreak", "mention", "emoji", "inlineCard", "date", "status":
+		return true
+	}
+	return false
+}
+
+func prefixLines(s, prefix, blankPrefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if l == "" {
+			lines[i] = blankPrefix
+		} else {
+			lines[i] = prefix + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func renderList(n map[string]any) string {
+	t := nodeType(n)
+	order := nodeAttrInt(n, "order", 1)
+	var items []string
+	num := order
+	for _, item := range nodeContent(n) {
+		var marker, body string
+		switch nodeType(item) {
+		case "taskItem":
+			marker = "- [ ] "
+			if nodeAttrString(item, "state") == "DONE" {
+				marker = "- [x] "
+			}
+			body = renderInline(nodeContent(item))
+		case "taskList", "bulletList", "orderedList":
+			// A nested task list sits directly in its parent list.
+			items = append(items, prefixLines(renderList(item), "  ", ""))
+			continue
+		default:
+			body = joinBlocks(nodeContent(item), true)
+			if len(nodeContent(item)) > 0 && nodeType(nodeContent(item)[0]) != "paragraph" {
+				// Keep a leading code block or list off the marker line.
+				body = "\n" + body
+			}
+		}
+		if marker == "" {
+			if t == "orderedList" {
+				marker = strconv.Itoa(num) + ". "
+				num++
+			} else {
+				marker = "- "
+			}
+		}
+		indent := strings.Repeat(" ", utf8.RuneCountInString(marker))
+		if strings.HasPrefix(marker, "- [") {
+			indent = "  "
+		}
+		lines := strings.Split(body, "\n")
+		for i := 1; i < len(lines); i++ {
+			if lines[i] != "" {
+				lines[i] = indent + lines[i]
+			}
+		}
+		items = append(items, strings.TrimRight(marker+strings.Join(lines, "\n"), " "))
+	}
+	return strings.Join(items, "\n")
+}
+
+func renderTable(n map[string]any) string {
+	var rows [][]string
+	cols := 0
+	for _, row := range nodeContent(n) {
+		var cells []string
+		for _, cell := range nodeContent(row) {
+			var parts []string
+			for _, block := range nodeContent(cell) {
+				var s string
+				if nodeType(block) == "paragraph" {
+					s = renderInline(nodeContent(block))
+				} else {
+					s = renderBlock(block)
+				}
+				parts = append(parts, strings.ReplaceAll(s, "\n", "<br>"))
+			}
+			cells = append(cells, strings.ReplaceAll(strings.Join(parts, "<br>"), "|", `\|`))
+		}
+		cols = max(cols, len(cells))
+		rows = append(rows, cells)
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	writeRow := func(cells []string) {
+		b.WriteString("|")
+		for i := 0; i < cols; i++ {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+	}
+	writeRow(rows[0])
+	b.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+	for _, r := range rows[1:] {
+		writeRow(r)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func nodeMarks(n map[string]any) []adfMark {
+	raw, _ := n["marks"].([]any)
+	var marks []adfMark
+	for _, r := range raw {
+		m, _ := r.(map[string]any)
+		switch t := nodeType(m); t {
+		case "strong", "em", "strike", "code":
+			marks = append(marks, adfMark{typ: t})
+		case "link":
+			marks = append(marks, adfMark{typ: t, href: nodeAttrString(m, "href")})
+		}
+	}
+	sortMarks(marks)
+	return marks
+}
+
+// inlineWriter renders inline nodes, opening and closing mark delimiters
+// only where the set of marks changes so adjacent nodes share delimiters.
+type inlineWriter struct {
+	out  []byte
+	open []openMark
+}
+
+type openMark struct {
+	m   adfMark
+	pos int // index of the em delimiter, decided when the mark closes
+}
+
+func (w *inlineWriter) openTo(marks []adfMark) {
+	for _, m := range marks {
+		pos := len(w.out)
+		switch m.typ {
+		case "link":
+			w.out = append(w.out, '[')
+		case "strong":
+			w.out = append(w.out, "**"...)
+		case "em":
+			w.out = append(w.out, '_')
+		case "strike":
+			w.out = append(w.out, "~~"...)
+		}
+		w.open = append(w.open, openMark{m, pos})
+	}
+}
+
+// closeTo closes open marks until n remain. nextAlnum reports whether the
+// next character written will be a letter or digit, which decides whether
+// an em can use "_" (it cannot touch a word) or must use "*".
+func (w *inlineWriter) closeTo(n int, nextAlnum bool) {
+	for len(w.open) > n {
+		o := w.open[len(w.open)-1]
+		w.open = w.open[:len(w.open)-1]
+		switch o.m.typ {
+		case "link":
+			href := strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(o.m.href)
+			w.out = append(w.out, "]("+href+")"...)
+		case "strong":
+			w.out = append(w.out, "**"...)
+		case "em":
+			prev, _ := utf8.DecodeLastRune(w.out[:o.pos])
+			d := byte('_')
+			if isAlnum(prev) || nextAlnum {
+				d = '*'
+			}
+			w.out[o.pos] = d
+			w.out = append(w.out, d)
+		case "strike":
+			w.out = append(w.out, "~~"...)
+		}
+	}
+}
+
+func isAlnum(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+func renderInline(nodes []map[string]any) string {
+	w := &inlineWriter{}
+	for _, n := range nodes {
+		switch nodeType(n) {
+		case "text":
+			text, _ := n["text"].(string)
+			if text == "" {
+				continue
+			}
+			marks := nodeMarks(n)
+			code := false
+			if len(marks) > 0 && marks[len(marks)-1].typ == "code" {
+				code = true
+				marks = marks[:len(marks)-1]
+			}
+			keep := 0
+			for keep < len(w.open) && keep < len(marks) && w.open[keep].m == marks[keep] {
+				keep++
+			}
+			var s string
+			if code {
+				s = codeSpan(text)
+			} else {
+				s = escapeInline(text)
+			}
+			first, _ := utf8.DecodeRuneInString(s)
+			w.closeTo(keep, keep == len(marks) && isAlnum(first))
+			w.openTo(marks[keep:])
+			w.out = append(w.out, s...)
+		case "hardBreak":
+			w.closeTo(0, false)
+			w.out = append(w.out, '\n')
+		case "mention":
+			w.closeTo(0, false)
+			text := nodeAttrString(n, "text")
+			if text == "" {
+				text = "@" + nodeAttrString(n, "id")
+			}
+			w.out = append(w.out, "["+escapeInline(text)+"]("+mentionScheme+nodeAttrString(n, "id")+")"...)
+		case "emoji":
+			text := nodeAttrString(n, "text")
+			if text == "" {
+				text = nodeAttrString(n, "shortName")
+			}
+			w.out = append(w.out, escapeInline(text)...)
+		case "inlineCard":
+			w.closeTo(0, false)
+			w.out = append(w.out, "<"+nodeAttrString(n, "url")+">"...)
+		case "date":
+			ts, _ := strconv.ParseInt(nodeAttrString(n, "timestamp"), 10, 64)
+			w.out = append(w.out, time.UnixMilli(ts).UTC().Format("2006-01-02")...)
+		case "status":
+			w.out = append(w.out, "\\["+escapeInline(nodeAttrString(n, "text"))+"\\]"...)
+		default:
+			w.out = append(w.out, renderInline(nodeContent(n))...)
+		}
+	}
+	w.closeTo(0, false)
+	return string(w.out)
+}
+
+// codeSpan wraps text in enough backticks that it cannot close early.
+func codeSpan(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", longest+1)
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") ||
+		(strings.HasPrefix(text, " ") && strings.HasSuffix(text, " ") && strings.TrimSpace(text) != "") {
+		text = " " + text + " "
+	}
+	return fence + text + fence
+}
+
+// escapeInline backslash-escapes characters the inline parser would
+// otherwise read as markup.
+func escapeInline(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		var prev, next rune
+		if i > 0 {
+			prev = runes[i-1]
+		}
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		switch {
+		case r == '\\' || r == '`' || r == '*' || r == '[' || r == ']':
+			b.WriteByte('\\')
+		case r == '_' && !(isAlnum(prev) && isAlnum(next)):
+			b.WriteByte('\\')
+		case r == '~' && (prev == '~' || next == '~'):
+			b.WriteByte('\\')
+		case r == '<' && strings.HasPrefix(string(runes[i+1:]), "http"):
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var reOrderedStart = regexp.MustCompile(`^(\d{1,9})([.)])( |$)`)
+
+// escapeBlockStarts escapes line beginnings that would parse as a heading,
+// quote, list or rule.
+func escapeBlockStarts(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		switch {
+		case l == "":
+		case l[0] == '#' || l[0] == '>':
+			lines[i] = `\` + l
+		case (l[0] == '-' || l[0] == '+') && (len(l) == 1 || l[1] == ' ' || l[1] == '-'):
+			lines[i] = `\` + l
+		default:
+			if m := reOrderedStart.FindStringSubmatchIndex(l); m != nil {
+				lines[i] = l[:m[3]] + `\` + l[m[3]:]
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ---------------------------------------------------------------------------
+// Markdown → ADF
+// ---------------------------------------------------------------------------
+
+// markdownToADF parses Markdown into an ADF document.
+func markdownToADF(md string) map[string]any {
+	md = strings.ReplaceAll(md, "\r\n", "\n")
+	p := &mdParser{}
+	content := p.blocks(strings.Split(md, "\n"))
+	if content == nil {
+		content = []any{}
+	}
+	return map[string]any{"type": "doc", "version": 1, "content": content}
+}
+
+type mdParser struct {
+	tasks int // for task item localIds
+}
+
+var (
+	reHeading   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?[ \t]*$`)
+	reListItem  = regexp.MustCompile(`^( *)([-+*]|\d{1,9}[.)])( +|$)(.*)$`)
+	reFence     = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})(.*)$")
+	reTableSep  = regexp.MustCompile(`^ *\|? *:?-+:? *(\| *:?-+:? *)*\|? *$`)
+	reTaskStart = regexp.MustCompile(`^\[([ xX])\](?: +|$)`)
+)
+
+type listMarker struct {
+	indent  int
+	ordered bool
+	start   int
+	width   int // columns from the line start to the item content
+	content string
+}
+
+func parseListMarker(line string) (listMarker, bool) {
+	m := reListItem.FindStringSubmatch(line)
+	if m == nil {
+		return listMarker{}, false
+	}
+	lm := listMarker{indent: len(m[1]), content: m[4]}
+	spaces := max(len(m[3]), 1)
+	if spaces > 4 {
+		spaces = 1
+		lm.content = strings.Repeat(" ", len(m[3])-1) + m[4]
+	}
+	lm.width = lm.indent + len(m[2]) + spaces
+	if n, err := strconv.Atoi(strings.TrimRight(m[2], ".)")); err == nil {
+		lm.ordered, lm.start = true, n
+	}
+	return lm, true
+}
+
+// isRule reports whether line is a thematic break: three or more of the
+// same '-', '*' or '_', optionally spaced.
+func isRule(line string) bool {
+	t := strings.TrimLeft(line, " ")
+	if len(line)-len(t) > 3 || t == "" || !strings.ContainsRune("-*_", rune(t[0])) {
+		return false
+	}
+	n := 0
+	for _, r := range t {
+		switch {
+		case r == rune(t[0]):
+			n++
+		case r != ' ' && r != '\t':
+			return false
+		}
+	}
+	return n >= 3
+}
+
+func indentOf(line string) int { return len(line) - len(strings.TrimLeft(line, " ")) }
+
+func isBlank(line string) bool { return strings.TrimSpace(line) == "" }
+
+func (p *mdParser) startsBlock(lines []string, i int) bool {
+	l := lines[i]
+	if reFence.MatchString(l) || reHeading.MatchString(l) || isRule(l) {
+		return true
+	}
+	if strings.HasPrefix(strings.TrimLeft(l, " "), ">") {
+		return true
+	}
+	if _, ok := parseListMarker(l); ok {
+		return true
+	}
+	return isTableStart(lines, i)
+}
+
+// isTableStart reports whether lines[i] is a table header row, i.e. is
+// followed by a "| --- |" delimiter row.
+func isTableStart(lines []string, i int) bool {
+	return strings.Contains(lines[i], "|") && i+1 < len(lines) &&
+		strings.Contains(lines[i+1], "-") && reTableSep.MatchString(lines[i+1])
+}
+
+func (p *mdParser) blocks(lines []string) []any {
+	var out []any
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+
+		case reFence.MatchString(line):
+			m := reFence.FindStringSubmatch(line)
+			fence := m[1]
+			var body []string
+			i++
+			for i < len(lines) {
+				t := strings.TrimSpace(lines[i])
+				if strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" {
+					i++
+					break
+				}
+				body = append(body, lines[i])
+				i++
+			}
+			node := map[string]any{"type": "codeBlock"}
+			if lang := strings.TrimSpace(m[2]); lang != "" {
+				node["attrs"] = map[string]any{"language": lang}
+			}
+			if text := strings.Join(body, "\n"); text != "" {
+				node["content"] = []any{map[string]any{"type": "text", "text": text}}
+			}
+			out = append(out, node)
+
+		case isRule(line):
+			out = append(out, map[string]any{"type": "rule"})
+			i++
+
+		case reHeading.MatchString(line):
+			m := reHeading.FindStringSubmatch(line)
+			node := map[string]any{"type": "heading", "attrs": map[string]any{"level": len(m[1])}}
+			if content := parseInline(m[2]); len(content) > 0 {
+				node["content"] = content
+			}
+			out = append(out, node)
+			i++
+
+		case strings.HasPrefix(strings.TrimLeft(line, " "), ">"):
+			var inner []string
+			for i < len(lines) && strings.HasPrefix(strings.TrimLeft(lines[i], " "), ">") {
+				l := strings.TrimPrefix(strings.TrimLeft(lines[i], " "), ">")
+				inner = append(inner, strings.TrimPrefix(l, " "))
+				i++
+			}
+			if content := p.blocks(inner); len(content) > 0 {
+				out = append(out, map[string]any{"type": "blockquote", "content": content})
+			}
+
+		case isTableStart(lines, i) && !reListItem.MatchString(line):
+			var node map[string]any
+			node, i = p.table(lines, i)
+			out = append(out, node)
+
+		default:
+			if _, ok := parseListMarker(line); ok {
+				var node map[string]any
+				node, i = p.list(lines, i)
+				out = append(out, node)
+				continue
+			}
+			var para []string
+			for i < len(lines) && !isBlank(lines[i]) && (len(para) == 0 || !p.startsBlock(lines, i)) {
+				para = append(para, strings.TrimLeft(lines[i], " "))
+				i++
+			}
+			out = append(out, paragraph(parseInline(strings.Join(para, "\n"))))
+		}
+	}
+	return out
+}
+
+func paragraph(content []any) map[string]any {
+	node := map[string]any{"type": "paragraph"}
+	if len(content) > 0 {
+		node["content"] = content
+	}
+	return node
+}
+
+type mdListItem struct {
+	lines []string
+	task  string // "", "TODO" or "DONE"
+}
+
+func (p *mdParser) list(lines []string, i int) (map[string]any, int) {
+	first, _ := parseListMarker(lines[i])
+	var items []mdListItem
+	for i < len(lines) {
+		m, ok := parseListMarker(lines[i])
+		if !ok || m.ordered != first.ordered || m.indent > first.indent+1 {
+			break
+		}
+		item := mdListItem{lines: []string{m.content}}
+		i++
+		for i < len(lines) {
+			l := lines[i]
+			if isBlank(l) {
+				k := i
+				for k < len(lines) && isBlank(lines[k]) {
+					k++
+				}
+				if k < len(lines) && indentOf(lines[k]) >= m.width {
+					for ; i < k; i++ {
+						item.lines = append(item.lines, "")
+					}
+					continue
+				}
+				break
+			}
+			if indentOf(l) >= m.width {
+				item.lines = append(item.lines, l[m.width:])
+				i++
+				continue
+			}
+			if p.startsBlock(lines, i) {
+				break
+			}
+			item.lines = append(item.lines, strings.TrimLeft(l, " "))
+			i++
+		}
+		items = append(items, item)
+
+		// A blank line between items does not end the list.
+		k := i
+		for k < len(lines) && isBlank(lines[k]) {
+			k++
+		}
+		if k > i && k < len(lines) {
+			if next, ok := parseListMarker(lines[k]); ok && next.ordered == first.ordered && next.indent <= first.indent+1 {
+				i = k
+			}
+		}
+	}
+
+	allTasks := !first.ordered
+	for j := range items {
+		if m := reTaskStart.FindStringSubmatch(items[j].lines[0]); m != nil {
+			items[j].task = "TODO"
+			if m[1] != " " {
+				items[j].task = "DONE"
+			}
+		} else {
+			allTasks = false
+		}
+	}
+	if allTasks {
+		if node, ok := p.taskList(items); ok {
+			return node, i
+		}
+	}
+
+	typ := "bulletList"
+	if first.ordered {
+		typ = "orderedList"
+	}
+	node := map[string]any{"type": typ}
+	if first.ordered && first.start != 1 {
+		node["attrs"] = map[string]any{"order": first.start}
+	}
+	var content []any
+	for _, item := range items {
+		blocks := p.blocks(item.lines)
+		if len(blocks) == 0 {
+			blocks = []any{paragraph(nil)}
+		}
+		content = append(content, map[string]any{"type": "listItem", "content": blocks})
+	}
+	node["content"] = content
+	return node, i
+}
+
+// taskList builds an ADF task list, which holds only task items and nested
+// task lists. It reports false if an item has other content, in which case
+// the list is kept as a bullet list with literal checkboxes.
+func (p *mdParser) taskList(items []mdListItem) (map[string]any, bool) {
+	var content []any
+	for _, item := range items {
+		lines := append([]string{reTaskStart.ReplaceAllString(item.lines[0], "")}, item.lines[1:]...)
+		blocks := p.blocks(lines)
+		p.tasks++
+		task := map[string]any{
+			"type":  "taskItem",
+			"attrs": map[string]any{"localId": fmt.Sprintf("task-%d", p.tasks), "state": item.task},
+		}
+		var nested []any
+		for k, b := range blocks {
+			n := b.(map[string]any)
+			switch {
+			case k == 0 && nodeType(n) == "paragraph":
+				if c, ok := n["content"]; ok {
+					task["content"] = c
+				}
+			case nodeType(n) == "taskList":
+				nested = append(nested, n)
+			default:
+				return nil, false
+			}
+		}
+		content = append(append(content, task), nested...)
+	}
+	return map[string]any{
+		"type":    "taskList",
+		"attrs":   map[string]any{"localId": fmt.Sprintf("tasks-%d", p.tasks)},
+		"content": content,
+	}, true
+}
+
+func (p *mdParser) table(lines []string, i int) (map[string]any, int) {
+	header := splitTableRow(lines[i])
+	i += 2
+	rows := []any{tableRow(header, "tableHeader", len(header))}
+	for i < len(lines) && !isBlank(lines[i]) && strings.Contains(lines[i], "|") {
+		rows = append(rows, tableRow(splitTableRow(lines[i]), "tableCell", len(header)))
+		i++
+	}
+	return map[string]any{"type": "table", "content": rows}, i
+}
+
+func tableRow(cells []string, cellType string, cols int) map[string]any {
+	var content []any
+	for c := 0; c < cols; c++ {
+		text := ""
+		if c < len(cells) {
+			text = cells[c]
+		}
+		var paras []any
+		for _, part := range strings.Split(text, "<br>") {
+			paras = append(paras, paragraph(parseInline(strings.TrimSpace(part))))
+		}
+		content = append(content, map[string]any{"type": cellType, "content": paras})
+	}
+	return map[string]any{"type": "tableRow", "content": content}
+}
+
+// splitTableRow splits a GFM table row on unescaped pipes; "\|" in a cell
+// is a literal pipe.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cur strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cur.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cur.String()))
+}
+
+// parseInline parses inline Markdown into ADF inline nodes.
+func parseInline(s string) []any {
+	var out []any
+	parseInlineInto(s, nil, &out)
+	return mergeTextNodes(out)
+}
+
+func textNode(text string, marks []adfMark) map[string]any {
+	n := map[string]any{"type": "text", "text": text}
+	if len(marks) > 0 {
+		sorted := append([]adfMark(nil), marks...)
+		sortMarks(sorted)
+		list := make([]any, len(sorted))
+		for i, m := range sorted {
+			list[i] = m.toADF()
+		}
+		n["marks"] = list
+	}
+	return n
+}
+
+func withMark(marks []adfMark, m adfMark) []adfMark {
+	return append(append([]adfMark(nil), marks...), m)
+}
+
+func isASCIIPunct(c byte) bool {
+	return c < 0x80 && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
+
+func runLen(s string, i int, c byte) int {
+	j := i
+	for j < len(s) && s[j] == c {
+		j++
+	}
+	return j - i
+}
+
+// findCodeClose returns the start of the next run of exactly n backticks.
+func findCodeClose(s string, from, n int) int {
+	for j := from; j < len(s); {
+		if s[j] != '`' {
+			j++
+			continue
+		}
+		r := runLen(s, j, '`')
+		if r == n {
+			return j
+		}
+		j += r
+	}
+	return -1
+}
+
+// findCloser finds the delimiter closing an opener of k c's: the next run of
+// exactly k, or the last k of a run of three (where an inner em and strong
+// close together). Escapes and code spans are skipped.
+func findCloser(s string, from int, c byte, k int) int {
+	for j := from; j < len(s); {
+		switch s[j] {
+		case '\\':
+			j += 2
+			continue
+		case '`':
+			r := runLen(s, j, '`')
+			if e := findCodeClose(s, j+r, r); e >= 0 {
+				j = e + r
+			} else {
+				j += r
+			}
+			continue
+		case c:
+			r := runLen(s, j, c)
+			ok := r == k || (r == 3 && k < 3 && c != '~')
+			if c == '_' && j+r < len(s) {
+				next, _ := utf8.DecodeRuneInString(s[j+r:])
+				ok = ok && !isAlnum(next)
+			}
+			if ok {
+				return j + r - k
+			}
+			j += r
+			continue
+		}
+		j++
+	}
+	return -1
+}
+
+func parseInlineInto(s string, marks []adfMark, out *[]any) {
+	var buf strings.Builder
+	flush := func() {
+		if buf.Len() > 0 {
+			*out = append(*out, textNode(buf.String(), marks))
+			buf.Reset()
+		}
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			buf.WriteByte(s[i+1])
+			i += 2
+
+		case c == '\n':
+			flush()
+			*out = append(*out, map[string]any{"type": "hardBreak"})
+			i++
+
+		case c == '`':
+			n := runLen(s, i, '`')
+			end := findCodeClose(s, i+n, n)
+			if end < 0 {
+				buf.WriteString(s[i : i+n])
+				i += n
+				continue
+			}
+			code := s[i+n : end]
+			if len(code) >= 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+				code = code[1 : len(code)-1]
+			}
+			flush()
+			// Code combines only with links in ADF.
+			var codeMarks []adfMark
+			for _, m := range marks {
+				if m.typ == "link" {
+					codeMarks = append(codeMarks, m)
+				}
+			}
+			if code != "" {
+				*out = append(*out, textNode(code, withMark(codeMarks, adfMark{typ: "code"})))
+			}
+			i = end + n
+
+		case c == '[':
+			end, ok := parseLink(s, i, marks, flush, out)
+			if !ok {
+				buf.WriteByte(c)
+				i++
+				continue
+			}
+			i = end
+
+		case c == '<' && (strings.HasPrefix(s[i:], "<http://") || strings.HasPrefix(s[i:], "<https://")):
+			end := strings.IndexByte(s[i:], '>')
+			if end < 0 || strings.ContainsAny(s[i:i+end], " \n") {
+				buf.WriteByte(c)
+				i++
+				continue
+			}
+			url := s[i+1 : i+end]
+			flush()
+			*out = append(*out, textNode(url, withMark(marks, adfMark{typ: "link", href: url})))
+			i += end + 1
+
+		case c == '*' || c == '_' || c == '~':
+			n := runLen(s, i, c)
+			consumed := 0
+			prev, _ := utf8.DecodeLastRuneInString(s[:i])
+			if !(c == '_' && isAlnum(prev)) && n <= 3 && !(c == '~' && n != 2) {
+				tries := []int{n}
+				if n == 3 {
+					tries = []int{3, 2, 1}
+				}
+				for _, k := range tries {
+					end := findCloser(s, i+k, c, k)
+					if end <= i+k {
+						continue
+					}
+					flush()
+					inner := marks
+					switch {
+					case c == '~':
+						inner = withMark(inner, adfMark{typ: "strike"})
+					case k == 1:
+						inner = withMark(inner, adfMark{typ: "em"})
+					case k == 2:
+						inner = withMark(inner, adfMark{typ: "strong"})
+					default:
+						inner = withMark(withMark(inner, adfMark{typ: "strong"}), adfMark{typ: "em"})
+					}
+					parseInlineInto(s[i+k:end], inner, out)
+					consumed = end + k - i
+					break
+				}
+			}
+			if consumed == 0 {
+				buf.WriteString(s[i : i+n])
+				consumed = n
+			}
+			i += consumed
+
+		default:
+			buf.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+}
+
+// parseLink parses "[text](href)" at s[i]. An accountid: target makes a
+// mention. It returns the index after the link.
+func parseLink(s string, i int, marks []adfMark, flush func(), out *[]any) (int, bool) {
+	depth := 0
+	closeIdx := -1
+	for j := i; j < len(s) && closeIdx < 0; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '`':
+			r := runLen(s, j, '`')
+			if e := findCodeClose(s, j+r, r); e >= 0 {
+				j = e + r - 1
+			} else {
+				j += r - 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closeIdx = j
+			}
+		}
+	}
+	if closeIdx < 0 || closeIdx+1 >= len(s) || s[closeIdx+1] != '(' {
+		return 0, false
+	}
+	end := strings.IndexByte(s[closeIdx+2:], ')')
+	if end < 0 {
+		return 0, false
+	}
+	href := strings.Trim(strings.TrimSpace(s[closeIdx+2:closeIdx+2+end]), "<>")
+	text := s[i+1 : closeIdx]
+	flush()
+	if id, ok := strings.CutPrefix(href, mentionScheme); ok {
+		var plain strings.Builder
+		for k := 0; k < len(text); k++ {
+			if text[k] == '\\' && k+1 < len(text) {
+				k++
+			}
+			plain.WriteByte(text[k])
+		}
+		*out = append(*out, map[string]any{
+			"type":  "mention",
+			"attrs": map[string]any{"id": id, "text": plain.String()},
+		})
+	} else {
+		parseInlineInto(text, withMark(marks, adfMark{typ: "link", href: href}), out)
+	}
+	return closeIdx + 2 + end + 1, true
+}
+
+// mergeTextNodes joins adjacent text nodes that carry the same marks.
+func mergeTextNodes(nodes []any) []any {
+	var out []any
+	for _, n := range nodes {
+		m := n.(map[string]any)
+		if len(out) > 0 && nodeType(m) == "text" {
+			last := out[len(out)-1].(map[string]any)
+			if nodeType(last) == "text" && fmt.Sprint(last["marks"]) == fmt.Sprint(m["marks"]) {
+				last["text"] = last["text"].(string) + m["text"].(string)
+				continue
+			}
+		}
+		out = append(out, m)
+	}
+	return out
+}
@@ -0,0 +1,982 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"mcp-server/internal/mcp"
+)
+
+// data_query runs a small query — filter, projection, group-by with
+// aggregates, sort, limit — over a CSV, JSON or JSONL file in the workspace
+// and returns only the result rows. Files are streamed record by record;
+// memory is bounded by the number of groups and by limit, not by file size.
+
+const (
+	defaultQueryRows = 100
+	maxQueryRows     = 1000
+	maxQueryGroups   = 100_000
+)
+
+// record is one row of input: CSV columns or a decoded JSON object.
+type record map[string]any
+
+// field returns the value at a dotted path such as "user.name". A key that
+// itself contains dots is matched first.
+func (r record) field(name string) any {
+	if v, ok := r[name]; ok {
+		return v
+	}
+	var cur any = map[string]any(r)
+	for _, seg := range strings.Split(name, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		if cur, ok = m[seg]; !ok {
+			return nil
+		}
+	}
+	return cur
+}
+
+// valueString renders a value for comparison and grouping.
+func valueString(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case json.Number:
+		return x.String()
+	case bool:
+		return strconv.FormatBool(x)
+	default:
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+}
+
+func valueNumber(v any) (float64, bool) {
+	switch x := v.(type) {
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	case float64:
+		return x, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// compareValues orders numerically when both sides are numbers and as
+// strings otherwise; missing values sort first.
+func compareValues(a, b any) int {
+	if fa, ok := valueNumber(a); ok {
+		if fb, ok := valueNumber(b); ok {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(valueString(a), valueString(b))
+}
+
+// ---------------------------------------------------------------------------
+// where expressions
+// ---------------------------------------------------------------------------
+
+// whereExpr is a parsed filter such as
+//
+//	status = "open" and (amount >= 100 or priority in (High, Highest))
+type whereExpr interface{ match(record) bool }
+
+type andExpr []whereExpr
+type orExpr []whereExpr
+type notExpr struct{ e whereExpr }
+
+func (e notExpr) match(r record) bool { return !e.e.match(r) }
+
+func (e andExpr) match(r record) bool {
+	for _, x := range e {
+		if !x.match(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e orExpr) match(r record) bool {
+	for _, x := range e {
+		if x.match(r) {
+			return true
+		}
+	}
+	return false
+}
+
+type condExpr struct {
+	field  string
+	op     string // = != > >= < <= contains startswith endswith in
+	not    bool
+	values []string
+}
+
+func (c condExpr) match(r record) bool {
+	v := r.field(c.field)
+	var ok bool
+	switch c.op {
+	case "=":
+		ok = compareValues(v, c.values[0]) == 0
+	case "!=":
+		ok = compareValues(v, c.values[0]) != 0
+	case ">":
+		ok = v != nil && compareValues(v, c.values[0]) > 0
+	case ">=":
+		ok = v != nil && compareValues(v, c.values[0]) >= 0
+	case "<":
+		ok = v != nil && compareValues(v, c.values[0]) < 0
+	case "<=":
+		ok = v != nil && compareValues(v, c.values[0]) <= 0
+	case "contains":
+		ok = strings.Contains(strings.ToLower(valueString(v)), strings.ToLower(c.values[0]))
+	case "startswith":
+		ok = strings.HasPrefix(strings.ToLower(valueString(v)), strings.ToLower(c.values[0]))
+	case "endswith":
+		ok = strings.HasSuffix(strings.ToLower(valueString(v)), strings.ToLower(c.values[0]))
+	case "in":
+		for _, want := range c.values {
+			if compareValues(v, want) == 0 {
+				ok = true
+				break
+			}
+		}
+	}
+	return ok != c.not
+}
+
+type whereToken struct {
+	kind byte // 'w' word, 's' quoted string, 'o' operator, or one of ( ) ,
+	text string
+}
+
+func tokenizeWhere(s string) ([]whereToken, error) {
+	var toks []whereToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case c == '(' || c == ')' || c == ',':
+			toks = append(toks, whereToken{kind: c})
+			i++
+		case c == '"' || c == '\'' || c == '`':
+			j := strings.IndexByte(s[i+1:], c)
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated quote at position %d", i)
+			}
+			toks = append(toks, whereToken{kind: 's', text: s[i+1 : i+1+j]})
+			i += j + 2
+		case strings.IndexByte("=!<>", c) >= 0:
+			j := i + 1
+			for j < len(s) && strings.IndexByte("=<>", s[j]) >= 0 {
+				j++
+			}
+			op := s[i:j]
+			switch op {
+			case "==":
+				op = "="
+			case "<>":
+				op = "!="
+			case "=", "!=", ">", ">=", "<", "<=":
+			default:
+				return nil, fmt.Errorf("unknown operator %q", op)
+			}
+			toks = append(toks, whereToken{kind: 'o', text: op})
+			i = j
+		default:
+			// The current rune is neither space nor punctuation, so the word
+			// always takes at least it and the loop advances.
+			j := i + size
+			for j < len(s) {
+				r, n := utf8.DecodeRuneInString(s[j:])
+				if unicode.IsSpace(r) || strings.IndexByte(`()=!<>,"'`+"`", s[j]) >= 0 {
+					break
+				}
+				j += n
+			}
+			toks = append(toks, whereToken{kind: 'w', text: s[i:j]})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+type whereParser struct {
+	toks []whereToken
+	pos  int
+}
+
+// parseWhere parses a filter: conditions "field op value" joined with and /
+// or (and binds tighter), negated with not and grouped with parentheses.
+func parseWhere(s string) (whereExpr, error) {
+	toks, err := tokenizeWhere(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &whereParser{toks: toks}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q", p.toks[p.pos].show())
+	}
+	return e, nil
+}
+
+func (t whereToken) show() string {
+	if t.kind == 'w' || t.kind == 's' || t.kind == 'o' {
+		return t.text
+	}
+	return string(t.kind)
+}
+
+func (p *whereParser) peek() (whereToken, bool) {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos], true
+	}
+	return whereToken{}, false
+}
+
+func (p *whereParser) keyword(word string) bool {
+	t, ok := p.peek()
+	if ok && t.kind == 'w' && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *whereParser) or() (whereExpr, error) {
+	var terms orExpr
+	for {
+		t, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+		if !p.keyword("or") {
+			break
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+func (p *whereParser) and() (whereExpr, error) {
+	var terms andExpr
+	for {
+		t, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+		if !p.keyword("and") {
+			break
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+func (p *whereParser) factor() (whereExpr, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, errors.New("expected a condition")
+	}
+	if p.keyword("not") {
+		e, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{e}, nil
+	}
+	if t.kind == '(' {
+		p.pos++
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := p.peek(); !ok || t.kind != ')' {
+			return nil, errors.New("missing )")
+		}
+		p.pos++
+		return e, nil
+	}
+	return p.cond()
+}
+
+func (p *whereParser) value() (string, error) {
+	t, ok := p.peek()
+	if !ok || (t.kind != 'w' && t.kind != 's') {
+		return "", errors.New("expected a value")
+	}
+	p.pos++
+	return t.text, nil
+}
+
+func (p *whereParser) cond() (whereExpr, error) {
+	field, err := p.value()
+	if err != nil {
+		return nil, errors.New("expected a field name")
+	}
+	c := condExpr{field: field}
+	c.not = p.keyword("not")
+	t, ok := p.peek()
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("missing operator after %q", field)
+	case t.kind == 'o' && !c.not:
+		c.op = t.text
+	case t.kind == 'w':
+		c.op = strings.ToLower(t.text)
+		switch c.op {
+		case "contains", "startswith", "endswith", "in":
+		default:
+			return nil, fmt.Errorf("unknown operator %q", t.text)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected %q after %q", t.show(), field)
+	}
+	p.pos++
+
+	if c.op != "in" {
+		v, err := p.value()
+		if err != nil {
+			return nil, fmt.Errorf("%s after %s %s", err, field, c.op)
+		}
+		c.values = []string{v}
+		return c, nil
+	}
+	if t, ok := p.peek(); !ok || t.kind != '(' {
+		return nil, errors.New("expected ( after in")
+	}
+	p.pos++
+	for {
+		v, err := p.value()
+		if err != nil {
+			return nil, fmt.Errorf("%s in the in list", err)
+		}
+		c.values = append(c.values, v)
+		t, ok := p.peek()
+		if ok && t.kind == ',' {
+			p.pos++
+			continue
+		}
+		if ok && t.kind == ')' {
+			p.pos++
+			return c, nil
+		}
+		return nil, errors.New("missing ) after in list")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// aggregation and ordering
+// ---------------------------------------------------------------------------
+
+type aggSpec struct {
+	fn    string // count sum avg min max
+	field string // "" for count(*)
+	name  string // output column, e.g. "sum(amount)"
+}
+
+func parseAggregates(specs []string) ([]aggSpec, error) {
+	var aggs []aggSpec
+	for _, s := range specs {
+		fn, field := strings.ToLower(s), ""
+		if open := strings.IndexByte(s, '('); open > 0 && strings.HasSuffix(s, ")") {
+			fn = strings.ToLower(strings.TrimSpace(s[:open]))
+			field = strings.TrimSpace(s[open+1 : len(s)-1])
+		}
+		if field == "*" {
+			field = ""
+		}
+		switch fn {
+		case "count":
+		case "sum", "avg", "min", "max":
+			if field == "" {
+				return nil, fmt.Errorf("%s needs a field, e.g. %s(amount)", fn, fn)
+			}
+		default:
+			return nil, fmt.Errorf("unknown aggregate %q: use count, sum, avg, min or max", s)
+		}
+		name := fn
+		if field != "" {
+			name = fn + "(" + field + ")"
+		}
+		aggs = append(aggs, aggSpec{fn: fn, field: field, name: name})
+	}
+	return aggs, nil
+}
+
+type aggState struct {
+	count    int
+	sum      float64
+	numeric  int
+	min, max any
+}
+
+func (s *aggState) add(spec aggSpec, r record) {
+	if spec.field == "" {
+		s.count++
+		return
+	}
+	v := r.field(spec.field)
+	if v == nil || valueString(v) == "" {
+		return
+	}
+	s.count++
+	if f, ok := valueNumber(v); ok {
+		s.sum += f
+		s.numeric++
+	}
+	if s.min == nil || compareValues(v, s.min) < 0 {
+		s.min = v
+	}
+	if s.max == nil || compareValues(v, s.max) > 0 {
+		s.max = v
+	}
+}
+
+func (s *aggState) result(fn string) any {
+	switch fn {
+	case "count":
+		return s.count
+	case "sum":
+		return s.sum
+	case "avg":
+		if s.numeric == 0 {
+			return nil
+		}
+		return s.sum / float64(s.numeric)
+	case "min":
+		return s.min
+	}
+	return s.max
+}
+
+type orderKey struct {
+	field string
+	desc  bool
+}
+
+func parseOrder(specs []string) ([]orderKey, error) {
+	var keys []orderKey
+	for _, s := range specs {
+		parts := strings.Fields(s)
+		k := orderKey{field: parts[0]}
+		if len(parts) > 2 {
+			return nil, fmt.Errorf("invalid order_by %q: use \"field [asc|desc]\"", s)
+		}
+		if len(parts) == 2 {
+			switch strings.ToLower(parts[1]) {
+			case "desc":
+				k.desc = true
+			case "asc":
+			default:
+				return nil, fmt.Errorf("invalid order_by %q: use \"field [asc|desc]\"", s)
+			}
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// resultRow is an output row and its position in the input, which keeps
+// sorting stable.
+type resultRow struct {
+	values record
+	seq    int
+}
+
+func sortRows(rows []resultRow, order []orderKey) {
+	sort.Slice(rows, func(i, j int) bool {
+		for _, k := range order {
+			c := compareValues(rows[i].values.field(k.field), rows[j].values.field(k.field))
+			if c != 0 {
+				return (c < 0) != k.desc
+			}
+		}
+		return rows[i].seq < rows[j].seq
+	})
+}
+
+// ---------------------------------------------------------------------------
+// input formats
+// ---------------------------------------------------------------------------
+
+// queryFormat infers the input format from the file extension.
+func queryFormat(name, explicit string) (string, error) {
+	f := strings.ToLower(explicit)
+	if f == "" {
+		f = strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+		if f == "ndjson" {
+			f = "jsonl"
+		}
+	}
+	switch f {
+	case "csv", "tsv", "json", "jsonl":
+		return f, nil
+	}
+	return "", fmt.Errorf("cannot query %q: format must be csv, tsv, json or jsonl", name)
+}
+
+// errBadRecord marks an input record that could not be parsed; the query
+// skips it and carries on.
+type errBadRecord struct{ err error }
+
+func (e *errBadRecord) Error() string { return e.err.Error() }
+
+// validCSVDelimiter mirrors encoding/csv's own check, which otherwise fails
+// every Read.
+func validCSVDelimiter(r rune) bool {
+	return r != 0 && r != '"' && r != '\r' && r != '\n' && r != utf8.RuneError
+}
+
+func scanCSV(r io.Reader, comma rune, header bool, fn func(record, error) error) error {
+	cr := csv.NewReader(bufio.NewReader(r))
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.ReuseRecord = true
+
+	var columns []string
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// Only a malformed row is skipped; the reader has moved past it.
+			// Anything else (a read error) would repeat on every call.
+			var perr *csv.ParseError
+			if !errors.As(err, &perr) {
+				return err
+			}
+			if e := fn(nil, &errBadRecord{err}); e != nil {
+				return e
+			}
+			continue
+		}
+		if columns == nil {
+			columns = make([]string, len(row))
+			for i, c := range row {
+				if header {
+					columns[i] = strings.TrimSpace(strings.TrimPrefix(c, "\ufeff"))
+				} else {
+					columns[i] = fmt.Sprintf("col%d", i+1)
+				}
+			}
+			if header {
+				continue
+			}
+		}
+		rec := make(record, len(row))
+		for i, v := range row {
+			name := fmt.Sprintf("col%d", i+1)
+			if i < len(columns) {
+				name = columns[i]
+			}
+			rec[name] = v
+		}
+		if err := fn(rec, nil); err != nil {
+			return err
+		}
+	}
+}
+
+func scanJSONL(r io.Reader, fn func(record, error) error) error {
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		b, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(b)) > 0 {
+			if rec, perr := decodeRecord(b); perr != nil {
+				if e := fn(nil, &errBadRecord{fmt.Errorf("line %d: %v", line, perr)}); e != nil {
+					return e
+				}
+			} else if e := fn(rec, nil); e != nil {
+				return e
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func decodeRecord(b []byte) (record, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return asRecord(v), nil
+}
+
+// asRecord wraps non-object values so they can still be queried as "value".
+func asRecord(v any) record {
+	if m, ok := v.(map[string]any); ok {
+		return m
+	}
+	return record{"value": v}
+}
+
+// scanJSON streams the elements of a JSON array: the whole document, or the
+// array found at recordsPath (dotted keys) inside it.
+func scanJSON(r io.Reader, recordsPath string, fn func(record, error) error) error {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	dec.UseNumber()
+
+	var segs []string
+	if recordsPath != "" {
+		segs = strings.Split(recordsPath, ".")
+	}
+	for depth := 0; ; depth++ {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("invalid JSON: %v", err)
+		}
+		if tok == json.Delim('[') {
+			if depth < len(segs) {
+				return fmt.Errorf("records_path %q: %q is an array, not an object", recordsPath, strings.Join(segs[:depth], "."))
+			}
+			break
+		}
+		if tok != json.Delim('{') {
+			return errors.New("JSON must be an array of records, or an object holding one (see records_path)")
+		}
+		if depth == len(segs) {
+			keys, _ := objectKeys(dec)
+			return fmt.Errorf("JSON is an object, not an array of records; pass records_path naming the array (keys: %s)", strings.Join(keys, ", "))
+		}
+		found := false
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return fmt.Errorf("invalid JSON: %v", err)
+			}
+			if key, _ := keyTok.(string); key == segs[depth] {
+				found = true
+				break
+			}
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return fmt.Errorf("invalid JSON: %v", err)
+			}
+		}
+		if !found {
+			return fmt.Errorf("records_path %q: key %q not found", recordsPath, segs[depth])
+		}
+	}
+
+	for dec.More() {
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return fmt.Errorf("invalid JSON: %v", err)
+		}
+		if err := fn(asRecord(v), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// objectKeys lists the remaining keys of the object dec is inside.
+func objectKeys(dec *json.Decoder) ([]string, error) {
+	var keys []string
+	for dec.More() && len(keys) < 20 {
+		tok, err := dec.Token()
+		if err != nil {
+			return keys, err
+		}
+		if k, ok := tok.(string); ok {
+			keys = append(keys, k)
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return keys, err
+		}
+	}
+	return keys, nil
+}
+
+// ---------------------------------------------------------------------------
+// tool
+// ---------------------------------------------------------------------------
+
+var errTooManyGroups = fmt.Errorf("more than %d groups; group by fewer or coarser fields", maxQueryGroups)
+
+func dataQuery(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
+	path, errResult, err := requireString(args, "path")
+	if errResult != nil {
+		return *errResult, err
+	}
+	rel, errResult, err := safePath(path)
+	if errResult != nil {
+		return *errResult, err
+	}
+	format, err := queryFormat(path, optionalString(args, "format", ""))
+	if err != nil {
+		return textErr(err.Error())
+	}
+
+	var where whereExpr
+	if w := optionalString(args, "where", ""); w != "" {
+		if where, err = parseWhere(w); err != nil {
+			return textErr(fmt.Sprintf("invalid where: %v", err))
+		}
+	}
+	selectCols := optionalStringList(args, "select")
+	groupBy := optionalStringList(args, "group_by")
+	aggs, err := parseAggregates(optionalStringList(args, "aggregate"))
+	if err != nil {
+		return textErr(err.Error())
+	}
+	order, err := parseOrder(optionalStringList(args, "order_by"))
+	if err != nil {
+		return textErr(err.Error())
+	}
+	limit := int(optionalFloat(args, "limit", defaultQueryRows))
+	limit = min(max(limit, 1), maxQueryRows)
+	grouped := len(groupBy) > 0 || len(aggs) > 0
+	if len(groupBy) > 0 && len(aggs) == 0 {
+		aggs = []aggSpec{{fn: "count", name: "count"}}
+	}
+
+	f, info, err := ws.open(rel)
+	if err != nil {
+		return textErr(fmt.Sprintf("read file failed: %v", err))
+	}
+	defer f.Close()
+	if info.IsDir() {
+		return textErr(fmt.Sprintf("%q is a directory", path))
+	}
+
+	type group struct {
+		keys   record
+		states []aggState
+		seq    int
+	}
+	groups := map[string]*group{}
+	var rows []resultRow
+	scanned, matched, bad := 0, 0, 0
+	firstBad := ""
+
+	process := func(rec record, recErr error) error {
+		if recErr != nil {
+			bad++
+			if firstBad == "" {
+				firstBad = recErr.Error()
+			}
+			return nil
+		}
+		scanned++
+		if where != nil && !where.match(rec) {
+			return nil
+		}
+		matched++
+
+		if grouped {
+			var kb strings.Builder
+			for _, g := range groupBy {
+				kb.WriteString(valueString(rec.field(g)))
+				kb.WriteByte(0)
+			}
+			g := groups[kb.String()]
+			if g == nil {
+				if len(groups) == maxQueryGroups {
+					return errTooManyGroups
+				}
+				g = &group{keys: record{}, states: make([]aggState, len(aggs)), seq: len(groups)}
+				for _, k := range groupBy {
+					g.keys[k] = rec.field(k)
+				}
+				groups[kb.String()] = g
+			}
+			for i, a := range aggs {
+				g.states[i].add(a, rec)
+			}
+			return nil
+		}
+
+		// Rows keep the whole record until the end so order_by can use
+		// columns that select leaves out; projection happens after sorting.
+		switch {
+		case len(order) == 0:
+			if len(rows) < limit {
+				rows = append(rows, resultRow{rec, matched})
+			}
+		default:
+			// Keep only the best rows seen so far: sort and trim whenever
+			// the buffer doubles, so memory stays O(limit).
+			rows = append(rows, resultRow{rec, matched})
+			if len(rows) >= 2*limit+64 {
+				sortRows(rows, order)
+				rows = rows[:limit]
+			}
+		}
+		return nil
+	}
+
+	switch format {
+	case "csv", "tsv":
+		comma := ','
+		if format == "tsv" {
+			comma = '\t'
+		}
+		if d := optionalString(args, "delimiter", ""); d != "" {
+			if d == `\t` {
+				d = "\t"
+			}
+			r, size := utf8.DecodeRuneInString(d)
+			if size != len(d) || !validCSVDelimiter(r) {
+				return textErr(fmt.Sprintf("invalid delimiter %q: use a single character other than a quote or line break", d))
+			}
+			comma = r
+		}
+		err = scanCSV(f, comma, optionalBool(args, "header", true), process)
+	case "jsonl":
+		err = scanJSONL(f, process)
+	default:
+		err = scanJSON(f, optionalString(args, "records_path", ""), process)
+	}
+	if err != nil {
+		return textErr(fmt.Sprintf("query failed: %v", err))
+	}
+
+	columns := selectCols
+	if grouped {
+		columns = append(append([]string{}, groupBy...), aggNames(aggs)...)
+		if len(groupBy) == 0 && len(groups) == 0 {
+			// Aggregates over no matching rows still give one row.
+			groups[""] = &group{keys: record{}, states: make([]aggState, len(aggs))}
+		}
+		rows = rows[:0]
+		for _, g := range groups {
+			row := record{}
+			for k, v := range g.keys {
+				row[k] = v
+			}
+			for i, a := range aggs {
+				row[a.name] = g.states[i].result(a.fn)
+			}
+			rows = append(rows, resultRow{row, g.seq})
+		}
+		if len(order) == 0 {
+			sort.Slice(rows, func(i, j int) bool { return rows[i].seq < rows[j].seq })
+		}
+	}
+	total := len(rows)
+	if !grouped {
+		total = matched
+	}
+	if len(order) > 0 {
+		sortRows(rows, order)
+	}
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+
+	out := make([]record, len(rows))
+	for i, r := range rows {
+		out[i] = r.values
+		if !grouped && len(selectCols) > 0 {
+			out[i] = make(record, len(selectCols))
+			for _, c := range selectCols {
+				out[i][c] = r.values.field(c)
+			}
+		}
+	}
+	result := map[string]any{
+		"path":         path,
+		"format":       format,
+		"rows":         out,
+		"returned":     len(out),
+		"scanned_rows": scanned,
+		"matched_rows": matched,
+		"truncated":    total > len(out),
+	}
+	if grouped {
+		result["groups"] = total
+	}
+	if len(columns) > 0 {
+		result["columns"] = columns
+	}
+	if bad > 0 {
+		result["bad_rows"] = bad
+		result["first_error"] = firstBad
+	}
+	return textResult(result)
+}
+
+func aggNames(aggs []aggSpec) []string {
+	names := make([]string, len(aggs))
+	for i, a := range aggs {
+		names[i] = a.name
+	}
+	return names
+}
+
+func dataDefinitions() []mcp.ToolDefinition {
+	return []mcp.ToolDefinition{
+		{
+			Name:        "data_query",
+			Description: "Query a CSV, TSV, JSON or JSONL file in the agent's workspace without reading it into context: filter rows, pick columns, group and aggregate (count, sum, avg, min, max), sort, and return only the result rows. The file is streamed, so it can be much larger than file_read allows. Nested JSON fields are addressed with dots, e.g. \"fields.status.name\".",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"path":         {Type: "string", Description: "Relative path to the data file."},
+					"where":        {Type: "string", Description: `Filter, e.g. status = "Done" and (points >= 3 or labels contains urgent). Operators: = != > >= < <= contains startswith endswith in (a, b), optionally preceded by not; combine with and/or and parentheses. Numbers compare numerically; contains/startswith/endswith ignore case.`},
+					"select":       {Type: "string", Description: `Comma-separated fields to return, e.g. "key,summary,assignee.name". Defaults to whole records.`},
+					"group_by":     {Type: "string", Description: "Comma-separated fields to group by; each result row is one group."},
+					"aggregate":    {Type: "string", Description: `Comma-separated aggregates, e.g. "count,sum(points),avg(points)". Defaults to count when group_by is set; without group_by they summarise all matching rows.`},
+					"order_by":     {Type: "string", Description: `Comma-separated sort keys, each "field" or "field desc", e.g. "sum(points) desc". Aggregates are referred to by their column name.`},
+					"limit":        {Type: "number", Description: "Maximum rows to return (default 100, max 1000)."},
+					"format":       {Type: "string", Description: "csv, tsv, json or jsonl; inferred from the extension by default."},
+					"records_path": {Type: "string", Description: `For JSON objects, the dotted path to the array of records, e.g. "issues" or "data.items".`},
+					"delimiter":    {Type: "string", Description: `CSV field delimiter (default "," or tab for .tsv), e.g. ";".`},
+					"header":       {Type: "boolean", Description: "Whether the first CSV row holds column names (default true). Without a header, columns are named col1, col2, ..."},
+				},
+				Required: []string{"path"},
+			},
+		},
+	}
+}
@@ -0,0 +1,1114 @@
+package tools
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Document formats recognised by sniffFormat.
+const (
+	formatHTML   = "html"
+	formatText   = "text"
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatPDF    = "pdf"
+	formatDOCX   = "docx"
+	formatBinary = "binary"
+)
+
+// maxCSVRows caps how many rows of a CSV document are returned.
+const maxCSVRows = 1000
+
+// extractedDoc is the agent-readable form of a fetched body.
+type extractedDoc struct {
+	Format string
+	Text   string
+	Rows   [][]string // CSV only
+	// RowsTruncated reports that the CSV had more than maxCSVRows rows.
+	RowsTruncated bool
+}
+
+// sniffFormat decides how to decode body using, in order, the Content-Type
+// header, magic bytes and the URL's file extension.
+func sniffFormat(contentType string, body []byte, rawURL string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	ext := ""
+	if u, err := url.Parse(rawURL); err == nil {
+		ext = strings.ToLower(path.Ext(u.Path))
+	}
+
+	switch {
+	case bytes.HasPrefix(body, []byte("%PDF-")) || mediaType == "application/pdf":
+		return formatPDF
+	case mediaType == "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		bytes.HasPrefix(body, []byte("PK\x03\x04")) && (ext == ".docx" || bytes.Contains(body, []byte("word/document.xml"))):
+		return formatDOCX
+	case mediaType == "text/csv", mediaType == "application/csv", mediaType == "text/tab-separated-values":
+		return formatCSV
+	case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"):
+		return formatJSON
+	case mediaType == "text/html", mediaType == "application/xhtml+xml":
+		return formatHTML
+	}
+
+	// Servers often label documents generically; fall back to the extension
+	// and then to content sniffing.
+	generic := mediaType == "" || mediaType == "application/octet-stream" || mediaType == "text/plain"
+	if generic {
+		switch ext {
+		case ".csv", ".tsv":
+			return formatCSV
+		case ".json", ".jsonl", ".geojson":
+			return formatJSON
+		}
+		if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+			return formatJSON
+		}
+	}
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+		if mediaType == "text/html" {
+			return formatHTML
+		}
+	}
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "xml") ||
+		mediaType == "application/javascript" || mediaType == "application/x-yaml" {
+		return formatText
+	}
+	return formatBinary
+}
+
+// extractDocument converts body into text (or rows) according to format.
+func extractDocument(format string, body []byte, contentType string) (extractedDoc, error) {
+	doc := extractedDoc{Format: format}
+	switch format {
+	case formatPDF:
+		text, err := pdfText(body)
+		if err != nil {
+			return doc, fmt.Errorf("extract pdf: %w", err)
+		}
+		doc.Text = text
+	case formatDOCX:
+		text, err := docxText(body)
+		if err != nil {
+			return doc, fmt.Errorf("extract docx: %w", err)
+		}
+		doc.Text = text
+	case formatCSV:
+		rows, truncated, err := csvRows(body, contentType)
+		if err != nil {
+			return doc, fmt.Errorf("parse csv: %w", err)
+		}
+		doc.Rows, doc.RowsTruncated = rows, truncated
+	case formatJSON:
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, bytes.TrimSpace(body), "", "  "); err != nil {
+			// Truncated or JSONL: hand back the raw text rather than failing.
+			doc.Text = string(body)
+		} else {
+			doc.Text = buf.String()
+		}
+	case formatBinary:
+		doc.Text = fmt.Sprintf("[binary content: %s, %d bytes — not shown]", contentType, len(body))
+	default:
+		doc.Text = string(body)
+	}
+	return doc, nil
+}
+
+// ── CSV ─────────────────────────────────────────────────────────────────────
+
+// csvRows parses up to maxCSVRows records. The delimiter is a tab for TSV
+// and otherwise whichever of , ; or tab is most frequent on the first line.
+func csvRows(body []byte, contentType string) ([][]string, bool, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.Comma = sniffDelimiter(body, contentType)
+
+	var rows [][]string
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return rows, false, nil
+		}
+		if err != nil {
+			// A body cut off by the fetch cap usually ends mid-record.
+			if len(rows) > 0 {
+				return rows, true, nil
+			}
+			return nil, false, err
+		}
+		if len(rows) == maxCSVRows {
+			return rows, true, nil
+		}
+		rows = append(rows, rec)
+	}
+}
+
+func sniffDelimiter(body []byte, contentType string) rune {
+	if strings.HasPrefix(contentType, "text/tab-separated-values") {
+		return '\t'
+	}
+	first, _, _ := bytes.Cut(body, []byte("\n"))
+	best, bestCount := ',', bytes.Count(first, []byte(","))
+	for _, d := range []rune{';', '\t'} {
+		if n := bytes.Count(first, []byte(string(d))); n > bestCount {
+			best, bestCount = d, n
+		}
+	}
+	return best
+}
+
+// ── DOCX ────────────────────────────────────────────────────────────────────
+
+// docxText reads word/document.xml from a DOCX package and flattens it to
+// text: one line per paragraph, table cells separated by tabs.
+func docxText(body []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return "", err
+	}
+	var docFile *zip.File
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			docFile = f
+			break
+		}
+	}
+	if docFile == nil {
+		return "", fmt.Errorf("word/document.xml not found")
+	}
+	rc, err := docFile.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	var sb strings.Builder
+	dec := xml.NewDecoder(io.LimitReader(rc, 20<<20))
+	inText, cellDepth := false, 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteByte('\t')
+			case "br", "cr":
+				sb.WriteByte('\n')
+			case "tc":
+				cellDepth++
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if cellDepth > 0 {
+					sb.WriteByte(' ')
+				} else {
+					sb.WriteByte('\n')
+				}
+			case "tc":
+				cellDepth--
+				sb.WriteByte('\t')
+			case "tr":
+				sb.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// ── PDF ─────────────────────────────────────────────────────────────────────
+
+// pdfText is a best-effort, dependency-free PDF text extractor. It indexes
+// the document's objects (including those packed in object streams),
+// inflates every FlateDecode (or unfiltered) stream that looks like page
+// content, and interprets the text-showing operators (Tj, TJ, ', ") with
+// line breaks inferred from the positioning operators. Strings shown in a
+// font with a ToUnicode CMap are decoded through it; text in CID-keyed
+// fonts without one holds bare glyph ids and is left out, and documents
+// whose output is mostly unprintable are refused rather than returned
+// garbled. Scanned PDFs have no text at all.
+func pdfText(body []byte) (string, error) {
+	doc := newPDFDocument(body)
+	owners, fallback := doc.contentFonts()
+
+	var sb strings.Builder
+	skipped := 0
+	for _, num := range doc.order {
+		obj := doc.objects[num]
+		if obj.stream == nil {
+			continue
+		}
+		content, ok := pdfDecodeStream(obj.dict, obj.stream)
+		if !ok {
+			continue
+		}
+		fonts, ok := owners[num]
+		if !ok {
+			fonts = fallback
+		}
+		text, n := pdfContentText(content, fonts)
+		sb.WriteString(text)
+		skipped += n
+	}
+
+	text := strings.TrimSpace(collapseBlankLines(sb.String()))
+	if text == "" && skipped > 0 {
+		return "", fmt.Errorf("text not extractable: the PDF's CID-keyed fonts have no ToUnicode map")
+	}
+	if mostlyUnprintable(text) {
+		return "", fmt.Errorf("text not extractable: the PDF's fonts use custom encodings without a supported Unicode mapping")
+	}
+	if text == "" {
+		return "", fmt.Errorf("no extractable text (the PDF may be scanned or use unsupported encodings)")
+	}
+	return text, nil
+}
+
+// pdfObject is one indirect object: its dictionary (or whole body for
+// non-stream objects) and, for streams, the still-encoded data.
+type pdfObject struct {
+	dict   []byte
+	stream []byte
+}
+
+// pdfDocument indexes a PDF's objects by number, in file order.
+type pdfDocument struct {
+	objects map[int]*pdfObject
+	order   []int
+	fonts   map[int]*pdfFont
+}
+
+var (
+	rePDFObject = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+	rePDFRef    = regexp.MustCompile(`(\d+)\s+\d+\s+R\b`)
+	rePDFFont   = regexp.MustCompile(`/([^\s/<>\[\]()]+)\s+(\d+)\s+\d+\s+R\b`)
+)
+
+func newPDFDocument(body []byte) *pdfDocument {
+	doc := &pdfDocument{objects: map[int]*pdfObject{}, fonts: map[int]*pdfFont{}}
+	rest := body
+	for {
+		loc := rePDFObject.FindSubmatchIndex(rest)
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(rest[loc[2]:loc[3]]))
+		rest = rest[loc[1]:]
+		obj := &pdfObject{}
+		endObj := bytes.Index(rest, []byte("endobj"))
+		idx := bytes.Index(rest, []byte("stream"))
+		if idx < 0 || (endObj >= 0 && endObj < idx) {
+			if endObj < 0 {
+				endObj = len(rest)
+			}
+			obj.dict = rest[:endObj]
+			rest = rest[endObj:]
+		} else {
+			obj.dict = rest[:idx]
+			start := idx + len("stream")
+			if start < len(rest) && rest[start] == '\r' {
+				start++
+			}
+			if start < len(rest) && rest[start] == '\n' {
+				start++
+			}
+			end := bytes.Index(rest[start:], []byte("endstream"))
+			if end < 0 {
+				end = len(rest) - start
+			}
+			obj.stream = rest[start : start+end]
+			rest = rest[start+end:]
+		}
+		doc.add(num, obj)
+		if obj.stream != nil && string(pdfDictValue(obj.dict, "Type")) == "/ObjStm" {
+			doc.addObjectStream(obj)
+		}
+	}
+	return doc
+}
+
+func (d *pdfDocument) add(num int, obj *pdfObject) {
+	if _, dup := d.objects[num]; !dup {
+		d.order = append(d.order, num)
+	}
+	d.objects[num] = obj
+}
+
+// addObjectStream indexes the objects packed in a /Type /ObjStm stream: a
+// header of "number offset" pairs followed, from /First, by their bodies.
+func (d *pdfDocument) addObjectStream(obj *pdfObject) {
+	data, ok := pdfInflate(obj.dict, obj.stream)
+	if !ok {
+		return
+	}
+	first, err := strconv.Atoi(string(pdfDictValue(obj.dict, "First")))
+	if err != nil || first < 0 || first > len(data) {
+		return
+	}
+	header := strings.Fields(string(data[:first]))
+	for i := 0; i+1 < len(header); i += 2 {
+		num, err1 := strconv.Atoi(header[i])
+		off, err2 := strconv.Atoi(header[i+1])
+		if err1 != nil || err2 != nil || first+off > len(data) {
+			return
+		}
+		end := len(data)
+		if i+3 < len(header) {
+			if next, err := strconv.Atoi(header[i+3]); err == nil && first+next >= first+off && first+next <= len(data) {
+				end = first + next
+			}
+		}
+		d.add(num, &pdfObject{dict: data[first+off : end]})
+	}
+}
+
+// resolve returns the body of the object v refers to, or v itself when it
+// is a direct value.
+func (d *pdfDocument) resolve(v []byte) []byte {
+	if m := rePDFRef.FindSubmatch(v); m != nil && bytes.HasPrefix(bytes.TrimSpace(v), m[0]) {
+		num, _ := strconv.Atoi(string(m[1]))
+		if obj := d.objects[num]; obj != nil {
+			return obj.dict
+		}
+		return nil
+	}
+	return v
+}
+
+// resources returns the resource dictionary of a page or form, inherited
+// from the page tree when the object has none of its own.
+func (d *pdfDocument) resources(dict []byte) []byte {
+	for range 32 {
+		if v := pdfDictValue(dict, "Resources"); v != nil {
+			return d.resolve(v)
+		}
+		m := rePDFRef.FindSubmatch(pdfDictValue(dict, "Parent"))
+		if m == nil {
+			return nil
+		}
+		num, _ := strconv.Atoi(string(m[1]))
+		parent := d.objects[num]
+		if parent == nil {
+			return nil
+		}
+		dict = parent.dict
+	}
+	return nil
+}
+
+// fontMap maps the font resource names of a resource dictionary (/F1 …) to
+// their fonts.
+func (d *pdfDocument) fontMap(resources []byte) map[string]*pdfFont {
+	fonts := map[string]*pdfFont{}
+	for _, m := range rePDFFont.FindAllSubmatch(d.resolve(pdfDictValue(resources, "Font")), -1) {
+		num, _ := strconv.Atoi(string(m[2]))
+		fonts["/"+string(m[1])] = d.font(num)
+	}
+	return fonts
+}
+
+// contentFonts works out which fonts each content stream may select: a
+// page's streams use the page's resources and a form XObject its own.
+// Streams owned by neither get every font the document names.
+func (d *pdfDocument) contentFonts() (owners map[int]map[string]*pdfFont, fallback map[string]*pdfFont) {
+	owners = map[int]map[string]*pdfFont{}
+	fallback = map[string]*pdfFont{}
+	for _, num := range d.order {
+		obj := d.objects[num]
+		var streams [][]byte
+		if obj.stream != nil && pdfDictValue(obj.dict, "Resources") != nil {
+			streams = [][]byte{[]byte(strconv.Itoa(num) + " 0 R")}
+		} else if v := pdfDictValue(obj.dict, "Contents"); v != nil {
+			streams = rePDFRef.FindAll(v, -1)
+		} else {
+			continue
+		}
+		fonts := d.fontMap(d.resources(obj.dict))
+		for name, f := range fonts {
+			if _, ok := fallback[name]; !ok {
+				fallback[name] = f
+			}
+		}
+		for _, ref := range streams {
+			n, _ := strconv.Atoi(string(rePDFRef.FindSubmatch(ref)[1]))
+			owners[n] = fonts
+		}
+	}
+	return owners, fallback
+}
+
+// pdfFont is what text extraction needs to know about a font.
+type pdfFont struct {
+	cid  bool     // Type0: strings hold multi-byte glyph codes
+	cmap *pdfCMap // ToUnicode map, nil if absent
+}
+
+func (d *pdfDocument) font(num int) *pdfFont {
+	if f, ok := d.fonts[num]; ok {
+		return f
+	}
+	f := &pdfFont{}
+	d.fonts[num] = f
+	obj := d.objects[num]
+	if obj == nil {
+		return f
+	}
+	f.cid = string(pdfDictValue(obj.dict, "Subtype")) == "/Type0"
+	if m := rePDFRef.FindSubmatch(pdfDictValue(obj.dict, "ToUnicode")); m != nil {
+		n, _ := strconv.Atoi(string(m[1]))
+		if cm := d.objects[n]; cm != nil && cm.stream != nil {
+			if data, ok := pdfInflate(cm.dict, cm.stream); ok {
+				f.cmap = parseCMap(data)
+			}
+		}
+	}
+	return f
+}
+
+// pdfDictValue returns the raw value of /key in a dictionary: a nested
+// << >> dictionary, a [ ] array, a name, or the tokens up to the next key
+// (a number or an "n g R" reference). It returns nil when key is absent.
+func pdfDictValue(dict []byte, key string) []byte {
+	needle := []byte("/" + key)
+	for off := 0; ; {
+		idx := bytes.Index(dict[off:], needle)
+		if idx < 0 {
+			return nil
+		}
+		pos := off + idx + len(needle)
+		off = pos
+		if pos < len(dict) && !isPDFSpace(dict[pos]) && !isPDFDelim(dict[pos]) {
+			continue // a longer name such as /FontDescriptor
+		}
+		for pos < len(dict) && isPDFSpace(dict[pos]) {
+			pos++
+		}
+		rest := dict[pos:]
+		switch {
+		case bytes.HasPrefix(rest, []byte("<<")):
+			depth := 0
+			for i := 0; i+1 < len(rest); i++ {
+				switch string(rest[i : i+2]) {
+				case "<<":
+					depth++
+					i++
+				case ">>":
+					depth--
+					i++
+					if depth == 0 {
+						return rest[:i+1]
+					}
+				}
+			}
+			return rest
+		case bytes.HasPrefix(rest, []byte("[")):
+			if end := bytes.IndexByte(rest, ']'); end >= 0 {
+				return rest[:end+1]
+			}
+			return rest
+		case bytes.HasPrefix(rest, []byte("/")):
+			end := 1
+			for end < len(rest) && !isPDFSpace(rest[end]) && !isPDFDelim(rest[end]) {
+				end++
+			}
+			return rest[:end]
+		}
+		end := bytes.IndexAny(rest, "/>")
+		if end < 0 {
+			end = len(rest)
+		}
+		return bytes.TrimSpace(rest[:end])
+	}
+}
+
+// pdfCMap is a parsed ToUnicode CMap: character codes (as raw bytes) to
+// the text they stand for.
+type pdfCMap struct {
+	lengths []int // code lengths in bytes, shortest first
+	chars   map[string]string
+	ranges  []pdfCMapRange
+}
+
+type pdfCMapRange struct {
+	lo, hi uint32
+	size   int
+	base   []uint16 // destination of lo, incremented across the range
+	dsts   []string // or one destination per code
+}
+
+// parseCMap reads the codespace ranges and bfchar/bfrange mappings of a
+// ToUnicode CMap, ignoring everything else.
+func parseCMap(data []byte) *pdfCMap {
+	cm := &pdfCMap{chars: map[string]string{}}
+	var operands []any
+	lx := &pdfLexer{data: data}
+	for {
+		tok, ok := lx.next()
+		if !ok {
+			break
+		}
+		op, isOp := tok.(pdfOperator)
+		if !isOp {
+			operands = append(operands, tok)
+			continue
+		}
+		switch op {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				if lo, ok := operands[i].(pdfString); ok && len(lo.raw) > 0 && !slices.Contains(cm.lengths, len(lo.raw)) {
+					cm.lengths = append(cm.lengths, len(lo.raw))
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					cm.chars[string(src.raw)] = decodeUTF16BE(dst.raw)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo.raw) != len(hi.raw) || len(lo.raw) == 0 || len(lo.raw) > 4 {
+					continue
+				}
+				r := pdfCMapRange{lo: codeValue(lo.raw), hi: codeValue(hi.raw), size: len(lo.raw)}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					for j := 0; j+1 < len(dst.raw); j += 2 {
+						r.base = append(r.base, uint16(dst.raw[j])<<8|uint16(dst.raw[j+1]))
+					}
+				case []any:
+					for _, el := range dst {
+						if s, ok := el.(pdfString); ok {
+							r.dsts = append(r.dsts, decodeUTF16BE(s.raw))
+						}
+					}
+				}
+				if r.hi >= r.lo && (len(r.base) > 0 || len(r.dsts) > 0) {
+					cm.ranges = append(cm.ranges, r)
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	if len(cm.lengths) == 0 {
+		for src := range cm.chars {
+			if !slices.Contains(cm.lengths, len(src)) {
+				cm.lengths = append(cm.lengths, len(src))
+			}
+		}
+		for _, r := range cm.ranges {
+			if !slices.Contains(cm.lengths, r.size) {
+				cm.lengths = append(cm.lengths, r.size)
+			}
+		}
+	}
+	slices.Sort(cm.lengths)
+	return cm
+}
+
+func codeValue(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+func (cm *pdfCMap) lookup(code []byte) (string, bool) {
+	if s, ok := cm.chars[string(code)]; ok {
+		return s, true
+	}
+	v := codeValue(code)
+	for _, r := range cm.ranges {
+		if r.size != len(code) || v < r.lo || v > r.hi {
+			continue
+		}
+		off := v - r.lo
+		if r.dsts != nil {
+			if int(off) < len(r.dsts) {
+				return r.dsts[off], true
+			}
+			return "", false
+		}
+		u := slices.Clone(r.base)
+		u[len(u)-1] += uint16(off)
+		return string(utf16.Decode(u)), true
+	}
+	return "", false
+}
+
+// decode maps a shown string through the CMap, trying the shortest code
+// length first. Codes the CMap does not cover are dropped.
+func (cm *pdfCMap) decode(b []byte) string {
+	lengths := cm.lengths
+	if len(lengths) == 0 {
+		lengths = []int{2}
+	}
+	var sb strings.Builder
+	for len(b) > 0 {
+		step := lengths[0]
+		for _, n := range lengths {
+			if n > len(b) {
+				break
+			}
+			if s, ok := cm.lookup(b[:n]); ok {
+				sb.WriteString(s)
+				step = n
+				break
+			}
+		}
+		b = b[min(step, len(b)):]
+	}
+	return sb.String()
+}
+
+// mostlyUnprintable reports whether over a fifth of the characters of text
+// are control or replacement characters, the mark of glyph codes decoded as
+// if they were text.
+func mostlyUnprintable(text string) bool {
+	total, bad := 0, 0
+	for _, r := range text {
+		total++
+		if r == utf8.RuneError || (unicode.IsControl(r) && r != '\n' && r != '\t') {
+			bad++
+		}
+	}
+	return total > 0 && bad*5 > total
+}
+
+// pdfDecodeStream returns the decoded stream if it is plain or Flate-encoded
+// and looks like a content stream.
+func pdfDecodeStream(dict, data []byte) ([]byte, bool) {
+	if bytes.Contains(dict, []byte("/Image")) || bytes.Contains(dict, []byte("/FontFile")) {
+		return nil, false
+	}
+	data, ok := pdfInflate(dict, data)
+	if !ok || !bytes.Contains(data, []byte("BT")) {
+		return nil, false
+	}
+	return data, true
+}
+
+// pdfInflate decodes a plain or Flate-encoded stream.
+func pdfInflate(dict, data []byte) ([]byte, bool) {
+	if bytes.Contains(dict, []byte("/FlateDecode")) {
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, false
+		}
+		// Keep whatever inflated before a checksum or truncation error.
+		decoded, _ := io.ReadAll(io.LimitReader(zr, 20<<20))
+		zr.Close()
+		return decoded, true
+	}
+	if bytes.Contains(dict, []byte("/Filter")) {
+		return nil, false // DCT, LZW, ASCII85 … not supported
+	}
+	return data, true
+}
+
+// pdfContentText interprets the text operators of one content stream,
+// decoding strings through the selected font's ToUnicode map. It also
+// returns how many strings were left out because their font is CID-keyed
+// and has no such map.
+func pdfContentText(content []byte, fonts map[string]*pdfFont) (string, int) {
+	var sb strings.Builder
+	var operands []any // pdfString, float64, pdfName or []any
+	lastY, haveY := 0.0, false
+	var font *pdfFont
+	skipped := 0
+
+	show := func(s pdfString) {
+		switch {
+		case font != nil && font.cmap != nil:
+			sb.WriteString(font.cmap.decode(s.raw))
+		case font != nil && font.cid:
+			skipped++
+		default:
+			sb.WriteString(s.text())
+		}
+	}
+
+	newline := func() {
+		s := sb.String()
+		if len(s) > 0 && !strings.HasSuffix(s, "\n") {
+			sb.WriteByte('\n')
+		}
+	}
+
+	lx := &pdfLexer{data: content}
+	for {
+		tok, ok := lx.next()
+		if !ok {
+			break
+		}
+		op, isOp := tok.(pdfOperator)
+		if !isOp {
+			operands = append(operands, tok)
+			continue
+		}
+		switch op {
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[len(operands)-2].(pdfName); ok {
+					font = fonts[string(name)]
+				}
+			}
+		case "Tj":
+			if s, ok := lastOperand[pdfString](operands); ok {
+				show(s)
+			}
+		case "'", "\"":
+			newline()
+			if s, ok := lastOperand[pdfString](operands); ok {
+				show(s)
+			}
+		case "TJ":
+			if arr, ok := lastOperand[[]any](operands); ok {
+				for _, el := range arr {
+					switch v := el.(type) {
+					case pdfString:
+						show(v)
+					case float64:
+						// Large negative kerning is how many producers encode spaces.
+						if v < -200 {
+							sb.WriteByte(' ')
+						}
+					}
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, ok := operands[len(operands)-1].(float64); ok && ty != 0 {
+					newline()
+				} else {
+					sb.WriteByte(' ')
+				}
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				if y, ok := operands[len(operands)-1].(float64); ok {
+					if haveY && y != lastY {
+						newline()
+					} else if haveY {
+						sb.WriteByte(' ')
+					}
+					lastY, haveY = y, true
+				}
+			}
+		case "T*":
+			newline()
+		case "ET":
+			sb.WriteByte(' ')
+		case "BI":
+			lx.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+	s := sb.String()
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	return s, skipped
+}
+
+func lastOperand[T any](operands []any) (T, bool) {
+	var zero T
+	if len(operands) == 0 {
+		return zero, false
+	}
+	v, ok := operands[len(operands)-1].(T)
+	return v, ok
+}
+
+// collapseBlankLines trims trailing spaces and squeezes runs of spaces and
+// blank lines.
+func collapseBlankLines(s string) string {
+	lines := strings.Split(s, "\n")
+	out := lines[:0]
+	blank := false
+	for _, l := range lines {
+		l = strings.Join(strings.Fields(l), " ")
+		if l == "" {
+			if blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		out = append(out, l)
+	}
+	return strings.Join(out, "\n")
+}
+
+// pdfOperator is a bare keyword in a content stream (Tj, BT, cm …).
+type pdfOperator string
+
+// pdfLexer tokenises a PDF content stream into operands and operators.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelim(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// next returns the next token: a pdfString, float64, []any (array), pdfName
+// or pdfOperator.
+func (lx *pdfLexer) next() (any, bool) {
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		switch {
+		case isPDFSpace(c):
+			lx.pos++
+		case c == '%':
+			for lx.pos < len(lx.data) && lx.data[lx.pos] != '\n' && lx.data[lx.pos] != '\r' {
+				lx.pos++
+			}
+		case c == '(':
+			lx.pos++
+			return lx.literalString(), true
+		case c == '<' && lx.pos+1 < len(lx.data) && lx.data[lx.pos+1] == '<':
+			lx.pos += 2
+			return pdfOperator("<<"), true
+		case c == '>' && lx.pos+1 < len(lx.data) && lx.data[lx.pos+1] == '>':
+			lx.pos += 2
+			return pdfOperator(">>"), true
+		case c == '<':
+			lx.pos++
+			return lx.hexString(), true
+		case c == '[':
+			lx.pos++
+			var arr []any
+			for {
+				tok, ok := lx.next()
+				if !ok || tok == pdfOperator("]") {
+					return arr, true
+				}
+				arr = append(arr, tok)
+			}
+		case c == ']':
+			lx.pos++
+			return pdfOperator("]"), true
+		case c == '/':
+			start := lx.pos
+			lx.pos++
+			for lx.pos < len(lx.data) && !isPDFSpace(lx.data[lx.pos]) && !isPDFDelim(lx.data[lx.pos]) {
+				lx.pos++
+			}
+			return pdfName(lx.data[start:lx.pos]), true
+		default:
+			start := lx.pos
+			for lx.pos < len(lx.data) && !isPDFSpace(lx.data[lx.pos]) && !isPDFDelim(lx.data[lx.pos]) {
+				lx.pos++
+			}
+			if lx.pos == start { // stray delimiter such as '{' or ')'
+				lx.pos++
+				continue
+			}
+			word := string(lx.data[start:lx.pos])
+			if f, err := strconv.ParseFloat(word, 64); err == nil {
+				return f, true
+			}
+			return pdfOperator(word), true
+		}
+	}
+	return nil, false
+}
+
+// pdfName is a /Name operand; it is never shown as text.
+type pdfName string
+
+// pdfString is a string operand as raw bytes, so that it can be decoded
+// through the font that shows it.
+type pdfString struct {
+	raw []byte
+	hex bool
+}
+
+// text decodes s for fonts without a ToUnicode map. Two-byte hex strings
+// whose high bytes are all zero are almost always UTF-16-ish glyph codes
+// for Latin text.
+func (s pdfString) text() string {
+	if s.hex && len(s.raw) >= 2 && len(s.raw)%2 == 0 {
+		allLatin := true
+		for i := 0; i < len(s.raw); i += 2 {
+			if s.raw[i] != 0 {
+				allLatin = false
+				break
+			}
+		}
+		if allLatin {
+			return decodeUTF16BE(s.raw)
+		}
+	}
+	return decodePDFString(s.raw)
+}
+
+func (lx *pdfLexer) literalString() pdfString {
+	var buf []byte
+	depth := 1
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		lx.pos++
+		switch c {
+		case '(':
+			depth++
+			buf = append(buf, c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfString{raw: buf}
+			}
+			buf = append(buf, c)
+		case '\\':
+			if lx.pos >= len(lx.data) {
+				return pdfString{raw: buf}
+			}
+			e := lx.data[lx.pos]
+			lx.pos++
+			switch e {
+			case 'n':
+				buf = append(buf, '\n')
+			case 'r':
+				buf = append(buf, '\r')
+			case 't':
+				buf = append(buf, '\t')
+			case 'b':
+				buf = append(buf, '\b')
+			case 'f':
+				buf = append(buf, '\f')
+			case '\r':
+				if lx.pos < len(lx.data) && lx.data[lx.pos] == '\n' {
+					lx.pos++
+				}
+			case '\n':
+				// line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && lx.pos < len(lx.data) && lx.data[lx.pos] >= '0' && lx.data[lx.pos] <= '7'; i++ {
+						n = n*8 + int(lx.data[lx.pos]-'0')
+						lx.pos++
+					}
+					buf = append(buf, byte(n))
+				} else {
+					buf = append(buf, e)
+				}
+			}
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return pdfString{raw: buf}
+}
+
+func (lx *pdfLexer) hexString() pdfString {
+	var digits []byte
+	for lx.pos < len(lx.data) && lx.data[lx.pos] != '>' {
+		if c := lx.data[lx.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		lx.pos++
+	}
+	lx.pos++ // '>'
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	buf := make([]byte, 0, len(digits)/2)
+	for i := 0; i+1 < len(digits); i += 2 {
+		b, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			return pdfString{hex: true}
+		}
+		buf = append(buf, byte(b))
+	}
+	return pdfString{raw: buf, hex: true}
+}
+
+// skipInlineImage advances past the binary data of a BI … ID … EI block.
+func (lx *pdfLexer) skipInlineImage() {
+	if idx := bytes.Index(lx.data[lx.pos:], []byte("ID")); idx >= 0 {
+		lx.pos += idx + 2
+	}
+	for lx.pos < len(lx.data) {
+		idx := bytes.Index(lx.data[lx.pos:], []byte("EI"))
+		if idx < 0 {
+			lx.pos = len(lx.data)
+			return
+		}
+		at := lx.pos + idx
+		lx.pos = at + 2
+		if at > 0 && isPDFSpace(lx.data[at-1]) && (lx.pos >= len(lx.data) || isPDFSpace(lx.data[lx.pos])) {
+			return
+		}
+	}
+}
+
+// decodePDFString decodes a text string: UTF-16BE when it carries a BOM,
+// otherwise PDFDocEncoding, approximated here as Latin-1.
+func decodePDFString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		return decodeUTF16BE(b[2:])
+	}
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+func decodeUTF16BE(b []byte) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,502 @@
+package tools
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mcp-server/internal/mcp"
+)
+
+// file_zip packs workspace files into a .zip, .tar.gz or .tar archive and
+// file_unzip unpacks one. Both stay inside the workspace: entries whose
+// names would land outside the destination ("zip slip") are rejected before
+// anything is written, links and special files are skipped, and extraction
+// stops at FILE_UNZIP_MAX_BYTES of decompressed data (default 100 MiB) and
+// FILE_UNZIP_MAX_FILES entries (default 1000), whatever the archive's
+// headers claim. Written files count against the workspace quota.
+
+const maxListedEntries = 200
+
+// archiveFormat infers an archive format from a file name, unless the
+// caller named one explicitly.
+func archiveFormat(name, explicit string) (string, error) {
+	if explicit != "" {
+		switch f := strings.ToLower(strings.TrimPrefix(explicit, ".")); f {
+		case "zip", "tar", "tar.gz":
+			return f, nil
+		case "tgz":
+			return "tar.gz", nil
+		}
+		return "", fmt.Errorf("unknown archive format %q: use zip, tar.gz or tar", explicit)
+	}
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip", nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz", nil
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar", nil
+	}
+	return "", fmt.Errorf("cannot tell the archive format of %q: name it .zip, .tar.gz or .tar, or pass format", name)
+}
+
+// archiveWriter builds an archive in memory, failing once it grows past
+// limit bytes.
+type archiveWriter struct {
+	buf   bytes.Buffer
+	limit int64
+	zw    *zip.Writer
+	gz    *gzip.Writer
+	tw    *tar.Writer
+}
+
+var errArchiveTooLarge = errors.New("archive too large")
+
+func newArchiveWriter(format string, limit int64) *archiveWriter {
+	a := &archiveWriter{limit: limit}
+	w := io.Writer(&limitedBuffer{a})
+	switch format {
+	case "zip":
+		a.zw = zip.NewWriter(w)
+	case "tar.gz":
+		a.gz = gzip.NewWriter(w)
+		a.tw = tar.NewWriter(a.gz)
+	default:
+		a.tw = tar.NewWriter(w)
+	}
+	return a
+}
+
+type limitedBuffer struct{ a *archiveWriter }
+
+func (l *limitedBuffer) Write(p []byte) (int, error) {
+	if int64(l.a.buf.Len()+len(p)) > l.a.limit {
+		return 0, errArchiveTooLarge
+	}
+	return l.a.buf.Write(p)
+}
+
+func (a *archiveWriter) add(name string, info fs.FileInfo, data []byte) error {
+	if a.zw != nil {
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		hdr.Method = zip.Deflate
+		w, err := a.zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	hdr.Size = int64(len(data))
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = a.tw.Write(data)
+	return err
+}
+
+func (a *archiveWriter) close() ([]byte, error) {
+	if a.zw != nil {
+		if err := a.zw.Close(); err != nil {
+			return nil, err
+		}
+		return a.buf.Bytes(), nil
+	}
+	if err := a.tw.Close(); err != nil {
+		return nil, err
+	}
+	if a.gz != nil {
+		if err := a.gz.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return a.buf.Bytes(), nil
+}
+
+// fileZip archives files and directories from the workspace into a new
+// archive file in the workspace.
+func fileZip(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
+	output, errResult, err := requireString(args, "output")
+	if errResult != nil {
+		return *errResult, err
+	}
+	outRel, errResult, err := safePath(output)
+	if errResult != nil {
+		return *errResult, err
+	}
+	format, err := archiveFormat(output, optionalString(args, "format", ""))
+	if err != nil {
+		return textErr(err.Error())
+	}
+	paths := optionalStringList(args, "paths")
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	exclude := optionalStringList(args, "exclude")
+
+	// Collect the files first so the archive lists them in a stable order.
+	outKey := slashRel(outRel)
+	found := map[string]fs.FileInfo{}
+	for _, p := range paths {
+		rel, errResult, err := safePath(p)
+		if errResult != nil {
+			return *errResult, err
+		}
+		info, err := ws.root.Lstat(rel)
+		if err != nil {
+			return textErr(fmt.Sprintf("%q not found in the workspace", p))
+		}
+		if !info.IsDir() {
+			if info.Mode().IsRegular() {
+				found[slashRel(rel)] = info
+			}
+			continue
+		}
+		err = walkWorkspace(ws, rel, nil, exclude, func(rel string, info fs.FileInfo) error {
+			found[rel] = info
+			return nil
+		})
+		if err != nil {
+			return workspaceWalkErr(err)
+		}
+	}
+	delete(found, outKey)
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return textErr("no files to archive")
+	}
+
+	quota := quotaFromEnv()
+	aw := newArchiveWriter(format, quota.maxFileBytes)
+	var total int64
+	skipped := []string{}
+	for _, name := range names {
+		data, readErr := ws.readFile(name)
+		if readErr != nil {
+			// Hard-linked files and files removed since the walk.
+			skipped = append(skipped, name)
+			continue
+		}
+		if err = aw.add(name, found[name], data); err != nil {
+			break
+		}
+		total += int64(len(data))
+	}
+	var data []byte
+	if err == nil {
+		data, err = aw.close()
+	}
+	if errors.Is(err, errArchiveTooLarge) {
+		return textErr(fmt.Sprintf("archive would exceed the per-file limit of %d bytes; archive fewer files", quota.maxFileBytes))
+	}
+	if err != nil {
+		return textErr(fmt.Sprintf("create archive failed: %v", err))
+	}
+	if err := writeWorkspaceFile(ws, outRel, data, "zip"); err != nil {
+		return textErr(fmt.Sprintf("write archive failed: %v", err))
+	}
+
+	return textResult(map[string]any{
+		"ok":            true,
+		"path":          output,
+		"format":        format,
+		"files":         len(names) - len(skipped),
+		"bytes":         total,
+		"archive_bytes": len(data),
+		"skipped":       skipped,
+	})
+}
+
+// archiveEntry is one member of an archive being read.
+type archiveEntry struct {
+	name    string
+	dir     bool
+	regular bool
+	size    int64 // as declared by the archive; not trusted
+}
+
+// eachArchiveEntry calls fn for every member of the archive in f, with a
+// reader for its content (regular files only).
+func eachArchiveEntry(f *os.File, size int64, format string, fn func(archiveEntry, io.Reader) error) error {
+	if format == "zip" {
+		zr, err := zip.NewReader(f, size)
+		if err != nil {
+			return err
+		}
+		for _, zf := range zr.File {
+			mode := zf.Mode()
+			e := archiveEntry{
+				name:    zf.Name,
+				dir:     mode.IsDir() || strings.HasSuffix(zf.Name, "/"),
+				regular: mode.IsRegular() && !strings.HasSuffix(zf.Name, "/"),
+				size:    int64(zf.UncompressedSize64),
+			}
+			var r io.ReadCloser
+			if e.regular {
+				if r, err = zf.Open(); err != nil {
+					return err
+				}
+			}
+			err = fn(e, r)
+			if r != nil {
+				r.Close()
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var r io.Reader = f
+	if format == "tar.gz" {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		e := archiveEntry{
+			name:    hdr.Name,
+			dir:     hdr.Typeflag == tar.TypeDir,
+			regular: hdr.Typeflag == tar.TypeReg,
+			size:    hdr.Size,
+		}
+		if err := fn(e, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// entryTarget maps an archive member name to a workspace path under dest,
+// or reports that it would land outside it.
+func entryTarget(dest, name string) (string, bool) {
+	clean := path.Clean(strings.ReplaceAll(name, `\`, "/"))
+	if clean == "." {
+		return dest, true // "./" entries in tarballs
+	}
+	if strings.HasPrefix(clean, "/") || !filepath.IsLocal(filepath.FromSlash(clean)) {
+		return "", false
+	}
+	rel, errResult, _ := safePath(filepath.Join(dest, filepath.FromSlash(clean)))
+	if errResult != nil {
+		return "", false
+	}
+	return rel, true
+}
+
+// fileUnzip extracts an archive from the workspace into a workspace
+// directory. The archive is checked in full before anything is written.
+func fileUnzip(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
+	archivePath, errResult, err := requireString(args, "path")
+	if errResult != nil {
+		return *errResult, err
+	}
+	rel, errResult, err := safePath(archivePath)
+	if errResult != nil {
+		return *errResult, err
+	}
+	format, err := archiveFormat(archivePath, optionalString(args, "format", ""))
+	if err != nil {
+		return textErr(err.Error())
+	}
+	defaultDest := filepath.Join(filepath.Dir(rel), archiveBaseName(filepath.Base(rel)))
+	destArg := optionalString(args, "dest", defaultDest)
+	dest, errResult, err := safePath(destArg)
+	if errResult != nil {
+		return *errResult, err
+	}
+	overwrite := optionalBool(args, "overwrite", false)
+	maxBytes := envInt64("FILE_UNZIP_MAX_BYTES", 100<<20)
+	maxFiles := int(envInt64("FILE_UNZIP_MAX_FILES", 1000))
+
+	f, info, err := ws.open(rel)
+	if err != nil {
+		return textErr(fmt.Sprintf("open archive failed: %v", err))
+	}
+	defer f.Close()
+	if info.IsDir() {
+		return textErr(fmt.Sprintf("%q is a directory", archivePath))
+	}
+
+	// Pass 1: validate every entry name and the declared sizes.
+	var declared int64
+	files := 0
+	var existing []string
+	sizes := map[string]int64{}
+	err = eachArchiveEntry(f, info.Size(), format, func(e archiveEntry, _ io.Reader) error {
+		target, ok := entryTarget(dest, e.name)
+		if !ok {
+			return fmt.Errorf("entry %q would be extracted outside %q; nothing was extracted", e.name, destArg)
+		}
+		if !e.regular {
+			return nil
+		}
+		files++
+		declared += max(e.size, 0)
+		sizes[target] = max(e.size, 0)
+		if !overwrite {
+			if _, err := ws.root.Lstat(target); err == nil {
+				existing = append(existing, slashRel(target))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return textErr(fmt.Sprintf("unsafe or unreadable archive: %v", err))
+	}
+	if files > maxFiles {
+		return textErr(fmt.Sprintf("archive has %d files, over the extraction limit of %d", files, maxFiles))
+	}
+	if declared > maxBytes {
+		return textErr(fmt.Sprintf("archive expands to %d bytes, over the extraction limit of %d", declared, maxBytes))
+	}
+	if len(existing) > 0 {
+		if len(existing) > 5 {
+			existing = append(existing[:5], "...")
+		}
+		return textErr(fmt.Sprintf("extraction would overwrite existing files (%s); pass overwrite or choose another dest", strings.Join(existing, ", ")))
+	}
+	// The workspace quota is checked up front too, so that an archive too
+	// big for the workspace is refused instead of half extracted.
+	quota := quotaFromEnv()
+	for target, size := range sizes {
+		if err := quota.checkFile(target, size); err != nil {
+			return textErr(fmt.Sprintf("%s: %v; nothing was extracted", slashRel(target), err))
+		}
+	}
+	if err := quota.checkTotals(ws, sizes); err != nil {
+		return textErr(fmt.Sprintf("%v; nothing was extracted", err))
+	}
+
+	// Pass 2: extract, enforcing the limits on the actual decompressed data.
+	perFile := quota.maxFileBytes
+	var written int64
+	extracted := []string{}
+	skipped := []string{}
+	err = eachArchiveEntry(f, info.Size(), format, func(e archiveEntry, r io.Reader) error {
+		target, _ := entryTarget(dest, e.name)
+		switch {
+		case e.dir:
+			return ws.root.MkdirAll(target, 0o755)
+		case !e.regular:
+			skipped = append(skipped, e.name)
+			return nil
+		}
+		remaining := maxBytes - written
+		data, err := io.ReadAll(io.LimitReader(r, min(remaining, perFile)+1))
+		if err != nil {
+			return err
+		}
+		if int64(len(data)) > remaining {
+			return fmt.Errorf("decompressed data exceeds the extraction limit of %d bytes", maxBytes)
+		}
+		if err := writeWorkspaceFile(ws, target, data, "unzip"); err != nil {
+			return fmt.Errorf("%s: %w", e.name, err)
+		}
+		written += int64(len(data))
+		extracted = append(extracted, slashRel(target))
+		return nil
+	})
+	if err != nil {
+		return textErr(fmt.Sprintf("extraction stopped after %d files: %v", len(extracted), err))
+	}
+
+	result := map[string]any{
+		"ok":        true,
+		"path":      archivePath,
+		"dest":      slashRel(dest),
+		"format":    format,
+		"files":     len(extracted),
+		"bytes":     written,
+		"skipped":   skipped,
+		"truncated": len(extracted) > maxListedEntries,
+	}
+	if len(extracted) > maxListedEntries {
+		extracted = extracted[:maxListedEntries]
+	}
+	result["extracted"] = extracted
+	return textResult(result)
+}
+
+// archiveBaseName strips a known archive extension: "data.tar.gz" → "data".
+func archiveBaseName(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".tar.gz", ".tgz", ".zip", ".tar"} {
+		if strings.HasSuffix(lower, ext) && len(name) > len(ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name + ".d"
+}
+
+func fileArchiveDefinitions() []mcp.ToolDefinition {
+	return []mcp.ToolDefinition{
+		{
+			Name:        "file_zip",
+			Description: "Pack files and directories from the agent's workspace into a .zip, .tar.gz or .tar archive in the workspace, e.g. to package deliverables. Entries keep their workspace-relative paths. The archive counts against the per-file size limit.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"output":  {Type: "string", Description: `Relative path of the archive to create, e.g. "out/report.zip". Its extension selects the format.`},
+					"paths":   {Type: "string", Description: `Comma-separated files and directories to include, e.g. "report.md,charts". Defaults to the whole workspace.`},
+					"exclude": {Type: "string", Description: `Comma-separated globs for files or directories to leave out, e.g. "*.tmp,crawl".`},
+					"format":  {Type: "string", Description: `"zip", "tar.gz" or "tar"; overrides the output extension.`},
+				},
+				Required: []string{"output"},
+			},
+		},
+		{
+			Name:        "file_unzip",
+			Description: "Extract a .zip, .tar.gz/.tgz or .tar archive from the agent's workspace into a workspace directory, e.g. to unpack a downloaded dataset. Entries that would land outside the destination make the whole extraction fail; links are skipped. Extraction is capped in total size and file count, and existing files are not overwritten unless asked.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"path":      {Type: "string", Description: "Relative path to the archive."},
+					"dest":      {Type: "string", Description: `Directory to extract into (default: next to the archive, named after it, e.g. "data.zip" → "data").`},
+					"overwrite": {Type: "boolean", Description: "Replace files that already exist in dest (default false: fail instead)."},
+					"format":    {Type: "string", Description: `"zip", "tar.gz" or "tar"; overrides the archive extension.`},
+				},
+				Required: []string{"path"},
+			},
+		},
+	}
+}
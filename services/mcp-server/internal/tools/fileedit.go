@@ -0,0 +1,396 @@
+package tools
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mcp-server/internal/mcp"
+)
+
+// readForEdit resolves and reads an existing workspace file for the editing
+// tools.
+func readForEdit(ws *workspace, args map[string]any) (path, rel, content string, errResult *mcp.ToolCallResult, err error) {
+	path, errResult, err = requireString(args, "path")
+	if errResult != nil {
+		return
+	}
+	rel, errResult, err = safePath(path)
+	if errResult != nil {
+		return
+	}
+	data, rerr := readEditable(ws, rel)
+	if rerr != nil {
+		r, e := textErr(fmt.Sprintf("read file failed: %v", rerr))
+		return "", "", "", &r, e
+	}
+	return path, rel, string(data), nil, nil
+}
+
+// readEditable reads a file the editing tools will rewrite. Files over the
+// per-file quota are refused up front rather than loaded into memory.
+func readEditable(ws *workspace, rel string) ([]byte, error) {
+	f, info, err := ws.open(rel)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if limit := quotaFromEnv().maxFileBytes; info.Size() > limit {
+		return nil, fmt.Errorf("file is %d bytes, over the %d byte limit for editing", info.Size(), limit)
+	}
+	return io.ReadAll(f)
+}
+
+func saveEdit(ws *workspace, op, path, rel, content string, extra map[string]any) (mcp.ToolCallResult, error) {
+	if err := writeWorkspaceFile(ws, rel, []byte(content), op); err != nil {
+		return textErr(fmt.Sprintf("write file failed: %v", err))
+	}
+	result := map[string]any{"ok": true, "path": path, "bytes": len(content)}
+	for k, v := range extra {
+		result[k] = v
+	}
+	return textResult(result)
+}
+
+// fileEdit replaces an exact string. Unless replace_all is set, old_string
+// must occur exactly once so the agent cannot edit the wrong spot.
+func fileEdit(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
+	oldString, errResult, err := requireString(args, "old_string")
+	if errResult != nil {
+		return *errResult, err
+	}
+	// An empty new_string deletes old_string, but it must be given.
+	newString, ok := args["new_string"].(string)
+	if !ok {
+		return textErr(`missing required argument: "new_string"`)
+	}
+	path, rel, content, errResult, err := readForEdit(ws, args)
+	if errResult != nil {
+		return *errResult, err
+	}
+
+	n := strings.Count(content, oldString)
+	switch {
+	case n == 0:
+		return textErr(fmt.Sprintf("old_string not found in %s", path))
+	case n > 1 && !optionalBool(args, "replace_all", false):
+		return textErr(fmt.Sprintf("old_string occurs %d times in %s; include more surrounding text to make it unique, or set replace_all", n, path))
+	}
+	return saveEdit(ws, "edit", path, rel, strings.ReplaceAll(content, oldString, newString), map[string]any{"replacements": n})
+}
+
+// fileAppend adds content to the end of a file, creating it if needed.
+func fileAppend(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
+	path, errResult, err := requireString(args, "path")
+	if errResult != nil {
+		return *errResult, err
+	}
+	content, errResult, err := requireString(args, "content")
+	if errResult != nil {
+		return *errResult, err
+	}
+	rel, errResult, err := safePath(path)
+	if errResult != nil {
+		return *errResult, err
+	}
+	existing, err := readEditable(ws, rel)
+	if err != nil && !os.IsNotExist(err) {
+		return textErr(fmt.Sprintf("read file failed: %v", err))
+	}
+	return saveEdit(ws, "append", path, rel, string(existing)+content, map[string]any{"appended": len(content)})
+}
+
+// splitLines splits s into lines that keep their "\n" terminators, so
+// joining them restores s exactly.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// fileInsertLines inserts content before a 1-based line number; line one
+// past the last line appends.
+func fileInsertLines(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
+	content, errResult, err := requireString(args, "content")
+	if errResult != nil {
+		return *errResult, err
+	}
+	path, rel, existing, errResult, err := readForEdit(ws, args)
+	if errResult != nil {
+		return *errResult, err
+	}
+	lines := splitLines(existing)
+	at := int(optionalFloat(args, "line", float64(len(lines)+1)))
+	if at < 1 || at > len(lines)+1 {
+		return textErr(fmt.Sprintf("line %d out of range: %s has %d lines", at, path, len(lines)))
+	}
+	if !strings.HasSuffix(content, "\n") && at <= len(lines) {
+		content += "\n"
+	}
+	if at > 1 && !strings.HasSuffix(lines[at-2], "\n") {
+		lines[at-2] += "\n"
+	}
+	inserted := splitLines(content)
+	out := append(append(append([]string{}, lines[:at-1]...), inserted...), lines[at-1:]...)
+	return saveEdit(ws, "insert_lines", path, rel, strings.Join(out, ""), map[string]any{"lines_inserted": len(inserted), "line": at})
+}
+
+// fileDeleteLines removes the inclusive 1-based range start_line..end_line.
+func fileDeleteLines(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
+	path, rel, existing, errResult, err := readForEdit(ws, args)
+	if errResult != nil {
+		return *errResult, err
+	}
+	lines := splitLines(existing)
+	start := int(optionalFloat(args, "start_line", 0))
+	end := int(optionalFloat(args, "end_line", float64(start)))
+	if start < 1 || end < start || end > len(lines) {
+		return textErr(fmt.Sprintf("invalid line range %d-%d: %s has %d lines", start, end, path, len(lines)))
+	}
+	out := append(append([]string{}, lines[:start-1]...), lines[end:]...)
+	return saveEdit(ws, "delete_lines", path, rel, strings.Join(out, ""), map[string]any{"lines_deleted": end - start + 1})
+}
+
+// ── unified diff ────────────────────────────────────────────────────────────
+
+var reHunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// diffHunk is one "@@" section of a unified diff.
+type diffHunk struct {
+	oldStart int
+	oldLines []string // context and removed lines, without terminators
+	newLines []string // context and added lines, without terminators
+	noEOL    bool     // the last new line has no trailing newline
+}
+
+// parseUnifiedDiff reads the hunks of a single-file unified diff. Each hunk
+// ends once the line counts in its header are used up, so anything between
+// hunks ("---"/"+++" file headers, "diff" and "index" lines) is ignored.
+func parseUnifiedDiff(patch string) ([]diffHunk, error) {
+	var hunks []diffHunk
+	var cur *diffHunk
+	oldLeft, newLeft := 0, 0
+	lastAdded := false
+	for i, line := range strings.Split(strings.TrimSuffix(patch, "\n"), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.HasPrefix(line, `\`) {
+			if cur != nil && lastAdded {
+				cur.noEOL = true
+			}
+			continue
+		}
+		if cur == nil || (oldLeft == 0 && newLeft == 0) {
+			m := reHunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			start, _ := strconv.Atoi(m[1])
+			oldLeft, newLeft = hunkCount(m[2]), hunkCount(m[4])
+			hunks = append(hunks, diffHunk{oldStart: start})
+			cur = &hunks[len(hunks)-1]
+			continue
+		}
+
+		op, text := byte(' '), "" // a blank line is an empty context line
+		if line != "" {
+			op, text = line[0], line[1:]
+		}
+		switch op {
+		case ' ':
+			cur.oldLines = append(cur.oldLines, text)
+			cur.newLines = append(cur.newLines, text)
+			oldLeft--
+			newLeft--
+		case '-':
+			cur.oldLines = append(cur.oldLines, text)
+			oldLeft--
+		case '+':
+			cur.newLines = append(cur.newLines, text)
+			newLeft--
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q in hunk", i+1, line)
+		}
+		if oldLeft < 0 || newLeft < 0 {
+			return nil, fmt.Errorf("line %d: hunk has more lines than its @@ header says", i+1)
+		}
+		lastAdded = op != '-'
+	}
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("no hunks found; expected unified diff with @@ headers")
+	}
+	if oldLeft > 0 || newLeft > 0 {
+		return nil, fmt.Errorf("last hunk is shorter than its @@ header says")
+	}
+	return hunks, nil
+}
+
+// hunkCount parses the optional ",count" of a hunk range, which defaults to 1.
+func hunkCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// applyHunks applies hunks in order. Each hunk's old lines must match the
+// file exactly; when line numbers have drifted the nearest matching position
+// is used, as patch(1) does.
+func applyHunks(content string, hunks []diffHunk) (string, error) {
+	lines := splitLines(content)
+	bare := make([]string, len(lines))
+	for i, l := range lines {
+		bare[i] = strings.TrimSuffix(strings.TrimSuffix(l, "\n"), "\r")
+	}
+	crlf := len(lines) > 0 && strings.HasSuffix(lines[0], "\r\n")
+	eol := "\n"
+	if crlf {
+		eol = "\r\n"
+	}
+
+	var out []string
+	pos, offset := 0, 0
+	for n, h := range hunks {
+		want := h.oldStart - 1 + offset
+		if len(h.oldLines) == 0 {
+			want = h.oldStart + offset // pure insertion after oldStart
+		}
+		at := findBlock(bare, h.oldLines, max(want, pos), pos)
+		if at < 0 {
+			return "", fmt.Errorf("hunk %d (@@ -%d) does not apply: context not found", n+1, h.oldStart)
+		}
+		out = append(out, lines[pos:at]...)
+		for i, l := range h.newLines {
+			if i == len(h.newLines)-1 && h.noEOL {
+				out = append(out, l)
+			} else {
+				out = append(out, l+eol)
+			}
+		}
+		pos = at + len(h.oldLines)
+		offset = at - (h.oldStart - 1)
+	}
+	out = append(out, lines[pos:]...)
+	return strings.Join(out, ""), nil
+}
+
+// findBlock returns the index nearest to want (and not before floor) at which
+// block occurs in lines, or -1.
+func findBlock(lines, block []string, want, floor int) int {
+	matches := func(at int) bool {
+		if at < floor || at+len(block) > len(lines) {
+			return false
+		}
+		for i, b := range block {
+			if lines[at+i] != b {
+				return false
+			}
+		}
+		return true
+	}
+	for d := 0; d <= len(lines); d++ {
+		if matches(want + d) {
+			return want + d
+		}
+		if d > 0 && matches(want-d) {
+			return want - d
+		}
+	}
+	return -1
+}
+
+// filePatch applies a unified diff to one workspace file. Either every hunk
+// applies or the file is left untouched.
+func filePatch(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
+	patch, errResult, err := requireString(args, "patch")
+	if errResult != nil {
+		return *errResult, err
+	}
+	path, rel, content, errResult, err := readForEdit(ws, args)
+	if errResult != nil {
+		return *errResult, err
+	}
+	hunks, err := parseUnifiedDiff(patch)
+	if err != nil {
+		return textErr(fmt.Sprintf("invalid patch: %v", err))
+	}
+	patched, err := applyHunks(content, hunks)
+	if err != nil {
+		return textErr(fmt.Sprintf("patch failed, %s unchanged: %v", path, err))
+	}
+	return saveEdit(ws, "patch", path, rel, patched, map[string]any{"hunks_applied": len(hunks)})
+}
+
+func fileEditDefinitions() []mcp.ToolDefinition {
+	return []mcp.ToolDefinition{
+		{
+			Name:        "file_edit",
+			Description: "Replace an exact string in a workspace file without re-sending the whole file. old_string must match exactly once (include surrounding lines to make it unique) unless replace_all is true. The write is atomic.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"path":        {Type: "string", Description: "Relative path to the file."},
+					"old_string":  {Type: "string", Description: "The exact text to replace, including whitespace and line breaks."},
+					"new_string":  {Type: "string", Description: "The replacement text (may be empty to delete old_string)."},
+					"replace_all": {Type: "boolean", Description: "Replace every occurrence instead of requiring a unique match (default false)."},
+				},
+				Required: []string{"path", "old_string", "new_string"},
+			},
+		},
+		{
+			Name:        "file_append",
+			Description: "Append text to the end of a workspace file, creating it if it doesn't exist. Use for logs and incrementally built reports. The write is atomic.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"path":    {Type: "string", Description: "Relative path to the file."},
+					"content": {Type: "string", Description: "The text to append. Include a trailing newline if the next append should start on a new line."},
+				},
+				Required: []string{"path", "content"},
+			},
+		},
+		{
+			Name:        "file_insert_lines",
+			Description: "Insert text into a workspace file before a given line number. The write is atomic.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"path":    {Type: "string", Description: "Relative path to the file."},
+					"line":    {Type: "number", Description: "1-based line to insert before. Use the line count plus one (the default) to append."},
+					"content": {Type: "string", Description: "The lines to insert."},
+				},
+				Required: []string{"path", "content"},
+			},
+		},
+		{
+			Name:        "file_delete_lines",
+			Description: "Delete an inclusive range of lines from a workspace file. The write is atomic.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"path":       {Type: "string", Description: "Relative path to the file."},
+					"start_line": {Type: "number", Description: "First line to delete (1-based)."},
+					"end_line":   {Type: "number", Description: "Last line to delete (inclusive). Defaults to start_line."},
+				},
+				Required: []string{"path", "start_line"},
+			},
+		},
+		{
+			Name:        "file_patch",
+			Description: "Apply a unified diff (as produced by diff -u or git diff) to a workspace file. Every hunk's context and removed lines must match the file; hunks whose line numbers have drifted are placed at the nearest match. If any hunk fails the file is left unchanged. The write is atomic.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"path":  {Type: "string", Description: "Relative path to the file to patch."},
+					"patch": {Type: "string", Description: "Unified diff for this one file, with @@ -l,s +l,s @@ hunk headers. ---/+++ file headers are optional and ignored."},
+				},
+				Required: []string{"path", "patch"},
+			},
+		},
+	}
+}
@@ -0,0 +1,439 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"mcp-server/internal/mcp"
+)
+
+// historyDir is the reserved directory at the workspace root that holds file
+// history. The file tools refuse paths inside it and hide it from listings.
+//
+//	.history/objects/<sha256>    file contents, stored once per distinct content
+//	.history/logs/<sha256>.json  version list of one file, keyed by its path
+//
+// Configuration (all optional):
+//   - FILE_HISTORY_MAX_VERSIONS  versions kept per file, default 20; 0 disables history
+//   - FILE_HISTORY_MAX_BYTES     total size of stored contents, default 100 MiB;
+//     the oldest versions (never a file's latest) are dropped beyond it
+const historyDir = ".history"
+
+// historyMu serialises history updates; logs are read-modify-written.
+var historyMu sync.Mutex
+
+// fileVersion is one recorded state of a workspace file.
+type fileVersion struct {
+	Version int       `json:"version"`
+	Hash    string    `json:"hash"`
+	Bytes   int       `json:"bytes"`
+	Time    time.Time `json:"time"`
+	Op      string    `json:"op"`
+}
+
+type historyLog struct {
+	Path     string        `json:"path"`
+	Versions []fileVersion `json:"versions"` // oldest first
+}
+
+func historyMaxVersions() int { return int(envInt64("FILE_HISTORY_MAX_VERSIONS", 20)) }
+
+// writeWorkspaceFile atomically writes data to rel and records the new
+// content in the file history under op (e.g. "write", "edit"). When the
+// file's current content was never recorded — it predates history or was
+// changed outside the tools — that content is saved first so the write can
+// still be undone. History failures never fail the write itself.
+//
+// The write is refused with an *errQuota when it would break the workspace
+// quota (see workspaceQuota).
+func writeWorkspaceFile(ws *workspace, rel string, data []byte, op string) error {
+	if err := quotaFromEnv().check(ws, rel, int64(len(data))); err != nil {
+		return err
+	}
+	prevSize, existed := ws.fileSize(rel)
+	if historyMaxVersions() <= 0 {
+		if err := ws.writeFileAtomic(rel, data); err != nil {
+			return err
+		}
+		noteWrite(ws, prevSize, existed, int64(len(data)))
+		return nil
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	key := slashRel(rel)
+	if prev, err := ws.readFile(rel); err == nil {
+		recordVersionLocked(ws, key, prev, "external")
+	}
+	if err := ws.writeFileAtomic(rel, data); err != nil {
+		return err
+	}
+	noteWrite(ws, prevSize, existed, int64(len(data)))
+	recordVersionLocked(ws, key, data, op)
+	return nil
+}
+
+func historyLogPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return path.Join(historyDir, "logs", hex.EncodeToString(sum[:])+".json")
+}
+
+func historyObjectPath(hash string) string {
+	return path.Join(historyDir, "objects", hash)
+}
+
+func loadHistory(ws *workspace, key string) historyLog {
+	h := historyLog{Path: key}
+	if b, err := ws.readFile(historyLogPath(key)); err == nil {
+		_ = json.Unmarshal(b, &h)
+	}
+	return h
+}
+
+func saveHistory(ws *workspace, h historyLog) error {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return ws.writeFileAtomic(historyLogPath(h.Path), b)
+}
+
+// recordVersionLocked appends data as the newest version of the file key
+// (its slash-separated workspace path) unless it is already the newest.
+func recordVersionLocked(ws *workspace, key string, data []byte, op string) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	idx := historyIndexLocked(ws)
+	h := loadHistory(ws, key)
+	if n := len(h.Versions); n > 0 && h.Versions[n-1].Hash == hash {
+		return
+	}
+
+	obj := historyObjectPath(hash)
+	if _, err := ws.stat(obj); err != nil {
+		if ws.writeFileAtomic(obj, data) != nil {
+			return
+		}
+	}
+	next := 1
+	if n := len(h.Versions); n > 0 {
+		next = h.Versions[n-1].Version + 1
+	}
+	h.Versions = append(h.Versions, fileVersion{Version: next, Hash: hash, Bytes: len(data), Time: time.Now().UTC(), Op: op})
+	var dropped []fileVersion
+	if keep := historyMaxVersions(); len(h.Versions) > keep {
+		dropped = h.Versions[:len(h.Versions)-keep]
+		h.Versions = h.Versions[len(h.Versions)-keep:]
+	}
+	if saveHistory(ws, h) != nil {
+		return
+	}
+	idx.ref(hash, int64(len(data)))
+	for _, v := range dropped {
+		idx.unref(ws, v.Hash)
+	}
+	if maxBytes := envInt64("FILE_HISTORY_MAX_BYTES", 100<<20); idx.bytes > maxBytes {
+		// Prune a little below the limit so that a workspace sitting at it
+		// does not rescan every log on each write.
+		pruneHistoryLocked(ws, idx, maxBytes-maxBytes/10)
+	}
+}
+
+// historyIndex counts, for one workspace, the versions referring to each
+// stored object and the total size of referenced objects, so a write does
+// not have to re-read every log. It is built by one scan on first use and
+// then kept current by recordVersionLocked.
+type historyIndex struct {
+	refs  map[string]int   // object hash → versions using it
+	sizes map[string]int64 // object hash → size
+	bytes int64            // total size of referenced objects
+}
+
+// historyIndexes holds the index of each workspace by directory; guarded by
+// historyMu.
+var historyIndexes = map[string]*historyIndex{}
+
+func historyIndexLocked(ws *workspace) *historyIndex {
+	if idx := historyIndexes[ws.dir]; idx != nil {
+		return idx
+	}
+	idx := &historyIndex{refs: map[string]int{}, sizes: map[string]int64{}}
+	for _, h := range loadAllHistory(ws) {
+		for _, v := range h.Versions {
+			idx.ref(v.Hash, int64(v.Bytes))
+		}
+	}
+	// Objects left behind by an interrupted write are removed once here.
+	objects, _ := ws.readDir(path.Join(historyDir, "objects"))
+	for _, o := range objects {
+		if idx.refs[o.Name()] == 0 {
+			ws.root.Remove(historyObjectPath(o.Name()))
+		}
+	}
+	historyIndexes[ws.dir] = idx
+	return idx
+}
+
+// forgetHistoryIndex drops the index of a workspace directory that was
+// removed, so a workspace later created under the same name starts afresh.
+func forgetHistoryIndex(dir string) {
+	historyMu.Lock()
+	delete(historyIndexes, dir)
+	historyMu.Unlock()
+}
+
+func (idx *historyIndex) ref(hash string, size int64) {
+	if idx.refs[hash] == 0 {
+		idx.sizes[hash] = size
+		idx.bytes += size
+	}
+	idx.refs[hash]++
+}
+
+// unref drops one reference to hash and deletes the object once no version
+// uses it.
+func (idx *historyIndex) unref(ws *workspace, hash string) {
+	if idx.refs[hash]--; idx.refs[hash] > 0 {
+		return
+	}
+	idx.bytes -= idx.sizes[hash]
+	delete(idx.refs, hash)
+	delete(idx.sizes, hash)
+	ws.root.Remove(historyObjectPath(hash))
+}
+
+// loadAllHistory reads every history log of ws.
+func loadAllHistory(ws *workspace) []historyLog {
+	logDir := path.Join(historyDir, "logs")
+	entries, _ := ws.readDir(logDir)
+	var logs []historyLog
+	for _, e := range entries {
+		var h historyLog
+		if b, err := ws.readFile(path.Join(logDir, e.Name())); err == nil && json.Unmarshal(b, &h) == nil {
+			logs = append(logs, h)
+		}
+	}
+	return logs
+}
+
+// pruneHistoryLocked drops the oldest non-latest versions across all files
+// until the stored contents fit in target bytes.
+func pruneHistoryLocked(ws *workspace, idx *historyIndex, target int64) {
+	logs := loadAllHistory(ws)
+	changed := map[int]bool{}
+	for idx.bytes > target {
+		// Find the globally oldest version that is not a file's latest.
+		oldest, oi := time.Time{}, -1
+		for i, h := range logs {
+			if len(h.Versions) > 1 && (oi < 0 || h.Versions[0].Time.Before(oldest)) {
+				oldest, oi = h.Versions[0].Time, i
+			}
+		}
+		if oi < 0 {
+			break
+		}
+		idx.unref(ws, logs[oi].Versions[0].Hash)
+		logs[oi].Versions = logs[oi].Versions[1:]
+		changed[oi] = true
+	}
+	for i := range changed {
+		_ = saveHistory(ws, logs[i])
+	}
+}
+
+// historyFor resolves the path argument and loads its history.
+func historyFor(ws *workspace, args map[string]any) (path, rel string, h historyLog, errResult *mcp.ToolCallResult, err error) {
+	path, errResult, err = requireString(args, "path")
+	if errResult != nil {
+		return
+	}
+	rel, errResult, err = safePath(path)
+	if errResult != nil {
+		return
+	}
+	historyMu.Lock()
+	h = loadHistory(ws, slashRel(rel))
+	historyMu.Unlock()
+	return path, rel, h, nil, nil
+}
+
+// versionContent returns the content of a version given as a number, or the
+// file's current content for "current".
+func versionContent(ws *workspace, rel string, h historyLog, which string) ([]byte, string, error) {
+	if which == "current" {
+		b, err := ws.readFile(rel)
+		return b, "current", err
+	}
+	n, err := strconv.Atoi(which)
+	if err != nil {
+		return nil, "", fmt.Errorf("version must be a number or \"current\", got %q", which)
+	}
+	for _, v := range h.Versions {
+		if v.Version == n {
+			b, err := ws.readFile(historyObjectPath(v.Hash))
+			return b, "v" + which, err
+		}
+	}
+	return nil, "", fmt.Errorf("version %d not found (it may have been pruned); see file_history", n)
+}
+
+func fileHistory(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
+	path, _, h, errResult, err := historyFor(ws, args)
+	if errResult != nil {
+		return *errResult, err
+	}
+	type versionInfo struct {
+		Version int    `json:"version"`
+		Bytes   int    `json:"bytes"`
+		Time    string `json:"time"`
+		Op      string `json:"op"`
+		Hash    string `json:"hash"`
+	}
+	versions := make([]versionInfo, 0, len(h.Versions))
+	for _, v := range h.Versions {
+		versions = append(versions, versionInfo{v.Version, v.Bytes, v.Time.Format(time.RFC3339), v.Op, v.Hash[:12]})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version > versions[j].Version })
+	return textResult(map[string]any{
+		"path":     path,
+		"versions": versions,
+		"count":    len(versions),
+	})
+}
+
+// versionArg reads a version argument that may be a JSON number or a string.
+func versionArg(args map[string]any, key, def string) string {
+	switch v := args[key].(type) {
+	case float64:
+		return strconv.Itoa(int(v))
+	case string:
+		if v != "" {
+			return v
+		}
+	}
+	return def
+}
+
+func fileDiff(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
+	path, rel, h, errResult, err := historyFor(ws, args)
+	if errResult != nil {
+		return *errResult, err
+	}
+	to := versionArg(args, "to", "current")
+	from := versionArg(args, "from", "")
+	if from == "" {
+		// Default to the version before "to".
+		if len(h.Versions) == 0 {
+			return textErr(fmt.Sprintf("%s has no history", path))
+		}
+		prev := h.Versions[len(h.Versions)-1]
+		if to != "current" {
+			n, _ := strconv.Atoi(to)
+			prev = fileVersion{}
+			for _, v := range h.Versions {
+				if v.Version < n {
+					prev = v
+				}
+			}
+			if prev.Version == 0 {
+				return textErr(fmt.Sprintf("no version of %s before %s", path, to))
+			}
+		} else if cur, err := ws.readFile(rel); err == nil && len(h.Versions) > 1 {
+			if sum := sha256.Sum256(cur); hex.EncodeToString(sum[:]) == prev.Hash {
+				prev = h.Versions[len(h.Versions)-2]
+			}
+		}
+		from = strconv.Itoa(prev.Version)
+	}
+
+	a, aName, err := versionContent(ws, rel, h, from)
+	if err != nil {
+		return textErr(fmt.Sprintf("diff failed: %v", err))
+	}
+	b, bName, err := versionContent(ws, rel, h, to)
+	if err != nil {
+		return textErr(fmt.Sprintf("diff failed: %v", err))
+	}
+	diff := unifiedDiff(path+"@"+aName, path+"@"+bName, string(a), string(b))
+	diff, truncated := truncateText(diff, fetchMaxText)
+	return textResult(map[string]any{
+		"path":      path,
+		"from":      aName,
+		"to":        bName,
+		"identical": diff == "",
+		"diff":      diff,
+		"truncated": truncated,
+	})
+}
+
+func fileRestore(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
+	path, rel, h, errResult, err := historyFor(ws, args)
+	if errResult != nil {
+		return *errResult, err
+	}
+	which := versionArg(args, "version", "")
+	if which == "" || which == "current" {
+		return textErr(`missing required argument: "version"`)
+	}
+	data, name, err := versionContent(ws, rel, h, which)
+	if err != nil {
+		return textErr(fmt.Sprintf("restore failed: %v", err))
+	}
+	if err := writeWorkspaceFile(ws, rel, data, "restore "+name); err != nil {
+		return textErr(fmt.Sprintf("write file failed: %v", err))
+	}
+	return textResult(map[string]any{
+		"ok":       true,
+		"path":     path,
+		"restored": name,
+		"bytes":    len(data),
+	})
+}
+
+func fileHistoryDefinitions() []mcp.ToolDefinition {
+	return []mcp.ToolDefinition{
+		{
+			Name:        "file_history",
+			Description: "List the saved versions of a workspace file, newest first. Every write, edit, patch and restore records a version, so earlier content can be compared with file_diff or brought back with file_restore. Old versions are pruned beyond the retention limits.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"path": {Type: "string", Description: "Relative path to the file."},
+				},
+				Required: []string{"path"},
+			},
+		},
+		{
+			Name:        "file_diff",
+			Description: "Show a unified diff between two versions of a workspace file. With no arguments besides path, shows what the latest change did.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"path": {Type: "string", Description: "Relative path to the file."},
+					"from": {Type: "number", Description: "Version to diff from. Defaults to the version before \"to\"."},
+					"to":   {Type: "string", Description: `Version number to diff to, or "current" for the file as it is now (default).`},
+				},
+				Required: []string{"path"},
+			},
+		},
+		{
+			Name:        "file_restore",
+			Description: "Restore a workspace file to an earlier version from file_history. The restore is itself recorded, so it can be undone the same way.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"path":    {Type: "string", Description: "Relative path to the file."},
+					"version": {Type: "number", Description: "Version number to restore."},
+				},
+				Required: []string{"path", "version"},
+			},
+		},
+	}
+}
@@ -1,9 +1,16 @@
 package tools
 
 import (
+	"bufio"
+	"encoding/base64"
 	"fmt"
+	"io"
+	"mime"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"unicode/utf8"
 
 	"mcp-server/internal/mcp"
 )
@@ -17,55 +24,235 @@ func workDir() string {
 	return "./agent-workspace"
 }
 
-// safePath resolves p relative to workDir and ensures it doesn't escape via
-// path traversal. Returns an error result if the path is unsafe.
+// safePath cleans p into a path relative to the workspace root and rejects
+// lexical traversal ("../..") and the reserved history directory with a
+// friendly error result. Leading slashes are dropped, so "/notes.md" means
+// the workspace's notes.md. Confinement against symlinks and concurrent
+// changes is enforced by the workspace's os.Root, not here.
 func safePath(p string) (string, *mcp.ToolCallResult, error) {
-	root, err := filepath.Abs(workDir())
-	if err != nil {
-		r, e := textErr(fmt.Sprintf("cannot resolve work dir: %v", err))
+	rel := strings.TrimLeft(filepath.Clean(p), string(filepath.Separator))
+	if rel == "" {
+		rel = "."
+	}
+	if rel != "." && !filepath.IsLocal(rel) {
+		r, e := textErr(fmt.Sprintf("path %q escapes the workspace", p))
 		return "", &r, e
 	}
-
-	abs, err := filepath.Abs(filepath.Join(root, p))
-	if err != nil {
-		r, e := textErr(fmt.Sprintf("cannot resolve path: %v", err))
+	if rel == historyDir || strings.HasPrefix(rel, historyDir+string(filepath.Separator)) {
+		r, e := textErr(fmt.Sprintf("path %q is reserved for file history; use file_history, file_diff or file_restore", p))
 		return "", &r, e
 	}
+	return rel, nil, nil
+}
 
-	// Prevent path traversal outside the work directory.
-	rel, err := filepath.Rel(root, abs)
-	if err != nil || len(rel) >= 2 && rel[:2] == ".." {
-		r, e := textErr(fmt.Sprintf("path %q escapes the workspace", p))
+// fileReadMaxBytes caps how much file_read returns in one call. Larger files
+// are read in pieces with offset/length or start_line/end_line. Override
+// with FILE_READ_MAX_BYTES.
+func fileReadMaxBytes() int64 {
+	return envInt64("FILE_READ_MAX_BYTES", 256*1024)
+}
+
+// encodingArg reads the "encoding" argument: "text" (UTF-8, the default) or
+// "base64" for binary content.
+func encodingArg(args map[string]any) (string, *mcp.ToolCallResult, error) {
+	enc := strings.ToLower(optionalString(args, "encoding", "text"))
+	if enc != "text" && enc != "base64" {
+		r, e := textErr(fmt.Sprintf("unknown encoding %q: use \"text\" or \"base64\"", enc))
 		return "", &r, e
 	}
-
-	return abs, nil, nil
+	return enc, nil, nil
 }
 
-func fileRead(args map[string]any) (mcp.ToolCallResult, error) {
+// fileRead returns a file's content, or a byte or line range of it, without
+// loading more than fileReadMaxBytes into memory.
+func fileRead(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
 	path, errResult, err := requireString(args, "path")
 	if errResult != nil {
 		return *errResult, err
 	}
 
-	abs, errResult, err := safePath(path)
+	rel, errResult, err := safePath(path)
+	if errResult != nil {
+		return *errResult, err
+	}
+	enc, errResult, err := encodingArg(args)
 	if errResult != nil {
 		return *errResult, err
 	}
 
-	data, err := os.ReadFile(abs)
+	f, info, err := ws.open(rel)
 	if err != nil {
 		return textErr(fmt.Sprintf("read file failed: %v", err))
 	}
+	defer f.Close()
+	if info.IsDir() {
+		return textErr(fmt.Sprintf("%q is a directory; use file_list", path))
+	}
+	limit := fileReadMaxBytes()
 
-	return textResult(map[string]any{
-		"path":    path,
-		"content": string(data),
-		"bytes":   len(data),
-	})
+	if _, ok := args["start_line"]; ok {
+		if enc == "base64" {
+			return textErr("start_line/end_line read text; use offset and length with base64")
+		}
+		return readLines(f, path, info.Size(), args, limit)
+	}
+
+	offset := int64(optionalFloat(args, "offset", 0))
+	if offset < 0 || offset > info.Size() {
+		return textErr(fmt.Sprintf("offset %d out of range: %s is %d bytes", offset, path, info.Size()))
+	}
+	length := int64(optionalFloat(args, "length", float64(limit)))
+	if length < 1 {
+		return textErr(fmt.Sprintf("length must be at least 1, got %d", length))
+	}
+	length = min(length, limit)
+	data, err := io.ReadAll(io.NewSectionReader(f, offset, length))
+	if err != nil {
+		return textErr(fmt.Sprintf("read file failed: %v", err))
+	}
+	end := offset + int64(len(data))
+	if end < info.Size() && enc == "text" {
+		// Don't split a UTF-8 sequence; the next read starts at next_offset.
+		// A length shorter than the first character still returns it whole,
+		// so paging always moves forward.
+		if trimmed := trimPartialRune(data); len(trimmed) > 0 {
+			data = trimmed
+		} else {
+			head := make([]byte, utf8.UTFMax)
+			n, _ := f.ReadAt(head, offset)
+			_, size := utf8.DecodeRune(head[:n])
+			data = head[:max(size, 1)]
+		}
+		end = offset + int64(len(data))
+	}
+
+	result := map[string]any{
+		"path":      path,
+		"encoding":  enc,
+		"bytes":     len(data),
+		"size":      info.Size(),
+		"offset":    offset,
+		"truncated": end < info.Size(),
+	}
+	if enc == "base64" {
+		result["content"] = base64.StdEncoding.EncodeToString(data)
+	} else {
+		result["content"] = string(data)
+		if !utf8.Valid(data) {
+			result["note"] = `content is not valid UTF-8 and was altered; read it with encoding "base64"`
+		}
+	}
+	if end < info.Size() {
+		result["next_offset"] = end
+	}
+	return textResult(result)
 }
 
-func fileWrite(args map[string]any) (mcp.ToolCallResult, error) {
+// readLines returns lines start_line..end_line (1-based, inclusive) of f,
+// stopping early if the byte limit is reached. Lines are read in buffer-sized
+// pieces, so a huge line never sits in memory whole: one that would not fit
+// ends the result, and if it is the first line it is clipped at the limit
+// and next_offset says where a byte-range read picks it up.
+func readLines(f *os.File, path string, size int64, args map[string]any, limit int64) (mcp.ToolCallResult, error) {
+	start := int(optionalFloat(args, "start_line", 1))
+	end := int(optionalFloat(args, "end_line", 0)) // 0 = to the end of the file
+	if start < 1 || (end != 0 && end < start) {
+		return textErr(fmt.Sprintf("invalid line range %d-%d", start, end))
+	}
+
+	r := bufio.NewReader(f)
+	var b []byte
+	var pos int64 // file offset of the next unread byte
+	line, last := 0, 0
+	more := false
+	nextOffset := int64(-1)
+	for end == 0 || line < end {
+		lineStart := pos
+		var cur []byte
+		n, overflow := 0, false
+		var err error
+		for {
+			var chunk []byte
+			chunk, err = r.ReadSlice('\n')
+			n += len(chunk)
+			pos += int64(len(chunk))
+			if line+1 >= start {
+				cur = append(cur, chunk...)
+				if int64(len(b)+len(cur)) > limit {
+					overflow = true
+					break
+				}
+			}
+			if err != bufio.ErrBufferFull {
+				break
+			}
+		}
+		if n == 0 {
+			break
+		}
+		line++
+		if overflow {
+			more = true
+			if len(b) == 0 {
+				clipped := trimPartialRune(cur[:limit])
+				if len(clipped) == 0 {
+					_, size := utf8.DecodeRune(cur)
+					clipped = cur[:max(size, 1)]
+				}
+				b = clipped
+				last = line
+				nextOffset = lineStart + int64(len(clipped))
+			}
+			break
+		}
+		if line >= start {
+			b = append(b, cur...)
+			last = line
+		}
+		if err != nil {
+			break
+		}
+	}
+	if !more && end != 0 && line >= end {
+		_, err := r.Peek(1)
+		more = err == nil
+	}
+	if last == 0 && start > 1 {
+		return textErr(fmt.Sprintf("start_line %d is past the end of %s (%d lines)", start, path, line))
+	}
+
+	result := map[string]any{
+		"path":       path,
+		"content":    string(b),
+		"bytes":      len(b),
+		"size":       size,
+		"start_line": start,
+		"end_line":   last,
+		"truncated":  more,
+	}
+	if more {
+		result["next_line"] = last + 1
+	}
+	if nextOffset >= 0 {
+		result["next_offset"] = nextOffset
+	}
+	return textResult(result)
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence from the end of b.
+func trimPartialRune(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+func fileWrite(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
 	path, errResult, err := requireString(args, "path")
 	if errResult != nil {
 		return *errResult, err
@@ -75,36 +262,45 @@ func fileWrite(args map[string]any) (mcp.ToolCallResult, error) {
 		return *errResult, err
 	}
 
-	abs, errResult, err := safePath(path)
+	rel, errResult, err := safePath(path)
 	if errResult != nil {
 		return *errResult, err
 	}
-
-	// Ensure parent directories exist.
-	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
-		return textErr(fmt.Sprintf("create directories failed: %v", err))
+	enc, errResult, err := encodingArg(args)
+	if errResult != nil {
+		return *errResult, err
+	}
+	data := []byte(content)
+	if enc == "base64" {
+		// Tolerate line-wrapped base64 as produced by most encoders.
+		data, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(content), ""))
+		if err != nil {
+			return textErr(fmt.Sprintf("content is not valid base64: %v", err))
+		}
 	}
 
-	if err := os.WriteFile(abs, []byte(content), 0o644); err != nil {
+	// Parent directories are created; the write is atomic (temp + rename)
+	// and the previous content stays available through file_history.
+	if err := writeWorkspaceFile(ws, rel, data, "write"); err != nil {
 		return textErr(fmt.Sprintf("write file failed: %v", err))
 	}
 
 	return textResult(map[string]any{
 		"ok":    true,
 		"path":  path,
-		"bytes": len(content),
+		"bytes": len(data),
 	})
 }
 
-func fileList(args map[string]any) (mcp.ToolCallResult, error) {
+func fileList(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
 	path := optionalString(args, "path", ".")
 
-	abs, errResult, err := safePath(path)
+	rel, errResult, err := safePath(path)
 	if errResult != nil {
 		return *errResult, err
 	}
 
-	entries, err := os.ReadDir(abs)
+	entries, err := ws.readDir(rel)
 	if err != nil {
 		return textErr(fmt.Sprintf("list directory failed: %v", err))
 	}
@@ -113,18 +309,25 @@ func fileList(args map[string]any) (mcp.ToolCallResult, error) {
 		Name  string `json:"name"`
 		IsDir bool   `json:"is_dir"`
 		Bytes int64  `json:"bytes,omitempty"`
+		MIME  string `json:"mime,omitempty"`
 	}
 	var files []entry
 	for _, e := range entries {
+		if rel == "." && e.Name() == historyDir {
+			continue
+		}
 		info, _ := e.Info()
 		var size int64
-		if info != nil && !e.IsDir() {
+		var mimeType string
+		if info != nil && info.Mode().IsRegular() {
 			size = info.Size()
+			mimeType = detectMIME(ws, filepath.Join(rel, e.Name()))
 		}
 		files = append(files, entry{
 			Name:  e.Name(),
 			IsDir: e.IsDir(),
 			Bytes: size,
+			MIME:  mimeType,
 		})
 	}
 
@@ -135,34 +338,56 @@ func fileList(args map[string]any) (mcp.ToolCallResult, error) {
 	})
 }
 
+// detectMIME guesses a file's media type from its extension, falling back
+// to sniffing its first 512 bytes. It returns "" if the file can't be read.
+func detectMIME(ws *workspace, rel string) string {
+	if t := mime.TypeByExtension(filepath.Ext(rel)); t != "" {
+		return t
+	}
+	f, _, err := ws.open(rel)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	return http.DetectContentType(head[:n])
+}
+
 func fileDefinitions() []mcp.ToolDefinition {
 	return []mcp.ToolDefinition{
 		{
 			Name:        "file_read",
-			Description: "Read the contents of a file from the agent's workspace. Paths are relative to the workspace root and cannot escape it.",
+			Description: "Read the contents of a file from the agent's workspace. Paths are relative to the workspace root and cannot escape it. Large files are returned in pieces: a truncated result gives next_offset (or next_line) to continue from; start_line/end_line read a line range, e.g. around a file_search match.",
 			InputSchema: mcp.JSONSchema{
 				Type: "object",
 				Properties: map[string]mcp.Property{
-					"path": {Type: "string", Description: "Relative path to the file (e.g. \"data/report.txt\")."},
+					"path":       {Type: "string", Description: "Relative path to the file (e.g. \"data/report.txt\")."},
+					"offset":     {Type: "number", Description: "Byte offset to start reading at (default 0). Use the next_offset of a truncated read to continue."},
+					"length":     {Type: "number", Description: "Maximum bytes to return, at least 1 (default and max 256 KB unless configured otherwise). A text read returns at least one whole character."},
+					"start_line": {Type: "number", Description: "Read by lines instead of bytes, starting at this 1-based line."},
+					"end_line":   {Type: "number", Description: "Last line to return (inclusive) when start_line is given. Defaults to the end of the file."},
+					"encoding":   {Type: "string", Description: `"text" (default) or "base64" to read binary files such as images or archives.`},
 				},
 				Required: []string{"path"},
 			},
 		},
 		{
 			Name:        "file_write",
-			Description: "Write content to a file in the agent's workspace, creating it (and any parent directories) if it doesn't exist. Overwrites existing files.",
+			Description: "Write content to a file in the agent's workspace, creating it (and any parent directories) if it doesn't exist. Overwrites existing files. Binary content can be written base64-encoded.",
 			InputSchema: mcp.JSONSchema{
 				Type: "object",
 				Properties: map[string]mcp.Property{
-					"path":    {Type: "string", Description: "Relative path to write to (e.g. \"output/summary.txt\")."},
-					"content": {Type: "string", Description: "The text content to write."},
+					"path":     {Type: "string", Description: "Relative path to write to (e.g. \"output/summary.txt\")."},
+					"content":  {Type: "string", Description: "The content to write: text, or base64 when encoding is \"base64\"."},
+					"encoding": {Type: "string", Description: `"text" (default) or "base64" to write binary content.`},
 				},
 				Required: []string{"path", "content"},
 			},
 		},
 		{
 			Name:        "file_list",
-			Description: "List the files and subdirectories in a directory within the agent's workspace.",
+			Description: "List the files and subdirectories in a directory within the agent's workspace, with each file's size and detected MIME type.",
 			InputSchema: mcp.JSONSchema{
 				Type: "object",
 				Properties: map[string]mcp.Property{
@@ -171,5 +396,37 @@ func fileDefinitions() []mcp.ToolDefinition {
 				Required: []string{},
 			},
 		},
+		{
+			Name:        "file_search",
+			Description: "Search the contents of files in the agent's workspace, recursively, and return matching lines with their file path and line number. Use instead of reading files one by one to find where something is mentioned. Binary files and files over 10 MB are skipped; output is capped at max_matches.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"pattern":       {Type: "string", Description: "Text to search for; a regular expression (Go RE2 syntax) when regex is true."},
+					"regex":         {Type: "boolean", Description: "Treat pattern as a regular expression (default false: literal text)."},
+					"ignore_case":   {Type: "boolean", Description: "Match case-insensitively (default false)."},
+					"path":          {Type: "string", Description: "Relative directory to search under. Defaults to the workspace root."},
+					"include":       {Type: "string", Description: `Comma-separated globs; only matching files are searched, e.g. "*.md,docs/**/*.txt". A glob without "/" matches file names at any depth.`},
+					"exclude":       {Type: "string", Description: `Comma-separated globs for files or directories to skip, e.g. "crawl,*.json".`},
+					"max_matches":   {Type: "number", Description: "Maximum matching lines to return (default 50, max 500)."},
+					"context_lines": {Type: "number", Description: "Lines of context to include before and after each match (default 0, max 10)."},
+				},
+				Required: []string{"pattern"},
+			},
+		},
+		{
+			Name:        "file_glob",
+			Description: "Find files in the agent's workspace by name pattern, recursively, e.g. \"**/*.csv\" or \"reports/*.md\". Returns workspace-relative paths with sizes, capped at max_results.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"pattern":     {Type: "string", Description: `Glob matched against workspace-relative paths. "*" matches within one path segment, "**" spans directories; a glob without "/" matches file names at any depth.`},
+					"path":        {Type: "string", Description: "Relative directory to search under. Defaults to the workspace root."},
+					"exclude":     {Type: "string", Description: "Comma-separated globs for files or directories to skip."},
+					"max_results": {Type: "number", Description: "Maximum files to return (default 200, max 1000)."},
+				},
+				Required: []string{"pattern"},
+			},
+		},
 	}
 }
@@ -0,0 +1,305 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+
+	"mcp-server/internal/mcp"
+)
+
+// Caps that keep file_search and file_glob output small enough for the
+// agent's context.
+const (
+	defaultSearchMatches = 50
+	maxSearchMatches     = 500
+	maxSearchContext     = 10
+	maxSearchLineRunes   = 500
+	maxSearchFileBytes   = 10 << 20
+	maxSearchLineBytes   = 1 << 20
+	maxSearchErrors      = 20
+	defaultGlobResults   = 200
+	maxGlobResults       = 1000
+)
+
+// searchMatch is one matching line, with optional surrounding lines.
+type searchMatch struct {
+	Path   string   `json:"path"`
+	Line   int      `json:"line"`
+	Text   string   `json:"text"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// fileSearch greps the workspace for a literal string or regular expression.
+// Binary files and files larger than maxSearchFileBytes are skipped; files
+// are scanned line by line rather than read whole. A file that cannot be
+// read to the end keeps the matches found before the failure and is listed
+// under errors.
+func fileSearch(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
+	pattern, errResult, err := requireString(args, "pattern")
+	if errResult != nil {
+		return *errResult, err
+	}
+	expr := pattern
+	if !optionalBool(args, "regex", false) {
+		expr = regexp.QuoteMeta(pattern)
+	}
+	if optionalBool(args, "ignore_case", false) {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return textErr(fmt.Sprintf("invalid regex %q: %v", pattern, err))
+	}
+
+	limit := int(optionalFloat(args, "max_matches", defaultSearchMatches))
+	limit = min(max(limit, 1), maxSearchMatches)
+	context := int(optionalFloat(args, "context_lines", 0))
+	context = min(max(context, 0), maxSearchContext)
+
+	// One match past the limit is looked for, so that truncated means more
+	// matches really exist.
+	matches := []searchMatch{}
+	var fileErrs []string
+	filesSearched := 0
+	err = walkWorkspace(ws, optionalString(args, "path", "."),
+		optionalStringList(args, "include"), optionalStringList(args, "exclude"),
+		func(rel string, info fs.FileInfo) error {
+			if info.Size() > maxSearchFileBytes {
+				return nil
+			}
+			found, err := searchFile(ws, rel, re, context, limit+1-len(matches))
+			if err != nil && len(fileErrs) < maxSearchErrors {
+				fileErrs = append(fileErrs, fmt.Sprintf("%s: %v", rel, err))
+			}
+			filesSearched++
+			matches = append(matches, found...)
+			if len(matches) > limit {
+				return fs.SkipAll
+			}
+			return nil
+		})
+	if err != nil {
+		return workspaceWalkErr(err)
+	}
+	truncated := len(matches) > limit
+	if truncated {
+		matches = matches[:limit]
+	}
+
+	result := map[string]any{
+		"pattern":        pattern,
+		"matches":        matches,
+		"match_count":    len(matches),
+		"files_searched": filesSearched,
+		"truncated":      truncated,
+	}
+	if len(fileErrs) > 0 {
+		result["errors"] = fileErrs
+	}
+	return textResult(result)
+}
+
+// searchFile scans one file and returns at most limit matches. On a read
+// error it returns the matches found so far along with the error.
+func searchFile(ws *workspace, rel string, re *regexp.Regexp, context, limit int) ([]searchMatch, error) {
+	f, _, err := ws.open(rel)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	if head, _ := br.Peek(8000); bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil // binary
+	}
+
+	var found []searchMatch
+	var before []string
+	var open []int // indexes of matches still collecting after-context
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 64*1024), maxSearchLineBytes)
+	for n := 1; scanner.Scan(); n++ {
+		line := clipLine(scanner.Text())
+
+		stillOpen := open[:0]
+		for _, i := range open {
+			found[i].After = append(found[i].After, line)
+			if len(found[i].After) < context {
+				stillOpen = append(stillOpen, i)
+			}
+		}
+		open = stillOpen
+
+		if len(found) < limit && re.MatchString(line) {
+			found = append(found, searchMatch{Path: rel, Line: n, Text: line, Before: append([]string(nil), before...)})
+			if context > 0 {
+				open = append(open, len(found)-1)
+			}
+		}
+		if len(found) == limit && len(open) == 0 {
+			break
+		}
+		if context > 0 {
+			before = append(before, line)
+			if len(before) > context {
+				before = before[1:]
+			}
+		}
+	}
+	err = scanner.Err()
+	if errors.Is(err, bufio.ErrTooLong) {
+		err = fmt.Errorf("a line is longer than %d bytes; the rest of the file was not searched", maxSearchLineBytes)
+	}
+	return found, err
+}
+
+func clipLine(s string) string {
+	if r := []rune(s); len(r) > maxSearchLineRunes {
+		return string(r[:maxSearchLineRunes]) + "…"
+	}
+	return s
+}
+
+// fileGlob lists workspace files whose path matches a glob, recursively.
+func fileGlob(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
+	pattern, errResult, err := requireString(args, "pattern")
+	if errResult != nil {
+		return *errResult, err
+	}
+	if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		return textErr(fmt.Sprintf("invalid glob %q: %v", pattern, err))
+	}
+	limit := int(optionalFloat(args, "max_results", defaultGlobResults))
+	limit = min(max(limit, 1), maxGlobResults)
+
+	type globEntry struct {
+		Path  string `json:"path"`
+		Bytes int64  `json:"bytes"`
+	}
+	files := []globEntry{}
+	truncated := false
+	err = walkWorkspace(ws, optionalString(args, "path", "."), []string{pattern},
+		optionalStringList(args, "exclude"),
+		func(rel string, info fs.FileInfo) error {
+			if len(files) == limit {
+				truncated = true
+				return fs.SkipAll
+			}
+			files = append(files, globEntry{Path: rel, Bytes: info.Size()})
+			return nil
+		})
+	if err != nil {
+		return workspaceWalkErr(err)
+	}
+
+	return textResult(map[string]any{
+		"pattern":   pattern,
+		"files":     files,
+		"count":     len(files),
+		"truncated": truncated,
+	})
+}
+
+// errWalkPath reports an unsafe or missing starting directory to
+// workspaceWalkErr.
+type errWalkPath struct{ result mcp.ToolCallResult }
+
+func (e *errWalkPath) Error() string { return e.result.Content[0].Text }
+
+// walkWorkspace calls fn for every regular file under dir (a workspace path)
+// whose workspace-relative path matches one of include (all files when
+// empty) and none of exclude. Excluded directories are not descended into,
+// and symlinks are never followed. Paths passed to fn use forward slashes and
+// are relative to the workspace root, so they can be handed straight to the
+// other file tools.
+func walkWorkspace(ws *workspace, dir string, include, exclude []string, fn func(rel string, info fs.FileInfo) error) error {
+	start, errResult, _ := safePath(dir)
+	if errResult != nil {
+		return &errWalkPath{*errResult}
+	}
+	if info, err := ws.stat(start); err != nil || !info.IsDir() {
+		r, _ := textErr(fmt.Sprintf("%q is not a directory in the workspace", dir))
+		return &errWalkPath{r}
+	}
+	start = slashRel(start)
+
+	return ws.walkDir(start, func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if rel != start && (rel == historyDir || matchAnyGlob(exclude, rel)) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if len(include) > 0 && !matchAnyGlob(include, rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		return fn(rel, info)
+	})
+}
+
+func workspaceWalkErr(err error) (mcp.ToolCallResult, error) {
+	if e, ok := err.(*errWalkPath); ok {
+		return e.result, nil
+	}
+	return textErr(fmt.Sprintf("walk workspace failed: %v", err))
+}
+
+func matchAnyGlob(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if matchGlob(p, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated path against a glob. A pattern without
+// a slash matches the base name at any depth ("*.md"); otherwise it matches
+// the whole path, where a "**" segment spans any number of directories
+// ("docs/**/*.md").
+func matchGlob(pattern, rel string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pat, parts []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pat[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], parts[0]); !ok {
+			return false
+		}
+		pat, parts = pat[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
@@ -3,6 +3,10 @@ package tools
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"mcp-server/internal/mcp"
 )
@@ -46,3 +50,55 @@ func optionalFloat(args map[string]any, key string, def float64) float64 {
 	}
 	return def
 }
+
+func optionalBool(args map[string]any, key string, def bool) bool {
+	if v, ok := args[key].(bool); ok {
+		return v
+	}
+	return def
+}
+
+// envInt64 reads an integer setting from the environment, falling back to def
+// when the variable is unset or malformed.
+func envInt64(key string, def int64) int64 {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// envDuration reads a Go duration (e.g. "15m") from the environment, falling
+// back to def when the variable is unset or malformed.
+func envDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return def
+}
+
+// optionalStringList accepts either a JSON array of strings or a single
+// comma-separated string and returns the trimmed, non-empty items.
+func optionalStringList(args map[string]any, key string) []string {
+	var raw []string
+	switch v := args[key].(type) {
+	case string:
+		raw = strings.Split(v, ",")
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				raw = append(raw, s)
+			}
+		}
+	}
+	var out []string
+	for _, s := range raw {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -16,7 +16,10 @@ var httpClient = &http.Client{Timeout: 30 * time.Second}
 
 // httpRequest makes a generic outbound HTTP call so the agent can hit any
 // external API without needing a dedicated tool per service.
-func httpRequest(args map[string]any) (mcp.ToolCallResult, error) {
+// GET requests without a body go through the shared response cache; the
+// request headers are part of the cache key. Any other method drops the
+// URL's cached responses, since it may have changed what a GET returns.
+func httpRequest(cache *responseCache, args map[string]any) (mcp.ToolCallResult, error) {
 	rawURL, errResult, err := requireString(args, "url")
 	if errResult != nil {
 		return *errResult, err
@@ -56,18 +59,30 @@ func httpRequest(args map[string]any) (mcp.ToolCallResult, error) {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return textErr(fmt.Sprintf("request failed: %v", err))
-	}
-	defer resp.Body.Close()
-
 	// Cap response at 100 KB.
 	const maxBytes = 100 * 1024
-	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes))
+	var entry *cacheEntry
+	var cached bool
+	if method == "GET" && bodyReader == nil {
+		key := "http:" + normalizeURL(rawURL) + "#" + headerFingerprint(req.Header)
+		entry, cached, err = cache.cachedGet("http_request", httpClient, req, key, maxBytes,
+			optionalBool(args, "no_cache", false))
+	} else {
+		var resp *http.Response
+		resp, err = httpClient.Do(req)
+		if err == nil {
+			entry, err = readResponse(resp, "", maxBytes)
+			resp.Body.Close()
+		}
+		if method != "GET" {
+			cache.invalidate("http:" + normalizeURL(rawURL))
+			cache.invalidate("fetch:" + normalizeURL(rawURL))
+		}
+	}
 	if err != nil {
-		return textErr(fmt.Sprintf("read response failed: %v", err))
+		return textErr(fmt.Sprintf("request failed: %v", err))
 	}
+	body := entry.Body
 
 	// Try to parse the response as JSON for a cleaner result.
 	var parsedBody any
@@ -76,17 +91,12 @@ func httpRequest(args map[string]any) (mcp.ToolCallResult, error) {
 		parsedBody = string(body)
 	}
 
-	// Collect response headers.
-	headers := make(map[string]string, len(resp.Header))
-	for k, vs := range resp.Header {
-		headers[k] = strings.Join(vs, ", ")
-	}
-
 	return textResult(map[string]any{
-		"status":    resp.StatusCode,
-		"headers":   headers,
+		"status":    entry.Status,
+		"headers":   entry.Headers,
 		"body":      parsedBody,
-		"truncated": len(body) == maxBytes,
+		"truncated": entry.Truncated,
+		"cached":    cached,
 	})
 }
 
@@ -98,10 +108,11 @@ func httpDefinitions() []mcp.ToolDefinition {
 			InputSchema: mcp.JSONSchema{
 				Type: "object",
 				Properties: map[string]mcp.Property{
-					"url":     {Type: "string", Description: "The full URL to call (must include https://)."},
-					"method":  {Type: "string", Description: `HTTP method: "GET", "POST", "PUT", "PATCH", "DELETE". Defaults to "GET".`},
-					"headers": {Type: "object", Description: "Optional map of request headers (e.g. {\"Authorization\": \"Bearer token\"})."},
-					"body":    {Type: "string", Description: "Optional request body. Pass a JSON string or a plain string. If an object is passed it will be serialised to JSON automatically."},
+					"url":      {Type: "string", Description: "The full URL to call (must include https://)."},
+					"method":   {Type: "string", Description: `HTTP method: "GET", "POST", "PUT", "PATCH", "DELETE". Defaults to "GET".`},
+					"headers":  {Type: "object", Description: "Optional map of request headers (e.g. {\"Authorization\": \"Bearer token\"})."},
+					"body":     {Type: "string", Description: "Optional request body. Pass a JSON string or a plain string. If an object is passed it will be serialised to JSON automatically."},
+					"no_cache": {Type: "boolean", Description: "For GET requests, skip the response cache and call the API directly (default false)."},
 				},
 				Required: []string{"url"},
 			},
@@ -8,14 +8,28 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"mcp-server/internal/mcp"
 )
 
+// jiraMaxResponseBytes bounds how much of a Jira response is read. Search
+// pages with many fields can be several megabytes.
+const jiraMaxResponseBytes = 10 << 20
+
 type jiraClient struct {
 	baseURL string
 	email   string
 	token   string
+
+	mu           sync.Mutex
+	estimation   map[int]string // board id → story points field id
+	fields       []jiraFieldDef // field catalogue, see fieldDefs
+	fieldsLoaded time.Time
+	issueTypes   map[string]jiraIssueTypes // project key → issue types
+	users        map[string]jiraUserHits   // lower-cased query → matches
+	self         *jiraUser
 }
 
 func jiraIsConfigured() bool {
@@ -29,6 +43,10 @@ func newJiraClient() *jiraClient {
 		baseURL: strings.TrimRight(os.Getenv("JIRA_BASE_URL"), "/"),
 		email:   os.Getenv("JIRA_EMAIL"),
 		token:   os.Getenv("JIRA_API_TOKEN"),
+
+		estimation: map[int]string{},
+		issueTypes: map[string]jiraIssueTypes{},
+		users:      map[string]jiraUserHits{},
 	}
 }
 
@@ -44,7 +62,7 @@ func (c *jiraClient) get(path string) ([]byte, int, error) {
 		return nil, 0, err
 	}
 	defer resp.Body.Close()
-	body, _ := io.ReadAll(io.LimitReader(resp.Body, 100*1024))
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, jiraMaxResponseBytes))
 	return body, resp.StatusCode, nil
 }
 
@@ -62,7 +80,7 @@ func (c *jiraClient) post(path string, payload any) ([]byte, int, error) {
 		return nil, 0, err
 	}
 	defer resp.Body.Close()
-	body, _ := io.ReadAll(io.LimitReader(resp.Body, 100*1024))
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, jiraMaxResponseBytes))
 	return body, resp.StatusCode, nil
 }
 
@@ -80,69 +98,175 @@ func (c *jiraClient) put(path string, payload any) ([]byte, int, error) {
 		return nil, 0, err
 	}
 	defer resp.Body.Close()
-	body, _ := io.ReadAll(io.LimitReader(resp.Body, 100*1024))
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, jiraMaxResponseBytes))
 	return body, resp.StatusCode, nil
 }
 
-func (c *jiraClient) searchIssues(args map[string]any) (mcp.ToolCallResult, error) {
-	query, errResult, err := requireString(args, "query")
-	if errResult != nil {
-		return *errResult, err
+// getJSON GETs path and decodes a 200 response into v. Errors carry the
+// messages the tools report to the agent.
+func (c *jiraClient) getJSON(path string, v any) error {
+	raw, status, err := c.get(path)
+	if err != nil {
+		return fmt.Errorf("jira request failed: %v", err)
+	}
+	if status != 200 {
+		return fmt.Errorf("jira error %d: %s", status, string(raw))
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("parse response failed: %v", err)
 	}
-	maxResults := int(optionalFloat(args, "max_results", 20))
+	return nil
+}
 
+// Search paging limits. Jira Cloud's /search/jql pages with an opaque
+// nextPageToken and no total count; all_pages follows the tokens up to
+// jiraMaxSearchItems issues so one call cannot pull an entire instance.
+const (
+	jiraDefaultPageSize = 20
+	jiraMaxPageSize     = 100
+	jiraDefaultAllItems = 500
+	jiraMaxSearchItems  = 5000
+)
+
+var jiraDefaultSearchFields = []string{"summary", "status", "assignee"}
+
+type jiraSearchPage struct {
+	Issues []struct {
+		Key    string         `json:"key"`
+		Fields map[string]any `json:"fields"`
+	} `json:"issues"`
+	NextPageToken string `json:"nextPageToken"`
+	IsLast        bool   `json:"isLast"`
+}
+
+func (c *jiraClient) searchPage(jql string, fields []string, maxResults int, pageToken string) (*jiraSearchPage, error) {
 	// Use POST /rest/api/3/search/jql (current Jira Cloud endpoint).
 	// The older GET /rest/api/3/issue/search returns 404 for some tenants.
 	body := map[string]any{
-		"jql":        query,
+		"jql":        jql,
 		"maxResults": maxResults,
-		"fields":     []string{"summary", "status", "assignee"},
+		"fields":     fields,
+	}
+	if pageToken != "" {
+		body["nextPageToken"] = pageToken
 	}
 	raw, status, err := c.post("/rest/api/3/search/jql", body)
 	if err != nil {
-		return textErr(fmt.Sprintf("jira request failed: %v", err))
+		return nil, fmt.Errorf("jira request failed: %v", err)
 	}
 	if status != 200 {
-		return textErr(fmt.Sprintf("jira error %d: %s", status, string(raw)))
+		return nil, fmt.Errorf("jira error %d: %s", status, string(raw))
+	}
+	var page jiraSearchPage
+	if err := json.Unmarshal(raw, &page); err != nil {
+		return nil, fmt.Errorf("parse response failed: %v", err)
 	}
+	return &page, nil
+}
 
-	var result struct {
-		Total  int `json:"total"`
-		Issues []struct {
-			Key    string `json:"key"`
-			Fields struct {
-				Summary  string                                             `json:"summary"`
-				Status   struct{ Name string `json:"name"` }               `json:"status"`
-				Assignee *struct{ DisplayName string `json:"displayName"` } `json:"assignee"`
-			} `json:"fields"`
-		} `json:"issues"`
+func (c *jiraClient) searchIssues(args map[string]any) (mcp.ToolCallResult, error) {
+	query, errResult, err := requireString(args, "query")
+	if errResult != nil {
+		return *errResult, err
 	}
-	if err := json.Unmarshal(raw, &result); err != nil {
-		return textErr(fmt.Sprintf("parse response failed: %v", err))
+	allPages := optionalBool(args, "all_pages", false)
+	pageSize := jiraDefaultPageSize
+	if allPages {
+		pageSize = jiraMaxPageSize
+	}
+	pageSize = int(optionalFloat(args, "max_results", float64(pageSize)))
+	pageSize = min(max(pageSize, 1), jiraMaxPageSize)
+	maxItems := pageSize
+	if allPages {
+		maxItems = int(optionalFloat(args, "max_items", jiraDefaultAllItems))
+		maxItems = min(max(maxItems, 1), jiraMaxSearchItems)
+	}
+	fields := optionalStringList(args, "fields")
+	if len(fields) == 0 {
+		fields = jiraDefaultSearchFields
+	}
+	token := optionalString(args, "next_page_token", "")
+
+	issues := []map[string]any{}
+	pages := 0
+	for {
+		page, err := c.searchPage(query, fields, min(pageSize, maxItems-len(issues)), token)
+		if err != nil {
+			return textErr(err.Error())
+		}
+		pages++
+		for _, iss := range page.Issues {
+			issues = append(issues, c.issueSummary(iss.Key, iss.Fields, fields))
+		}
+		token = page.NextPageToken
+		if page.IsLast {
+			token = ""
+		}
+		if !allPages || token == "" || len(issues) >= maxItems || len(page.Issues) == 0 {
+			break
+		}
 	}
 
-	type issueOut struct {
-		Key      string `json:"key"`
-		Summary  string `json:"summary"`
-		Status   string `json:"status"`
-		Assignee string `json:"assignee,omitempty"`
-		URL      string `json:"url"`
+	result := map[string]any{
+		"issues": issues,
+		"count":  len(issues),
+		"pages":  pages,
 	}
-	out := make([]issueOut, len(result.Issues))
-	for i, iss := range result.Issues {
-		assignee := ""
-		if iss.Fields.Assignee != nil {
-			assignee = iss.Fields.Assignee.DisplayName
+	if token != "" {
+		// More results remain; pass the token back to continue.
+		result["next_page_token"] = token
+		if allPages {
+			result["truncated"] = true
 		}
-		out[i] = issueOut{
-			Key:      iss.Key,
-			Summary:  iss.Fields.Summary,
-			Status:   iss.Fields.Status.Name,
-			Assignee: assignee,
-			URL:      c.baseURL + "/browse/" + iss.Key,
+	}
+	return textResult(result)
+}
+
+// issueSummary flattens a search hit: key and URL plus each requested field,
+// with Jira's nested objects reduced to their display values.
+func (c *jiraClient) issueSummary(key string, raw map[string]any, fields []string) map[string]any {
+	out := map[string]any{
+		"key": key,
+		"url": c.baseURL + "/browse/" + key,
+	}
+	for _, f := range fields {
+		switch {
+		case f == "*all" || f == "*navigable":
+			for name, v := range raw {
+				if v != nil {
+					out[name] = jiraFieldValue(v)
+				}
+			}
+		case !strings.HasPrefix(f, "-"): // "-name" excludes a field
+			out[f] = jiraFieldValue(raw[f])
 		}
 	}
-	return textResult(map[string]any{"total": result.Total, "issues": out})
+	return out
+}
+
+// jiraFieldValue reduces a Jira field value to something an agent can read:
+// users, statuses, priorities and options become their display names,
+// rich text becomes plain text, and lists are reduced element-wise.
+func jiraFieldValue(v any) any {
+	switch x := v.(type) {
+	case []any:
+		out := make([]any, len(x))
+		for i, item := range x {
+			out[i] = jiraFieldValue(item)
+		}
+		return out
+	case map[string]any:
+		if t, _ := x["type"].(string); t == "doc" {
+			return adfToMarkdown(x)
+		}
+		for _, k := range []string{"displayName", "name", "value", "key"} {
+			if s, ok := x[k].(string); ok {
+				return s
+			}
+		}
+		return x
+	}
+	return v
 }
 
 func (c *jiraClient) getIssue(args map[string]any) (mcp.ToolCallResult, error) {
@@ -151,7 +275,7 @@ func (c *jiraClient) getIssue(args map[string]any) (mcp.ToolCallResult, error) {
 		return *errResult, err
 	}
 
-	path := fmt.Sprintf("/rest/api/3/issue/%s?fields=summary,description,status,assignee,priority,created,updated",
+	path := fmt.Sprintf("/rest/api/3/issue/%s?fields=summary,description,status,assignee,priority,created,updated,issuetype,parent,subtasks,issuelinks",
 		url.PathEscape(key))
 
 	raw, status, err := c.get(path)
@@ -172,6 +296,10 @@ func (c *jiraClient) getIssue(args map[string]any) (mcp.ToolCallResult, error) {
 			Priority    *struct{ Name string `json:"name"` }              `json:"priority"`
 			Created     string                                             `json:"created"`
 			Updated     string                                             `json:"updated"`
+			IssueType   struct{ Name string `json:"name"` }               `json:"issuetype"`
+			Parent      *jiraIssueRef                                      `json:"parent"`
+			Subtasks    []jiraIssueRef                                     `json:"subtasks"`
+			IssueLinks  []jiraIssueLink                                    `json:"issuelinks"`
 		} `json:"fields"`
 	}
 	if err := json.Unmarshal(raw, &result); err != nil {
@@ -186,17 +314,30 @@ func (c *jiraClient) getIssue(args map[string]any) (mcp.ToolCallResult, error) {
 		priority = result.Fields.Priority.Name
 	}
 
-	return textResult(map[string]any{
+	out := map[string]any{
 		"key":         result.Key,
 		"summary":     result.Fields.Summary,
-		"description": adfToText(result.Fields.Description),
+		"description": adfToMarkdown(result.Fields.Description),
 		"status":      result.Fields.Status.Name,
 		"assignee":    assignee,
 		"priority":    priority,
+		"issue_type":  result.Fields.IssueType.Name,
 		"url":         c.baseURL + "/browse/" + result.Key,
 		"created":     result.Fields.Created,
 		"updated":     result.Fields.Updated,
-	})
+		"links":       jiraLinks(result.Fields.IssueLinks),
+	}
+	if result.Fields.Parent != nil {
+		out["parent"] = result.Fields.Parent.summary()
+	}
+	if len(result.Fields.Subtasks) > 0 {
+		subtasks := make([]map[string]any, len(result.Fields.Subtasks))
+		for i := range result.Fields.Subtasks {
+			subtasks[i] = result.Fields.Subtasks[i].summary()
+		}
+		out["subtasks"] = subtasks
+	}
+	return textResult(out)
 }
 
 func (c *jiraClient) addComment(args map[string]any) (mcp.ToolCallResult, error) {
@@ -209,21 +350,8 @@ func (c *jiraClient) addComment(args map[string]any) (mcp.ToolCallResult, error)
 		return *errResult, err
 	}
 
-	// Jira requires plain text wrapped in Atlassian Document Format (ADF).
-	payload := map[string]any{
-		"body": map[string]any{
-			"type":    "doc",
-			"version": 1,
-			"content": []any{
-				map[string]any{
-					"type": "paragraph",
-					"content": []any{
-						map[string]any{"type": "text", "text": body},
-					},
-				},
-			},
-		},
-	}
+	// Jira requires Atlassian Document Format (ADF); the body is Markdown.
+	payload := map[string]any{"body": markdownToADF(body)}
 
 	path := fmt.Sprintf("/rest/api/3/issue/%s/comment", url.PathEscape(key))
 	raw, status, err := c.post(path, payload)
@@ -261,24 +389,24 @@ func (c *jiraClient) createIssue(args map[string]any) (mcp.ToolCallResult, error
 	issueType := optionalString(args, "issue_type", "Task")
 	description := optionalString(args, "description", "")
 
-	fields := map[string]any{
-		"project":   map[string]string{"key": projectKey},
-		"summary":   summary,
-		"issuetype": map[string]string{"name": issueType},
+	fields, err := c.issueFieldArgs(args)
+	if err != nil {
+		return textErr(err.Error())
+	}
+	fields["project"] = map[string]string{"key": projectKey}
+	fields["summary"] = summary
+	// Check the issue type up front so a typo is reported with the valid
+	// names. If the project's types cannot be read, leave it to Jira.
+	fields["issuetype"] = map[string]string{"name": issueType}
+	if types, err := c.projectIssueTypes(projectKey); err == nil {
+		t, err := matchIssueType(projectKey, types, issueType)
+		if err != nil {
+			return textErr(err.Error())
+		}
+		fields["issuetype"] = map[string]string{"id": t.ID}
 	}
 	if description != "" {
-		fields["description"] = map[string]any{
-			"type":    "doc",
-			"version": 1,
-			"content": []any{
-				map[string]any{
-					"type": "paragraph",
-					"content": []any{
-						map[string]any{"type": "text", "text": description},
-					},
-				},
-			},
-		}
+		fields["description"] = markdownToADF(description)
 	}
 
 	raw, status, err := c.post("/rest/api/3/issue", map[string]any{"fields": fields})
@@ -308,26 +436,18 @@ func (c *jiraClient) updateIssue(args map[string]any) (mcp.ToolCallResult, error
 		return *errResult, err
 	}
 
-	fields := map[string]any{}
+	fields, err := c.issueFieldArgs(args)
+	if err != nil {
+		return textErr(err.Error())
+	}
 	if summary := optionalString(args, "summary", ""); summary != "" {
 		fields["summary"] = summary
 	}
 	if description := optionalString(args, "description", ""); description != "" {
-		fields["description"] = map[string]any{
-			"type":    "doc",
-			"version": 1,
-			"content": []any{
-				map[string]any{
-					"type": "paragraph",
-					"content": []any{
-						map[string]any{"type": "text", "text": description},
-					},
-				},
-			},
-		}
+		fields["description"] = markdownToADF(description)
 	}
 	if len(fields) == 0 {
-		return textErr("at least one of 'summary' or 'description' must be provided")
+		return textErr("at least one field to change must be provided")
 	}
 
 	path := fmt.Sprintf("/rest/api/3/issue/%s", url.PathEscape(key))
@@ -347,121 +467,41 @@ func (c *jiraClient) updateIssue(args map[string]any) (mcp.ToolCallResult, error
 	})
 }
 
+// closeIssue is jira_transition_issue with the destination defaulting to
+// "Done", so required screen fields are validated the same way.
 func (c *jiraClient) closeIssue(args map[string]any) (mcp.ToolCallResult, error) {
-	key, errResult, err := requireString(args, "key")
-	if errResult != nil {
+	if _, errResult, err := requireString(args, "key"); errResult != nil {
 		return *errResult, err
 	}
-	targetStatus := optionalString(args, "status", "Done")
-
-	// Step 1: fetch available transitions.
-	transPath := fmt.Sprintf("/rest/api/3/issue/%s/transitions", url.PathEscape(key))
-	raw, status, err := c.get(transPath)
-	if err != nil {
-		return textErr(fmt.Sprintf("jira request failed: %v", err))
-	}
-	if status != 200 {
-		return textErr(fmt.Sprintf("jira error %d: %s", status, string(raw)))
-	}
-
-	var transResult struct {
-		Transitions []struct {
-			ID   string `json:"id"`
-			Name string `json:"name"`
-			To   struct {
-				Name string `json:"name"`
-			} `json:"to"`
-		} `json:"transitions"`
-	}
-	if err := json.Unmarshal(raw, &transResult); err != nil {
-		return textErr(fmt.Sprintf("parse transitions failed: %v", err))
-	}
-
-	// Step 2: match by transition name or destination status name (case-insensitive).
-	var matchID, matchName string
-	var available []string
-	for _, t := range transResult.Transitions {
-		available = append(available, t.Name)
-		tName := strings.ToLower(t.Name)
-		toName := strings.ToLower(t.To.Name)
-		target := strings.ToLower(targetStatus)
-		if tName == target || toName == target {
-			matchID = t.ID
-			matchName = t.Name
-			break
-		}
-	}
-	if matchID == "" {
-		return textErr(fmt.Sprintf(
-			"no transition matching %q found for issue %s. Available: %v",
-			targetStatus, key, available,
-		))
-	}
-
-	// Step 3: apply the transition.
-	payload := map[string]any{"transition": map[string]string{"id": matchID}}
-	raw, status, err = c.post(transPath, payload)
-	if err != nil {
-		return textErr(fmt.Sprintf("jira request failed: %v", err))
-	}
-	// Jira returns 204 No Content on success.
-	if status != 204 {
-		return textErr(fmt.Sprintf("jira error %d: %s", status, string(raw)))
-	}
-
-	return textResult(map[string]any{
-		"key":             key,
-		"url":             c.baseURL + "/browse/" + key,
-		"transitioned_to": matchName,
-	})
-}
-
-// adfToText extracts plain text from Atlassian Document Format (ADF).
-func adfToText(node any) string {
-	if node == nil {
-		return ""
-	}
-	var sb strings.Builder
-	extractADFNode(node, &sb)
-	return strings.TrimSpace(sb.String())
-}
-
-func extractADFNode(node any, sb *strings.Builder) {
-	m, ok := node.(map[string]any)
-	if !ok {
-		return
-	}
-	if t, _ := m["type"].(string); t == "text" {
-		if text, _ := m["text"].(string); text != "" {
-			sb.WriteString(text)
-		}
-		return
-	}
-	if children, ok := m["content"].([]any); ok {
-		for _, child := range children {
-			extractADFNode(child, sb)
-		}
-		sb.WriteString(" ")
+	forwarded := map[string]any{}
+	for k, v := range args {
+		forwarded[k] = v
 	}
+	forwarded["transition"] = optionalString(args, "status", "Done")
+	return c.transitionIssue(forwarded)
 }
 
 func jiraDefinitions() []mcp.ToolDefinition {
 	return []mcp.ToolDefinition{
 		{
 			Name:        "jira_search_issues",
-			Description: "Search Jira issues using a JQL query. Returns matching issues with their key, summary, status, assignee, and URL.",
+			Description: "Search Jira issues using a JQL query. Returns matching issues with their key, URL and the requested fields (summary, status and assignee by default). Results are paged: pass next_page_token back to continue, or set all_pages to collect every match up to a cap.",
 			InputSchema: mcp.JSONSchema{
 				Type: "object",
 				Properties: map[string]mcp.Property{
-					"query":       {Type: "string", Description: `JQL query string, e.g. "project = PROJ AND status = 'In Progress' ORDER BY created DESC".`},
-					"max_results": {Type: "number", Description: "Maximum number of issues to return (1–50, default 20)."},
+					"query":           {Type: "string", Description: `JQL query string, e.g. "project = PROJ AND status = 'In Progress' ORDER BY created DESC".`},
+					"max_results":     {Type: "number", Description: "Issues per page (1–100, default 20; 100 with all_pages)."},
+					"next_page_token": {Type: "string", Description: "Token from a previous result's next_page_token to fetch the following page."},
+					"all_pages":       {Type: "boolean", Description: "Follow page tokens and return every match, up to max_items (default false)."},
+					"max_items":       {Type: "number", Description: "With all_pages, stop after this many issues (default 500, max 5000). A next_page_token is returned if more remain."},
+					"fields":          {Type: "string", Description: `Comma-separated Jira fields to return, e.g. "summary,status,assignee,updated,labels,customfield_10016" (default "summary,status,assignee"). Users, statuses and options are returned as their names.`},
 				},
 				Required: []string{"query"},
 			},
 		},
 		{
 			Name:        "jira_get_issue",
-			Description: `Fetch full details for a single Jira issue by its key (e.g. "PROJ-123"). Returns summary, description, status, assignee, priority, and timestamps.`,
+			Description: `Fetch full details for a single Jira issue by its key (e.g. "PROJ-123"). Returns summary, description (as Markdown), status, assignee, priority, issue type, timestamps, parent, subtasks and issue links (e.g. "blocks", "is blocked by").`,
 			InputSchema: mcp.JSONSchema{
 				Type: "object",
 				Properties: map[string]mcp.Property{
@@ -477,46 +517,62 @@ func jiraDefinitions() []mcp.ToolDefinition {
 				Type: "object",
 				Properties: map[string]mcp.Property{
 					"key":  {Type: "string", Description: `Jira issue key, e.g. "PROJ-123".`},
-					"body": {Type: "string", Description: "Comment text to post."},
+					"body": {Type: "string", Description: "Comment text to post, in Markdown (headings, lists, code blocks, tables, links; mention users with [@Name](accountid:ID))."},
 				},
 				Required: []string{"key", "body"},
 			},
 		},
 		{
 			Name:        "jira_create_issue",
-			Description: "Create a new Jira issue in a project. Returns the new issue key and URL.",
+			Description: "Create a new Jira issue in a project, optionally with labels, priority, components, due date, assignee, reporter and custom fields by name. Returns the new issue key and URL.",
 			InputSchema: mcp.JSONSchema{
 				Type: "object",
 				Properties: map[string]mcp.Property{
 					"project_key": {Type: "string", Description: `Jira project key, e.g. "PROJ".`},
 					"summary":     {Type: "string", Description: "One-line issue title."},
-					"issue_type":  {Type: "string", Description: `Issue type name, e.g. "Task", "Bug", "Story". Defaults to "Task".`},
-					"description": {Type: "string", Description: "Optional longer description (plain text)."},
+					"issue_type":  {Type: "string", Description: `Issue type name, e.g. "Task", "Bug", "Story". Defaults to "Task". See jira_list_issue_types.`},
+					"description": {Type: "string", Description: "Optional longer description, in Markdown."},
+					"labels":      {Type: "string", Description: `Comma-separated labels, e.g. "backend,urgent".`},
+					"priority":    {Type: "string", Description: `Priority name, e.g. "High".`},
+					"components":  {Type: "string", Description: `Comma-separated component names.`},
+					"due_date":    {Type: "string", Description: "Due date as YYYY-MM-DD."},
+					"assignee":    {Type: "string", Description: `Assignee email, display name, account id or "me"; "none" leaves it unassigned.`},
+					"reporter":    {Type: "string", Description: `Reporter email, display name, account id or "me".`},
+					"fields":      {Type: "object", Description: `Other fields keyed by name or id, e.g. {"Story Points": 5, "Team": "Platform"}. Option values are given by name; see jira_get_create_fields and jira_list_fields.`},
 				},
 				Required: []string{"project_key", "summary"},
 			},
 		},
 		{
 			Name:        "jira_update_issue",
-			Description: "Update the summary or description of an existing Jira issue. Provide at least one field to change.",
+			Description: "Update fields of an existing Jira issue: summary, description, labels, priority, components, due date, assignee, reporter, or any field by name. Provide at least one field to change.",
 			InputSchema: mcp.JSONSchema{
 				Type: "object",
 				Properties: map[string]mcp.Property{
 					"key":         {Type: "string", Description: `Jira issue key, e.g. "PROJ-123".`},
 					"summary":     {Type: "string", Description: "New one-line title (optional)."},
-					"description": {Type: "string", Description: "New description text (optional)."},
+					"description": {Type: "string", Description: "New description, in Markdown (optional)."},
+					"labels":      {Type: "string", Description: `Comma-separated labels, e.g. "backend,urgent"; replaces the current labels.`},
+					"priority":    {Type: "string", Description: `Priority name, e.g. "High".`},
+					"components":  {Type: "string", Description: `Comma-separated component names; replaces the current components.`},
+					"due_date":    {Type: "string", Description: "Due date as YYYY-MM-DD."},
+					"assignee":    {Type: "string", Description: `Assignee email, display name, account id or "me"; "none" leaves it unassigned.`},
+					"reporter":    {Type: "string", Description: `Reporter email, display name, account id or "me".`},
+					"fields":      {Type: "object", Description: `Other fields keyed by name or id, e.g. {"Story Points": 5, "Team": "Platform"}. Option values are given by name; see jira_get_create_fields and jira_list_fields.`},
 				},
 				Required: []string{"key"},
 			},
 		},
 		{
 			Name:        "jira_close_issue",
-			Description: `Transition a Jira issue to a closed/done status. Fetches available transitions and matches by name (default "Done"). Returns the transition name applied.`,
+			Description: `Transition a Jira issue to a closed/done status. Fetches available transitions and matches by name (default "Done"); fields the transition requires, such as a resolution, are validated first. Returns the transition name applied.`,
 			InputSchema: mcp.JSONSchema{
 				Type: "object",
 				Properties: map[string]mcp.Property{
-					"key":    {Type: "string", Description: `Jira issue key, e.g. "PROJ-123".`},
-					"status": {Type: "string", Description: `Target status name to transition to. Defaults to "Done". Common values: "Done", "Closed", "Resolved".`},
+					"key":        {Type: "string", Description: `Jira issue key, e.g. "PROJ-123".`},
+					"status":     {Type: "string", Description: `Target status name to transition to. Defaults to "Done". Common values: "Done", "Closed", "Resolved".`},
+					"resolution": {Type: "string", Description: `Resolution name, e.g. "Done", "Won't Do", when the workflow asks for one.`},
+					"comment":    {Type: "string", Description: "Optional closing comment, in Markdown."},
 				},
 				Required: []string{"key"},
 			},
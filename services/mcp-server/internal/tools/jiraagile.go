@@ -0,0 +1,588 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcp-server/internal/mcp"
+)
+
+// The Agile API (/rest/agile/1.0) covers boards, sprints and the backlog.
+// It pages with startAt/maxResults like the issue history endpoints, and
+// accepts at most 50 issues per move request.
+const (
+	jiraAgileMoveBatch   = 50
+	jiraSprintIssueLimit = 1000
+)
+
+var jiraAgileIssueFields = []string{"summary", "status", "assignee", "issuetype", "priority"}
+
+// requireJiraID reads a numeric board or sprint id, given as a number or a
+// numeric string.
+func requireJiraID(args map[string]any, key string) (int, *mcp.ToolCallResult, error) {
+	var id int
+	switch v := args[key].(type) {
+	case float64:
+		id = int(v)
+	case string:
+		id, _ = strconv.Atoi(strings.TrimSpace(v))
+	}
+	if id <= 0 {
+		r, err := textErr(fmt.Sprintf("%q must be a numeric id", key))
+		return 0, &r, err
+	}
+	return id, nil, nil
+}
+
+type jiraSprint struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	State         string `json:"state"`
+	StartDate     string `json:"startDate"`
+	EndDate       string `json:"endDate"`
+	CompleteDate  string `json:"completeDate"`
+	Goal          string `json:"goal"`
+	OriginBoardID int    `json:"originBoardId"`
+}
+
+func (s *jiraSprint) summary() map[string]any {
+	out := map[string]any{
+		"id":         s.ID,
+		"name":       s.Name,
+		"state":      s.State,
+		"start_date": s.StartDate,
+		"end_date":   s.EndDate,
+	}
+	if s.Goal != "" {
+		out["goal"] = s.Goal
+	}
+	if s.CompleteDate != "" {
+		out["complete_date"] = s.CompleteDate
+	}
+	return out
+}
+
+func (c *jiraClient) getSprint(id int) (*jiraSprint, error) {
+	var s jiraSprint
+	if err := c.getJSON(fmt.Sprintf("/rest/agile/1.0/sprint/%d", id), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// pointsField returns the story points field id: the story_points_field
+// argument, then JIRA_STORY_POINTS_FIELD, then the board's estimation
+// field. Board lookups are cached. An empty result means issues are not
+// estimated in points.
+func (c *jiraClient) pointsField(args map[string]any, boardID int) string {
+	if f := optionalString(args, "story_points_field", ""); f != "" {
+		return f
+	}
+	if f := os.Getenv("JIRA_STORY_POINTS_FIELD"); f != "" {
+		return f
+	}
+	if boardID <= 0 {
+		return ""
+	}
+	c.mu.Lock()
+	f, ok := c.estimation[boardID]
+	c.mu.Unlock()
+	if ok {
+		return f
+	}
+	var conf struct {
+		Estimation struct {
+			Type  string `json:"type"`
+			Field struct {
+				FieldID string `json:"fieldId"`
+			} `json:"field"`
+		} `json:"estimation"`
+	}
+	if err := c.getJSON(fmt.Sprintf("/rest/agile/1.0/board/%d/configuration", boardID), &conf); err != nil {
+		return "" // not cached: the board may be readable later
+	}
+	if conf.Estimation.Type == "field" {
+		f = conf.Estimation.Field.FieldID
+	}
+	c.mu.Lock()
+	c.estimation[boardID] = f
+	c.mu.Unlock()
+	return f
+}
+
+// agileIssue is a sprint or backlog issue reduced for the agent.
+type agileIssue struct {
+	Key            string   `json:"key"`
+	Summary        string   `json:"summary"`
+	Status         string   `json:"status"`
+	StatusCategory string   `json:"status_category"`
+	Assignee       string   `json:"assignee,omitempty"`
+	IssueType      string   `json:"issue_type,omitempty"`
+	Priority       string   `json:"priority,omitempty"`
+	StoryPoints    *float64 `json:"story_points,omitempty"`
+}
+
+// jiraStatusCategory maps Jira's status category keys to "to_do",
+// "in_progress" and "done".
+func jiraStatusCategory(status any) string {
+	m, _ := status.(map[string]any)
+	cat, _ := m["statusCategory"].(map[string]any)
+	key, _ := cat["key"].(string)
+	return statusCategoryName(key)
+}
+
+func statusCategoryName(key string) string {
+	switch key {
+	case "done":
+		return "done"
+	case "indeterminate":
+		return "in_progress"
+	}
+	return "to_do"
+}
+
+// agileIssues pages through an Agile issue listing (a sprint or the
+// backlog) and returns up to limit issues with the total Jira reports.
+func (c *jiraClient) agileIssues(path, jql, pointsField string, limit int) ([]agileIssue, int, error) {
+	fields := jiraAgileIssueFields
+	if pointsField != "" {
+		fields = append(append([]string(nil), fields...), pointsField)
+	}
+	issues := []agileIssue{}
+	total := 0
+	for len(issues) < limit {
+		q := url.Values{}
+		q.Set("startAt", strconv.Itoa(len(issues)))
+		q.Set("maxResults", strconv.Itoa(min(jiraMaxPageSize, limit-len(issues))))
+		q.Set("fields", strings.Join(fields, ","))
+		if jql != "" {
+			q.Set("jql", jql)
+		}
+		var page struct {
+			Total  int `json:"total"`
+			Issues []struct {
+				Key    string         `json:"key"`
+				Fields map[string]any `json:"fields"`
+			} `json:"issues"`
+		}
+		if err := c.getJSON(path+"?"+q.Encode(), &page); err != nil {
+			return nil, 0, err
+		}
+		total = page.Total
+		for _, iss := range page.Issues {
+			ai := agileIssue{
+				Key:            iss.Key,
+				Summary:        fmt.Sprint(jiraFieldValue(iss.Fields["summary"])),
+				Status:         fmt.Sprint(jiraFieldValue(iss.Fields["status"])),
+				StatusCategory: jiraStatusCategory(iss.Fields["status"]),
+			}
+			ai.Assignee, _ = jiraFieldValue(iss.Fields["assignee"]).(string)
+			ai.IssueType, _ = jiraFieldValue(iss.Fields["issuetype"]).(string)
+			ai.Priority, _ = jiraFieldValue(iss.Fields["priority"]).(string)
+			if p, ok := iss.Fields[pointsField].(float64); ok && pointsField != "" {
+				ai.StoryPoints = &p
+			}
+			issues = append(issues, ai)
+		}
+		if len(page.Issues) == 0 || len(issues) >= total {
+			break
+		}
+	}
+	return issues, total, nil
+}
+
+func sumPoints(issues []agileIssue) float64 {
+	total := 0.0
+	for _, iss := range issues {
+		if iss.StoryPoints != nil {
+			total += *iss.StoryPoints
+		}
+	}
+	return total
+}
+
+func (c *jiraClient) listBoards(args map[string]any) (mcp.ToolCallResult, error) {
+	startAt, maxResults := jiraPageArgs(args)
+	q := url.Values{}
+	q.Set("startAt", strconv.Itoa(startAt))
+	q.Set("maxResults", strconv.Itoa(maxResults))
+	for arg, param := range map[string]string{"project_key": "projectKeyOrId", "name": "name", "type": "type"} {
+		if v := optionalString(args, arg, ""); v != "" {
+			q.Set(param, v)
+		}
+	}
+
+	var page struct {
+		Total  int `json:"total"`
+		Values []struct {
+			ID       int    `json:"id"`
+			Name     string `json:"name"`
+			Type     string `json:"type"`
+			Location struct {
+				ProjectKey string `json:"projectKey"`
+			} `json:"location"`
+		} `json:"values"`
+	}
+	if err := c.getJSON("/rest/agile/1.0/board?"+q.Encode(), &page); err != nil {
+		return textErr(err.Error())
+	}
+
+	boards := make([]map[string]any, 0, len(page.Values))
+	for _, b := range page.Values {
+		boards = append(boards, map[string]any{
+			"id":          b.ID,
+			"name":        b.Name,
+			"type":        b.Type,
+			"project_key": b.Location.ProjectKey,
+		})
+	}
+	return textResult(pageResult(map[string]any{"boards": boards}, startAt, len(boards), page.Total))
+}
+
+func (c *jiraClient) listSprints(args map[string]any) (mcp.ToolCallResult, error) {
+	boardID, errResult, err := requireJiraID(args, "board_id")
+	if errResult != nil {
+		return *errResult, err
+	}
+	startAt, maxResults := jiraPageArgs(args)
+	q := url.Values{}
+	q.Set("startAt", strconv.Itoa(startAt))
+	q.Set("maxResults", strconv.Itoa(maxResults))
+	q.Set("state", optionalString(args, "state", "active,future"))
+
+	var page struct {
+		IsLast bool         `json:"isLast"`
+		Values []jiraSprint `json:"values"`
+	}
+	if err := c.getJSON(fmt.Sprintf("/rest/agile/1.0/board/%d/sprint?%s", boardID, q.Encode()), &page); err != nil {
+		return textErr(err.Error())
+	}
+
+	sprints := make([]map[string]any, 0, len(page.Values))
+	for i := range page.Values {
+		sprints = append(sprints, page.Values[i].summary())
+	}
+	result := map[string]any{"board_id": boardID, "sprints": sprints}
+	if !page.IsLast && len(sprints) > 0 {
+		result["next_start_at"] = startAt + len(sprints)
+	}
+	return textResult(result)
+}
+
+func (c *jiraClient) getSprintIssues(args map[string]any) (mcp.ToolCallResult, error) {
+	sprintID, errResult, err := requireJiraID(args, "sprint_id")
+	if errResult != nil {
+		return *errResult, err
+	}
+	sprint, err := c.getSprint(sprintID)
+	if err != nil {
+		return textErr(err.Error())
+	}
+	limit := int(optionalFloat(args, "max_items", jiraSprintIssueLimit))
+	limit = min(max(limit, 1), jiraMaxSearchItems)
+	pointsField := c.pointsField(args, sprint.OriginBoardID)
+
+	issues, total, err := c.agileIssues(fmt.Sprintf("/rest/agile/1.0/sprint/%d/issue", sprintID),
+		optionalString(args, "jql", ""), pointsField, limit)
+	if err != nil {
+		return textErr(err.Error())
+	}
+	result := map[string]any{
+		"sprint":             sprint.summary(),
+		"issues":             issues,
+		"count":              len(issues),
+		"total":              total,
+		"story_points_field": pointsField,
+		"total_points":       sumPoints(issues),
+	}
+	if len(issues) < total {
+		result["truncated"] = true
+	}
+	return textResult(result)
+}
+
+func (c *jiraClient) getBacklog(args map[string]any) (mcp.ToolCallResult, error) {
+	boardID, errResult, err := requireJiraID(args, "board_id")
+	if errResult != nil {
+		return *errResult, err
+	}
+	limit := int(optionalFloat(args, "max_items", jiraSprintIssueLimit))
+	limit = min(max(limit, 1), jiraMaxSearchItems)
+	pointsField := c.pointsField(args, boardID)
+
+	issues, total, err := c.agileIssues(fmt.Sprintf("/rest/agile/1.0/board/%d/backlog", boardID),
+		optionalString(args, "jql", ""), pointsField, limit)
+	if err != nil {
+		return textErr(err.Error())
+	}
+	result := map[string]any{
+		"board_id":           boardID,
+		"issues":             issues,
+		"count":              len(issues),
+		"total":              total,
+		"story_points_field": pointsField,
+		"total_points":       sumPoints(issues),
+	}
+	if len(issues) < total {
+		result["truncated"] = true
+	}
+	return textResult(result)
+}
+
+// moveIssues moves issues into a sprint, or to the backlog when no sprint
+// is given, in batches of jiraAgileMoveBatch.
+func (c *jiraClient) moveIssues(args map[string]any) (mcp.ToolCallResult, error) {
+	keys := optionalStringList(args, "issues")
+	if len(keys) == 0 {
+		return textErr("issues is required")
+	}
+	path, destination := "/rest/agile/1.0/backlog/issue", "backlog"
+	if _, ok := args["sprint_id"]; ok {
+		sprintID, errResult, err := requireJiraID(args, "sprint_id")
+		if errResult != nil {
+			return *errResult, err
+		}
+		path = fmt.Sprintf("/rest/agile/1.0/sprint/%d/issue", sprintID)
+		destination = fmt.Sprintf("sprint %d", sprintID)
+	}
+
+	moved := 0
+	for start := 0; start < len(keys); start += jiraAgileMoveBatch {
+		batch := keys[start:min(start+jiraAgileMoveBatch, len(keys))]
+		raw, status, err := c.post(path, map[string]any{"issues": batch})
+		if err != nil {
+			return textErr(fmt.Sprintf("jira request failed after moving %d issues: %v", moved, err))
+		}
+		// Jira returns 204 No Content on success.
+		if status != 204 {
+			return textErr(fmt.Sprintf("jira error %d after moving %d issues: %s", status, moved, string(raw)))
+		}
+		moved += len(batch)
+	}
+	return textResult(map[string]any{
+		"moved":       moved,
+		"issues":      keys,
+		"destination": destination,
+	})
+}
+
+// sprintProgress summarises a sprint: issue and point totals per status
+// category, time elapsed, and a per-assignee breakdown.
+func (c *jiraClient) sprintProgress(args map[string]any) (mcp.ToolCallResult, error) {
+	var sprint *jiraSprint
+	if _, ok := args["sprint_id"]; ok {
+		sprintID, errResult, err := requireJiraID(args, "sprint_id")
+		if errResult != nil {
+			return *errResult, err
+		}
+		if sprint, err = c.getSprint(sprintID); err != nil {
+			return textErr(err.Error())
+		}
+	} else {
+		boardID, errResult, err := requireJiraID(args, "board_id")
+		if errResult != nil {
+			return *errResult, err
+		}
+		var page struct {
+			Values []jiraSprint `json:"values"`
+		}
+		if err := c.getJSON(fmt.Sprintf("/rest/agile/1.0/board/%d/sprint?state=active", boardID), &page); err != nil {
+			return textErr(err.Error())
+		}
+		if len(page.Values) == 0 {
+			return textErr(fmt.Sprintf("board %d has no active sprint", boardID))
+		}
+		sprint = &page.Values[0]
+		if sprint.OriginBoardID == 0 {
+			sprint.OriginBoardID = boardID
+		}
+	}
+	pointsField := c.pointsField(args, sprint.OriginBoardID)
+	issues, total, err := c.agileIssues(fmt.Sprintf("/rest/agile/1.0/sprint/%d/issue", sprint.ID), "", pointsField, jiraMaxSearchItems)
+	if err != nil {
+		return textErr(err.Error())
+	}
+
+	type tally struct {
+		Issues      int     `json:"issues"`
+		Done        int     `json:"done"`
+		Points      float64 `json:"points"`
+		PointsDone  float64 `json:"points_done"`
+		Unestimated int     `json:"unestimated,omitempty"`
+	}
+	counts := map[string]int{"to_do": 0, "in_progress": 0, "done": 0}
+	points := map[string]float64{"to_do": 0, "in_progress": 0, "done": 0}
+	var all tally
+	byAssignee := map[string]*tally{}
+	for _, iss := range issues {
+		name := iss.Assignee
+		if name == "" {
+			name = "Unassigned"
+		}
+		a := byAssignee[name]
+		if a == nil {
+			a = &tally{}
+			byAssignee[name] = a
+		}
+		counts[iss.StatusCategory]++
+		for _, t := range []*tally{&all, a} {
+			t.Issues++
+			if iss.StatusCategory == "done" {
+				t.Done++
+			}
+			if iss.StoryPoints == nil {
+				t.Unestimated++
+				continue
+			}
+			t.Points += *iss.StoryPoints
+			if iss.StatusCategory == "done" {
+				t.PointsDone += *iss.StoryPoints
+			}
+		}
+		if iss.StoryPoints != nil {
+			points[iss.StatusCategory] += *iss.StoryPoints
+		}
+	}
+
+	assignees := make([]map[string]any, 0, len(byAssignee))
+	for name, t := range byAssignee {
+		assignees = append(assignees, map[string]any{
+			"assignee": name, "issues": t.Issues, "done": t.Done,
+			"points": t.Points, "points_done": t.PointsDone,
+		})
+	}
+	sort.Slice(assignees, func(i, j int) bool {
+		if assignees[i]["points"] != assignees[j]["points"] {
+			return assignees[i]["points"].(float64) > assignees[j]["points"].(float64)
+		}
+		return assignees[i]["assignee"].(string) < assignees[j]["assignee"].(string)
+	})
+
+	// Progress is measured in points when the sprint is estimated, else in
+	// issue count.
+	percent := 0.0
+	if all.Points > 0 {
+		percent = all.PointsDone / all.Points * 100
+	} else if all.Issues > 0 {
+		percent = float64(all.Done) / float64(all.Issues) * 100
+	}
+
+	result := map[string]any{
+		"sprint":             sprint.summary(),
+		"issue_count":        all.Issues,
+		"issues_by_status":   counts,
+		"points_by_status":   points,
+		"total_points":       all.Points,
+		"unestimated_issues": all.Unestimated,
+		"percent_done":       math.Round(percent*10) / 10,
+		"by_assignee":        assignees,
+		"story_points_field": pointsField,
+	}
+	if len(issues) < total {
+		result["truncated"] = true
+	}
+	start, errStart := time.Parse(time.RFC3339, sprint.StartDate)
+	end, errEnd := time.Parse(time.RFC3339, sprint.EndDate)
+	if errStart == nil && errEnd == nil && end.After(start) {
+		length := end.Sub(start)
+		elapsed := min(max(time.Since(start), 0), length)
+		days := func(d time.Duration) float64 { return math.Round(d.Hours()/24*10) / 10 }
+		result["days_total"] = days(length)
+		result["days_elapsed"] = days(elapsed)
+		result["days_remaining"] = days(length - elapsed)
+		result["percent_time_elapsed"] = math.Round(float64(elapsed)/float64(length)*1000) / 10
+	}
+	return textResult(result)
+}
+
+func jiraAgileDefinitions() []mcp.ToolDefinition {
+	pointsProp := mcp.Property{Type: "string", Description: `Story points field id, e.g. "customfield_10016". Defaults to JIRA_STORY_POINTS_FIELD, then the board's estimation field.`}
+	return []mcp.ToolDefinition{
+		{
+			Name:        "jira_list_boards",
+			Description: "List Jira Agile (Scrum/Kanban) boards with their id, name, type and project. Board ids are used by the sprint and backlog tools.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"project_key": {Type: "string", Description: `Only boards for this project, e.g. "PROJ".`},
+					"name":        {Type: "string", Description: "Only boards whose name contains this text."},
+					"type":        {Type: "string", Description: `"scrum" or "kanban".`},
+					"start_at":    {Type: "number", Description: "Index of the first board to return (default 0)."},
+					"max_results": {Type: "number", Description: "Boards per page (1–100, default 50)."},
+				},
+			},
+		},
+		{
+			Name:        "jira_list_sprints",
+			Description: "List the sprints of a board (active and future by default) with id, state, dates and goal.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"board_id":    {Type: "number", Description: "Board id from jira_list_boards."},
+					"state":       {Type: "string", Description: `Comma-separated sprint states: "active", "future", "closed" (default "active,future").`},
+					"start_at":    {Type: "number", Description: "Index of the first sprint to return (default 0)."},
+					"max_results": {Type: "number", Description: "Sprints per page (1–100, default 50)."},
+				},
+				Required: []string{"board_id"},
+			},
+		},
+		{
+			Name:        "jira_get_sprint_issues",
+			Description: "List the issues in a sprint with status, status category (to_do, in_progress, done), assignee and story points, plus the sprint details and total points.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"sprint_id":          {Type: "number", Description: "Sprint id from jira_list_sprints."},
+					"jql":                {Type: "string", Description: `Optional JQL filter within the sprint, e.g. "assignee = currentUser()".`},
+					"max_items":          {Type: "number", Description: "Maximum issues to return (default 1000, max 5000)."},
+					"story_points_field": pointsProp,
+				},
+				Required: []string{"sprint_id"},
+			},
+		},
+		{
+			Name:        "jira_get_backlog",
+			Description: "List the backlog of a board (issues not in any active or future sprint) in rank order, with status, assignee and story points.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"board_id":           {Type: "number", Description: "Board id from jira_list_boards."},
+					"jql":                {Type: "string", Description: "Optional JQL filter within the backlog."},
+					"max_items":          {Type: "number", Description: "Maximum issues to return (default 1000, max 5000)."},
+					"story_points_field": pointsProp,
+				},
+				Required: []string{"board_id"},
+			},
+		},
+		{
+			Name:        "jira_move_issues",
+			Description: "Move issues into a sprint, or back to the backlog when sprint_id is omitted.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"issues":    {Type: "string", Description: `Comma-separated issue keys, e.g. "PROJ-1,PROJ-2".`},
+					"sprint_id": {Type: "number", Description: "Destination sprint id. Omit to move the issues to the backlog."},
+				},
+				Required: []string{"issues"},
+			},
+		},
+		{
+			Name:        "jira_sprint_progress",
+			Description: "Summarise a sprint's progress: issues and story points per status category, percent done, days elapsed and remaining, and a per-assignee breakdown. Pass sprint_id, or board_id for the board's active sprint.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"sprint_id":          {Type: "number", Description: "Sprint id from jira_list_sprints."},
+					"board_id":           {Type: "number", Description: "Board id; its active sprint is used when sprint_id is omitted."},
+					"story_points_field": pointsProp,
+				},
+			},
+		},
+	}
+}
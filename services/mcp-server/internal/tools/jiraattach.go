@@ -0,0 +1,283 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"mcp-server/internal/mcp"
+)
+
+// jiraAttachmentMaxBytes caps attachment downloads and uploads. Downloads
+// are also bound by the workspace's per-file quota. Override with
+// JIRA_ATTACHMENT_MAX_BYTES.
+func jiraAttachmentMaxBytes() int64 {
+	return envInt64("JIRA_ATTACHMENT_MAX_BYTES", 25<<20)
+}
+
+type jiraAttachment struct {
+	ID       string    `json:"id"`
+	Filename string    `json:"filename"`
+	Size     int64     `json:"size"`
+	MimeType string    `json:"mimeType"`
+	Created  string    `json:"created"`
+	Author   *jiraUser `json:"author"`
+}
+
+func (a *jiraAttachment) summary() map[string]any {
+	return map[string]any{
+		"id":        a.ID,
+		"filename":  a.Filename,
+		"size":      a.Size,
+		"mime_type": a.MimeType,
+		"created":   a.Created,
+		"author":    a.Author.name(),
+	}
+}
+
+func (c *jiraClient) attachments(key string) ([]jiraAttachment, error) {
+	var issue struct {
+		Fields struct {
+			Attachment []jiraAttachment `json:"attachment"`
+		} `json:"fields"`
+	}
+	path := fmt.Sprintf("/rest/api/3/issue/%s?fields=attachment", url.PathEscape(key))
+	if err := c.getJSON(path, &issue); err != nil {
+		return nil, err
+	}
+	return issue.Fields.Attachment, nil
+}
+
+// download GETs path without the usual response cap, failing once more
+// than limit bytes arrive. Jira answers attachment content requests with a
+// redirect to its media service, which the client follows.
+func (c *jiraClient) download(path string, limit int64) ([]byte, error) {
+	req, err := http.NewRequest("GET", c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.email, c.token)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("jira request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, jiraMaxResponseBytes))
+		return nil, fmt.Errorf("jira error %d: %s", resp.StatusCode, string(body))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("jira request failed: %v", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("attachment is over the download limit of %d bytes", limit)
+	}
+	return data, nil
+}
+
+func (c *jiraClient) listAttachments(args map[string]any) (mcp.ToolCallResult, error) {
+	key, errResult, err := requireString(args, "key")
+	if errResult != nil {
+		return *errResult, err
+	}
+	list, err := c.attachments(key)
+	if err != nil {
+		return textErr(err.Error())
+	}
+	out := make([]map[string]any, 0, len(list))
+	for i := range list {
+		out = append(out, list[i].summary())
+	}
+	return textResult(map[string]any{
+		"key":         key,
+		"attachments": out,
+		"count":       len(out),
+	})
+}
+
+// downloadAttachment saves an attachment, chosen by id or file name, into
+// the workspace (by default jira-attachments/<KEY>/<file name>).
+func (c *jiraClient) downloadAttachment(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
+	key, errResult, err := requireString(args, "key")
+	if errResult != nil {
+		return *errResult, err
+	}
+	want, errResult, err := requireString(args, "attachment")
+	if errResult != nil {
+		return *errResult, err
+	}
+
+	list, err := c.attachments(key)
+	if err != nil {
+		return textErr(err.Error())
+	}
+	var att *jiraAttachment
+	var names []string
+	for i := range list {
+		names = append(names, list[i].Filename)
+		if att == nil && (list[i].ID == want || strings.EqualFold(list[i].Filename, want)) {
+			att = &list[i]
+		}
+	}
+	if att == nil {
+		return textErr(fmt.Sprintf("no attachment %q on %s. Available: %s", want, key, strings.Join(names, ", ")))
+	}
+
+	// The attachment's name comes from Jira, so only its base name is used.
+	name := filepath.Base(filepath.Clean("/" + att.Filename))
+	if name == "/" {
+		name = "attachment-" + att.ID
+	}
+	path := optionalString(args, "path", filepath.Join("jira-attachments", key, name))
+	rel, errResult, err := safePath(path)
+	if errResult != nil {
+		return *errResult, err
+	}
+
+	limit := min(jiraAttachmentMaxBytes(), quotaFromEnv().maxFileBytes)
+	if att.Size > limit {
+		return textErr(fmt.Sprintf("attachment %s is %d bytes, over the download limit of %d bytes", att.Filename, att.Size, limit))
+	}
+	data, err := c.download(fmt.Sprintf("/rest/api/3/attachment/content/%s", url.PathEscape(att.ID)), limit)
+	if err != nil {
+		return textErr(err.Error())
+	}
+	if err := writeWorkspaceFile(ws, rel, data, "jira download"); err != nil {
+		return textErr(fmt.Sprintf("save attachment failed: %v", err))
+	}
+
+	return textResult(map[string]any{
+		"key":           key,
+		"attachment_id": att.ID,
+		"filename":      att.Filename,
+		"mime_type":     att.MimeType,
+		"path":          filepath.ToSlash(rel),
+		"bytes":         len(data),
+	})
+}
+
+// uploadAttachment attaches a workspace file to an issue.
+func (c *jiraClient) uploadAttachment(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
+	key, errResult, err := requireString(args, "key")
+	if errResult != nil {
+		return *errResult, err
+	}
+	path, errResult, err := requireString(args, "path")
+	if errResult != nil {
+		return *errResult, err
+	}
+	rel, errResult, err := safePath(path)
+	if errResult != nil {
+		return *errResult, err
+	}
+	name := optionalString(args, "filename", filepath.Base(rel))
+
+	f, info, err := ws.open(rel)
+	if err != nil {
+		return textErr(fmt.Sprintf("open file failed: %v", err))
+	}
+	defer f.Close()
+	if !info.Mode().IsRegular() {
+		return textErr(fmt.Sprintf("%s is not a regular file", path))
+	}
+	if limit := jiraAttachmentMaxBytes(); info.Size() > limit {
+		return textErr(fmt.Sprintf("%s is %d bytes, over the upload limit of %d bytes", path, info.Size(), limit))
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, name))
+	header.Set("Content-Type", detectMIME(ws, rel))
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return textErr(fmt.Sprintf("upload failed: %v", err))
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return textErr(fmt.Sprintf("read file failed: %v", err))
+	}
+	mw.Close()
+
+	req, err := http.NewRequest("POST", c.baseURL+fmt.Sprintf("/rest/api/3/issue/%s/attachments", url.PathEscape(key)), &body)
+	if err != nil {
+		return textErr(fmt.Sprintf("jira request failed: %v", err))
+	}
+	req.SetBasicAuth(c.email, c.token)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	// Jira rejects multipart posts without this header as possible XSRF.
+	req.Header.Set("X-Atlassian-Token", "no-check")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return textErr(fmt.Sprintf("jira request failed: %v", err))
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, jiraMaxResponseBytes))
+	if resp.StatusCode != 200 {
+		return textErr(fmt.Sprintf("jira error %d: %s", resp.StatusCode, string(raw)))
+	}
+	var created []jiraAttachment
+	if err := json.Unmarshal(raw, &created); err != nil {
+		return textErr(fmt.Sprintf("parse response failed: %v", err))
+	}
+	if len(created) == 0 {
+		return textErr("jira accepted the upload but returned no attachment")
+	}
+
+	result := created[0].summary()
+	result["key"] = key
+	result["path"] = filepath.ToSlash(rel)
+	return textResult(result)
+}
+
+func jiraAttachmentDefinitions() []mcp.ToolDefinition {
+	return []mcp.ToolDefinition{
+		{
+			Name:        "jira_list_attachments",
+			Description: "List the files attached to a Jira issue with their id, file name, size, type, author and upload time.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"key": {Type: "string", Description: `Jira issue key, e.g. "PROJ-123".`},
+				},
+				Required: []string{"key"},
+			},
+		},
+		{
+			Name:        "jira_download_attachment",
+			Description: "Download a Jira attachment into the file workspace, e.g. a spec document to read with file_read. Files over the size limit (25 MiB by default, and the workspace's per-file quota) are refused.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"key":        {Type: "string", Description: `Jira issue key, e.g. "PROJ-123".`},
+					"attachment": {Type: "string", Description: "Attachment id or file name, from jira_list_attachments."},
+					"path":       {Type: "string", Description: `Workspace path to save to (default "jira-attachments/<KEY>/<file name>").`},
+					"workspace":  workspaceProperty,
+				},
+				Required: []string{"key", "attachment"},
+			},
+		},
+		{
+			Name:        "jira_upload_attachment",
+			Description: "Attach a file from the file workspace to a Jira issue, e.g. a generated report. Files over the size limit (25 MiB by default) are refused.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"key":       {Type: "string", Description: `Jira issue key, e.g. "PROJ-123".`},
+					"path":      {Type: "string", Description: `Workspace path of the file to attach, e.g. "reports/summary.pdf".`},
+					"filename":  {Type: "string", Description: "File name to show in Jira (default: the file's own name)."},
+					"workspace": workspaceProperty,
+				},
+				Required: []string{"key", "path"},
+			},
+		},
+	}
+}
@@ -0,0 +1,354 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"mcp-server/internal/mcp"
+)
+
+// Bulk limits. Jira's bulk fetch endpoint takes up to 100 keys per request;
+// jira_get_issues splits larger lists. Updates and transitions are one
+// request (or two) per issue, run JIRA_BULK_CONCURRENCY at a time.
+const (
+	jiraBulkFetchSize         = 100
+	jiraMaxBulkFetch          = 1000
+	jiraMaxBulkItems          = 100
+	jiraDefaultBulkConcurrent = 4
+	jiraMaxBulkConcurrent     = 16
+)
+
+func jiraBulkConcurrency() int {
+	n := int(envInt64("JIRA_BULK_CONCURRENCY", jiraDefaultBulkConcurrent))
+	return min(max(n, 1), jiraMaxBulkConcurrent)
+}
+
+// bulkRun calls fn for every index in [0, n), with at most
+// jiraBulkConcurrency calls in flight.
+func bulkRun(n int, fn func(i int)) {
+	sem := make(chan struct{}, jiraBulkConcurrency())
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
+
+// bulkKeys reads the keys argument, dropping duplicates but keeping order.
+func bulkKeys(args map[string]any, limit int) ([]string, error) {
+	var keys []string
+	seen := map[string]bool{}
+	for _, k := range optionalStringList(args, "keys") {
+		k = strings.ToUpper(k)
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) > limit {
+		return nil, fmt.Errorf("too many keys: %d (max %d per call)", len(keys), limit)
+	}
+	return keys, nil
+}
+
+// objectListArg reads an array-of-objects argument, also accepted as a JSON
+// string.
+func objectListArg(args map[string]any, key string) ([]map[string]any, error) {
+	raw := args[key]
+	if s, ok := raw.(string); ok {
+		if strings.TrimSpace(s) == "" {
+			return nil, nil
+		}
+		if err := json.Unmarshal([]byte(s), &raw); err != nil {
+			return nil, fmt.Errorf("%s must be a JSON array of objects: %v", key, err)
+		}
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array of objects", key)
+	}
+	out := make([]map[string]any, 0, len(list))
+	for i, item := range list {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s[%d] must be an object", key, i)
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// bulkItem turns one tool call's outcome into an entry of the per-item result
+// list: the call's own result fields plus ok, or the error text.
+func bulkItem(key string, res mcp.ToolCallResult, err error) map[string]any {
+	text := ""
+	if len(res.Content) > 0 {
+		text = res.Content[0].Text
+	}
+	if err != nil {
+		text = err.Error()
+	}
+	if err != nil || res.IsError {
+		return map[string]any{"key": key, "ok": false, "error": text}
+	}
+	entry := map[string]any{}
+	if json.Unmarshal([]byte(text), &entry) != nil {
+		entry = map[string]any{"result": text}
+	}
+	entry["key"] = key
+	entry["ok"] = true
+	return entry
+}
+
+func bulkResult(results []map[string]any) (mcp.ToolCallResult, error) {
+	succeeded := 0
+	for _, r := range results {
+		if r["ok"] == true {
+			succeeded++
+		}
+	}
+	return textResult(map[string]any{
+		"results":   results,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+	})
+}
+
+// getIssues fetches many issues by key through /rest/api/3/issue/bulkfetch,
+// so hydrating a search costs one request per 100 issues instead of one per
+// issue. Keys Jira cannot return are listed under errors.
+func (c *jiraClient) getIssues(args map[string]any) (mcp.ToolCallResult, error) {
+	keys, err := bulkKeys(args, jiraMaxBulkFetch)
+	if err != nil {
+		return textErr(err.Error())
+	}
+	if len(keys) == 0 {
+		return textErr(`missing required argument: "keys"`)
+	}
+	fields := optionalStringList(args, "fields")
+	if len(fields) == 0 {
+		fields = jiraDefaultSearchFields
+	}
+
+	type batch struct {
+		Issues []struct {
+			Key    string         `json:"key"`
+			Fields map[string]any `json:"fields"`
+		} `json:"issues"`
+		IssueErrors []struct {
+			ID           string `json:"id"`
+			ErrorMessage string `json:"errorMessage"`
+		} `json:"issueErrors"`
+	}
+	var chunks [][]string
+	for start := 0; start < len(keys); start += jiraBulkFetchSize {
+		chunks = append(chunks, keys[start:min(start+jiraBulkFetchSize, len(keys))])
+	}
+	batches := make([]batch, len(chunks))
+	failures := make([]error, len(chunks))
+	bulkRun(len(chunks), func(i int) {
+		body := map[string]any{"issueIdsOrKeys": chunks[i], "fields": fields}
+		raw, status, err := c.post("/rest/api/3/issue/bulkfetch", body)
+		switch {
+		case err != nil:
+			failures[i] = fmt.Errorf("jira request failed: %v", err)
+		case status != 200:
+			failures[i] = fmt.Errorf("jira error %d: %s", status, string(raw))
+		default:
+			if err := json.Unmarshal(raw, &batches[i]); err != nil {
+				failures[i] = fmt.Errorf("parse response failed: %v", err)
+			}
+		}
+	})
+
+	found := map[string]map[string]any{}
+	reasons := map[string]string{}
+	for i, b := range batches {
+		if failures[i] != nil {
+			for _, k := range chunks[i] {
+				reasons[k] = failures[i].Error()
+			}
+			continue
+		}
+		for _, iss := range b.Issues {
+			found[strings.ToUpper(iss.Key)] = c.issueSummary(iss.Key, iss.Fields, fields)
+		}
+		for _, e := range b.IssueErrors {
+			reasons[strings.ToUpper(e.ID)] = e.ErrorMessage
+		}
+	}
+
+	issues := []map[string]any{}
+	errors := []map[string]any{}
+	for _, k := range keys {
+		if iss, ok := found[k]; ok {
+			issues = append(issues, iss)
+			continue
+		}
+		reason := reasons[k]
+		if reason == "" {
+			reason = "issue does not exist or you do not have permission to see it"
+		}
+		errors = append(errors, map[string]any{"key": k, "error": reason})
+	}
+	result := map[string]any{
+		"issues": issues,
+		"count":  len(issues),
+	}
+	if len(errors) > 0 {
+		result["errors"] = errors
+	}
+	return textResult(result)
+}
+
+// bulkUpdate applies jira_update_issue to many issues: either the same
+// changes to every key, or a list of per-issue updates.
+func (c *jiraClient) bulkUpdate(args map[string]any) (mcp.ToolCallResult, error) {
+	updates, err := objectListArg(args, "updates")
+	if err != nil {
+		return textErr(err.Error())
+	}
+	keys, err := bulkKeys(args, jiraMaxBulkItems)
+	if err != nil {
+		return textErr(err.Error())
+	}
+
+	var items []map[string]any
+	switch {
+	case len(updates) > 0 && len(keys) > 0:
+		return textErr("provide either 'keys' with shared changes or 'updates', not both")
+	case len(updates) > 0:
+		if len(updates) > jiraMaxBulkItems {
+			return textErr(fmt.Sprintf("too many updates: %d (max %d per call)", len(updates), jiraMaxBulkItems))
+		}
+		items = updates
+	case len(keys) > 0:
+		// Check the shared changes once, so a bad field name is one error
+		// rather than the same error for every issue.
+		if _, err := c.issueFieldArgs(args); err != nil {
+			return textErr(err.Error())
+		}
+		for _, k := range keys {
+			item := map[string]any{}
+			for name, v := range args {
+				if name != "keys" && name != "updates" {
+					item[name] = v
+				}
+			}
+			item["key"] = k
+			items = append(items, item)
+		}
+	default:
+		return textErr("provide 'keys' with the changes to apply, or 'updates'")
+	}
+
+	results := make([]map[string]any, len(items))
+	bulkRun(len(items), func(i int) {
+		res, err := c.updateIssue(items[i])
+		results[i] = bulkItem(optionalString(items[i], "key", ""), res, err)
+	})
+	return bulkResult(results)
+}
+
+// bulkTransition applies jira_transition_issue to many issues. The
+// transition is looked up per issue, since issues may sit in different
+// statuses.
+func (c *jiraClient) bulkTransition(args map[string]any) (mcp.ToolCallResult, error) {
+	keys, err := bulkKeys(args, jiraMaxBulkItems)
+	if err != nil {
+		return textErr(err.Error())
+	}
+	if len(keys) == 0 {
+		return textErr(`missing required argument: "keys"`)
+	}
+	if _, errResult, err := requireString(args, "transition"); errResult != nil {
+		return *errResult, err
+	}
+	shared := map[string]any{}
+	for name, v := range args {
+		if name != "keys" {
+			shared[name] = v
+		}
+	}
+	if v := optionalString(args, "assignee", ""); v != "" {
+		id, err := c.resolveUser(v)
+		if err != nil {
+			return textErr(err.Error())
+		}
+		shared["assignee"] = id
+	}
+
+	results := make([]map[string]any, len(keys))
+	bulkRun(len(keys), func(i int) {
+		item := map[string]any{}
+		for name, v := range shared {
+			item[name] = v
+		}
+		item["key"] = keys[i]
+		res, err := c.transitionIssue(item)
+		results[i] = bulkItem(keys[i], res, err)
+	})
+	return bulkResult(results)
+}
+
+func jiraBulkDefinitions() []mcp.ToolDefinition {
+	return []mcp.ToolDefinition{
+		{
+			Name:        "jira_get_issues",
+			Description: "Fetch many Jira issues by key in one call (100 per request to Jira), returning the requested fields for each. Keys that do not exist or are not visible are listed under errors. Use it to hydrate jira_search_issues results instead of calling jira_get_issue per key.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"keys":   {Type: "string", Description: `Comma-separated issue keys, e.g. "PROJ-1,PROJ-2" (max 1000).`},
+					"fields": {Type: "string", Description: `Comma-separated fields to return, e.g. "summary,description,status,assignee,updated" (default "summary,status,assignee"). Rich text is returned as Markdown.`},
+				},
+				Required: []string{"keys"},
+			},
+		},
+		{
+			Name:        "jira_bulk_update",
+			Description: "Update many Jira issues at once, either applying the same changes to every key or a list of per-issue updates. Each issue is updated independently; the result lists every issue with ok or its error, plus succeeded and failed counts.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"keys":       {Type: "string", Description: `Comma-separated issue keys to apply the changes below to (max 100).`},
+					"updates":    {Type: "string", Description: `Per-issue updates instead of keys, as a JSON array of objects with "key" and any jira_update_issue arguments, e.g. [{"key": "PROJ-1", "priority": "High"}, {"key": "PROJ-2", "fields": {"Story Points": 3}}] (max 100).`},
+					"summary":    {Type: "string", Description: "New summary."},
+					"labels":     {Type: "string", Description: "Comma-separated labels; replaces the current labels."},
+					"priority":   {Type: "string", Description: `Priority name, e.g. "High".`},
+					"components": {Type: "string", Description: "Comma-separated component names; replaces the current components."},
+					"due_date":   {Type: "string", Description: "Due date as YYYY-MM-DD."},
+					"assignee":   {Type: "string", Description: `Assignee email, display name, account id or "me"; "none" unassigns.`},
+					"fields":     {Type: "object", Description: `Other fields keyed by name or id, e.g. {"Story Points": 5}.`},
+				},
+			},
+		},
+		{
+			Name:        "jira_bulk_transition",
+			Description: "Move many Jira issues through a workflow transition, chosen by name or destination status (e.g. \"Done\"). Each issue is transitioned independently with the same screen values; the result lists every issue with its new status or its error, plus succeeded and failed counts.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"keys":       {Type: "string", Description: `Comma-separated issue keys, e.g. "PROJ-1,PROJ-2" (max 100).`},
+					"transition": {Type: "string", Description: `Transition name or destination status name, e.g. "Done".`},
+					"resolution": {Type: "string", Description: `Resolution name, e.g. "Done", "Won't Do" (when the screen has a resolution field).`},
+					"assignee":   {Type: "string", Description: "Assignee email, display name or account id (when the screen has an assignee field)."},
+					"comment":    {Type: "string", Description: "Optional comment to add to each issue, in Markdown."},
+					"fields":     {Type: "object", Description: "Other screen field values keyed by field id or name."},
+				},
+				Required: []string{"keys", "transition"},
+			},
+		},
+	}
+}
@@ -0,0 +1,325 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jiraFieldSchema is the type description Jira attaches to every field.
+type jiraFieldSchema struct {
+	Type   string `json:"type"`
+	Items  string `json:"items"`
+	System string `json:"system"`
+	Custom string `json:"custom"`
+}
+
+// jiraFieldMeta describes a field on a Jira screen, as returned with
+// transitions (expand=transitions.fields) and by the create-issue metadata
+// endpoints.
+type jiraFieldMeta struct {
+	FieldID         string           `json:"fieldId"`
+	Name            string           `json:"name"`
+	Required        bool             `json:"required"`
+	HasDefaultValue bool             `json:"hasDefaultValue"`
+	Schema          jiraFieldSchema  `json:"schema"`
+	AllowedValues   []map[string]any `json:"allowedValues"`
+}
+
+// maxListedValues caps how many allowed values are shown per field.
+const maxListedValues = 50
+
+// summary describes the field for the agent: id, name, type, whether it is
+// required and, for option-like fields, the values it accepts.
+func (f *jiraFieldMeta) summary(id string) map[string]any {
+	out := map[string]any{
+		"id":       id,
+		"name":     f.Name,
+		"type":     f.typeName(),
+		"required": f.Required && !f.HasDefaultValue,
+	}
+	if names := f.allowedNames(); len(names) > 0 {
+		if len(names) > maxListedValues {
+			names = append(names[:maxListedValues], "…")
+		}
+		out["allowed_values"] = names
+	}
+	return out
+}
+
+func (f *jiraFieldMeta) typeName() string {
+	if f.Schema.Type == "array" && f.Schema.Items != "" {
+		return "array of " + f.Schema.Items
+	}
+	return f.Schema.Type
+}
+
+func (f *jiraFieldMeta) allowedNames() []string {
+	var names []string
+	for _, v := range f.AllowedValues {
+		if name := allowedValueName(v); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func allowedValueName(v map[string]any) string {
+	for _, k := range []string{"name", "value", "key", "id"} {
+		if s, ok := v[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// input converts an agent-supplied value into the JSON Jira expects for the
+// field, checking it against the allowed values where the field has them.
+// Option-like values may be given by name, value or id; users by account id;
+// arrays as a list or a comma-separated string; rich text as Markdown.
+func (f *jiraFieldMeta) input(v any) (any, error) {
+	if f.Schema.Type == "array" {
+		var items []any
+		switch x := v.(type) {
+		case []any:
+			items = x
+		case string:
+			for _, s := range strings.Split(x, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					items = append(items, s)
+				}
+			}
+		default:
+			items = []any{x}
+		}
+		out := make([]any, 0, len(items))
+		for _, item := range items {
+			converted, err := f.scalarInput(f.Schema.Items, item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, converted)
+		}
+		return out, nil
+	}
+	return f.scalarInput(f.Schema.Type, v)
+}
+
+// isRichText reports whether the field holds ADF rather than a plain
+// string: the description and environment system fields and paragraph
+// (textarea) custom fields.
+func (f *jiraFieldMeta) isRichText() bool {
+	return f.Schema.System == "description" || f.Schema.System == "environment" ||
+		strings.HasSuffix(f.Schema.Custom, ":textarea")
+}
+
+func (f *jiraFieldMeta) scalarInput(typ string, v any) (any, error) {
+	if len(f.AllowedValues) > 0 {
+		want := strings.TrimSpace(fmt.Sprint(v))
+		for _, allowed := range f.AllowedValues {
+			for _, k := range []string{"name", "value", "key", "id"} {
+				if s, ok := allowed[k].(string); ok && strings.EqualFold(s, want) {
+					if id, ok := allowed["id"].(string); ok {
+						return map[string]any{"id": id}, nil
+					}
+					return map[string]any{k: s}, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("%q is not an allowed value for %s. Allowed: %s",
+			want, f.Name, strings.Join(f.allowedNames(), ", "))
+	}
+	switch typ {
+	case "user":
+		if m, ok := v.(map[string]any); ok {
+			return m, nil
+		}
+		return map[string]any{"accountId": fmt.Sprint(v)}, nil
+	case "number":
+		switch x := v.(type) {
+		case float64:
+			return x, nil
+		case string:
+			n, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a number, got %q", f.Name, x)
+			}
+			return n, nil
+		}
+		return nil, fmt.Errorf("%s must be a number", f.Name)
+	case "priority", "resolution", "version", "component", "issuetype", "project":
+		if m, ok := v.(map[string]any); ok {
+			return m, nil
+		}
+		return map[string]any{"name": fmt.Sprint(v)}, nil
+	case "option":
+		if m, ok := v.(map[string]any); ok {
+			return m, nil
+		}
+		return map[string]any{"value": fmt.Sprint(v)}, nil
+	case "string":
+		if f.isRichText() {
+			return markdownToADF(fmt.Sprint(v)), nil
+		}
+		return fmt.Sprint(v), nil
+	case "date", "datetime":
+		return fmt.Sprint(v), nil
+	}
+	return v, nil
+}
+
+// jiraFieldCacheTTL is how long the field catalogue is reused before it is
+// fetched again, so newly created custom fields show up eventually.
+const jiraFieldCacheTTL = time.Hour
+
+// jiraFieldDef is one entry of the field catalogue (GET /rest/api/3/field).
+type jiraFieldDef struct {
+	ID     string          `json:"id"`
+	Name   string          `json:"name"`
+	Custom bool            `json:"custom"`
+	Schema jiraFieldSchema `json:"schema"`
+}
+
+// fieldDefs returns every system and custom field, cached for
+// jiraFieldCacheTTL.
+func (c *jiraClient) fieldDefs() ([]jiraFieldDef, error) {
+	c.mu.Lock()
+	defs, loaded := c.fields, c.fieldsLoaded
+	c.mu.Unlock()
+	if defs != nil && time.Since(loaded) < jiraFieldCacheTTL {
+		return defs, nil
+	}
+	if err := c.getJSON("/rest/api/3/field", &defs); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.fields, c.fieldsLoaded = defs, time.Now()
+	c.mu.Unlock()
+	return defs, nil
+}
+
+// resolveField finds a field by id ("customfield_10016") or by its name
+// ("Story Points"), case-insensitively. A name shared by several fields is
+// an error that lists their ids.
+func (c *jiraClient) resolveField(nameOrID string) (*jiraFieldDef, error) {
+	defs, err := c.fieldDefs()
+	if err != nil {
+		return nil, err
+	}
+	var matches []*jiraFieldDef
+	for i := range defs {
+		if defs[i].ID == nameOrID {
+			return &defs[i], nil
+		}
+		if strings.EqualFold(defs[i].Name, nameOrID) || strings.EqualFold(defs[i].ID, nameOrID) {
+			matches = append(matches, &defs[i])
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("unknown field %q; use jira_list_fields to find its name or id", nameOrID)
+	case 1:
+		return matches[0], nil
+	}
+	ids := make([]string, len(matches))
+	for i, m := range matches {
+		ids[i] = m.ID
+	}
+	sort.Strings(ids)
+	return nil, fmt.Errorf("field name %q is ambiguous; use one of the ids %s", nameOrID, strings.Join(ids, ", "))
+}
+
+// fieldInputs converts values keyed by field name or id into the fields
+// object of a create or edit request.
+func (c *jiraClient) fieldInputs(values map[string]any) (map[string]any, error) {
+	out := map[string]any{}
+	var problems []string
+	for name, v := range values {
+		def, err := c.resolveField(name)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		meta := jiraFieldMeta{FieldID: def.ID, Name: def.Name, Schema: def.Schema}
+		converted, err := meta.input(v)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		out[def.ID] = converted
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return out, nil
+}
+
+// objectArg reads an object argument, also accepted as a JSON string.
+func objectArg(args map[string]any, key string) (map[string]any, error) {
+	switch v := args[key].(type) {
+	case map[string]any:
+		return v, nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, nil
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(v), &m); err != nil {
+			return nil, fmt.Errorf("%s must be a JSON object: %v", key, err)
+		}
+		return m, nil
+	}
+	return nil, nil
+}
+
+// issueFieldArgs collects the optional issue fields shared by
+// jira_create_issue and jira_update_issue: labels, priority, components, due
+// date, assignee, reporter and the free-form fields object (keyed by human
+// name or id). Users may be given by email, display name or account id.
+func (c *jiraClient) issueFieldArgs(args map[string]any) (map[string]any, error) {
+	fields := map[string]any{}
+	if custom, err := objectArg(args, "fields"); err != nil {
+		return nil, err
+	} else if len(custom) > 0 {
+		if fields, err = c.fieldInputs(custom); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := args["labels"]; ok {
+		labels := optionalStringList(args, "labels")
+		if labels == nil {
+			labels = []string{}
+		}
+		fields["labels"] = labels
+	}
+	if v := optionalString(args, "priority", ""); v != "" {
+		fields["priority"] = map[string]string{"name": v}
+	}
+	if _, ok := args["components"]; ok {
+		components := []map[string]string{}
+		for _, name := range optionalStringList(args, "components") {
+			components = append(components, map[string]string{"name": name})
+		}
+		fields["components"] = components
+	}
+	if v := optionalString(args, "due_date", ""); v != "" {
+		if _, err := time.Parse("2006-01-02", v); err != nil {
+			return nil, fmt.Errorf("due_date must be YYYY-MM-DD, got %q", v)
+		}
+		fields["duedate"] = v
+	}
+	for _, name := range []string{"assignee", "reporter"} {
+		if v := optionalString(args, name, ""); v != "" {
+			ref, err := c.userRef(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			fields[name] = ref
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,257 @@
+package tools
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"mcp-server/internal/mcp"
+)
+
+// Comments, changelog and worklogs are paged by Jira with startAt/maxResults
+// and a total count. The tools expose the same paging: pass next_start_at
+// back as start_at to continue.
+const (
+	jiraDefaultHistoryPage = 50
+	jiraMaxHistoryPage     = 100
+)
+
+// jiraUser is the author/assignee object Jira embeds in most resources. It is
+// absent for anonymous and some automation actions. The email address is
+// only present when the user's privacy settings allow it.
+type jiraUser struct {
+	AccountID    string `json:"accountId"`
+	AccountType  string `json:"accountType"`
+	DisplayName  string `json:"displayName"`
+	EmailAddress string `json:"emailAddress"`
+	Active       bool   `json:"active"`
+}
+
+func (u *jiraUser) name() string {
+	if u == nil {
+		return ""
+	}
+	return u.DisplayName
+}
+
+func (u *jiraUser) id() string {
+	if u == nil {
+		return ""
+	}
+	return u.AccountID
+}
+
+// jiraPageArgs reads start_at and max_results.
+func jiraPageArgs(args map[string]any) (startAt, maxResults int) {
+	startAt = max(int(optionalFloat(args, "start_at", 0)), 0)
+	maxResults = int(optionalFloat(args, "max_results", jiraDefaultHistoryPage))
+	return startAt, min(max(maxResults, 1), jiraMaxHistoryPage)
+}
+
+// pageResult adds the paging fields shared by the history tools.
+func pageResult(result map[string]any, startAt, returned, total int) map[string]any {
+	result["start_at"] = startAt
+	result["total"] = total
+	if startAt+returned < total && returned > 0 {
+		result["next_start_at"] = startAt + returned
+	}
+	return result
+}
+
+func (c *jiraClient) listComments(args map[string]any) (mcp.ToolCallResult, error) {
+	key, errResult, err := requireString(args, "key")
+	if errResult != nil {
+		return *errResult, err
+	}
+	startAt, maxResults := jiraPageArgs(args)
+	orderBy := "created"
+	if optionalBool(args, "newest_first", false) {
+		orderBy = "-created"
+	}
+
+	var page struct {
+		Total    int `json:"total"`
+		Comments []struct {
+			ID      string    `json:"id"`
+			Author  *jiraUser `json:"author"`
+			Body    any       `json:"body"`
+			Created string    `json:"created"`
+			Updated string    `json:"updated"`
+		} `json:"comments"`
+	}
+	path := fmt.Sprintf("/rest/api/3/issue/%s/comment?startAt=%d&maxResults=%d&orderBy=%s",
+		url.PathEscape(key), startAt, maxResults, orderBy)
+	if err := c.getJSON(path, &page); err != nil {
+		return textErr(err.Error())
+	}
+
+	comments := make([]map[string]any, 0, len(page.Comments))
+	for _, cm := range page.Comments {
+		entry := map[string]any{
+			"id":        cm.ID,
+			"author":    cm.Author.name(),
+			"author_id": cm.Author.id(),
+			"created":   cm.Created,
+			"body":      adfToMarkdown(cm.Body),
+		}
+		if cm.Updated != cm.Created {
+			entry["updated"] = cm.Updated
+		}
+		comments = append(comments, entry)
+	}
+	return textResult(pageResult(map[string]any{
+		"key":      key,
+		"comments": comments,
+	}, startAt, len(comments), page.Total))
+}
+
+func (c *jiraClient) getChangelog(args map[string]any) (mcp.ToolCallResult, error) {
+	key, errResult, err := requireString(args, "key")
+	if errResult != nil {
+		return *errResult, err
+	}
+	startAt, maxResults := jiraPageArgs(args)
+	only := map[string]bool{}
+	for _, f := range optionalStringList(args, "fields") {
+		only[strings.ToLower(f)] = true
+	}
+
+	var page struct {
+		Total  int `json:"total"`
+		Values []struct {
+			Author  *jiraUser `json:"author"`
+			Created string    `json:"created"`
+			Items   []struct {
+				Field      string `json:"field"`
+				FieldID    string `json:"fieldId"`
+				From       string `json:"from"`
+				FromString string `json:"fromString"`
+				To         string `json:"to"`
+				ToString   string `json:"toString"`
+			} `json:"items"`
+		} `json:"values"`
+	}
+	path := fmt.Sprintf("/rest/api/3/issue/%s/changelog?startAt=%d&maxResults=%d",
+		url.PathEscape(key), startAt, maxResults)
+	if err := c.getJSON(path, &page); err != nil {
+		return textErr(err.Error())
+	}
+
+	// Each history entry can change several fields at once; flatten them to
+	// one change per field so status and assignee moves are easy to scan.
+	changes := []map[string]any{}
+	for _, h := range page.Values {
+		for _, it := range h.Items {
+			if len(only) > 0 && !only[strings.ToLower(it.Field)] && !only[strings.ToLower(it.FieldID)] {
+				continue
+			}
+			from, to := it.FromString, it.ToString
+			if from == "" {
+				from = it.From
+			}
+			if to == "" {
+				to = it.To
+			}
+			changes = append(changes, map[string]any{
+				"created": h.Created,
+				"author":  h.Author.name(),
+				"field":   it.Field,
+				"from":    from,
+				"to":      to,
+			})
+		}
+	}
+	return textResult(pageResult(map[string]any{
+		"key":     key,
+		"changes": changes,
+	}, startAt, len(page.Values), page.Total))
+}
+
+func (c *jiraClient) listWorklogs(args map[string]any) (mcp.ToolCallResult, error) {
+	key, errResult, err := requireString(args, "key")
+	if errResult != nil {
+		return *errResult, err
+	}
+	startAt, maxResults := jiraPageArgs(args)
+
+	var page struct {
+		Total    int `json:"total"`
+		Worklogs []struct {
+			ID               string    `json:"id"`
+			Author           *jiraUser `json:"author"`
+			Comment          any       `json:"comment"`
+			Started          string    `json:"started"`
+			TimeSpent        string    `json:"timeSpent"`
+			TimeSpentSeconds int       `json:"timeSpentSeconds"`
+		} `json:"worklogs"`
+	}
+	path := fmt.Sprintf("/rest/api/3/issue/%s/worklog?startAt=%d&maxResults=%d",
+		url.PathEscape(key), startAt, maxResults)
+	if err := c.getJSON(path, &page); err != nil {
+		return textErr(err.Error())
+	}
+
+	worklogs := make([]map[string]any, 0, len(page.Worklogs))
+	seconds := 0
+	for _, w := range page.Worklogs {
+		entry := map[string]any{
+			"id":         w.ID,
+			"author":     w.Author.name(),
+			"started":    w.Started,
+			"time_spent": w.TimeSpent,
+			"seconds":    w.TimeSpentSeconds,
+		}
+		if comment := adfToMarkdown(w.Comment); comment != "" {
+			entry["comment"] = comment
+		}
+		worklogs = append(worklogs, entry)
+		seconds += w.TimeSpentSeconds
+	}
+	return textResult(pageResult(map[string]any{
+		"key":           key,
+		"worklogs":      worklogs,
+		"total_seconds": seconds,
+	}, startAt, len(worklogs), page.Total))
+}
+
+func jiraHistoryDefinitions() []mcp.ToolDefinition {
+	pageProps := func(props map[string]mcp.Property) map[string]mcp.Property {
+		props["key"] = mcp.Property{Type: "string", Description: `Jira issue key, e.g. "PROJ-123".`}
+		props["start_at"] = mcp.Property{Type: "number", Description: "Index of the first entry to return (default 0). Pass a previous result's next_start_at to continue."}
+		props["max_results"] = mcp.Property{Type: "number", Description: "Entries per page (1–100, default 50)."}
+		return props
+	}
+	return []mcp.ToolDefinition{
+		{
+			Name:        "jira_list_comments",
+			Description: "List the comments on a Jira issue, oldest first, with author, timestamps and the body as Markdown. Paged; next_start_at is returned while more remain.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: pageProps(map[string]mcp.Property{
+					"newest_first": {Type: "boolean", Description: "Return the newest comments first (default false)."},
+				}),
+				Required: []string{"key"},
+			},
+		},
+		{
+			Name:        "jira_get_changelog",
+			Description: "Get the change history of a Jira issue as one entry per field change (date, author, field, from, to), oldest first — e.g. status transitions and assignee changes. Paged by history entry.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: pageProps(map[string]mcp.Property{
+					"fields": {Type: "string", Description: `Comma-separated fields to keep, e.g. "status,assignee" (default all).`},
+				}),
+				Required: []string{"key"},
+			},
+		},
+		{
+			Name:        "jira_list_worklogs",
+			Description: "List the work logged on a Jira issue: author, start time, time spent and comment, plus the total seconds on the page. Paged.",
+			InputSchema: mcp.JSONSchema{
+				Type:       "object",
+				Properties: pageProps(map[string]mcp.Property{}),
+				Required:   []string{"key"},
+			},
+		},
+	}
+}
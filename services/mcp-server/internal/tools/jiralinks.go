@@ -0,0 +1,280 @@
+package tools
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"mcp-server/internal/mcp"
+)
+
+// jiraIssueRef is the abbreviated issue Jira embeds for parents, subtasks
+// and linked issues.
+type jiraIssueRef struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary string `json:"summary"`
+		Status  struct {
+			Name string `json:"name"`
+		} `json:"status"`
+		IssueType struct {
+			Name string `json:"name"`
+		} `json:"issuetype"`
+	} `json:"fields"`
+}
+
+func (r *jiraIssueRef) summary() map[string]any {
+	return map[string]any{
+		"key":        r.Key,
+		"summary":    r.Fields.Summary,
+		"status":     r.Fields.Status.Name,
+		"issue_type": r.Fields.IssueType.Name,
+	}
+}
+
+// jiraIssueLink is one entry of an issue's issuelinks field. Exactly one of
+// InwardIssue and OutwardIssue is set: an outward link reads "this issue
+// <outward> other" (e.g. "blocks"), an inward one "this issue <inward>
+// other" (e.g. "is blocked by").
+type jiraIssueLink struct {
+	ID   string `json:"id"`
+	Type struct {
+		Name    string `json:"name"`
+		Inward  string `json:"inward"`
+		Outward string `json:"outward"`
+	} `json:"type"`
+	InwardIssue  *jiraIssueRef `json:"inwardIssue"`
+	OutwardIssue *jiraIssueRef `json:"outwardIssue"`
+}
+
+// jiraLinks describes links from the issue's point of view.
+func jiraLinks(links []jiraIssueLink) []map[string]any {
+	out := []map[string]any{}
+	for _, l := range links {
+		relation, other := l.Type.Outward, l.OutwardIssue
+		if other == nil {
+			relation, other = l.Type.Inward, l.InwardIssue
+		}
+		if other == nil {
+			continue
+		}
+		entry := other.summary()
+		entry["relation"] = relation
+		entry["link_id"] = l.ID
+		out = append(out, entry)
+	}
+	return out
+}
+
+func (c *jiraClient) linkIssues(args map[string]any) (mcp.ToolCallResult, error) {
+	key, errResult, err := requireString(args, "key")
+	if errResult != nil {
+		return *errResult, err
+	}
+	relation, errResult, err := requireString(args, "relation")
+	if errResult != nil {
+		return *errResult, err
+	}
+	target, errResult, err := requireString(args, "target")
+	if errResult != nil {
+		return *errResult, err
+	}
+
+	var types struct {
+		IssueLinkTypes []struct {
+			Name    string `json:"name"`
+			Inward  string `json:"inward"`
+			Outward string `json:"outward"`
+		} `json:"issueLinkTypes"`
+	}
+	if err := c.getJSON("/rest/api/3/issueLinkType", &types); err != nil {
+		return textErr(err.Error())
+	}
+
+	// Match the relation against the outward phrase ("blocks"), the type
+	// name ("Blocks") or the inward phrase ("is blocked by"); an inward match
+	// swaps the two issues.
+	want := strings.TrimSpace(relation)
+	typeName, inward := "", false
+	var available []string
+	for _, t := range types.IssueLinkTypes {
+		available = append(available, t.Outward, t.Inward)
+		if typeName != "" {
+			continue
+		}
+		if strings.EqualFold(t.Outward, want) || strings.EqualFold(t.Name, want) {
+			typeName = t.Name
+		} else if strings.EqualFold(t.Inward, want) {
+			typeName, inward = t.Name, true
+		}
+	}
+	if typeName == "" {
+		return textErr(fmt.Sprintf("unknown link relation %q. Available: %v", relation, available))
+	}
+
+	// In the create-link request the inwardIssue is the one the outward
+	// phrase applies to: {inwardIssue: A, outwardIssue: B} means "A blocks B".
+	from, to := key, target
+	if inward {
+		from, to = target, key
+	}
+	payload := map[string]any{
+		"type":         map[string]string{"name": typeName},
+		"inwardIssue":  map[string]string{"key": from},
+		"outwardIssue": map[string]string{"key": to},
+	}
+	if comment := optionalString(args, "comment", ""); comment != "" {
+		payload["comment"] = map[string]any{"body": markdownToADF(comment)}
+	}
+	raw, status, err := c.post("/rest/api/3/issueLink", payload)
+	if err != nil {
+		return textErr(fmt.Sprintf("jira request failed: %v", err))
+	}
+	if status != 201 {
+		return textErr(fmt.Sprintf("jira error %d: %s", status, string(raw)))
+	}
+
+	return textResult(map[string]any{
+		"key":      key,
+		"relation": relation,
+		"target":   target,
+		"type":     typeName,
+		"linked":   true,
+	})
+}
+
+// getIssueTree returns an issue with its children (the stories of an epic,
+// or the subtasks of a story) and each child's subtasks. Children are found
+// with the JQL "parent = KEY", which covers epics in both company- and
+// team-managed projects.
+func (c *jiraClient) getIssueTree(args map[string]any) (mcp.ToolCallResult, error) {
+	key, errResult, err := requireString(args, "key")
+	if errResult != nil {
+		return *errResult, err
+	}
+	maxItems := int(optionalFloat(args, "max_children", jiraDefaultAllItems))
+	maxItems = min(max(maxItems, 1), jiraMaxSearchItems)
+
+	var root struct {
+		Key    string `json:"key"`
+		Fields struct {
+			Summary string `json:"summary"`
+			Status  struct {
+				Name string `json:"name"`
+			} `json:"status"`
+			IssueType struct {
+				Name string `json:"name"`
+			} `json:"issuetype"`
+			Parent *jiraIssueRef `json:"parent"`
+		} `json:"fields"`
+	}
+	path := fmt.Sprintf("/rest/api/3/issue/%s?fields=summary,status,issuetype,parent", url.PathEscape(key))
+	if err := c.getJSON(path, &root); err != nil {
+		return textErr(err.Error())
+	}
+
+	fields := []string{"summary", "status", "issuetype", "assignee", "subtasks"}
+	jql := fmt.Sprintf("parent = %s ORDER BY rank", root.Key)
+	children := []map[string]any{}
+	statusCounts := map[string]int{}
+	subtaskCount := 0
+	token, truncated := "", false
+	for {
+		page, err := c.searchPage(jql, fields, min(jiraMaxPageSize, maxItems-len(children)), token)
+		if err != nil {
+			return textErr(err.Error())
+		}
+		for _, iss := range page.Issues {
+			child := map[string]any{
+				"key":        iss.Key,
+				"summary":    jiraFieldValue(iss.Fields["summary"]),
+				"status":     jiraFieldValue(iss.Fields["status"]),
+				"issue_type": jiraFieldValue(iss.Fields["issuetype"]),
+				"assignee":   jiraFieldValue(iss.Fields["assignee"]),
+			}
+			statusCounts[fmt.Sprint(child["status"])]++
+			if subs := jiraSubtaskSummaries(iss.Fields["subtasks"]); len(subs) > 0 {
+				child["subtasks"] = subs
+				subtaskCount += len(subs)
+			}
+			children = append(children, child)
+		}
+		token = page.NextPageToken
+		if page.IsLast {
+			token = ""
+		}
+		if token == "" || len(page.Issues) == 0 {
+			break
+		}
+		if len(children) >= maxItems {
+			truncated = true
+			break
+		}
+	}
+
+	out := map[string]any{
+		"key":           root.Key,
+		"summary":       root.Fields.Summary,
+		"status":        root.Fields.Status.Name,
+		"issue_type":    root.Fields.IssueType.Name,
+		"url":           c.baseURL + "/browse/" + root.Key,
+		"children":      children,
+		"child_count":   len(children),
+		"subtask_count": subtaskCount,
+		"status_counts": statusCounts,
+	}
+	if root.Fields.Parent != nil {
+		out["parent"] = root.Fields.Parent.summary()
+	}
+	if truncated {
+		out["truncated"] = true
+	}
+	return textResult(out)
+}
+
+// jiraSubtaskSummaries reduces a search hit's raw subtasks field.
+func jiraSubtaskSummaries(v any) []map[string]any {
+	list, _ := v.([]any)
+	var out []map[string]any
+	for _, item := range list {
+		m, _ := item.(map[string]any)
+		fields, _ := m["fields"].(map[string]any)
+		out = append(out, map[string]any{
+			"key":     m["key"],
+			"summary": jiraFieldValue(fields["summary"]),
+			"status":  jiraFieldValue(fields["status"]),
+		})
+	}
+	return out
+}
+
+func jiraLinkDefinitions() []mcp.ToolDefinition {
+	return []mcp.ToolDefinition{
+		{
+			Name:        "jira_link_issues",
+			Description: `Link two Jira issues, e.g. key "PROJ-1" relation "blocks" target "PROJ-2" records that PROJ-1 blocks PROJ-2. The relation may be a link phrase from either side ("blocks", "is blocked by", "relates to", "duplicates", "is duplicated by") or a link type name ("Blocks", "Relates").`,
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"key":      {Type: "string", Description: `Issue the relation is stated from, e.g. "PROJ-1".`},
+					"relation": {Type: "string", Description: `How key relates to target, e.g. "blocks", "is blocked by", "relates to", "duplicates".`},
+					"target":   {Type: "string", Description: `The other issue, e.g. "PROJ-2".`},
+					"comment":  {Type: "string", Description: "Optional comment to add with the link, in Markdown."},
+				},
+				Required: []string{"key", "relation", "target"},
+			},
+		},
+		{
+			Name:        "jira_get_issue_tree",
+			Description: "Get an issue with its hierarchy: for an epic its child issues, for a story or task its subtasks, and each child's subtasks. Includes the issue's own parent and a count of children per status.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"key":          {Type: "string", Description: `Jira issue key of the epic or parent issue, e.g. "PROJ-10".`},
+					"max_children": {Type: "number", Description: "Maximum number of child issues to return (default 500, max 5000)."},
+				},
+				Required: []string{"key"},
+			},
+		},
+	}
+}
@@ -0,0 +1,248 @@
+package tools
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcp-server/internal/mcp"
+)
+
+type jiraIssueType struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Subtask     bool   `json:"subtask"`
+}
+
+type jiraIssueTypes struct {
+	types  []jiraIssueType
+	loaded time.Time
+}
+
+// projectIssueTypes returns the issue types that can be created in a
+// project, cached per project for jiraFieldCacheTTL.
+func (c *jiraClient) projectIssueTypes(projectKey string) ([]jiraIssueType, error) {
+	c.mu.Lock()
+	cached, ok := c.issueTypes[projectKey]
+	c.mu.Unlock()
+	if ok && time.Since(cached.loaded) < jiraFieldCacheTTL {
+		return cached.types, nil
+	}
+	var page struct {
+		IssueTypes []jiraIssueType `json:"issueTypes"`
+	}
+	path := fmt.Sprintf("/rest/api/3/issue/createmeta/%s/issuetypes?maxResults=200", url.PathEscape(projectKey))
+	if err := c.getJSON(path, &page); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.issueTypes[projectKey] = jiraIssueTypes{types: page.IssueTypes, loaded: time.Now()}
+	c.mu.Unlock()
+	return page.IssueTypes, nil
+}
+
+// resolveIssueType matches an issue type name or id within a project.
+func (c *jiraClient) resolveIssueType(projectKey, nameOrID string) (*jiraIssueType, error) {
+	types, err := c.projectIssueTypes(projectKey)
+	if err != nil {
+		return nil, err
+	}
+	return matchIssueType(projectKey, types, nameOrID)
+}
+
+func matchIssueType(projectKey string, types []jiraIssueType, nameOrID string) (*jiraIssueType, error) {
+	var names []string
+	for i := range types {
+		if types[i].ID == nameOrID || strings.EqualFold(types[i].Name, nameOrID) {
+			return &types[i], nil
+		}
+		names = append(names, types[i].Name)
+	}
+	return nil, fmt.Errorf("issue type %q does not exist in project %s. Available: %s",
+		nameOrID, projectKey, strings.Join(names, ", "))
+}
+
+func (c *jiraClient) listProjects(args map[string]any) (mcp.ToolCallResult, error) {
+	startAt, maxResults := jiraPageArgs(args)
+	q := url.Values{}
+	q.Set("startAt", strconv.Itoa(startAt))
+	q.Set("maxResults", strconv.Itoa(maxResults))
+	if query := optionalString(args, "query", ""); query != "" {
+		q.Set("query", query)
+	}
+
+	var page struct {
+		Total  int `json:"total"`
+		Values []struct {
+			ID             string `json:"id"`
+			Key            string `json:"key"`
+			Name           string `json:"name"`
+			ProjectTypeKey string `json:"projectTypeKey"`
+			Style          string `json:"style"`
+		} `json:"values"`
+	}
+	if err := c.getJSON("/rest/api/3/project/search?"+q.Encode(), &page); err != nil {
+		return textErr(err.Error())
+	}
+
+	projects := make([]map[string]any, 0, len(page.Values))
+	for _, p := range page.Values {
+		projects = append(projects, map[string]any{
+			"id":   p.ID,
+			"key":  p.Key,
+			"name": p.Name,
+			"type": p.ProjectTypeKey,
+			// "next-gen" projects are team-managed.
+			"team_managed": p.Style == "next-gen",
+		})
+	}
+	return textResult(pageResult(map[string]any{"projects": projects}, startAt, len(projects), page.Total))
+}
+
+func (c *jiraClient) listIssueTypes(args map[string]any) (mcp.ToolCallResult, error) {
+	projectKey, errResult, err := requireString(args, "project_key")
+	if errResult != nil {
+		return *errResult, err
+	}
+	types, err := c.projectIssueTypes(projectKey)
+	if err != nil {
+		return textErr(err.Error())
+	}
+	out := make([]map[string]any, 0, len(types))
+	for _, t := range types {
+		entry := map[string]any{"id": t.ID, "name": t.Name, "subtask": t.Subtask}
+		if t.Description != "" {
+			entry["description"] = t.Description
+		}
+		out = append(out, entry)
+	}
+	return textResult(map[string]any{
+		"project_key": projectKey,
+		"issue_types": out,
+	})
+}
+
+func (c *jiraClient) getCreateFields(args map[string]any) (mcp.ToolCallResult, error) {
+	projectKey, errResult, err := requireString(args, "project_key")
+	if errResult != nil {
+		return *errResult, err
+	}
+	issueType, err := c.resolveIssueType(projectKey, optionalString(args, "issue_type", "Task"))
+	if err != nil {
+		return textErr(err.Error())
+	}
+
+	var page struct {
+		Fields []jiraFieldMeta `json:"fields"`
+	}
+	path := fmt.Sprintf("/rest/api/3/issue/createmeta/%s/issuetypes/%s?maxResults=200",
+		url.PathEscape(projectKey), url.PathEscape(issueType.ID))
+	if err := c.getJSON(path, &page); err != nil {
+		return textErr(err.Error())
+	}
+
+	required, optional := []map[string]any{}, []map[string]any{}
+	for i := range page.Fields {
+		f := &page.Fields[i]
+		switch f.FieldID {
+		case "project", "issuetype":
+			continue // set by jira_create_issue itself
+		}
+		s := f.summary(f.FieldID)
+		if s["required"] == true {
+			required = append(required, s)
+		} else {
+			optional = append(optional, s)
+		}
+	}
+	return textResult(map[string]any{
+		"project_key": projectKey,
+		"issue_type":  issueType.Name,
+		"required":    required,
+		"optional":    optional,
+	})
+}
+
+func (c *jiraClient) listFields(args map[string]any) (mcp.ToolCallResult, error) {
+	defs, err := c.fieldDefs()
+	if err != nil {
+		return textErr(err.Error())
+	}
+	query := strings.ToLower(optionalString(args, "query", ""))
+	customOnly := optionalBool(args, "custom_only", false)
+
+	fields := []map[string]any{}
+	for _, d := range defs {
+		if customOnly && !d.Custom {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(d.Name), query) && !strings.Contains(strings.ToLower(d.ID), query) {
+			continue
+		}
+		meta := jiraFieldMeta{Schema: d.Schema}
+		fields = append(fields, map[string]any{
+			"id":     d.ID,
+			"name":   d.Name,
+			"type":   meta.typeName(),
+			"custom": d.Custom,
+		})
+	}
+	return textResult(map[string]any{
+		"fields": fields,
+		"count":  len(fields),
+	})
+}
+
+func jiraMetaDefinitions() []mcp.ToolDefinition {
+	return []mcp.ToolDefinition{
+		{
+			Name:        "jira_list_projects",
+			Description: "List the Jira projects visible to the integration with their key, name and type.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"query":       {Type: "string", Description: "Only projects whose key or name contains this text."},
+					"start_at":    {Type: "number", Description: "Index of the first project to return (default 0)."},
+					"max_results": {Type: "number", Description: "Projects per page (1–100, default 50)."},
+				},
+			},
+		},
+		{
+			Name:        "jira_list_issue_types",
+			Description: "List the issue types that can be created in a Jira project (e.g. Task, Bug, Story, Epic, Sub-task).",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"project_key": {Type: "string", Description: `Jira project key, e.g. "PROJ".`},
+				},
+				Required: []string{"project_key"},
+			},
+		},
+		{
+			Name:        "jira_get_create_fields",
+			Description: "Describe the create screen for an issue type in a project: required and optional fields with their id, name, type and allowed values. Use it before jira_create_issue to fill in custom fields.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"project_key": {Type: "string", Description: `Jira project key, e.g. "PROJ".`},
+					"issue_type":  {Type: "string", Description: `Issue type name or id (default "Task").`},
+				},
+				Required: []string{"project_key"},
+			},
+		},
+		{
+			Name:        "jira_list_fields",
+			Description: `List Jira field definitions (system and custom) with their id, name and type, e.g. to find that "Story Points" is customfield_10016.`,
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"query":       {Type: "string", Description: "Only fields whose name or id contains this text."},
+					"custom_only": {Type: "boolean", Description: "Only custom fields (default false)."},
+				},
+			},
+		},
+	}
+}
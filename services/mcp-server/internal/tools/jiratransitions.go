@@ -0,0 +1,266 @@
+package tools
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"mcp-server/internal/mcp"
+)
+
+type jiraTransition struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	HasScreen bool   `json:"hasScreen"`
+	To        struct {
+		Name           string `json:"name"`
+		StatusCategory struct {
+			Key string `json:"key"`
+		} `json:"statusCategory"`
+	} `json:"to"`
+	Fields map[string]*jiraFieldMeta `json:"fields"`
+}
+
+// fieldIDs returns the transition's screen fields in a stable order:
+// required first, then by name.
+func (t *jiraTransition) fieldIDs() []string {
+	ids := make([]string, 0, len(t.Fields))
+	for id := range t.Fields {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := t.Fields[ids[i]], t.Fields[ids[j]]
+		if a.Required != b.Required {
+			return a.Required
+		}
+		return a.Name < b.Name
+	})
+	return ids
+}
+
+// field finds a screen field by id or (case-insensitive) name.
+func (t *jiraTransition) field(nameOrID string) (string, *jiraFieldMeta) {
+	if f, ok := t.Fields[nameOrID]; ok {
+		return nameOrID, f
+	}
+	for id, f := range t.Fields {
+		if strings.EqualFold(f.Name, nameOrID) || strings.EqualFold(id, nameOrID) {
+			return id, f
+		}
+	}
+	return "", nil
+}
+
+// transitions fetches the transitions available to the issue in its current
+// status, with the fields each transition's screen accepts.
+func (c *jiraClient) transitions(key string) ([]jiraTransition, error) {
+	var result struct {
+		Transitions []jiraTransition `json:"transitions"`
+	}
+	path := fmt.Sprintf("/rest/api/3/issue/%s/transitions?expand=transitions.fields", url.PathEscape(key))
+	if err := c.getJSON(path, &result); err != nil {
+		return nil, err
+	}
+	return result.Transitions, nil
+}
+
+func (c *jiraClient) listTransitions(args map[string]any) (mcp.ToolCallResult, error) {
+	key, errResult, err := requireString(args, "key")
+	if errResult != nil {
+		return *errResult, err
+	}
+	transitions, err := c.transitions(key)
+	if err != nil {
+		return textErr(err.Error())
+	}
+
+	out := make([]map[string]any, 0, len(transitions))
+	for i := range transitions {
+		t := &transitions[i]
+		fields := make([]map[string]any, 0, len(t.Fields))
+		for _, id := range t.fieldIDs() {
+			fields = append(fields, t.Fields[id].summary(id))
+		}
+		out = append(out, map[string]any{
+			"id":          t.ID,
+			"name":        t.Name,
+			"to":          t.To.Name,
+			"to_category": statusCategoryName(t.To.StatusCategory.Key),
+			"has_screen":  t.HasScreen,
+			"fields":      fields,
+		})
+	}
+	return textResult(map[string]any{
+		"key":         key,
+		"transitions": out,
+	})
+}
+
+// transitionIssue moves an issue through a workflow transition, chosen by
+// id, name or destination status. Field values are checked against the
+// transition's screen before anything is posted, so a missing resolution or
+// an invalid option is reported by name instead of as an opaque 400.
+func (c *jiraClient) transitionIssue(args map[string]any) (mcp.ToolCallResult, error) {
+	key, errResult, err := requireString(args, "key")
+	if errResult != nil {
+		return *errResult, err
+	}
+	want, errResult, err := requireString(args, "transition")
+	if errResult != nil {
+		return *errResult, err
+	}
+
+	transitions, err := c.transitions(key)
+	if err != nil {
+		return textErr(err.Error())
+	}
+	var t *jiraTransition
+	var available []string
+	for i := range transitions {
+		tr := &transitions[i]
+		available = append(available, fmt.Sprintf("%s (→ %s)", tr.Name, tr.To.Name))
+		if t == nil && (tr.ID == want || strings.EqualFold(tr.Name, want) || strings.EqualFold(tr.To.Name, want)) {
+			t = tr
+		}
+	}
+	if t == nil {
+		return textErr(fmt.Sprintf("no transition matching %q found for issue %s. Available: %s",
+			want, key, strings.Join(available, ", ")))
+	}
+
+	// Gather values: the named arguments plus the free-form fields object.
+	values := map[string]any{}
+	fields, err := objectArg(args, "fields")
+	if err != nil {
+		return textErr(err.Error())
+	}
+	for k, v := range fields {
+		values[k] = v
+	}
+	if v := optionalString(args, "resolution", ""); v != "" {
+		values["resolution"] = v
+	}
+	if v := optionalString(args, "assignee", ""); v != "" {
+		id, err := c.resolveUser(v)
+		if err != nil {
+			return textErr(err.Error())
+		}
+		values["assignee"] = id
+	}
+
+	payloadFields := map[string]any{}
+	var problems []string
+	for name, v := range values {
+		id, meta := t.field(name)
+		if meta == nil {
+			problems = append(problems, fmt.Sprintf("%s is not on the %q transition screen", name, t.Name))
+			continue
+		}
+		converted, err := meta.input(v)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		payloadFields[id] = converted
+	}
+	for _, id := range t.fieldIDs() {
+		meta := t.Fields[id]
+		if _, set := payloadFields[id]; !set && meta.Required && !meta.HasDefaultValue {
+			msg := fmt.Sprintf("%s (%s) is required", meta.Name, id)
+			if names := meta.allowedNames(); len(names) > 0 {
+				msg += "; allowed: " + strings.Join(names, ", ")
+			}
+			problems = append(problems, msg)
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return textErr(fmt.Sprintf("cannot apply transition %q to %s: %s", t.Name, key, strings.Join(problems, "; ")))
+	}
+
+	payload := map[string]any{"transition": map[string]string{"id": t.ID}}
+	if len(payloadFields) > 0 {
+		payload["fields"] = payloadFields
+	}
+	// A comment can ride along with a transition that has a screen;
+	// otherwise it is added separately once the transition succeeds.
+	comment := optionalString(args, "comment", "")
+	if comment != "" && t.HasScreen {
+		payload["update"] = map[string]any{
+			"comment": []any{map[string]any{"add": map[string]any{"body": markdownToADF(comment)}}},
+		}
+	}
+
+	path := fmt.Sprintf("/rest/api/3/issue/%s/transitions", url.PathEscape(key))
+	raw, status, err := c.post(path, payload)
+	if err != nil {
+		return textErr(fmt.Sprintf("jira request failed: %v", err))
+	}
+	// Jira returns 204 No Content on success.
+	if status != 204 {
+		return textErr(fmt.Sprintf("jira error %d: %s", status, string(raw)))
+	}
+
+	result := map[string]any{
+		"key":             key,
+		"url":             c.baseURL + "/browse/" + key,
+		"transitioned_to": t.Name,
+		"status":          t.To.Name,
+	}
+	if len(payloadFields) > 0 {
+		set := make([]string, 0, len(payloadFields))
+		for id := range payloadFields {
+			set = append(set, t.Fields[id].Name)
+		}
+		sort.Strings(set)
+		result["fields_set"] = set
+	}
+	if comment != "" && !t.HasScreen {
+		commentPath := fmt.Sprintf("/rest/api/3/issue/%s/comment", url.PathEscape(key))
+		raw, status, err := c.post(commentPath, map[string]any{"body": markdownToADF(comment)})
+		switch {
+		case err != nil:
+			result["comment_error"] = fmt.Sprintf("jira request failed: %v", err)
+		case status != 201:
+			result["comment_error"] = fmt.Sprintf("jira error %d: %s", status, string(raw))
+		default:
+			result["comment_added"] = true
+		}
+	} else if comment != "" {
+		result["comment_added"] = true
+	}
+	return textResult(result)
+}
+
+func jiraTransitionDefinitions() []mcp.ToolDefinition {
+	return []mcp.ToolDefinition{
+		{
+			Name:        "jira_list_transitions",
+			Description: "List the workflow transitions available for a Jira issue in its current status, with the destination status and the fields each transition's screen accepts (required flag, type and allowed values such as resolutions).",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"key": {Type: "string", Description: `Jira issue key, e.g. "PROJ-123".`},
+				},
+				Required: []string{"key"},
+			},
+		},
+		{
+			Name:        "jira_transition_issue",
+			Description: "Move a Jira issue through a workflow transition, chosen by transition id, name or destination status. Values for the transition screen (resolution, assignee, other fields) are validated before posting; missing required fields are reported with their allowed values. Use jira_list_transitions to see what each transition needs.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"key":        {Type: "string", Description: `Jira issue key, e.g. "PROJ-123".`},
+					"transition": {Type: "string", Description: `Transition id or name, or destination status name, e.g. "Done".`},
+					"resolution": {Type: "string", Description: `Resolution name, e.g. "Done", "Won't Do" (when the screen has a resolution field).`},
+					"assignee":   {Type: "string", Description: "Assignee email, display name or account id (when the screen has an assignee field)."},
+					"comment":    {Type: "string", Description: "Optional comment to add with the transition, in Markdown."},
+					"fields":     {Type: "object", Description: `Other screen field values keyed by field id or name, e.g. {"Fix versions": "1.2", "customfield_10020": "x"}.`},
+				},
+				Required: []string{"key", "transition"},
+			},
+		},
+	}
+}
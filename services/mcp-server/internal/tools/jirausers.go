@@ -0,0 +1,303 @@
+package tools
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"mcp-server/internal/mcp"
+)
+
+// User search results are cached briefly so that resolving the same name
+// for several issues in a row costs one request.
+const (
+	jiraUserCacheTTL     = 10 * time.Minute
+	jiraUserCacheEntries = 500
+	jiraUserSearchSize   = 50
+)
+
+// jiraAccountIDPattern matches Atlassian account ids: 24 hex characters, or
+// "<number>:<uuid>" for newer accounts.
+var jiraAccountIDPattern = regexp.MustCompile(`^(?i:[0-9a-f]{24}|\d+:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})$`)
+
+type jiraUserHits struct {
+	users  []jiraUser
+	loaded time.Time
+}
+
+// findUsers searches users by display name or email, returning only real
+// people (not apps or customer accounts).
+func (c *jiraClient) findUsers(query string) ([]jiraUser, error) {
+	cacheKey := strings.ToLower(strings.TrimSpace(query))
+	c.mu.Lock()
+	hits, ok := c.users[cacheKey]
+	c.mu.Unlock()
+	if ok && time.Since(hits.loaded) < jiraUserCacheTTL {
+		return hits.users, nil
+	}
+
+	var found []jiraUser
+	path := fmt.Sprintf("/rest/api/3/user/search?query=%s&maxResults=%d", url.QueryEscape(query), jiraUserSearchSize)
+	if err := c.getJSON(path, &found); err != nil {
+		return nil, err
+	}
+	users := []jiraUser{}
+	for _, u := range found {
+		if u.AccountType == "" || u.AccountType == "atlassian" {
+			users = append(users, u)
+		}
+	}
+
+	c.mu.Lock()
+	if len(c.users) >= jiraUserCacheEntries {
+		c.users = map[string]jiraUserHits{}
+	}
+	c.users[cacheKey] = jiraUserHits{users: users, loaded: time.Now()}
+	c.mu.Unlock()
+	return users, nil
+}
+
+// myself returns the account the integration authenticates as.
+func (c *jiraClient) myself() (*jiraUser, error) {
+	c.mu.Lock()
+	self := c.self
+	c.mu.Unlock()
+	if self != nil {
+		return self, nil
+	}
+	var u jiraUser
+	if err := c.getJSON("/rest/api/3/myself", &u); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.self = &u
+	c.mu.Unlock()
+	return &u, nil
+}
+
+// resolveUser turns an account id, email address, display name or "me" into
+// an account id. An exact email or name match wins; otherwise the search
+// must narrow to a single active user.
+func (c *jiraClient) resolveUser(who string) (string, error) {
+	who = strings.TrimSpace(who)
+	if jiraAccountIDPattern.MatchString(who) {
+		return who, nil
+	}
+	if strings.EqualFold(who, "me") {
+		self, err := c.myself()
+		if err != nil {
+			return "", err
+		}
+		return self.AccountID, nil
+	}
+
+	users, err := c.findUsers(who)
+	if err != nil {
+		return "", err
+	}
+	var exact, active []jiraUser
+	for _, u := range users {
+		if strings.EqualFold(u.EmailAddress, who) || strings.EqualFold(u.DisplayName, who) {
+			exact = append(exact, u)
+		}
+		if u.Active {
+			active = append(active, u)
+		}
+	}
+	candidates := exact
+	if len(candidates) == 0 {
+		candidates = active
+	}
+	switch len(candidates) {
+	case 0:
+		return "", fmt.Errorf("no active Jira user matches %q; use jira_find_user to look them up", who)
+	case 1:
+		return candidates[0].AccountID, nil
+	}
+	names := make([]string, len(candidates))
+	for i, u := range candidates {
+		names[i] = userLabel(u)
+	}
+	return "", fmt.Errorf("%q matches several Jira users: %s; use an email or account id",
+		who, strings.Join(names, ", "))
+}
+
+func userLabel(u jiraUser) string {
+	if u.EmailAddress != "" {
+		return fmt.Sprintf("%s <%s> (%s)", u.DisplayName, u.EmailAddress, u.AccountID)
+	}
+	return fmt.Sprintf("%s (%s)", u.DisplayName, u.AccountID)
+}
+
+// isUnassign reports whether who asks to clear a user field.
+func isUnassign(who string) bool {
+	switch strings.ToLower(strings.TrimSpace(who)) {
+	case "none", "unassigned":
+		return true
+	}
+	return false
+}
+
+// userRef is the value of a user field: {"accountId": ...}, or nil for
+// "none"/"unassigned".
+func (c *jiraClient) userRef(who string) (any, error) {
+	if isUnassign(who) {
+		return nil, nil
+	}
+	id, err := c.resolveUser(who)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"accountId": id}, nil
+}
+
+func (c *jiraClient) findUser(args map[string]any) (mcp.ToolCallResult, error) {
+	query, errResult, err := requireString(args, "query")
+	if errResult != nil {
+		return *errResult, err
+	}
+	maxResults := int(optionalFloat(args, "max_results", 10))
+	maxResults = min(max(maxResults, 1), jiraUserSearchSize)
+
+	users, err := c.findUsers(query)
+	if err != nil {
+		return textErr(err.Error())
+	}
+	out := []map[string]any{}
+	for _, u := range users[:min(len(users), maxResults)] {
+		entry := map[string]any{
+			"account_id": u.AccountID,
+			"name":       u.DisplayName,
+			"active":     u.Active,
+		}
+		if u.EmailAddress != "" {
+			entry["email"] = u.EmailAddress
+		}
+		out = append(out, entry)
+	}
+	return textResult(map[string]any{
+		"query": query,
+		"users": out,
+		"count": len(out),
+	})
+}
+
+func (c *jiraClient) assignIssue(args map[string]any) (mcp.ToolCallResult, error) {
+	key, errResult, err := requireString(args, "key")
+	if errResult != nil {
+		return *errResult, err
+	}
+	assignee, errResult, err := requireString(args, "assignee")
+	if errResult != nil {
+		return *errResult, err
+	}
+	// The assignee endpoint takes {"accountId": null} to unassign.
+	payload := map[string]any{"accountId": nil}
+	if !isUnassign(assignee) {
+		id, err := c.resolveUser(assignee)
+		if err != nil {
+			return textErr(err.Error())
+		}
+		payload["accountId"] = id
+	}
+	path := fmt.Sprintf("/rest/api/3/issue/%s/assignee", url.PathEscape(key))
+	raw, status, err := c.put(path, payload)
+	if err != nil {
+		return textErr(fmt.Sprintf("jira request failed: %v", err))
+	}
+	if status != 204 {
+		return textErr(fmt.Sprintf("jira error %d: %s", status, string(raw)))
+	}
+	return textResult(map[string]any{
+		"key":        key,
+		"url":        c.baseURL + "/browse/" + key,
+		"account_id": payload["accountId"],
+		"assigned":   payload["accountId"] != nil,
+	})
+}
+
+func (c *jiraClient) watchIssue(args map[string]any) (mcp.ToolCallResult, error) {
+	key, errResult, err := requireString(args, "key")
+	if errResult != nil {
+		return *errResult, err
+	}
+	users := optionalStringList(args, "users")
+	if len(users) == 0 {
+		users = []string{"me"}
+	}
+
+	path := fmt.Sprintf("/rest/api/3/issue/%s/watchers", url.PathEscape(key))
+	added := []string{}
+	var problems []string
+	for _, who := range users {
+		id, err := c.resolveUser(who)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		// The request body is the bare account id as a JSON string.
+		raw, status, err := c.post(path, id)
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("%s: jira request failed: %v", who, err))
+		case status != 204:
+			problems = append(problems, fmt.Sprintf("%s: jira error %d: %s", who, status, string(raw)))
+		default:
+			added = append(added, id)
+		}
+	}
+	if len(added) == 0 {
+		return textErr(strings.Join(problems, "; "))
+	}
+	result := map[string]any{
+		"key":            key,
+		"watchers_added": added,
+	}
+	if len(problems) > 0 {
+		result["errors"] = problems
+	}
+	return textResult(result)
+}
+
+func jiraUserDefinitions() []mcp.ToolDefinition {
+	return []mcp.ToolDefinition{
+		{
+			Name:        "jira_find_user",
+			Description: "Find Jira users by display name or email address. Returns their account ids, which the assignee, reporter and watcher arguments also accept directly.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"query":       {Type: "string", Description: `Name or email to search for, e.g. "Jane" or "jane@example.com".`},
+					"max_results": {Type: "number", Description: "Maximum number of users to return (1–50, default 10)."},
+				},
+				Required: []string{"query"},
+			},
+		},
+		{
+			Name:        "jira_assign_issue",
+			Description: "Assign a Jira issue to a user given by email, display name, account id or \"me\", or unassign it with \"none\".",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"key":      {Type: "string", Description: `Jira issue key, e.g. "PROJ-123".`},
+					"assignee": {Type: "string", Description: `Email, display name, account id, "me", or "none" to unassign.`},
+				},
+				Required: []string{"key", "assignee"},
+			},
+		},
+		{
+			Name:        "jira_watch_issue",
+			Description: "Add watchers to a Jira issue so they are notified of changes. Defaults to the integration's own account.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"key":   {Type: "string", Description: `Jira issue key, e.g. "PROJ-123".`},
+					"users": {Type: "string", Description: `Comma-separated emails, display names or account ids (default "me").`},
+				},
+				Required: []string{"key"},
+			},
+		},
+	}
+}
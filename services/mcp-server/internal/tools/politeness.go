@@ -0,0 +1,390 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultUserAgent identifies the fetcher honestly; robots.txt groups are
+// matched against its product token ("ResearchBot").
+const defaultUserAgent = "ResearchBot/1.0 (+mcp-server; AI research agent)"
+
+// errRobotsDisallowed is returned (wrapped in a *url.Error) when robots.txt
+// forbids a fetch.
+type errRobotsDisallowed struct {
+	url       string
+	userAgent string
+}
+
+func (e *errRobotsDisallowed) Error() string {
+	return fmt.Sprintf("robots.txt disallows fetching %s for user agent %q", e.url, e.userAgent)
+}
+
+// politeTransport is the http.RoundTripper behind the page-fetching web
+// tools. Before each request it consults the host's robots.txt (cached) and
+// waits for a per-host concurrency slot and rate-limit turn, so an agent
+// looping over one site cannot hammer it. Search API calls do not go through
+// it.
+//
+// Configuration (all optional):
+//   - WEB_USER_AGENT          User-Agent sent with fetches, default defaultUserAgent
+//   - WEB_RESPECT_ROBOTS      "false" disables robots.txt checks, default true
+//   - WEB_ROBOTS_TTL          how long a robots.txt stays cached, default 1h
+//   - WEB_DOMAIN_CONCURRENCY  in-flight requests per host, default 2
+//   - WEB_DOMAIN_RPS          requests per second per host, default 2; a larger
+//     Crawl-delay in robots.txt wins
+type politeTransport struct {
+	base          http.RoundTripper
+	userAgent     string
+	respectRobots bool
+	robotsTTL     time.Duration
+	concurrency   int
+	interval      time.Duration
+
+	mu     sync.Mutex
+	hosts  map[string]*hostState
+	robots map[string]*robotsEntry
+}
+
+// hostState tracks the concurrency slots and next permitted start time for
+// one scheme+host.
+type hostState struct {
+	slots chan struct{}
+	next  time.Time
+}
+
+type robotsEntry struct {
+	rules     *robotsRules
+	fetchedAt time.Time
+}
+
+func newPoliteTransport() *politeTransport {
+	rps, err := strconv.ParseFloat(os.Getenv("WEB_DOMAIN_RPS"), 64)
+	if err != nil || rps <= 0 {
+		rps = 2
+	}
+	concurrency := int(envInt64("WEB_DOMAIN_CONCURRENCY", 2))
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	userAgent := os.Getenv("WEB_USER_AGENT")
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	return &politeTransport{
+		base:          http.DefaultTransport,
+		userAgent:     userAgent,
+		respectRobots: os.Getenv("WEB_RESPECT_ROBOTS") != "false",
+		robotsTTL:     envDuration("WEB_ROBOTS_TTL", time.Hour),
+		concurrency:   concurrency,
+		interval:      time.Duration(float64(time.Second) / rps),
+		hosts:         map[string]*hostState{},
+		robots:        map[string]*robotsEntry{},
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *politeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	origin := req.URL.Scheme + "://" + req.URL.Host
+	host := t.host(origin)
+
+	if t.respectRobots && req.URL.Path != "/robots.txt" {
+		rules, err := t.robotsFor(req, origin, host)
+		if err != nil {
+			return nil, err
+		}
+		if !rules.allowed(req.URL.RequestURI()) {
+			return nil, &errRobotsDisallowed{url: req.URL.String(), userAgent: t.userAgent}
+		}
+	}
+
+	release, err := t.acquire(req, host, t.crawlDelay(origin))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	// Hold the slot until the caller has finished reading the body.
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+func (t *politeTransport) host(origin string) *hostState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.hosts[origin]
+	if !ok {
+		h = &hostState{slots: make(chan struct{}, t.concurrency)}
+		t.hosts[origin] = h
+	}
+	return h
+}
+
+// acquire blocks until a concurrency slot is free and the host's rate limit
+// allows another request, then returns the function that frees the slot.
+func (t *politeTransport) acquire(req *http.Request, h *hostState, crawlDelay time.Duration) (func(), error) {
+	select {
+	case h.slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	var once sync.Once
+	release := func() { once.Do(func() { <-h.slots }) }
+
+	interval := max(t.interval, crawlDelay)
+	t.mu.Lock()
+	start := time.Now()
+	if h.next.After(start) {
+		start = h.next
+	}
+	h.next = start.Add(interval)
+	t.mu.Unlock()
+
+	if wait := time.Until(start); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			release()
+			return nil, req.Context().Err()
+		}
+	}
+	return release, nil
+}
+
+func (t *politeTransport) crawlDelay(origin string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e, ok := t.robots[origin]; ok {
+		return e.rules.crawlDelay
+	}
+	return 0
+}
+
+// robotsFor returns the cached rules for origin, fetching robots.txt when the
+// cache is empty or expired. Per RFC 9309, up to maxRobotsRedirects redirects
+// are followed; then a 4xx (or too many redirects) means "no restrictions"
+// and a 5xx means "disallow everything" until the next refresh.
+func (t *politeTransport) robotsFor(req *http.Request, origin string, h *hostState) (*robotsRules, error) {
+	t.mu.Lock()
+	e, ok := t.robots[origin]
+	t.mu.Unlock()
+	if ok && time.Since(e.fetchedAt) < t.robotsTTL {
+		return e.rules, nil
+	}
+
+	rules := &robotsRules{}
+	target := origin + "/robots.txt"
+	for hops := 0; ; hops++ {
+		robotsReq, err := http.NewRequestWithContext(req.Context(), "GET", target, nil)
+		if err != nil {
+			return nil, err
+		}
+		robotsReq.Header.Set("User-Agent", t.userAgent)
+
+		release, err := t.acquire(robotsReq, h, 0)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(robotsReq)
+		release()
+		if err != nil {
+			return nil, fmt.Errorf("fetch robots.txt: %w", err)
+		}
+
+		if loc := resp.Header.Get("Location"); isRedirect(resp.StatusCode) && loc != "" && hops < maxRobotsRedirects {
+			resp.Body.Close()
+			next, err := robotsReq.URL.Parse(loc)
+			if err != nil || (next.Scheme != "http" && next.Scheme != "https") {
+				break
+			}
+			target = next.String()
+			h = t.host(next.Scheme + "://" + next.Host)
+			continue
+		}
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			rules = parseRobots(io.LimitReader(resp.Body, 512*1024), t.userAgent)
+		case resp.StatusCode >= 500:
+			rules = &robotsRules{disallowAll: true}
+		}
+		resp.Body.Close()
+		break
+	}
+
+	t.mu.Lock()
+	t.robots[origin] = &robotsEntry{rules: rules, fetchedAt: time.Now()}
+	t.mu.Unlock()
+	return rules, nil
+}
+
+// maxRobotsRedirects is how many redirects a robots.txt fetch follows, the
+// minimum RFC 9309 asks for.
+const maxRobotsRedirects = 5
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// releasingBody frees a host slot once the response body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// ── robots.txt ──────────────────────────────────────────────────────────────
+
+// robotsRules is the group of robots.txt rules that applies to our agent.
+type robotsRules struct {
+	allow       []string
+	disallow    []string
+	crawlDelay  time.Duration
+	disallowAll bool
+}
+
+// parseRobots extracts the rules for userAgent from a robots.txt body: the
+// group naming our product token if any, otherwise the "*" group.
+func parseRobots(r io.Reader, userAgent string) *robotsRules {
+	token := strings.ToLower(userAgent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+
+	var specific, wildcard *robotsRules
+	var current []*robotsRules // groups the current rule lines apply to
+	inAgents := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "user-agent":
+			if !inAgents {
+				current = nil
+				inAgents = true
+			}
+			agent := strings.ToLower(value)
+			switch {
+			case agent == "*":
+				if wildcard == nil {
+					wildcard = &robotsRules{}
+				}
+				current = append(current, wildcard)
+			case token != "" && agent == token:
+				if specific == nil {
+					specific = &robotsRules{}
+				}
+				current = append(current, specific)
+			}
+		case "allow", "disallow", "crawl-delay":
+			inAgents = false
+			for _, g := range current {
+				switch field {
+				case "allow":
+					if value != "" {
+						g.allow = append(g.allow, value)
+					}
+				case "disallow":
+					if value != "" {
+						g.disallow = append(g.disallow, value)
+					}
+				case "crawl-delay":
+					if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+						g.crawlDelay = time.Duration(secs * float64(time.Second))
+					}
+				}
+			}
+		default:
+			inAgents = false
+		}
+	}
+
+	switch {
+	case specific != nil:
+		return specific
+	case wildcard != nil:
+		return wildcard
+	default:
+		return &robotsRules{}
+	}
+}
+
+// allowed applies the longest-match rule from RFC 9309: the most specific
+// matching pattern wins, and Allow wins a tie.
+func (r *robotsRules) allowed(path string) bool {
+	if r.disallowAll {
+		return false
+	}
+	best, allow := -1, true
+	for _, p := range r.allow {
+		if robotsMatch(p, path) && len(p) >= best {
+			best, allow = len(p), true
+		}
+	}
+	for _, p := range r.disallow {
+		if robotsMatch(p, path) && len(p) > best {
+			best, allow = len(p), false
+		}
+	}
+	return allow
+}
+
+// robotsMatch reports whether path matches a robots.txt pattern, which may
+// contain "*" wildcards and a trailing "$" end anchor.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		last := i == len(parts)-2
+		if last && anchored {
+			return strings.HasSuffix(rest, part)
+		}
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	if anchored && len(parts) == 1 {
+		return rest == ""
+	}
+	return true
+}
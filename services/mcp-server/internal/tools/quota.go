@@ -0,0 +1,198 @@
+package tools
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// workspaceQuota limits what the file tools may store, so one runaway agent
+// cannot fill the disk. It is read from the environment on every write.
+//
+// Configuration (all optional):
+//   - FILE_MAX_BYTES             largest single file, default 10 MiB
+//   - FILE_WORKSPACE_MAX_BYTES   total size of the workspace, default 1 GiB
+//   - FILE_MAX_COUNT             number of files in the workspace, default 10000
+//   - FILE_ALLOWED_EXTENSIONS    comma-separated allowlist such as ".md,.txt,.csv";
+//     unset allows any extension
+//
+// File history (.history) has its own limits and is not counted here.
+type workspaceQuota struct {
+	maxFileBytes  int64
+	maxTotalBytes int64
+	maxFiles      int64
+	extensions    map[string]bool // nil = any
+}
+
+// errQuota explains which limit a write would exceed.
+type errQuota struct{ msg string }
+
+func (e *errQuota) Error() string { return "workspace quota exceeded: " + e.msg }
+
+func quotaFromEnv() workspaceQuota {
+	q := workspaceQuota{
+		maxFileBytes:  envInt64("FILE_MAX_BYTES", 10<<20),
+		maxTotalBytes: envInt64("FILE_WORKSPACE_MAX_BYTES", 1<<30),
+		maxFiles:      envInt64("FILE_MAX_COUNT", 10000),
+	}
+	if v := os.Getenv("FILE_ALLOWED_EXTENSIONS"); v != "" {
+		q.extensions = map[string]bool{}
+		for _, ext := range strings.Split(v, ",") {
+			ext = strings.ToLower(strings.TrimSpace(ext))
+			if ext != "" && !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			q.extensions[ext] = true
+		}
+	}
+	return q
+}
+
+// checkExtension rejects file types outside the allowlist.
+func (q workspaceQuota) checkExtension(rel string) error {
+	if q.extensions == nil {
+		return nil
+	}
+	ext := strings.ToLower(path.Ext(rel))
+	if !q.extensions[ext] {
+		allowed := make([]string, 0, len(q.extensions))
+		for e := range q.extensions {
+			allowed = append(allowed, e)
+		}
+		sort.Strings(allowed)
+		return &errQuota{fmt.Sprintf("file type %q is not allowed (allowed: %s)", ext, strings.Join(allowed, ", "))}
+	}
+	return nil
+}
+
+// check reports whether replacing rel with size bytes keeps ws within its
+// limits.
+func (q workspaceQuota) check(ws *workspace, rel string, size int64) error {
+	if err := q.checkFile(rel, size); err != nil {
+		return err
+	}
+	return q.checkTotals(ws, map[string]int64{rel: size})
+}
+
+// checkFile applies the per-file limits to size bytes written to rel.
+func (q workspaceQuota) checkFile(rel string, size int64) error {
+	if err := q.checkExtension(rel); err != nil {
+		return err
+	}
+	if size > q.maxFileBytes {
+		return &errQuota{fmt.Sprintf("%d bytes is over the per-file limit of %d bytes", size, q.maxFileBytes)}
+	}
+	return nil
+}
+
+// checkTotals reports whether writing every file in sizes (path → new
+// size) keeps ws within its total size and file count, so a multi-file
+// operation can be refused before it writes anything.
+func (q workspaceQuota) checkTotals(ws *workspace, sizes map[string]int64) error {
+	var delta, added int64
+	for rel, size := range sizes {
+		existing, exists := ws.fileSize(rel)
+		delta += size - existing
+		if !exists {
+			added++
+		}
+	}
+	usageMu.Lock()
+	u := usageLocked(ws)
+	total, count := u.bytes, u.files
+	usageMu.Unlock()
+
+	if total+delta > q.maxTotalBytes {
+		return &errQuota{fmt.Sprintf("workspace would hold %d bytes, over its limit of %d bytes; delete or shrink files first",
+			total+delta, q.maxTotalBytes)}
+	}
+	if count+added > q.maxFiles {
+		if added == 1 {
+			return &errQuota{fmt.Sprintf("workspace already holds %d files, the limit", count)}
+		}
+		return &errQuota{fmt.Sprintf("workspace holds %d files and %d more would pass its limit of %d", count, added, q.maxFiles)}
+	}
+	return nil
+}
+
+// workspaceUsageTTL is how long a workspace's tracked usage is trusted
+// before it is measured again, so files changed outside the tools are
+// eventually counted.
+const workspaceUsageTTL = time.Minute
+
+// workspaceUsage is the size and file count of a workspace, history
+// excluded. It is measured by one walk and then adjusted by every write, so
+// a write does not have to walk the workspace.
+type workspaceUsage struct {
+	bytes, files int64
+	measured     time.Time
+}
+
+var (
+	usageMu sync.Mutex
+	usage   = map[string]*workspaceUsage{} // workspace dir → usage
+)
+
+// usageLocked returns the usage of ws, measuring it when unknown or stale.
+// The caller holds usageMu.
+func usageLocked(ws *workspace) *workspaceUsage {
+	if u := usage[ws.dir]; u != nil && time.Since(u.measured) < workspaceUsageTTL {
+		return u
+	}
+	u := &workspaceUsage{measured: time.Now()}
+	ws.walkDir(".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p == historyDir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			u.bytes += info.Size()
+			u.files++
+		}
+		return nil
+	})
+	usage[ws.dir] = u
+	return u
+}
+
+// noteWrite updates the usage of ws after a file of prev bytes (existed
+// false for a new file) was replaced by one of size bytes.
+func noteWrite(ws *workspace, prev int64, existed bool, size int64) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	u := usage[ws.dir]
+	if u == nil {
+		return
+	}
+	u.bytes += size - prev
+	if !existed {
+		u.files++
+	}
+}
+
+// forgetWorkspaceUsage drops the usage of a workspace directory that was
+// removed.
+func forgetWorkspaceUsage(dir string) {
+	usageMu.Lock()
+	delete(usage, dir)
+	usageMu.Unlock()
+}
+
+// fileSize returns the size of rel and whether it exists as a regular file.
+func (ws *workspace) fileSize(rel string) (int64, bool) {
+	info, err := ws.stat(rel)
+	if err != nil || !info.Mode().IsRegular() {
+		return 0, false
+	}
+	return info.Size(), true
+}
@@ -4,7 +4,9 @@
 // Tools are grouped by concern:
 //   - memory  — cross-step key/value store (in-process, survives within a run)
 //   - web     — search and page fetching
-//   - files   — read/write/list on the local filesystem
+//   - files   — read/write/list/search on the local filesystem, in the
+//     default workspace or a named per-project one
+//   - data    — queries over CSV/JSON files in the workspace
 //   - http    — generic outbound HTTP for any external API
 package tools
 
@@ -14,9 +16,10 @@ import (
 	"mcp-server/internal/mcp"
 )
 
-// Registry holds shared state (e.g. the memory store) and dispatches tool calls.
+// Registry holds shared state (e.g. the memory store and web cache) and dispatches tool calls.
 type Registry struct {
 	mem    *memoryStore
+	web    *webTools
 	jira   *jiraClient   // nil if JIRA_BASE_URL / JIRA_EMAIL / JIRA_API_TOKEN not set
 	github *githubClient // nil if GITHUB_TOKEN not set
 }
@@ -24,7 +27,7 @@ type Registry struct {
 // NewRegistry constructs a Registry with all tools ready.
 // Jira and GitHub clients are only initialised when the required env vars are present.
 func NewRegistry() *Registry {
-	r := &Registry{mem: newMemoryStore()}
+	r := &Registry{mem: newMemoryStore(), web: newWebTools()}
 	if jiraIsConfigured() {
 		r.jira = newJiraClient()
 	}
@@ -39,10 +42,23 @@ func (r *Registry) Definitions() []mcp.ToolDefinition {
 	defs := []mcp.ToolDefinition{}
 	defs = append(defs, memoryDefinitions()...)
 	defs = append(defs, webDefinitions()...)
-	defs = append(defs, fileDefinitions()...)
+	defs = append(defs, withWorkspaceArg(fileDefinitions())...)
+	defs = append(defs, withWorkspaceArg(fileEditDefinitions())...)
+	defs = append(defs, withWorkspaceArg(fileHistoryDefinitions())...)
+	defs = append(defs, withWorkspaceArg(fileArchiveDefinitions())...)
+	defs = append(defs, withWorkspaceArg(dataDefinitions())...)
+	defs = append(defs, workspaceDefinitions()...)
 	defs = append(defs, httpDefinitions()...)
 	if r.jira != nil {
 		defs = append(defs, jiraDefinitions()...)
+		defs = append(defs, jiraHistoryDefinitions()...)
+		defs = append(defs, jiraLinkDefinitions()...)
+		defs = append(defs, jiraAgileDefinitions()...)
+		defs = append(defs, jiraTransitionDefinitions()...)
+		defs = append(defs, jiraMetaDefinitions()...)
+		defs = append(defs, jiraUserDefinitions()...)
+		defs = append(defs, jiraBulkDefinitions()...)
+		defs = append(defs, jiraAttachmentDefinitions()...)
 	}
 	if r.github != nil {
 		defs = append(defs, githubDefinitions()...)
@@ -63,21 +79,63 @@ func (r *Registry) Call(name string, args map[string]any) (mcp.ToolCallResult, e
 
 	// web
 	case "web_search":
-		return webSearch(args)
+		return r.web.search(args)
 	case "web_fetch":
-		return webFetch(args)
+		return r.web.fetch(args)
+	case "web_extract":
+		return r.web.extract(args)
+	case "web_crawl":
+		return withWorkspace(args, r.web.crawl)
 
 	// files
 	case "file_read":
-		return fileRead(args)
+		return withWorkspace(args, fileRead)
 	case "file_write":
-		return fileWrite(args)
+		return withWorkspace(args, fileWrite)
 	case "file_list":
-		return fileList(args)
+		return withWorkspace(args, fileList)
+	case "file_search":
+		return withWorkspace(args, fileSearch)
+	case "file_glob":
+		return withWorkspace(args, fileGlob)
+	case "file_edit":
+		return withWorkspace(args, fileEdit)
+	case "file_append":
+		return withWorkspace(args, fileAppend)
+	case "file_insert_lines":
+		return withWorkspace(args, fileInsertLines)
+	case "file_delete_lines":
+		return withWorkspace(args, fileDeleteLines)
+	case "file_patch":
+		return withWorkspace(args, filePatch)
+	case "file_history":
+		return withWorkspace(args, fileHistory)
+	case "file_diff":
+		return withWorkspace(args, fileDiff)
+	case "file_restore":
+		return withWorkspace(args, fileRestore)
+	case "file_zip":
+		return withWorkspace(args, fileZip)
+	case "file_unzip":
+		return withWorkspace(args, fileUnzip)
+
+	// data
+	case "data_query":
+		return withWorkspace(args, dataQuery)
+
+	// workspaces
+	case "workspace_create":
+		return workspaceCreate(args)
+	case "workspace_list":
+		return workspaceList(args)
+	case "workspace_archive":
+		return workspaceArchive(args)
+	case "workspace_delete":
+		return workspaceDelete(args)
 
 	// http
 	case "http_request":
-		return httpRequest(args)
+		return httpRequest(r.web.cache, args)
 
 	// jira
 	case "jira_search_issues":
@@ -110,6 +168,136 @@ func (r *Registry) Call(name string, args map[string]any) (mcp.ToolCallResult, e
 			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
 		}
 		return r.jira.closeIssue(args)
+	case "jira_list_comments":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.listComments(args)
+	case "jira_get_changelog":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.getChangelog(args)
+	case "jira_list_worklogs":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.listWorklogs(args)
+	case "jira_link_issues":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.linkIssues(args)
+	case "jira_get_issue_tree":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.getIssueTree(args)
+	case "jira_list_boards":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.listBoards(args)
+	case "jira_list_sprints":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.listSprints(args)
+	case "jira_get_sprint_issues":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.getSprintIssues(args)
+	case "jira_get_backlog":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.getBacklog(args)
+	case "jira_move_issues":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.moveIssues(args)
+	case "jira_sprint_progress":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.sprintProgress(args)
+	case "jira_list_transitions":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.listTransitions(args)
+	case "jira_transition_issue":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.transitionIssue(args)
+	case "jira_list_projects":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.listProjects(args)
+	case "jira_list_issue_types":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.listIssueTypes(args)
+	case "jira_get_create_fields":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.getCreateFields(args)
+	case "jira_list_fields":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.listFields(args)
+	case "jira_find_user":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.findUser(args)
+	case "jira_assign_issue":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.assignIssue(args)
+	case "jira_watch_issue":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.watchIssue(args)
+	case "jira_get_issues":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.getIssues(args)
+	case "jira_bulk_update":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.bulkUpdate(args)
+	case "jira_bulk_transition":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.bulkTransition(args)
+	case "jira_list_attachments":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return r.jira.listAttachments(args)
+	case "jira_download_attachment":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return withWorkspace(args, r.jira.downloadAttachment)
+	case "jira_upload_attachment":
+		if r.jira == nil {
+			return mcp.ToolCallResult{}, fmt.Errorf("jira is not configured")
+		}
+		return withWorkspace(args, r.jira.uploadAttachment)
 
 	// github
 	case "github_list_issues":
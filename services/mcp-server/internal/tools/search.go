@@ -0,0 +1,698 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Compiled once at startup — used by ddgProvider.
+// DDG lite uses single-quoted class attributes; href precedes the class.
+var (
+	// Matches: href="//duckduckgo.com/l/?uddg=...&amp;rut=..." class='result-link'>Title</a>
+	reDDGLink    = regexp.MustCompile(`href="(//duckduckgo\.com/l/\?uddg=[^"]+)"[^>]*class='result-link'>([^<]+)</a>`)
+	reDDGSnippet = regexp.MustCompile(`class='result-snippet'[^>]*>([\s\S]*?)</td>`)
+)
+
+// SearchProvider is a web search backend used by web_search.
+//
+// Backends are registered in searchBackends and tried in the order given by
+// SEARCH_PROVIDERS; the next one is used when a backend errors or returns no
+// results.
+type SearchProvider interface {
+	// Name is the identifier used in SEARCH_PROVIDERS and reported in results.
+	Name() string
+	// Search returns at most q.Limit results.
+	Search(q SearchQuery) ([]SearchResult, error)
+}
+
+// SearchQuery is a backend-independent search request. Each provider maps
+// the optional fields onto its own parameters; site filters are also applied
+// to the results afterwards, so backends that ignore them still comply.
+type SearchQuery struct {
+	Query        string   `json:"query"`
+	Limit        int      `json:"limit"`
+	Offset       int      `json:"offset,omitempty"`
+	Sites        []string `json:"sites,omitempty"`         // restrict to these domains
+	ExcludeSites []string `json:"exclude_sites,omitempty"` // drop these domains
+	Freshness    string   `json:"freshness,omitempty"`     // "", "day", "week" or "month"
+	Language     string   `json:"language,omitempty"`      // ISO 639-1, e.g. "en"
+	Region       string   `json:"region,omitempty"`        // ISO 3166-1 alpha-2, e.g. "us"
+}
+
+// text returns the query string with site: operators appended, for backends
+// that only take free text.
+func (q SearchQuery) text() string {
+	parts := []string{q.Query}
+	switch len(q.Sites) {
+	case 0:
+	case 1:
+		parts = append(parts, "site:"+q.Sites[0])
+	default:
+		ors := make([]string, len(q.Sites))
+		for i, s := range q.Sites {
+			ors[i] = "site:" + s
+		}
+		parts = append(parts, "("+strings.Join(ors, " OR ")+")")
+	}
+	for _, s := range q.ExcludeSites {
+		parts = append(parts, "-site:"+s)
+	}
+	return strings.Join(parts, " ")
+}
+
+// pageOf maps the offset onto a backend that paginates in pages of size
+// results: the 0-based page holding the offset, and how many results at the
+// start of that page precede it and must be dropped.
+func (q SearchQuery) pageOf(size int) (page, skip int) { return q.Offset / size, q.Offset % size }
+
+// dropLeading removes the first n results, for offsets inside a page.
+func dropLeading(results []SearchResult, n int) []SearchResult {
+	return results[min(n, len(results)):]
+}
+
+// SearchResult is one normalised hit returned by any search backend.
+// Rank is the 1-based position across pages (offset + index + 1).
+type SearchResult struct {
+	Title     string `json:"title"`
+	URL       string `json:"url"`
+	Snippet   string `json:"snippet"`
+	Domain    string `json:"domain,omitempty"`
+	Published string `json:"published,omitempty"`
+	Rank      int    `json:"rank,omitempty"`
+}
+
+// searchFreshness lists the accepted freshness values.
+var searchFreshness = map[string]bool{"": true, "day": true, "week": true, "month": true}
+
+// searchBackends maps a SEARCH_PROVIDERS name to its constructor. A
+// constructor returns nil when the backend's credentials are not configured.
+var searchBackends = map[string]func() SearchProvider{
+	"brave":   newBraveProvider,
+	"searxng": newSearxngProvider,
+	"bing":    newBingProvider,
+	"google":  newGoogleCSEProvider,
+	"ddg":     newDDGProvider,
+	"fixture": newFixtureProvider,
+}
+
+// defaultSearchOrder is used when SEARCH_PROVIDERS is unset: keyed APIs
+// first, then the self-hosted instance, then keyless DuckDuckGo scraping.
+const defaultSearchOrder = "brave,bing,google,searxng,ddg"
+
+// searchProvidersFromEnv builds the ordered fallback chain from
+// SEARCH_PROVIDERS (comma-separated backend names). Unknown or unconfigured
+// names are skipped.
+func searchProvidersFromEnv() []SearchProvider {
+	order := os.Getenv("SEARCH_PROVIDERS")
+	if order == "" {
+		order = defaultSearchOrder
+	}
+	var providers []SearchProvider
+	for _, name := range strings.Split(order, ",") {
+		ctor, ok := searchBackends[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			continue
+		}
+		if p := ctor(); p != nil {
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+// providerNames lists the names of providers, in order.
+func providerNames(providers []SearchProvider) []string {
+	names := make([]string, len(providers))
+	for i, p := range providers {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+// searchWithFallback tries each provider in turn and returns the first
+// non-empty result set together with the name of the provider that produced
+// it. An empty result is only returned when no provider failed outright
+// with something better; if every provider errors, the errors are combined.
+func searchWithFallback(providers []SearchProvider, q SearchQuery) ([]SearchResult, string, error) {
+	if len(providers) == 0 {
+		return nil, "", fmt.Errorf("no search provider is configured")
+	}
+	var failures []string
+	emptyFrom := ""
+	for _, p := range providers {
+		results, err := p.Search(q)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		results = refineResults(results, q)
+		if len(results) == 0 {
+			if emptyFrom == "" {
+				emptyFrom = p.Name()
+			}
+			continue
+		}
+		if len(results) > q.Limit {
+			results = results[:q.Limit]
+		}
+		return results, p.Name(), nil
+	}
+	if emptyFrom != "" {
+		return []SearchResult{}, emptyFrom, nil
+	}
+	return nil, "", fmt.Errorf("all search providers failed: %s", strings.Join(failures, "; "))
+}
+
+// refineResults enforces the site filters, fills in Domain and numbers the
+// results by their global rank.
+func refineResults(results []SearchResult, q SearchQuery) []SearchResult {
+	out := results[:0]
+	for _, r := range results {
+		domain := resultDomain(r.URL)
+		if len(q.Sites) > 0 && !domainMatchesAny(domain, q.Sites) {
+			continue
+		}
+		if domainMatchesAny(domain, q.ExcludeSites) {
+			continue
+		}
+		r.Domain = domain
+		out = append(out, r)
+	}
+	for i := range out {
+		out[i].Rank = q.Offset + i + 1
+	}
+	return out
+}
+
+// resultDomain returns the lower-cased host of rawURL without a "www." prefix.
+func resultDomain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// domainMatchesAny reports whether domain equals or is a subdomain of any of
+// sites.
+func domainMatchesAny(domain string, sites []string) bool {
+	for _, s := range sites {
+		s = strings.TrimPrefix(strings.ToLower(s), "www.")
+		if domain == s || strings.HasSuffix(domain, "."+s) {
+			return true
+		}
+	}
+	return false
+}
+
+// getSearchJSON performs req and decodes a JSON response into v, prefixing
+// errors with the provider name.
+func getSearchJSON(name string, req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := webClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: request failed: %v", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4*1024))
+		return fmt.Errorf("%s: status %d: %s", name, resp.StatusCode, string(body))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: decode response: %v", name, err)
+	}
+	return nil
+}
+
+// ── Brave ───────────────────────────────────────────────────────────────────
+
+// braveProvider calls the Brave Search API (https://api.search.brave.com).
+// Free tier: 2 000 queries/month — sign up at search.brave.com/webmaster.
+// Enabled by BRAVE_SEARCH_API_KEY.
+type braveProvider struct{ apiKey string }
+
+func newBraveProvider() SearchProvider {
+	key := os.Getenv("BRAVE_SEARCH_API_KEY")
+	if key == "" {
+		return nil
+	}
+	return &braveProvider{apiKey: key}
+}
+
+func (p *braveProvider) Name() string { return "brave" }
+
+// braveFreshness maps SearchQuery.Freshness to Brave's freshness codes.
+var braveFreshness = map[string]string{"day": "pd", "week": "pw", "month": "pm"}
+
+func (p *braveProvider) Search(q SearchQuery) ([]SearchResult, error) {
+	params := url.Values{}
+	params.Set("q", q.text())
+	params.Set("count", fmt.Sprint(q.Limit))
+	params.Set("result_filter", "web")
+	params.Set("search_lang", "en")
+	if q.Language != "" {
+		params.Set("search_lang", q.Language)
+	}
+	if q.Region != "" {
+		params.Set("country", strings.ToUpper(q.Region))
+	}
+	if f := braveFreshness[q.Freshness]; f != "" {
+		params.Set("freshness", f)
+	}
+	// Brave's offset counts pages of `count` results, not results.
+	page, skip := q.pageOf(q.Limit)
+	if page > 0 {
+		params.Set("offset", fmt.Sprint(page))
+	}
+	req, err := http.NewRequest("GET", "https://api.search.brave.com/res/v1/web/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("brave: build request: %v", err)
+	}
+	req.Header.Set("X-Subscription-Token", p.apiKey)
+
+	var data struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+				PageAge     string `json:"page_age"`
+				Age         string `json:"age"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	if err := getSearchJSON("brave", req, &data); err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for _, r := range data.Web.Results {
+		published := r.PageAge
+		if published == "" {
+			published = r.Age
+		}
+		results = append(results, SearchResult{Title: r.Title, URL: r.URL, Snippet: r.Description, Published: published})
+	}
+	return dropLeading(results, skip), nil
+}
+
+// ── SearXNG ─────────────────────────────────────────────────────────────────
+
+// searxngProvider queries a self-hosted SearXNG instance through its JSON API.
+// Enabled by SEARXNG_URL (e.g. "http://searxng:8080"); the instance must
+// have "json" in search.formats.
+type searxngProvider struct{ baseURL string }
+
+func newSearxngProvider() SearchProvider {
+	base := strings.TrimRight(os.Getenv("SEARXNG_URL"), "/")
+	if base == "" {
+		return nil
+	}
+	return &searxngProvider{baseURL: base}
+}
+
+func (p *searxngProvider) Name() string { return "searxng" }
+
+// searxngPageSize is the number of results per SearXNG page, which the API
+// does not let callers change.
+const searxngPageSize = 10
+
+func (p *searxngProvider) Search(q SearchQuery) ([]SearchResult, error) {
+	params := url.Values{}
+	params.Set("format", "json")
+	params.Set("q", q.text())
+	if q.Freshness != "" {
+		params.Set("time_range", q.Freshness) // day, week and month map 1:1
+	}
+	if q.Language != "" {
+		lang := q.Language
+		if q.Region != "" {
+			lang += "-" + strings.ToUpper(q.Region)
+		}
+		params.Set("language", lang)
+	}
+	page, skip := q.pageOf(searxngPageSize)
+	params.Set("pageno", fmt.Sprint(page+1))
+	req, err := http.NewRequest("GET", p.baseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("searxng: build request: %v", err)
+	}
+
+	var data struct {
+		Results []struct {
+			Title         string `json:"title"`
+			URL           string `json:"url"`
+			Content       string `json:"content"`
+			PublishedDate string `json:"publishedDate"`
+		} `json:"results"`
+	}
+	if err := getSearchJSON("searxng", req, &data); err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for _, r := range data.Results {
+		results = append(results, SearchResult{
+			Title: r.Title, URL: r.URL, Snippet: cleanText(r.Content), Published: r.PublishedDate,
+		})
+	}
+	return dropLeading(results, skip), nil
+}
+
+// ── Bing ────────────────────────────────────────────────────────────────────
+
+// bingProvider calls the Bing Web Search API v7.
+// Enabled by BING_SEARCH_API_KEY; BING_SEARCH_ENDPOINT overrides the default
+// endpoint for Azure-hosted resources.
+type bingProvider struct {
+	apiKey   string
+	endpoint string
+}
+
+func newBingProvider() SearchProvider {
+	key := os.Getenv("BING_SEARCH_API_KEY")
+	if key == "" {
+		return nil
+	}
+	endpoint := os.Getenv("BING_SEARCH_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://api.bing.microsoft.com/v7.0/search"
+	}
+	return &bingProvider{apiKey: key, endpoint: endpoint}
+}
+
+func (p *bingProvider) Name() string { return "bing" }
+
+// bingFreshness maps SearchQuery.Freshness to Bing's freshness values.
+var bingFreshness = map[string]string{"day": "Day", "week": "Week", "month": "Month"}
+
+func (p *bingProvider) Search(q SearchQuery) ([]SearchResult, error) {
+	params := url.Values{}
+	params.Set("q", q.text())
+	params.Set("count", fmt.Sprint(q.Limit))
+	params.Set("offset", fmt.Sprint(q.Offset))
+	params.Set("responseFilter", "Webpages")
+	if f := bingFreshness[q.Freshness]; f != "" {
+		params.Set("freshness", f)
+	}
+	switch {
+	case q.Language != "" && q.Region != "":
+		params.Set("mkt", strings.ToLower(q.Language)+"-"+strings.ToUpper(q.Region))
+	case q.Region != "":
+		params.Set("cc", strings.ToUpper(q.Region))
+	}
+	if q.Language != "" {
+		params.Set("setLang", q.Language)
+	}
+	req, err := http.NewRequest("GET", p.endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("bing: build request: %v", err)
+	}
+	req.Header.Set("Ocp-Apim-Subscription-Key", p.apiKey)
+
+	var data struct {
+		WebPages struct {
+			Value []struct {
+				Name          string `json:"name"`
+				URL           string `json:"url"`
+				Snippet       string `json:"snippet"`
+				DatePublished string `json:"datePublished"`
+			} `json:"value"`
+		} `json:"webPages"`
+	}
+	if err := getSearchJSON("bing", req, &data); err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for _, r := range data.WebPages.Value {
+		results = append(results, SearchResult{Title: r.Name, URL: r.URL, Snippet: r.Snippet, Published: r.DatePublished})
+	}
+	return results, nil
+}
+
+// ── Google Programmable Search ──────────────────────────────────────────────
+
+// googleCSEProvider calls the Google Custom Search JSON API.
+// Enabled by GOOGLE_CSE_API_KEY together with GOOGLE_CSE_ID (the "cx" of the
+// search engine). The API returns at most 10 results per request.
+type googleCSEProvider struct {
+	apiKey string
+	cx     string
+}
+
+func newGoogleCSEProvider() SearchProvider {
+	key, cx := os.Getenv("GOOGLE_CSE_API_KEY"), os.Getenv("GOOGLE_CSE_ID")
+	if key == "" || cx == "" {
+		return nil
+	}
+	return &googleCSEProvider{apiKey: key, cx: cx}
+}
+
+func (p *googleCSEProvider) Name() string { return "google" }
+
+// googleFreshness maps SearchQuery.Freshness to dateRestrict values.
+var googleFreshness = map[string]string{"day": "d1", "week": "w1", "month": "m1"}
+
+func (p *googleCSEProvider) Search(q SearchQuery) ([]SearchResult, error) {
+	params := url.Values{}
+	params.Set("key", p.apiKey)
+	params.Set("cx", p.cx)
+	params.Set("q", q.text())
+	params.Set("num", fmt.Sprint(min(q.Limit, 10)))
+	params.Set("start", fmt.Sprint(q.Offset+1))
+	if f := googleFreshness[q.Freshness]; f != "" {
+		params.Set("dateRestrict", f)
+	}
+	if q.Language != "" {
+		params.Set("lr", "lang_"+strings.ToLower(q.Language))
+	}
+	if q.Region != "" {
+		params.Set("gl", strings.ToLower(q.Region))
+	}
+	req, err := http.NewRequest("GET", "https://www.googleapis.com/customsearch/v1?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("google: build request: %v", err)
+	}
+
+	var data struct {
+		Items []struct {
+			Title   string `json:"title"`
+			Link    string `json:"link"`
+			Snippet string `json:"snippet"`
+			Pagemap struct {
+				Metatags []map[string]string `json:"metatags"`
+			} `json:"pagemap"`
+		} `json:"items"`
+	}
+	if err := getSearchJSON("google", req, &data); err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for _, r := range data.Items {
+		published := ""
+		if len(r.Pagemap.Metatags) > 0 {
+			published = r.Pagemap.Metatags[0]["article:published_time"]
+		}
+		results = append(results, SearchResult{Title: r.Title, URL: r.Link, Snippet: r.Snippet, Published: published})
+	}
+	return results, nil
+}
+
+// ── DuckDuckGo ──────────────────────────────────────────────────────────────
+
+// ddgProvider scrapes DuckDuckGo Lite (lite.duckduckgo.com), which returns
+// real web results without requiring an API key. Always available.
+type ddgProvider struct{}
+
+func newDDGProvider() SearchProvider { return ddgProvider{} }
+
+func (ddgProvider) Name() string { return "ddg" }
+
+// ddgFreshness maps SearchQuery.Freshness to DuckDuckGo's df parameter.
+var ddgFreshness = map[string]string{"day": "d", "week": "w", "month": "m"}
+
+// ddgRegions lists DuckDuckGo's region codes, "<country>-<language>" in
+// DDG's own spelling ("uk" for Great Britain, "jp" for Japanese, ...). A
+// country's first entry is its default language.
+var ddgRegions = []string{
+	"xa-ar", "xa-en", "ar-es", "au-en", "at-de", "be-fr", "be-nl", "br-pt", "bg-bg",
+	"ca-en", "ca-fr", "ct-ca", "cl-es", "cn-zh", "co-es", "hr-hr", "cz-cs", "dk-da",
+	"ee-et", "fi-fi", "fr-fr", "de-de", "gr-el", "hk-tzh", "hu-hu", "in-en", "id-id",
+	"id-en", "ie-en", "il-he", "it-it", "jp-jp", "kr-kr", "lv-lv", "lt-lt", "xl-es",
+	"my-en", "my-ms", "mx-es", "nl-nl", "nz-en", "no-no", "pe-es", "ph-en", "ph-tl",
+	"pl-pl", "pt-pt", "ro-ro", "ru-ru", "sg-en", "sk-sk", "sl-sl", "za-en", "es-es",
+	"se-sv", "ch-de", "ch-fr", "ch-it", "tw-tzh", "th-th", "tr-tr", "ua-uk", "uk-en",
+	"us-en", "us-es", "vn-vi",
+}
+
+// ddgLanguageCodes maps DDG's non-ISO language codes to ISO 639-1.
+var ddgLanguageCodes = map[string]string{"jp": "ja", "kr": "ko", "tzh": "zh"}
+
+// ddgRegion returns the kl value for an ISO country and language, or "" when
+// DDG has no region for the country. A language DDG does not pair with the
+// country falls back to the country's default; a language alone selects no
+// region, since DDG has none that are language-only.
+func ddgRegion(region, lang string) string {
+	country, lang := strings.ToLower(region), strings.ToLower(lang)
+	if country == "gb" {
+		country = "uk"
+	}
+	fallback := ""
+	for _, kl := range ddgRegions {
+		c, l, _ := strings.Cut(kl, "-")
+		if c != country {
+			continue
+		}
+		if fallback == "" {
+			fallback = kl
+		}
+		if lang != "" && (l == lang || ddgLanguageCodes[l] == lang) {
+			return kl
+		}
+	}
+	return fallback
+}
+
+func (ddgProvider) Search(q SearchQuery) ([]SearchResult, error) {
+	params := url.Values{}
+	params.Set("q", q.text())
+	if f := ddgFreshness[q.Freshness]; f != "" {
+		params.Set("df", f)
+	}
+	if kl := ddgRegion(q.Region, q.Language); kl != "" {
+		params.Set("kl", kl)
+	}
+	if q.Offset > 0 {
+		params.Set("s", fmt.Sprint(q.Offset))
+	}
+	req, err := http.NewRequest("GET", "https://lite.duckduckgo.com/lite/?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("ddg: build request: %v", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+
+	resp, err := webClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ddg: request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 512*1024))
+	if err != nil {
+		return nil, fmt.Errorf("ddg: read body: %v", err)
+	}
+	content := string(body)
+
+	linkMatches := reDDGLink.FindAllStringSubmatch(content, -1)
+	snippetMatches := reDDGSnippet.FindAllStringSubmatch(content, -1)
+
+	var results []SearchResult
+	for i, m := range linkMatches {
+		if len(results) >= q.Limit {
+			break
+		}
+		// m[1] = href value (still HTML-encoded), m[2] = title text
+		actualURL := decodeDDGURL(html.UnescapeString(m[1]))
+		if actualURL == "" {
+			continue
+		}
+		title := cleanText(html.UnescapeString(m[2]))
+		if title == "" {
+			continue
+		}
+		snippet := ""
+		if i < len(snippetMatches) {
+			snippet = cleanText(html.UnescapeString(snippetMatches[i][1]))
+		}
+		results = append(results, SearchResult{Title: title, URL: actualURL, Snippet: snippet})
+	}
+	return results, nil
+}
+
+// decodeDDGURL extracts the real destination URL from a DDG redirect link.
+// DDG lite uses the format //duckduckgo.com/l/?uddg=<percent-encoded-url>.
+func decodeDDGURL(href string) string {
+	if strings.HasPrefix(href, "//") {
+		href = "https:" + href
+	}
+	parsed, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if target := parsed.Query().Get("uddg"); target != "" {
+		if decoded, err := url.QueryUnescape(target); err == nil {
+			return decoded
+		}
+		return target
+	}
+	// href was already an absolute URL (rare but possible)
+	if strings.HasPrefix(href, "http") {
+		return href
+	}
+	return ""
+}
+
+// ── Fixture ─────────────────────────────────────────────────────────────────
+
+// fixtureProvider serves canned results from a local JSON file so tests and
+// offline demos never touch the network. Enabled by SEARCH_FIXTURE_FILE and
+// only used when listed explicitly in SEARCH_PROVIDERS.
+//
+// The file maps normalised queries to result lists; the "*" key, if present,
+// answers any other query:
+//
+//	{"golang generics": [{"title": "...", "url": "...", "snippet": "..."}], "*": []}
+//
+// A null result list makes the provider fail for that query, which lets
+// tests exercise fallback. Offsets slice the stored list; other filters are
+// left to refineResults.
+type fixtureProvider struct{ path string }
+
+func newFixtureProvider() SearchProvider {
+	path := os.Getenv("SEARCH_FIXTURE_FILE")
+	if path == "" {
+		return nil
+	}
+	return &fixtureProvider{path: path}
+}
+
+func (p *fixtureProvider) Name() string { return "fixture" }
+
+func (p *fixtureProvider) Search(q SearchQuery) ([]SearchResult, error) {
+	raw, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("fixture: %v", err)
+	}
+	var fixtures map[string]*[]SearchResult
+	if err := json.Unmarshal(raw, &fixtures); err != nil {
+		return nil, fmt.Errorf("fixture: parse %s: %v", p.path, err)
+	}
+	results, ok := fixtures[normalizeQuery(q.Query)]
+	if !ok {
+		results, ok = fixtures["*"]
+	}
+	if !ok {
+		return nil, nil
+	}
+	if results == nil {
+		return nil, fmt.Errorf("fixture: configured failure for %q", q.Query)
+	}
+	// Emulate paging so offset can be exercised offline.
+	page := *results
+	if q.Offset >= len(page) {
+		return nil, nil
+	}
+	return page[q.Offset:], nil
+}
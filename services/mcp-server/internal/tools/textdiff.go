@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffCells bounds the LCS table built by diffLines. Inputs larger than
+// this (after trimming their common prefix and suffix) are diffed as a
+// wholesale replacement rather than risking a huge allocation.
+const maxDiffCells = 4_000_000
+
+// diffOp is one line of an edit script: ' ' keep, '-' delete, '+' insert.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// diffLines returns an edit script turning a into b, minimal in the number
+// of changed lines when the inputs are small enough.
+func diffLines(a, b []string) []diffOp {
+	var ops []diffOp
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		ops = append(ops, diffOp{' ', a[pre]})
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+
+	if len(ma)*len(mb) > maxDiffCells {
+		for _, l := range ma {
+			ops = append(ops, diffOp{'-', l})
+		}
+		for _, l := range mb {
+			ops = append(ops, diffOp{'+', l})
+		}
+	} else {
+		// lcs[i][j] is the LCS length of ma[i:] and mb[j:].
+		lcs := make([][]int32, len(ma)+1)
+		for i := range lcs {
+			lcs[i] = make([]int32, len(mb)+1)
+		}
+		for i := len(ma) - 1; i >= 0; i-- {
+			for j := len(mb) - 1; j >= 0; j-- {
+				if ma[i] == mb[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(ma) || j < len(mb) {
+			switch {
+			case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+				ops = append(ops, diffOp{' ', ma[i]})
+				i++
+				j++
+			case i < len(ma) && (j == len(mb) || lcs[i+1][j] >= lcs[i][j+1]):
+				ops = append(ops, diffOp{'-', ma[i]})
+				i++
+			default:
+				ops = append(ops, diffOp{'+', mb[j]})
+				j++
+			}
+		}
+	}
+
+	for k := len(a) - suf; k < len(a); k++ {
+		ops = append(ops, diffOp{' ', a[k]})
+	}
+	return ops
+}
+
+// unifiedDiff renders the changes from a to b in unified format with three
+// lines of context, the format file_patch accepts. It returns "" when the
+// texts are equal.
+func unifiedDiff(fromName, toName, a, b string) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+	const context = 3
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	changed := false
+
+	oldLine, newLine := 1, 1 // numbers of the line ops[k] refers to
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			oldLine++
+			newLine++
+			continue
+		}
+		changed = true
+		// Start the hunk up to `context` lines before the first change.
+		start := max(k-context, 0)
+		for start < k && ops[start].kind != ' ' {
+			start++
+		}
+		// Extend it until more than 2*context unchanged lines follow a change.
+		end, run := k, 0
+		for i := k; i < len(ops); i++ {
+			if ops[i].kind == ' ' {
+				run++
+				if run > 2*context {
+					break
+				}
+			} else {
+				run = 0
+				end = i
+			}
+		}
+		end = min(end+context+1, len(ops))
+
+		lead := k - start
+		hOld, hNew := oldLine-lead, newLine-lead
+		var oldCount, newCount int
+		var body strings.Builder
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+			body.WriteByte(op.kind)
+			body.WriteString(strings.TrimSuffix(op.line, "\n"))
+			body.WriteByte('\n')
+			if !strings.HasSuffix(op.line, "\n") {
+				body.WriteString("\\ No newline at end of file\n")
+			}
+		}
+		if oldCount == 0 {
+			hOld--
+		}
+		if newCount == 0 {
+			hNew--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", hOld, oldCount, hNew, newCount)
+		out.WriteString(body.String())
+
+		for _, op := range ops[k:end] {
+			if op.kind != '+' {
+				oldLine++
+			}
+			if op.kind != '-' {
+				newLine++
+			}
+		}
+		k = end
+	}
+	if !changed {
+		return ""
+	}
+	return out.String()
+}
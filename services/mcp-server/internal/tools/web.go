@@ -2,34 +2,58 @@ package tools
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"html"
-	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"mcp-server/internal/mcp"
 )
 
 var webClient = &http.Client{Timeout: 15 * time.Second}
 
-// Compiled once at startup — used by ddgHtmlSearch.
-// DDG lite uses single-quoted class attributes; href precedes the class.
-var (
-	// Matches: href="//duckduckgo.com/l/?uddg=...&amp;rut=..." class='result-link'>Title</a>
-	reDDGLink    = regexp.MustCompile(`href="(//duckduckgo\.com/l/\?uddg=[^"]+)"[^>]*class='result-link'>([^<]+)</a>`)
-	reDDGSnippet = regexp.MustCompile(`class='result-snippet'[^>]*>([\s\S]*?)</td>`)
-	reHTMLTags   = regexp.MustCompile(`<[^>]*>`)
-)
+// reHTMLTags is compiled once at startup — used by cleanText.
+var reHTMLTags = regexp.MustCompile(`<[^>]*>`)
+
+// webTools holds the state shared by the web tools: the response cache,
+// which http_request also uses for GET calls, the ordered search provider
+// chain, and the robots.txt-aware, rate-limited client used to fetch pages.
+type webTools struct {
+	cache     *responseCache
+	providers []SearchProvider
+	polite    *politeTransport
+	pages     *http.Client
+}
+
+func newWebTools() *webTools {
+	polite := newPoliteTransport()
+	return &webTools{
+		cache:     newResponseCache(),
+		providers: searchProvidersFromEnv(),
+		polite:    polite,
+		pages:     &http.Client{Timeout: webClient.Timeout, Transport: polite},
+	}
+}
 
-// webSearch dispatches to the best available search backend:
-//  1. Brave Search API  — when BRAVE_SEARCH_API_KEY is set (recommended, free tier)
-//  2. DuckDuckGo HTML   — scraped from lite.duckduckgo.com, no key required
-func webSearch(args map[string]any) (mcp.ToolCallResult, error) {
+// searchResponse is what web_search caches for a query.
+type searchResponse struct {
+	Provider string         `json:"provider"`
+	Results  []SearchResult `json:"results"`
+}
+
+// search runs the query through the configured SearchProvider chain (see
+// searchProvidersFromEnv). Without SEARCH_PROVIDERS this prefers keyed APIs
+// such as Brave (BRAVE_SEARCH_API_KEY) and falls back to DuckDuckGo, which
+// needs no key.
+//
+// Optional site, exclude_sites, freshness, language, region and offset
+// arguments are mapped to each backend's own parameters. Results are cached
+// per provider chain and full parameter set.
+func (w *webTools) search(args map[string]any) (mcp.ToolCallResult, error) {
 	query, errResult, err := requireString(args, "query")
 	if errResult != nil {
 		return *errResult, err
@@ -41,147 +65,56 @@ func webSearch(args map[string]any) (mcp.ToolCallResult, error) {
 	if limit > 10 {
 		limit = 10
 	}
-
-	if apiKey := os.Getenv("BRAVE_SEARCH_API_KEY"); apiKey != "" {
-		return braveSearch(query, limit, apiKey)
+	offset := int(optionalFloat(args, "offset", 0))
+	if offset < 0 {
+		offset = 0
 	}
-	return ddgHtmlSearch(query, limit)
-}
-
-// braveSearch calls the Brave Search API (https://api.search.brave.com).
-// Free tier: 2 000 queries/month — sign up at search.brave.com/webmaster.
-func braveSearch(query string, limit int, apiKey string) (mcp.ToolCallResult, error) {
-	apiURL := fmt.Sprintf(
-		"https://api.search.brave.com/res/v1/web/search?q=%s&count=%d&search_lang=en&result_filter=web",
-		url.QueryEscape(query), limit,
-	)
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return textErr(fmt.Sprintf("brave: build request: %v", err))
+	q := SearchQuery{
+		Query:        query,
+		Limit:        limit,
+		Offset:       offset,
+		Sites:        optionalStringList(args, "site"),
+		ExcludeSites: optionalStringList(args, "exclude_sites"),
+		Freshness:    strings.ToLower(optionalString(args, "freshness", "")),
+		Language:     optionalString(args, "language", ""),
+		Region:       optionalString(args, "region", ""),
 	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("X-Subscription-Token", apiKey)
-
-	resp, err := webClient.Do(req)
-	if err != nil {
-		return textErr(fmt.Sprintf("brave: request failed: %v", err))
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return textErr(fmt.Sprintf("brave: status %d: %s", resp.StatusCode, string(body)))
+	if !searchFreshness[q.Freshness] {
+		return textErr(fmt.Sprintf("invalid freshness %q: use \"day\", \"week\" or \"month\"", q.Freshness))
 	}
 
-	var data struct {
-		Web struct {
-			Results []struct {
-				Title       string `json:"title"`
-				URL         string `json:"url"`
-				Description string `json:"description"`
-			} `json:"results"`
-		} `json:"web"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return textErr(fmt.Sprintf("brave: decode response: %v", err))
-	}
+	// The cache key covers every parameter, with the query text normalised.
+	keyQuery := q
+	keyQuery.Query = normalizeQuery(query)
+	encodedQuery, _ := json.Marshal(keyQuery)
+	key := "search:" + strings.Join(providerNames(w.providers), ",") + ":" + string(encodedQuery)
 
-	type result struct {
-		Title   string `json:"title"`
-		URL     string `json:"url"`
-		Snippet string `json:"snippet"`
-	}
-	var results []result
-	for _, r := range data.Web.Results {
-		if len(results) >= limit {
-			break
+	if !optionalBool(args, "no_cache", false) {
+		if cached, ok := w.cache.lookupSearch(key); ok {
+			var sr searchResponse
+			if err := json.Unmarshal(cached, &sr); err == nil {
+				return textResult(map[string]any{
+					"query": query, "provider": sr.Provider, "offset": offset, "count": len(sr.Results),
+					"results": sr.Results, "cached": true,
+				})
+			}
 		}
-		results = append(results, result{Title: r.Title, URL: r.URL, Snippet: r.Description})
 	}
 
-	return textResult(map[string]any{"query": query, "count": len(results), "results": results})
-}
-
-// ddgHtmlSearch scrapes DuckDuckGo Lite (lite.duckduckgo.com), which returns
-// real web results without requiring an API key.
-func ddgHtmlSearch(query string, limit int) (mcp.ToolCallResult, error) {
-	req, err := http.NewRequest("GET",
-		"https://lite.duckduckgo.com/lite/?q="+url.QueryEscape(query),
-		nil,
-	)
+	results, provider, err := searchWithFallback(w.providers, q)
 	if err != nil {
-		return textErr(fmt.Sprintf("ddg: build request: %v", err))
+		return textErr(err.Error())
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
-
-	resp, err := webClient.Do(req)
-	if err != nil {
-		return textErr(fmt.Sprintf("ddg: request failed: %v", err))
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 512*1024))
-	if err != nil {
-		return textErr(fmt.Sprintf("ddg: read body: %v", err))
-	}
-	content := string(body)
-
-	linkMatches := reDDGLink.FindAllStringSubmatch(content, -1)
-	snippetMatches := reDDGSnippet.FindAllStringSubmatch(content, -1)
-
-	type result struct {
-		Title   string `json:"title"`
-		URL     string `json:"url"`
-		Snippet string `json:"snippet"`
-	}
-	var results []result
-
-	for i, m := range linkMatches {
-		if len(results) >= limit {
-			break
+	if len(results) > 0 {
+		if encoded, err := json.Marshal(searchResponse{Provider: provider, Results: results}); err == nil {
+			w.cache.storeSearch(key, encoded)
 		}
-		// m[1] = href value (still HTML-encoded), m[2] = title text
-		actualURL := decodeDDGURL(html.UnescapeString(m[1]))
-		if actualURL == "" {
-			continue
-		}
-		title := cleanText(html.UnescapeString(m[2]))
-		if title == "" {
-			continue
-		}
-		snippet := ""
-		if i < len(snippetMatches) {
-			snippet = cleanText(html.UnescapeString(snippetMatches[i][1]))
-		}
-		results = append(results, result{Title: title, URL: actualURL, Snippet: snippet})
 	}
 
-	return textResult(map[string]any{"query": query, "count": len(results), "results": results})
-}
-
-// decodeDDGURL extracts the real destination URL from a DDG redirect link.
-// DDG lite uses the format //duckduckgo.com/l/?uddg=<percent-encoded-url>.
-func decodeDDGURL(href string) string {
-	if strings.HasPrefix(href, "//") {
-		href = "https:" + href
-	}
-	parsed, err := url.Parse(href)
-	if err != nil {
-		return ""
-	}
-	if target := parsed.Query().Get("uddg"); target != "" {
-		if decoded, err := url.QueryUnescape(target); err == nil {
-			return decoded
-		}
-		return target
-	}
-	// href was already an absolute URL (rare but possible)
-	if strings.HasPrefix(href, "http") {
-		return href
-	}
-	return ""
+	return textResult(map[string]any{
+		"query": query, "provider": provider, "offset": offset, "count": len(results),
+		"results": results, "cached": false,
+	})
 }
 
 // cleanText strips HTML tags and collapses whitespace.
@@ -190,10 +123,14 @@ func cleanText(s string) string {
 	return strings.TrimSpace(strings.Join(strings.Fields(s), " "))
 }
 
-// webFetch fetches the raw text content of a URL.
-// It strips nothing — the agent receives the raw body. For HTML pages the
-// agent should extract what it needs; for JSON APIs it will parse cleanly.
-func webFetch(args map[string]any) (mcp.ToolCallResult, error) {
+// fetch fetches a URL and returns its content as text.
+// HTML and plain text are returned raw — the agent extracts what it needs.
+// Documents are decoded by type (see sniffFormat): PDF and DOCX to plain
+// text, CSV to rows, JSON pretty-printed; other binary bodies are described
+// rather than dumped.
+// Successful responses are cached and revalidated with ETag/Last-Modified;
+// network requests honour robots.txt and per-host limits (politeTransport).
+func (w *webTools) fetch(args map[string]any) (mcp.ToolCallResult, error) {
 	rawURL, errResult, err := requireString(args, "url")
 	if errResult != nil {
 		return *errResult, err
@@ -204,54 +141,151 @@ func webFetch(args map[string]any) (mcp.ToolCallResult, error) {
 		return textErr(fmt.Sprintf("invalid URL %q: %v", rawURL, err))
 	}
 
-	req, err := http.NewRequest("GET", rawURL, nil)
+	entry, cached, err := w.get("web_fetch", rawURL, optionalBool(args, "no_cache", false))
 	if err != nil {
-		return textErr(fmt.Sprintf("build request failed: %v", err))
+		return fetchErr(err)
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; ResearchBot/1.0)")
 
-	resp, err := webClient.Do(req)
+	contentType := entry.Headers["Content-Type"]
+	doc, err := extractDocument(sniffFormat(contentType, entry.Body, rawURL), entry.Body, contentType)
 	if err != nil {
-		return textErr(fmt.Sprintf("fetch failed: %v", err))
+		return textErr(fmt.Sprintf("%s (%s, %d bytes)", err, rawURL, len(entry.Body)))
+	}
+
+	result := map[string]any{
+		"url":          rawURL,
+		"status":       entry.Status,
+		"content_type": contentType,
+		"format":       doc.Format,
+		"truncated":    entry.Truncated,
+		"cached":       cached,
+	}
+	if doc.Rows != nil {
+		result["rows"] = doc.Rows
+		result["row_count"] = len(doc.Rows)
+		result["truncated"] = entry.Truncated || doc.RowsTruncated
+	} else {
+		// Cap the text at 100 KB to avoid overwhelming the agent context.
+		text, cut := truncateText(doc.Text, fetchMaxText)
+		result["body"] = text
+		result["truncated"] = entry.Truncated || cut
 	}
-	defer resp.Body.Close()
+	return textResult(result)
+}
+
+// get downloads rawURL through the polite client and the response cache,
+// capped at fetchMaxDownload. The page tools share one cache key per URL.
+func (w *webTools) get(tool, rawURL string, noCache bool) (*cacheEntry, bool, error) {
+	return w.getCapped(tool, rawURL, noCache, fetchMaxDownload())
+}
 
-	// Cap at 100 KB to avoid overwhelming the agent context.
-	const maxBytes = 100 * 1024
-	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes))
+// getCapped is get with the download capped at maxBytes instead.
+func (w *webTools) getCapped(tool, rawURL string, noCache bool, maxBytes int64) (*cacheEntry, bool, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
 	if err != nil {
-		return textErr(fmt.Sprintf("read body failed: %v", err))
+		return nil, false, err
 	}
+	req.Header.Set("User-Agent", w.polite.userAgent)
+	return w.cache.cachedGet(tool, w.pages, req, "fetch:"+normalizeURL(rawURL), maxBytes, noCache)
+}
 
-	return textResult(map[string]any{
-		"url":       rawURL,
-		"status":    resp.StatusCode,
-		"body":      string(body),
-		"truncated": len(body) == maxBytes,
-	})
+// fetchMaxText caps the text web_fetch returns to the agent.
+const fetchMaxText = 100 * 1024
+
+// fetchMaxDownload caps how much of a response is downloaded. It is larger
+// than fetchMaxText because PDFs and DOCX files shrink a lot once their text
+// is extracted. Override with WEB_FETCH_MAX_BYTES.
+func fetchMaxDownload() int64 {
+	return envInt64("WEB_FETCH_MAX_BYTES", 10<<20)
+}
+
+// truncateText cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncateText(s string, n int) (string, bool) {
+	if len(s) <= n {
+		return s, false
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n], true
+}
+
+// fetchErr turns a page-fetch failure into a tool error, calling out
+// robots.txt refusals so the agent does not retry them.
+func fetchErr(err error) (mcp.ToolCallResult, error) {
+	return textErr(fetchErrMessage(err))
+}
+
+// fetchErrMessage explains a failed page download, steering the agent away
+// from sources robots.txt forbids.
+func fetchErrMessage(err error) string {
+	var disallowed *errRobotsDisallowed
+	if errors.As(err, &disallowed) {
+		return fmt.Sprintf("fetch not allowed: %s. Choose a different source.", disallowed.Error())
+	}
+	return fmt.Sprintf("fetch failed: %v", err)
 }
 
 func webDefinitions() []mcp.ToolDefinition {
 	return []mcp.ToolDefinition{
 		{
 			Name:        "web_search",
-			Description: "Search the web for current information and return titles, URLs, and snippets. Uses Brave Search API if BRAVE_SEARCH_API_KEY is set, otherwise falls back to DuckDuckGo. Use during execute steps when the agent needs real-time or recent information.",
+			Description: "Search the web for current information and return titles, URLs, snippets, source domains, published dates (when known) and ranks. Backends (Brave, Bing, Google CSE, SearXNG, DuckDuckGo) are tried in the configured order, falling back to the next when one fails or finds nothing; the result names the provider used. Use during execute steps when the agent needs real-time or recent information. Repeated searches are served from a cache; the result's \"cached\" flag says so.",
 			InputSchema: mcp.JSONSchema{
 				Type: "object",
 				Properties: map[string]mcp.Property{
-					"query": {Type: "string", Description: "The search query."},
-					"limit": {Type: "number", Description: "Maximum number of results to return (default 5, max 10)."},
+					"query":         {Type: "string", Description: "The search query."},
+					"limit":         {Type: "number", Description: "Maximum number of results to return (default 5, max 10)."},
+					"offset":        {Type: "number", Description: "Number of results to skip, for fetching further pages (default 0). Pass the previous offset plus limit."},
+					"site":          {Type: "string", Description: `Restrict results to a domain and its subdomains, e.g. "go.dev". Several domains may be given comma-separated.`},
+					"exclude_sites": {Type: "string", Description: `Comma-separated domains to drop from results, e.g. "pinterest.com,quora.com".`},
+					"freshness":     {Type: "string", Description: `Only return pages from the last "day", "week" or "month".`},
+					"language":      {Type: "string", Description: `Result language as an ISO 639-1 code, e.g. "en", "de".`},
+					"region":        {Type: "string", Description: `Country to localise results for as an ISO 3166-1 code, e.g. "us", "gb".`},
+					"no_cache":      {Type: "boolean", Description: "Skip the result cache and query the backend directly (default false)."},
 				},
 				Required: []string{"query"},
 			},
 		},
 		{
 			Name:        "web_fetch",
-			Description: "Fetch the content of a URL and return it as text. Use to read a specific page or API endpoint found via web_search. Responses are capped at 100 KB.",
+			Description: "Fetch the content of a URL and return it as text. Use to read a specific page, API endpoint or document found via web_search, Jira or GitHub. PDF and DOCX files are converted to plain text, CSV files are returned as rows and JSON is pretty-printed; the result's \"format\" field names the detected type. Text is capped at 100 KB. Pages are cached and revalidated; the result's \"cached\" flag says whether the network was skipped. Sites are fetched politely: robots.txt is honoured and requests to one host are rate limited, so a disallowed URL returns an error.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"url":      {Type: "string", Description: "The full URL to fetch (must include https://)."},
+					"no_cache": {Type: "boolean", Description: "Skip the response cache and fetch a fresh copy (default false)."},
+				},
+				Required: []string{"url"},
+			},
+		},
+		{
+			Name:        "web_extract",
+			Description: "Fetch an HTML page and return its structure instead of its body: the title, an outline of h1–h6 headings as a tree, every http(s) link as an absolute URL with its anchor text, and HTML tables as JSON (objects keyed by column when the table has a header row, arrays otherwise). Use to navigate a site or pull tabular data without reading the whole page; follow a link with web_fetch or web_extract. Shares web_fetch's cache, size limit and robots.txt rules.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"url":         {Type: "string", Description: "The full URL of an HTML page (must include https://)."},
+					"same_origin": {Type: "boolean", Description: "Only return links on the same scheme and host as the page after any redirects (default false)."},
+					"no_cache":    {Type: "boolean", Description: "Skip the response cache and fetch a fresh copy (default false)."},
+				},
+				Required: []string{"url"},
+			},
+		},
+		{
+			Name:        "web_crawl",
+			Description: "Crawl a website breadth-first from a start URL and save every page's text into the file workspace, e.g. to ingest a documentation site. Only links on the same scheme and host (and under path_prefix, if given) are followed; sitemap.xml can seed the queue. The crawl stops at max_pages or max_bytes. HTML pages are saved as Markdown-like text, PDFs and DOCX as plain text. Returns an index of saved pages (also written to index.json in output_dir) that file_read can open. robots.txt and per-host rate limits apply.",
 			InputSchema: mcp.JSONSchema{
 				Type: "object",
 				Properties: map[string]mcp.Property{
-					"url": {Type: "string", Description: "The full URL to fetch (must include https://)."},
+					"url":         {Type: "string", Description: "The page to start from (must include https://)."},
+					"max_pages":   {Type: "number", Description: "Maximum number of pages to save (default 20, max 200)."},
+					"max_bytes":   {Type: "number", Description: "Maximum total bytes to download (default 5 MiB, max 50 MiB)."},
+					"use_sitemap": {Type: "boolean", Description: "Also queue the URLs listed in the site's /sitemap.xml (default false)."},
+					"path_prefix": {Type: "string", Description: `Only crawl URLs whose path starts with this prefix, e.g. "/docs/".`},
+					"output_dir":  {Type: "string", Description: `Workspace directory to write pages into (default "crawl/<host>").`},
+					"workspace":   workspaceProperty,
+					"no_cache":    {Type: "boolean", Description: "Skip the response cache and fetch fresh copies (default false)."},
 				},
 				Required: []string{"url"},
 			},
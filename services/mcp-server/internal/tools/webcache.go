@@ -0,0 +1,480 @@
+package tools
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mcp-server/internal/observability"
+)
+
+// responseCache is a size-bounded LRU of web responses shared by web_search,
+// web_fetch and GET http_request. Entries live in memory and, when
+// WEB_CACHE_DIR is set, are mirrored to disk so they survive restarts.
+// Responses to requests carrying credentials (Authorization, Cookie) stay in
+// memory only.
+//
+// Configuration (all optional):
+//   - WEB_CACHE_MAX_BYTES      in-memory budget, default 32 MiB; 0 disables the cache
+//   - WEB_CACHE_DIR            directory for the on-disk tier, disabled when unset
+//   - WEB_CACHE_DISK_MAX_BYTES on-disk budget, default 256 MiB
+//   - WEB_CACHE_SEARCH_TTL     freshness of search results, default 1h
+//   - WEB_CACHE_FETCH_TTL      freshness of fetched pages, default 10m
+//
+// A nil *responseCache is valid and caches nothing.
+type responseCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	lru      *list.List // front = most recently used
+	items    map[string]*list.Element
+
+	dir          string
+	diskMaxBytes int64
+	diskSize     int64
+	diskLRU      *list.List // of *diskFile, front = most recently used
+	diskFiles    map[string]*list.Element
+
+	searchTTL time.Duration
+	fetchTTL  time.Duration
+}
+
+// cacheEntry is one cached response. Search results are stored with Status
+// 200 and the JSON-encoded result list as Body.
+type cacheEntry struct {
+	Key          string            `json:"key"`
+	URL          string            `json:"url,omitempty"` // final URL, after redirects
+	Status       int               `json:"status"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Body         []byte            `json:"body"`
+	Truncated    bool              `json:"truncated,omitempty"`
+	Limit        int64             `json:"limit,omitempty"` // cap the body was read with
+	ETag         string            `json:"etag,omitempty"`
+	LastModified string            `json:"last_modified,omitempty"`
+	Expires      time.Time         `json:"expires"`
+}
+
+// diskFile is the disk tier's record of one cache file, kept so that
+// eviction need not rescan the directory.
+type diskFile struct {
+	name string
+	size int64
+}
+
+func (e *cacheEntry) fresh(now time.Time) bool { return now.Before(e.Expires) }
+
+func (e *cacheEntry) cost() int64 { return int64(len(e.Key) + len(e.Body)) }
+
+// covers reports whether e can answer a read capped at maxBytes: it holds
+// the whole body, or a prefix at least that long.
+func (e *cacheEntry) covers(maxBytes int64) bool { return !e.Truncated || e.Limit >= maxBytes }
+
+// capped returns e with its body cut to maxBytes.
+func (e *cacheEntry) capped(maxBytes int64) *cacheEntry {
+	if int64(len(e.Body)) <= maxBytes {
+		return e
+	}
+	cut := *e
+	cut.Body = e.Body[:maxBytes]
+	cut.Truncated = true
+	return &cut
+}
+
+func newResponseCache() *responseCache {
+	maxBytes := envInt64("WEB_CACHE_MAX_BYTES", 32<<20)
+	if maxBytes <= 0 {
+		return nil
+	}
+	c := &responseCache{
+		maxBytes:     maxBytes,
+		lru:          list.New(),
+		items:        map[string]*list.Element{},
+		dir:          os.Getenv("WEB_CACHE_DIR"),
+		diskMaxBytes: envInt64("WEB_CACHE_DISK_MAX_BYTES", 256<<20),
+		diskLRU:      list.New(),
+		diskFiles:    map[string]*list.Element{},
+		searchTTL:    envDuration("WEB_CACHE_SEARCH_TTL", time.Hour),
+		fetchTTL:     envDuration("WEB_CACHE_FETCH_TTL", 10*time.Minute),
+	}
+	if c.dir != "" {
+		if err := os.MkdirAll(c.dir, 0o755); err != nil {
+			c.dir = ""
+		} else {
+			c.loadDiskIndex()
+		}
+	}
+	return c
+}
+
+// loadDiskIndex records the files already in the cache directory, oldest
+// touched last, so a restart keeps the disk tier's LRU order.
+func (c *responseCache) loadDiskIndex() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	type file struct {
+		diskFile
+		mtime time.Time
+	}
+	var files []file
+	for _, de := range entries {
+		if de.IsDir() || !strings.HasSuffix(de.Name(), ".json") {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, file{diskFile{de.Name(), info.Size()}, info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mtime.Before(files[j].mtime) })
+	for _, f := range files {
+		c.noteDiskLocked(f.name, f.size)
+	}
+}
+
+// get returns the entry for key, fresh or stale, checking memory first and
+// then the disk tier.
+func (c *responseCache) get(key string) (*cacheEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.lru.MoveToFront(el)
+		return el.Value.(*cacheEntry), true
+	}
+	if c.dir == "" {
+		return nil, false
+	}
+	path := c.diskPath(key)
+	if _, ok := c.diskFiles[filepath.Base(path)]; !ok {
+		return nil, false
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		c.forgetDiskLocked(filepath.Base(path))
+		return nil, false
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(b, &e); err != nil || e.Key != key {
+		return nil, false
+	}
+	// Touch the file so disk eviction approximates LRU too, across restarts
+	// as well.
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	c.noteDiskLocked(filepath.Base(path), int64(len(b)))
+	c.insertLocked(&e)
+	return &e, true
+}
+
+// put stores e in memory and, when configured and persist is set, on disk.
+func (c *responseCache) put(e *cacheEntry, persist bool) {
+	if c == nil || e.cost() > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.insertLocked(e)
+	if c.dir != "" && persist {
+		c.writeDiskLocked(e)
+	}
+}
+
+// invalidate drops every entry of group (see cacheGroup) from memory and
+// disk.
+func (c *responseCache) invalidate(group string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, el := range c.items {
+		if cacheGroup(key) == group {
+			c.lru.Remove(el)
+			delete(c.items, key)
+			c.size -= el.Value.(*cacheEntry).cost()
+		}
+	}
+	if c.dir == "" {
+		return
+	}
+	prefix := groupHash(group) + "-"
+	for name := range c.diskFiles {
+		if strings.HasPrefix(name, prefix) {
+			os.Remove(filepath.Join(c.dir, name))
+			c.forgetDiskLocked(name)
+		}
+	}
+}
+
+func (c *responseCache) insertLocked(e *cacheEntry) {
+	if el, ok := c.items[e.Key]; ok {
+		c.size -= el.Value.(*cacheEntry).cost()
+		el.Value = e
+		c.lru.MoveToFront(el)
+	} else {
+		c.items[e.Key] = c.lru.PushFront(e)
+	}
+	c.size += e.cost()
+
+	for c.size > c.maxBytes {
+		oldest := c.lru.Back()
+		if oldest == nil {
+			break
+		}
+		victim := oldest.Value.(*cacheEntry)
+		c.lru.Remove(oldest)
+		delete(c.items, victim.Key)
+		c.size -= victim.cost()
+	}
+}
+
+// diskPath names the file of key after its group and itself, so that a
+// group's files can be found without reading them.
+func (c *responseCache) diskPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, groupHash(cacheGroup(key))+"-"+hex.EncodeToString(sum[:])+".json")
+}
+
+// cacheGroup returns the part of key before the header fingerprint, which
+// is shared by every cached variant of one URL.
+func cacheGroup(key string) string {
+	group, _, _ := strings.Cut(key, "#")
+	return group
+}
+
+func groupHash(group string) string {
+	sum := sha256.Sum256([]byte(group))
+	return hex.EncodeToString(sum[:8])
+}
+
+// hasCredentials reports whether h carries credentials, whose responses
+// must not be written to the disk tier.
+func hasCredentials(h http.Header) bool {
+	return h.Get("Authorization") != "" || h.Get("Proxy-Authorization") != "" || h.Get("Cookie") != ""
+}
+
+// writeDiskLocked persists e atomically, then evicts the least recently
+// touched files until the disk tier fits in diskMaxBytes. Disk errors are
+// ignored: the memory tier still works.
+func (c *responseCache) writeDiskLocked(e *cacheEntry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, werr := tmp.Write(b)
+	cerr := tmp.Close()
+	path := c.diskPath(e.Key)
+	if werr != nil || cerr != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	c.noteDiskLocked(filepath.Base(path), int64(len(b)))
+
+	for c.diskSize > c.diskMaxBytes {
+		oldest := c.diskLRU.Back()
+		if oldest == nil {
+			break
+		}
+		name := oldest.Value.(*diskFile).name
+		if err := os.Remove(filepath.Join(c.dir, name)); err != nil && !os.IsNotExist(err) {
+			break
+		}
+		c.forgetDiskLocked(name)
+	}
+}
+
+// noteDiskLocked records that the named file now holds size bytes and was
+// just used.
+func (c *responseCache) noteDiskLocked(name string, size int64) {
+	if el, ok := c.diskFiles[name]; ok {
+		f := el.Value.(*diskFile)
+		c.diskSize += size - f.size
+		f.size = size
+		c.diskLRU.MoveToFront(el)
+		return
+	}
+	c.diskFiles[name] = c.diskLRU.PushFront(&diskFile{name, size})
+	c.diskSize += size
+}
+
+func (c *responseCache) forgetDiskLocked(name string) {
+	if el, ok := c.diskFiles[name]; ok {
+		c.diskSize -= el.Value.(*diskFile).size
+		c.diskLRU.Remove(el)
+		delete(c.diskFiles, name)
+	}
+}
+
+// cachedGet sends the GET request req through the cache under key. Fresh
+// entries are served without touching the network; stale entries carrying an
+// ETag or Last-Modified are revalidated with a conditional request. Only 200
+// responses are stored. The bool result reports whether the body was served
+// from the cache (a hit or a successful revalidation).
+//
+// tool labels the hit/miss counters; bypass skips the lookup but still
+// refreshes the stored copy. A cached copy read with a smaller cap than
+// maxBytes is refetched, and a larger one is cut to maxBytes.
+func (c *responseCache) cachedGet(tool string, client *http.Client, req *http.Request, key string, maxBytes int64, bypass bool) (*cacheEntry, bool, error) {
+	persist := !hasCredentials(req.Header)
+	var stale *cacheEntry
+	if !bypass {
+		if e, ok := c.get(key); ok && e.covers(maxBytes) {
+			if e.fresh(time.Now()) {
+				observability.RecordCacheLookup(tool, "hit")
+				return e.capped(maxBytes), true, nil
+			}
+			if e.ETag != "" || e.LastModified != "" {
+				stale = e
+				if e.ETag != "" {
+					req.Header.Set("If-None-Match", e.ETag)
+				}
+				if e.LastModified != "" {
+					req.Header.Set("If-Modified-Since", e.LastModified)
+				}
+			}
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if stale != nil && resp.StatusCode == http.StatusNotModified {
+		observability.RecordCacheLookup(tool, "revalidated")
+		refreshed := *stale
+		refreshed.Expires = time.Now().Add(c.fetchTTL)
+		c.put(&refreshed, persist)
+		return refreshed.capped(maxBytes), true, nil
+	}
+	if c != nil && !bypass {
+		observability.RecordCacheLookup(tool, "miss")
+	}
+
+	e, err := readResponse(resp, key, maxBytes)
+	if err != nil {
+		return nil, false, err
+	}
+	if c != nil && resp.StatusCode == http.StatusOK &&
+		!strings.Contains(strings.ToLower(resp.Header.Get("Cache-Control")), "no-store") {
+		e.Expires = time.Now().Add(c.fetchTTL)
+		c.put(e, persist)
+	}
+	return e, false, nil
+}
+
+// readResponse reads up to maxBytes of resp into an (unexpired) cache entry.
+func readResponse(resp *http.Response, key string, maxBytes int64) (*cacheEntry, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes))
+	if err != nil {
+		return nil, err
+	}
+	headers := make(map[string]string, len(resp.Header))
+	for k, vs := range resp.Header {
+		headers[k] = strings.Join(vs, ", ")
+	}
+	e := &cacheEntry{
+		Key:          key,
+		Status:       resp.StatusCode,
+		Headers:      headers,
+		Body:         body,
+		Truncated:    int64(len(body)) == maxBytes,
+		Limit:        maxBytes,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if resp.Request != nil {
+		e.URL = resp.Request.URL.String()
+	}
+	return e, nil
+}
+
+// lookupSearch returns cached search results for key if they are still fresh.
+func (c *responseCache) lookupSearch(key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	if e, ok := c.get(key); ok && e.fresh(time.Now()) {
+		observability.RecordCacheLookup("web_search", "hit")
+		return e.Body, true
+	}
+	observability.RecordCacheLookup("web_search", "miss")
+	return nil, false
+}
+
+// storeSearch caches encoded search results under key for the search TTL.
+func (c *responseCache) storeSearch(key string, body []byte) {
+	if c == nil {
+		return
+	}
+	c.put(&cacheEntry{Key: key, Status: http.StatusOK, Body: body, Expires: time.Now().Add(c.searchTTL)}, true)
+}
+
+// normalizeURL canonicalises rawURL for use as a cache key: lower-case scheme
+// and host, default ports and fragments dropped, query parameters sorted.
+func normalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" &&
+		!(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawQuery = u.Query().Encode()
+	return u.String()
+}
+
+// normalizeQuery lower-cases a search query and collapses its whitespace.
+func normalizeQuery(q string) string {
+	return strings.Join(strings.Fields(strings.ToLower(q)), " ")
+}
+
+// headerFingerprint folds request headers into a cache key so responses
+// fetched with different credentials or content negotiation never collide.
+func headerFingerprint(h http.Header) string {
+	if len(h) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sum := sha256.New()
+	for _, k := range keys {
+		io.WriteString(sum, strings.ToLower(k)+":"+strings.Join(h[k], ",")+"\n")
+	}
+	return hex.EncodeToString(sum.Sum(nil))[:16]
+}
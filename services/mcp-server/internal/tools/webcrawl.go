@@ -0,0 +1,378 @@
+package tools
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"mcp-server/internal/mcp"
+)
+
+// Crawl budgets: defaults and the hard ceilings the arguments are clamped to.
+const (
+	defaultCrawlPages = 20
+	maxCrawlPages     = 200
+	defaultCrawlBytes = 5 << 20
+	maxCrawlBytes     = 50 << 20
+	maxCrawlSitemaps  = 5
+	maxCrawlQueue     = 10000
+	maxCrawlErrors    = 50
+)
+
+// crawledPage is one index entry for a page written to the workspace.
+type crawledPage struct {
+	URL    string `json:"url"`
+	Title  string `json:"title,omitempty"`
+	Path   string `json:"path"`
+	Format string `json:"format"`
+	Bytes  int    `json:"bytes"`
+	Depth  int    `json:"depth"`
+}
+
+type crawlError struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+type crawlItem struct {
+	url   string
+	depth int
+}
+
+var reUnsafePathChars = regexp.MustCompile(`[^A-Za-z0-9._/-]+`)
+
+// crawl walks a site breadth-first from a start URL, staying on its origin
+// (and under path_prefix when given), optionally seeded from sitemap.xml.
+// Each page's extracted text is written to output_dir in the file workspace
+// along with an index.json; the index is also returned. Pages go through the
+// same polite client and cache as web_fetch, so robots.txt and per-host rate
+// limits apply.
+func (w *webTools) crawl(ws *workspace, args map[string]any) (mcp.ToolCallResult, error) {
+	startURL, errResult, err := requireString(args, "url")
+	if errResult != nil {
+		return *errResult, err
+	}
+	start, err := url.ParseRequestURI(startURL)
+	if err != nil || (start.Scheme != "http" && start.Scheme != "https") {
+		return textErr(fmt.Sprintf("invalid URL %q: must be an absolute http(s) URL", startURL))
+	}
+	// The origin is settled by where the start URL's redirects end.
+	origin := originOf(startURL)
+	prefix := optionalString(args, "path_prefix", "")
+	noCache := optionalBool(args, "no_cache", false)
+
+	maxPages := int(optionalFloat(args, "max_pages", defaultCrawlPages))
+	maxPages = min(max(maxPages, 1), maxCrawlPages)
+	maxBytes := int64(optionalFloat(args, "max_bytes", defaultCrawlBytes))
+	maxBytes = min(max(maxBytes, 1), maxCrawlBytes)
+
+	outDir := optionalString(args, "output_dir", path.Join("crawl", reUnsafePathChars.ReplaceAllString(start.Host, "_")))
+	outRel, errResult, err := safePath(outDir)
+	if errResult != nil {
+		return *errResult, err
+	}
+
+	inScope := func(u *url.URL) bool {
+		return originOf(u.String()) == origin && strings.HasPrefix(u.Path, prefix)
+	}
+
+	queue := []crawlItem{{url: startURL}}
+	seen := map[string]bool{normalizeURL(startURL): true}
+	enqueue := func(raw string, depth int) {
+		u, err := url.Parse(raw)
+		if err != nil || !inScope(u) || len(queue) >= maxCrawlQueue {
+			return
+		}
+		u.Fragment = ""
+		key := normalizeURL(u.String())
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		queue = append(queue, crawlItem{url: u.String(), depth: depth})
+	}
+
+	useSitemap := optionalBool(args, "use_sitemap", false)
+	sitemapURLs := 0
+	settled := false
+	// settle fixes the origin once the start URL has been fetched and seeds
+	// the queue from that origin's sitemap.
+	settle := func(finalURL string) {
+		settled = true
+		if finalURL != "" {
+			origin = originOf(finalURL)
+		}
+		if !useSitemap {
+			return
+		}
+		for _, loc := range w.sitemapURLs(origin+"/sitemap.xml", noCache) {
+			before := len(queue)
+			enqueue(loc, 1)
+			sitemapURLs += len(queue) - before
+		}
+	}
+
+	var (
+		pages     = []crawledPage{}
+		errs      = []crawlError{}
+		total     int64
+		usedNames = map[string]bool{}
+		stopped   = "queue exhausted"
+	)
+	addErr := func(u, msg string) {
+		if len(errs) < maxCrawlErrors {
+			errs = append(errs, crawlError{URL: u, Error: msg})
+		}
+	}
+
+	for len(queue) > 0 {
+		if len(pages) >= maxPages {
+			stopped = "page budget reached"
+			break
+		}
+		if total >= maxBytes {
+			stopped = "byte budget reached"
+			break
+		}
+		item := queue[0]
+		queue = queue[1:]
+
+		// Cap the download at what is left of the byte budget.
+		entry, _, err := w.getCapped("web_crawl", item.url, noCache, min(maxBytes-total, fetchMaxDownload()))
+		if !settled {
+			finalURL := ""
+			if err == nil {
+				finalURL = entry.URL
+			}
+			settle(finalURL)
+		}
+		if err != nil {
+			addErr(item.url, fetchErrMessage(err))
+			continue
+		}
+		total += int64(len(entry.Body))
+		if entry.Status != 200 {
+			addErr(item.url, fmt.Sprintf("HTTP %d", entry.Status))
+			continue
+		}
+
+		// Pages are recorded under the URL their redirects ended at, which
+		// must itself be in scope.
+		pageURL := item.url
+		if entry.URL != "" {
+			pageURL = entry.URL
+		}
+		if u, err := url.Parse(pageURL); err != nil || !inScope(u) {
+			addErr(item.url, fmt.Sprintf("redirected out of scope to %s", pageURL))
+			continue
+		}
+		seen[normalizeURL(pageURL)] = true
+
+		contentType := entry.Headers["Content-Type"]
+		format := sniffFormat(contentType, entry.Body, pageURL)
+		var title, text string
+		switch format {
+		case formatHTML:
+			snap, err := extractPage(entry.Body, pageURL, origin)
+			if err != nil {
+				addErr(pageURL, err.Error())
+				continue
+			}
+			title = snap.Title
+			for _, l := range snap.Links {
+				enqueue(l.URL, item.depth+1)
+			}
+			text = htmlToText(entry.Body)
+		case formatCSV, formatJSON, formatText:
+			text = string(entry.Body)
+		default:
+			doc, err := extractDocument(format, entry.Body, contentType)
+			if err != nil || format == formatBinary {
+				addErr(pageURL, fmt.Sprintf("skipped %s content", format))
+				continue
+			}
+			text = doc.Text
+		}
+
+		name := crawlFileName(pageURL, format, usedNames)
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "Source: %s\n", pageURL)
+		if title != "" {
+			fmt.Fprintf(&buf, "Title: %s\n", title)
+		}
+		buf.WriteString("\n")
+		buf.WriteString(text)
+		// A refused write (usually the workspace quota) ends the crawl, but
+		// the pages already written still get their index.
+		if err := writeWorkspaceFile(ws, filepath.Join(outRel, name), buf.Bytes(), "crawl"); err != nil {
+			stopped = fmt.Sprintf("write failed: %v", err)
+			addErr(pageURL, err.Error())
+			break
+		}
+		pages = append(pages, crawledPage{
+			URL: pageURL, Title: title, Path: path.Join(outDir, name),
+			Format: format, Bytes: buf.Len(), Depth: item.depth,
+		})
+	}
+
+	index := map[string]any{
+		"start_url":      startURL,
+		"output_dir":     outDir,
+		"pages":          pages,
+		"page_count":     len(pages),
+		"bytes_fetched":  total,
+		"sitemap_urls":   sitemapURLs,
+		"pending_urls":   len(queue),
+		"stopped_reason": stopped,
+		"errors":         errs,
+	}
+	b, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return mcp.ToolCallResult{}, fmt.Errorf("marshal index: %w", err)
+	}
+	if err := writeWorkspaceFile(ws, filepath.Join(outRel, "index.json"), b, "crawl"); err != nil {
+		index["index_error"] = err.Error()
+		return textResult(index)
+	}
+	index["index_path"] = path.Join(outDir, "index.json")
+	return textResult(index)
+}
+
+// sitemapURLs returns the <loc> entries of a sitemap, following the child
+// sitemaps of a sitemap index while fetching at most maxCrawlSitemaps
+// sitemaps in all. A missing or malformed sitemap yields nothing.
+func (w *webTools) sitemapURLs(sitemapURL string, noCache bool) []string {
+	type sitemap struct {
+		URLs     []string `xml:"url>loc"`
+		Sitemaps []string `xml:"sitemap>loc"`
+	}
+	var locs []string
+	pending := []string{sitemapURL}
+	for fetched := 0; len(pending) > 0 && fetched < maxCrawlSitemaps; fetched++ {
+		entry, _, err := w.get("web_crawl", pending[0], noCache)
+		pending = pending[1:]
+		if err != nil || entry.Status != 200 {
+			continue
+		}
+		var sm sitemap
+		if xml.Unmarshal(entry.Body, &sm) != nil {
+			continue
+		}
+		for _, l := range sm.URLs {
+			locs = append(locs, strings.TrimSpace(l))
+		}
+		for _, s := range sm.Sitemaps {
+			pending = append(pending, strings.TrimSpace(s))
+		}
+	}
+	return locs
+}
+
+// crawlFileName derives a readable, unique workspace file name from a page
+// URL: "/guide/install.html" becomes "guide/install.html.md". A query string
+// adds a short hash so "?page=2" does not overwrite the first page.
+func crawlFileName(rawURL, format string, used map[string]bool) string {
+	u, _ := url.Parse(rawURL)
+	p := strings.Trim(path.Clean("/"+u.Path), "/")
+	if p == "" || strings.HasSuffix(u.Path, "/") {
+		p = path.Join(p, "index")
+	}
+	p = reUnsafePathChars.ReplaceAllString(p, "_")
+	p = strings.ReplaceAll(p, "..", "_")
+	if u.RawQuery != "" {
+		sum := sha256.Sum256([]byte(u.RawQuery))
+		p += "-" + hex.EncodeToString(sum[:4])
+	}
+	ext := ".txt"
+	if format == formatHTML {
+		ext = ".md"
+	}
+	name := p + ext
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%s-%d%s", p, i, ext)
+	}
+	used[name] = true
+	return name
+}
+
+// htmlToText renders the readable text of an HTML page as light Markdown:
+// headings keep their level as "#" prefixes, list items become "- " lines
+// and other blocks are separated by blank lines. Scripts, styles and page
+// chrome (nav, header, footer) are dropped.
+func htmlToText(body []byte) string {
+	root, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return cleanText(string(body))
+	}
+	var b strings.Builder
+	var line strings.Builder
+	flush := func(prefix string, blank bool) {
+		text := strings.Join(strings.Fields(line.String()), " ")
+		line.Reset()
+		if text == "" {
+			return
+		}
+		b.WriteString(prefix + text + "\n")
+		if blank {
+			b.WriteString("\n")
+		}
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			line.WriteString(n.Data)
+			return
+		}
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Nav, atom.Header, atom.Footer, atom.Head:
+				return
+			case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+				flush("", true)
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					walk(c)
+				}
+				flush(strings.Repeat("#", int(n.Data[1]-'0'))+" ", true)
+				return
+			case atom.Li:
+				flush("", false)
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					walk(c)
+				}
+				flush("- ", false)
+				return
+			case atom.Br:
+				line.WriteString("\n")
+				return
+			case atom.P, atom.Div, atom.Section, atom.Article, atom.Pre, atom.Blockquote,
+				atom.Table, atom.Tr, atom.Ul, atom.Ol, atom.Dl, atom.Dt, atom.Dd:
+				flush("", true)
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					walk(c)
+				}
+				flush("", n.DataAtom != atom.Tr)
+				return
+			case atom.Td, atom.Th:
+				line.WriteString(" ")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	flush("", false)
+	return strings.TrimSpace(collapseBlankLines(b.String())) + "\n"
+}
@@ -0,0 +1,363 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"mcp-server/internal/mcp"
+)
+
+// Caps on what web_extract returns, so a huge page still yields a compact
+// snapshot.
+const (
+	maxExtractLinks     = 500
+	maxExtractTables    = 20
+	maxExtractTableRows = 200
+	maxExtractTextRunes = 300
+)
+
+// pageSnapshot is the structure web_extract returns for an HTML page.
+type pageSnapshot struct {
+	Title   string         `json:"title"`
+	Outline []*headingNode `json:"outline"`
+	Links   []pageLink     `json:"links"`
+	Tables  []pageTable    `json:"tables"`
+
+	linksTruncated  bool
+	tablesTruncated bool
+}
+
+// headingNode is one h1–h6 with the lower-level headings that follow it.
+type headingNode struct {
+	Level    int            `json:"level"`
+	Text     string         `json:"text"`
+	ID       string         `json:"id,omitempty"`
+	Children []*headingNode `json:"children,omitempty"`
+}
+
+// pageLink is an absolute http(s) link with its anchor text.
+type pageLink struct {
+	URL  string `json:"url"`
+	Text string `json:"text"`
+}
+
+// pageTable is an HTML table. When the table has a header row, Rows holds one
+// object per body row keyed by column name; otherwise each row is an array.
+type pageTable struct {
+	Caption       string   `json:"caption,omitempty"`
+	Columns       []string `json:"columns,omitempty"`
+	Rows          []any    `json:"rows"`
+	RowsTruncated bool     `json:"rows_truncated,omitempty"`
+}
+
+// extractPage parses an HTML document fetched from pageURL. Relative links
+// are resolved against pageURL, or the document's <base href> when present.
+// A non-empty origin keeps only links to that origin, before the link cap
+// applies.
+func extractPage(body []byte, pageURL, origin string) (*pageSnapshot, error) {
+	root, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("parse HTML failed: %v", err)
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %v", pageURL, err)
+	}
+	if b := findElement(root, atom.Base); b != nil {
+		if ref, err := base.Parse(attr(b, "href")); err == nil && attr(b, "href") != "" {
+			base = ref
+		}
+	}
+
+	snap := &pageSnapshot{Outline: []*headingNode{}, Links: []pageLink{}, Tables: []pageTable{}}
+	if t := findElement(root, atom.Title); t != nil {
+		snap.Title = nodeText(t)
+	}
+
+	var stack []*headingNode // open headings, outermost first
+	seen := map[string]bool{}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Noscript, atom.Template:
+				return
+			case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+				h := &headingNode{Level: int(n.Data[1] - '0'), Text: nodeText(n), ID: attr(n, "id")}
+				if h.Text == "" {
+					break
+				}
+				for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+					stack = stack[:len(stack)-1]
+				}
+				if len(stack) == 0 {
+					snap.Outline = append(snap.Outline, h)
+				} else {
+					parent := stack[len(stack)-1]
+					parent.Children = append(parent.Children, h)
+				}
+				stack = append(stack, h)
+			case atom.A:
+				snap.addLink(base, origin, attr(n, "href"), nodeText(n), seen)
+			case atom.Table:
+				if len(snap.Tables) >= maxExtractTables {
+					snap.tablesTruncated = true
+				} else if t, ok := tableJSON(n); ok {
+					snap.Tables = append(snap.Tables, t)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return snap, nil
+}
+
+// addLink records href once, skipping fragments of the page itself,
+// non-web schemes such as mailto: and javascript:, and links to origins
+// other than origin when it is set.
+func (s *pageSnapshot) addLink(base *url.URL, origin, href, text string, seen map[string]bool) {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return
+	}
+	u, err := base.Parse(href)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	abs := u.String()
+	if seen[abs] || (origin != "" && originOf(abs) != origin) {
+		return
+	}
+	seen[abs] = true
+	if len(s.Links) >= maxExtractLinks {
+		s.linksTruncated = true
+		return
+	}
+	s.Links = append(s.Links, pageLink{URL: abs, Text: text})
+}
+
+// tableJSON converts a table element to rows. Rows of nested tables belong to
+// the nested table only. Tables without any cells are skipped.
+func tableJSON(table *html.Node) (pageTable, bool) {
+	var t pageTable
+	var rows [][]string
+	headerRow := -1
+
+	var walk func(n *html.Node, inHead bool)
+	walk = func(n *html.Node, inHead bool) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Table:
+				continue
+			case atom.Caption:
+				t.Caption = nodeText(c)
+			case atom.Thead:
+				walk(c, true)
+			case atom.Tr:
+				var cells []string
+				allTH := true
+				for td := c.FirstChild; td != nil; td = td.NextSibling {
+					if td.Type != html.ElementNode || (td.DataAtom != atom.Td && td.DataAtom != atom.Th) {
+						continue
+					}
+					allTH = allTH && td.DataAtom == atom.Th
+					text := nodeText(td)
+					span, _ := strconv.Atoi(attr(td, "colspan"))
+					for i := 0; i < max(span, 1) && i < 100; i++ {
+						cells = append(cells, text)
+					}
+				}
+				if len(cells) == 0 {
+					continue
+				}
+				if headerRow < 0 && len(rows) == 0 && (inHead || allTH) {
+					headerRow = 0
+				}
+				rows = append(rows, cells)
+			default:
+				walk(c, inHead)
+			}
+		}
+	}
+	walk(table, false)
+	if len(rows) == 0 {
+		return t, false
+	}
+
+	body := rows
+	if headerRow == 0 {
+		t.Columns = columnNames(rows[0])
+		body = rows[1:]
+	}
+	if len(body) > maxExtractTableRows {
+		body = body[:maxExtractTableRows]
+		t.RowsTruncated = true
+	}
+	t.Rows = make([]any, 0, len(body))
+	for _, r := range body {
+		if t.Columns == nil {
+			t.Rows = append(t.Rows, r)
+			continue
+		}
+		obj := make(map[string]string, len(t.Columns))
+		for i, col := range t.Columns {
+			if i < len(r) {
+				obj[col] = r[i]
+			} else {
+				obj[col] = ""
+			}
+		}
+		t.Rows = append(t.Rows, obj)
+	}
+	return t, true
+}
+
+// columnNames makes header cells usable as object keys: empty names become
+// column_N and repeated names get a numeric suffix.
+func columnNames(header []string) []string {
+	names := make([]string, len(header))
+	used := map[string]int{}
+	for i, h := range header {
+		if h == "" {
+			h = fmt.Sprintf("column_%d", i+1)
+		}
+		if n := used[h]; n > 0 {
+			used[h]++
+			h = fmt.Sprintf("%s_%d", h, n+1)
+		} else {
+			used[h] = 1
+		}
+		names[i] = h
+	}
+	return names
+}
+
+// findElement returns the first element with the given tag in document order.
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// nodeText returns the visible text under n with whitespace collapsed,
+// truncated to maxExtractTextRunes. Images contribute their alt text.
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Noscript, atom.Template:
+				return
+			case atom.Img:
+				b.WriteString(" " + attr(n, "alt") + " ")
+				return
+			case atom.Br, atom.P, atom.Div, atom.Li:
+				b.WriteByte(' ')
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	text := strings.Join(strings.Fields(b.String()), " ")
+	if r := []rune(text); len(r) > maxExtractTextRunes {
+		text = string(r[:maxExtractTextRunes]) + "…"
+	}
+	return text
+}
+
+// extract fetches an HTML page and returns its outline, links and tables
+// instead of the raw body, so the agent can navigate without reading it all.
+// It shares web_fetch's client, cache and download limit.
+func (w *webTools) extract(args map[string]any) (mcp.ToolCallResult, error) {
+	rawURL, errResult, err := requireString(args, "url")
+	if errResult != nil {
+		return *errResult, err
+	}
+	if _, err := url.ParseRequestURI(rawURL); err != nil {
+		return textErr(fmt.Sprintf("invalid URL %q: %v", rawURL, err))
+	}
+
+	entry, cached, err := w.get("web_extract", rawURL, optionalBool(args, "no_cache", false))
+	if err != nil {
+		return fetchErr(err)
+	}
+	contentType := entry.Headers["Content-Type"]
+	if format := sniffFormat(contentType, entry.Body, rawURL); format != formatHTML {
+		return textErr(fmt.Sprintf("web_extract needs an HTML page but %s is %s; use web_fetch instead", rawURL, format))
+	}
+	// Links resolve against, and same_origin compares with, the URL any
+	// redirects ended at.
+	finalURL := rawURL
+	if entry.URL != "" {
+		finalURL = entry.URL
+	}
+	origin := ""
+	if optionalBool(args, "same_origin", false) {
+		origin = originOf(finalURL)
+	}
+	snap, err := extractPage(entry.Body, finalURL, origin)
+	if err != nil {
+		return textErr(err.Error())
+	}
+
+	return textResult(map[string]any{
+		"url":              rawURL,
+		"final_url":        finalURL,
+		"status":           entry.Status,
+		"title":            snap.Title,
+		"outline":          snap.Outline,
+		"links":            snap.Links,
+		"link_count":       len(snap.Links),
+		"links_truncated":  snap.linksTruncated,
+		"tables":           snap.Tables,
+		"tables_truncated": snap.tablesTruncated,
+		"truncated":        entry.Truncated,
+		"cached":           cached,
+	})
+}
+
+// originOf returns the lower-cased scheme://host[:port] of rawURL.
+func originOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
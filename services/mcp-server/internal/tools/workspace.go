@@ -0,0 +1,145 @@
+package tools
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"mcp-server/internal/mcp"
+)
+
+// errHardlink is returned when a file in the workspace has other hard links.
+// Such a file may share its inode with something outside the workspace, which
+// no path check can detect, so it is not read.
+var errHardlink = errors.New("file has multiple hard links and may alias a file outside the workspace")
+
+// workspace is an open agent workspace. Every file operation goes through
+// an *os.Root, which resolves each path component relative to an open
+// directory handle (openat-style): ".." and symlinks can never lead outside
+// the root, even if the tree is changed concurrently between a check and
+// the operation. safePath's lexical check only gives the agent friendlier
+// errors; the root is what enforces confinement.
+//
+// Paths passed to the methods are workspace-relative, as returned by
+// safePath.
+type workspace struct {
+	root *os.Root
+	dir  string // absolute path of the root directory
+}
+
+// openWorkspace opens the workspace a tool call names (see workspaceRoot).
+// The default workspace is created if needed; named ones must exist.
+func openWorkspace(args map[string]any) (*workspace, error) {
+	dir, create, err := workspaceRoot(args)
+	if err != nil {
+		return nil, err
+	}
+	if create {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &workspace{root: root, dir: dir}, nil
+}
+
+func (ws *workspace) Close() error { return ws.root.Close() }
+
+// withWorkspace opens the workspace for the duration of one tool call.
+func withWorkspace(args map[string]any, fn func(*workspace, map[string]any) (mcp.ToolCallResult, error)) (mcp.ToolCallResult, error) {
+	ws, err := openWorkspace(args)
+	if err != nil {
+		return textErr(fmt.Sprintf("cannot open workspace: %v", err))
+	}
+	defer ws.Close()
+	return fn(ws, args)
+}
+
+// open opens a file for reading. Regular files with more than one hard link
+// are refused (see errHardlink); the check uses the opened descriptor, so
+// the file cannot be swapped after it.
+func (ws *workspace) open(rel string) (*os.File, fs.FileInfo, error) {
+	f, err := ws.root.Open(rel)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if info.Mode().IsRegular() && linkCount(info) > 1 {
+		f.Close()
+		return nil, nil, &fs.PathError{Op: "open", Path: rel, Err: errHardlink}
+	}
+	return f, info, nil
+}
+
+// readFile reads a whole file through open.
+func (ws *workspace) readFile(rel string) ([]byte, error) {
+	f, _, err := ws.open(rel)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func (ws *workspace) stat(rel string) (fs.FileInfo, error) { return ws.root.Stat(rel) }
+
+func (ws *workspace) readDir(rel string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(ws.root.FS(), filepath.ToSlash(rel))
+}
+
+// walkDir walks the tree under rel without following symlinks. Paths passed
+// to fn are slash-separated and workspace-relative.
+func (ws *workspace) walkDir(rel string, fn fs.WalkDirFunc) error {
+	return fs.WalkDir(ws.root.FS(), filepath.ToSlash(rel), fn)
+}
+
+// writeFileAtomic replaces rel with data by writing a temporary file in the
+// same directory and renaming it over the target, so readers never see a
+// half-written file. Parent directories are created as needed and an
+// existing file's permissions are kept. Renaming replaces a hard link or
+// symlink at rel rather than writing through it.
+func (ws *workspace) writeFileAtomic(rel string, data []byte) error {
+	dir := filepath.Dir(rel)
+	if err := ws.root.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	mode := os.FileMode(0o644)
+	if info, err := ws.root.Lstat(rel); err == nil && info.Mode().IsRegular() {
+		mode = info.Mode().Perm()
+	}
+
+	var suffix [6]byte
+	rand.Read(suffix[:])
+	tmp := filepath.Join(dir, ".tmp-"+filepath.Base(rel)+"-"+hex.EncodeToString(suffix[:]))
+	f, err := ws.root.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	_, werr := f.Write(data)
+	if cerr := f.Close(); werr == nil {
+		werr = cerr
+	}
+	if werr == nil {
+		werr = ws.root.Rename(tmp, rel)
+	}
+	if werr != nil {
+		ws.root.Remove(tmp)
+	}
+	return werr
+}
+
+// slashRel normalises a workspace-relative path for use as a stable key.
+func slashRel(rel string) string { return path.Clean(filepath.ToSlash(rel)) }
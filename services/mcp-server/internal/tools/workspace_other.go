@@ -0,0 +1,9 @@
+//go:build !unix
+
+package tools
+
+import "io/fs"
+
+// linkCount is not available on this platform; files are assumed to have a
+// single link.
+func linkCount(info fs.FileInfo) uint64 { return 1 }
@@ -0,0 +1,428 @@
+package tools
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"mcp-server/internal/mcp"
+)
+
+// Named workspaces give each project or run its own root under a base
+// directory, so agents working on different projects never see each other's
+// files. A file tool call picks one with the "workspace" argument; calls
+// without it use the shared FILE_WORK_DIR workspace as before.
+//
+//	<base>/<name>/                         a live workspace
+//	<base>/.archive/<name>-<time>.tar.gz   an archived workspace
+//
+// The base directory is FILE_WORKSPACES_DIR, default ./agent-workspaces.
+
+const archiveDir = ".archive"
+
+var reWorkspaceName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+func workspacesDir() string {
+	if d := os.Getenv("FILE_WORKSPACES_DIR"); d != "" {
+		return d
+	}
+	return "./agent-workspaces"
+}
+
+// workspaceDir returns the directory of the named workspace, rejecting
+// names that are not a single plain path component.
+func workspaceDir(name string) (string, error) {
+	if !reWorkspaceName.MatchString(name) || strings.Contains(name, "..") {
+		return "", fmt.Errorf("invalid workspace name %q: use 1-64 letters, digits, '.', '_' or '-', starting with a letter or digit", name)
+	}
+	base, err := filepath.Abs(workspacesDir())
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, name), nil
+}
+
+// workspaceRoot returns the directory a tool call operates in: the named
+// workspace from the "workspace" argument, which must already exist, or the
+// default FILE_WORK_DIR workspace, which is created on demand.
+func workspaceRoot(args map[string]any) (dir string, create bool, err error) {
+	name := optionalString(args, "workspace", "")
+	if name == "" {
+		dir, err = filepath.Abs(workDir())
+		return dir, true, err
+	}
+	dir, err = workspaceDir(name)
+	if err != nil {
+		return "", false, err
+	}
+	if info, statErr := os.Stat(dir); statErr != nil || !info.IsDir() {
+		return "", false, fmt.Errorf("workspace %q does not exist; create it with workspace_create", name)
+	}
+	return dir, false, nil
+}
+
+type workspaceInfo struct {
+	Name     string `json:"name"`
+	Files    int    `json:"files"`
+	Bytes    int64  `json:"bytes"`
+	Modified string `json:"modified"`
+}
+
+type archiveInfo struct {
+	Name     string `json:"name"`
+	Archive  string `json:"archive"`
+	Bytes    int64  `json:"bytes"`
+	Archived string `json:"archived"`
+}
+
+func workspaceCreate(args map[string]any) (mcp.ToolCallResult, error) {
+	name, errResult, err := requireString(args, "name")
+	if errResult != nil {
+		return *errResult, err
+	}
+	dir, err := workspaceDir(name)
+	if err != nil {
+		return textErr(err.Error())
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return textErr(fmt.Sprintf("create workspace failed: %v", err))
+	}
+	created := true
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		if !os.IsExist(err) {
+			return textErr(fmt.Sprintf("create workspace failed: %v", err))
+		}
+		created = false
+	}
+	return textResult(map[string]any{
+		"ok":        true,
+		"workspace": name,
+		"created":   created,
+	})
+}
+
+func workspaceList(args map[string]any) (mcp.ToolCallResult, error) {
+	base, err := filepath.Abs(workspacesDir())
+	if err != nil {
+		return textErr(fmt.Sprintf("cannot resolve workspaces dir: %v", err))
+	}
+	workspaces := []workspaceInfo{}
+	entries, _ := os.ReadDir(base)
+	for _, e := range entries {
+		if !e.IsDir() || !reWorkspaceName.MatchString(e.Name()) {
+			continue
+		}
+		ws := workspaceInfo{Name: e.Name()}
+		var modified time.Time
+		filepath.WalkDir(filepath.Join(base, e.Name()), func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				if err == nil && d.Name() == historyDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				ws.Files++
+				ws.Bytes += info.Size()
+				modified = maxTime(modified, info.ModTime())
+			}
+			return nil
+		})
+		if modified.IsZero() {
+			if info, err := e.Info(); err == nil {
+				modified = info.ModTime()
+			}
+		}
+		ws.Modified = modified.UTC().Format(time.RFC3339)
+		workspaces = append(workspaces, ws)
+	}
+
+	archives := []archiveInfo{}
+	if optionalBool(args, "include_archived", false) {
+		files, _ := os.ReadDir(filepath.Join(base, archiveDir))
+		for _, f := range files {
+			name, stamp, ok := parseArchiveName(f.Name())
+			if !ok {
+				continue
+			}
+			a := archiveInfo{Name: name, Archive: f.Name(), Archived: stamp.Format(time.RFC3339)}
+			if info, err := f.Info(); err == nil {
+				a.Bytes = info.Size()
+			}
+			archives = append(archives, a)
+		}
+		sort.Slice(archives, func(i, j int) bool { return archives[i].Archive < archives[j].Archive })
+	}
+
+	return textResult(map[string]any{
+		"workspaces": workspaces,
+		"count":      len(workspaces),
+		"archived":   archives,
+	})
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+const archiveStamp = "20060102T150405Z"
+
+// parseArchiveName splits "<name>-<stamp>.tar.gz" into the workspace name
+// and archive time. A second archive in the same second gets a "-<n>"
+// counter after the stamp.
+func parseArchiveName(file string) (string, time.Time, bool) {
+	base, ok := strings.CutSuffix(file, ".tar.gz")
+	if !ok {
+		return "", time.Time{}, false
+	}
+	if i := strings.LastIndexByte(base, '-'); i > 0 && i >= len(base)-4 && strings.Trim(base[i+1:], "0123456789") == "" {
+		if _, err := time.Parse(archiveStamp, base[max(i-len(archiveStamp), 0):i]); err == nil {
+			base = base[:i]
+		}
+	}
+	if len(base) < len(archiveStamp)+2 {
+		return "", time.Time{}, false
+	}
+	name, stamp := base[:len(base)-len(archiveStamp)-1], base[len(base)-len(archiveStamp):]
+	t, err := time.Parse(archiveStamp, stamp)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return name, t, true
+}
+
+// workspaceArchive packs a workspace, history included, into a tar.gz under
+// the archive directory and removes the live copy.
+func workspaceArchive(args map[string]any) (mcp.ToolCallResult, error) {
+	name, errResult, err := requireString(args, "name")
+	if errResult != nil {
+		return *errResult, err
+	}
+	dir, err := workspaceDir(name)
+	if err != nil {
+		return textErr(err.Error())
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return textErr(fmt.Sprintf("workspace %q does not exist", name))
+	}
+	defer root.Close()
+
+	archives := filepath.Join(filepath.Dir(dir), archiveDir)
+	if err := os.MkdirAll(archives, 0o755); err != nil {
+		return textErr(fmt.Sprintf("archive workspace failed: %v", err))
+	}
+	stamp := time.Now().UTC().Format(archiveStamp)
+	file := fmt.Sprintf("%s-%s.tar.gz", name, stamp)
+	files, size, err := writeTarGz(filepath.Join(archives, file), root.FS())
+	for n := 2; errors.Is(err, fs.ErrExist) && n < 1000; n++ {
+		file = fmt.Sprintf("%s-%s-%d.tar.gz", name, stamp, n)
+		files, size, err = writeTarGz(filepath.Join(archives, file), root.FS())
+	}
+	if err != nil {
+		return textErr(fmt.Sprintf("archive workspace failed: %v", err))
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return textErr(fmt.Sprintf("archived to %s but removing the workspace failed: %v", file, err))
+	}
+	forgetHistoryIndex(dir)
+	forgetWorkspaceUsage(dir)
+	return textResult(map[string]any{
+		"ok":        true,
+		"workspace": name,
+		"archive":   file,
+		"files":     files,
+		"bytes":     size,
+	})
+}
+
+// writeTarGz writes every regular file and directory of fsys to a new
+// gzip-compressed tar at dest, which must not exist yet; a partly written
+// archive is removed on failure. Symlinks, hard-linked files and other
+// special files are skipped. It returns the number of files and the archive
+// size.
+func writeTarGz(dest string, fsys fs.FS) (int, int64, error) {
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer out.Close()
+	files, err := writeTarStream(out, fsys)
+	if err != nil {
+		out.Close()
+		os.Remove(dest)
+		return 0, 0, err
+	}
+	info, err := out.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	return files, info.Size(), nil
+}
+
+// writeTarStream writes the tar.gz of fsys to out and returns the number of
+// files in it.
+func writeTarStream(out io.Writer, fsys fs.FS) (int, error) {
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	files := 0
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == "." {
+			return err
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && linkCount(info) > 1 {
+			return nil // see errHardlink
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = p
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		f, err := fsys.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		files++
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	return files, err
+}
+
+// workspaceDelete permanently removes a live workspace, or one archive of it.
+func workspaceDelete(args map[string]any) (mcp.ToolCallResult, error) {
+	name, errResult, err := requireString(args, "name")
+	if errResult != nil {
+		return *errResult, err
+	}
+	dir, err := workspaceDir(name)
+	if err != nil {
+		return textErr(err.Error())
+	}
+
+	if archive := optionalString(args, "archive", ""); archive != "" {
+		if owner, _, ok := parseArchiveName(archive); !ok || owner != name || filepath.Base(archive) != archive {
+			return textErr(fmt.Sprintf("%q is not an archive of workspace %q; see workspace_list with include_archived", archive, name))
+		}
+		if err := os.Remove(filepath.Join(filepath.Dir(dir), archiveDir, archive)); err != nil {
+			return textErr(fmt.Sprintf("delete archive failed: %v", err))
+		}
+		return textResult(map[string]any{"ok": true, "workspace": name, "deleted_archive": archive})
+	}
+
+	if info, err := os.Lstat(dir); err != nil || !info.IsDir() {
+		return textErr(fmt.Sprintf("workspace %q does not exist", name))
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return textErr(fmt.Sprintf("delete workspace failed: %v", err))
+	}
+	forgetHistoryIndex(dir)
+	forgetWorkspaceUsage(dir)
+	return textResult(map[string]any{"ok": true, "workspace": name, "deleted": true})
+}
+
+// workspaceProperty is added to every tool that reads or writes workspace
+// files.
+var workspaceProperty = mcp.Property{
+	Type:        "string",
+	Description: "Named workspace (from workspace_create) to operate in, e.g. the project key or run id. Omit to use the default shared workspace.",
+}
+
+// withWorkspaceArg adds the "workspace" argument to tool definitions.
+func withWorkspaceArg(defs []mcp.ToolDefinition) []mcp.ToolDefinition {
+	for i := range defs {
+		props := make(map[string]mcp.Property, len(defs[i].InputSchema.Properties)+1)
+		for k, v := range defs[i].InputSchema.Properties {
+			props[k] = v
+		}
+		props["workspace"] = workspaceProperty
+		defs[i].InputSchema.Properties = props
+	}
+	return defs
+}
+
+func workspaceDefinitions() []mcp.ToolDefinition {
+	return []mcp.ToolDefinition{
+		{
+			Name:        "workspace_create",
+			Description: "Create a named workspace: a private directory for one project or run that the file tools use when given the same workspace name. Creating an existing workspace is a no-op.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"name": {Type: "string", Description: "Workspace name: 1-64 letters, digits, '.', '_' or '-', e.g. \"PROJ-42\" or a run id."},
+				},
+				Required: []string{"name"},
+			},
+		},
+		{
+			Name:        "workspace_list",
+			Description: "List the named workspaces with their file counts, sizes and last modification times, and optionally their archives.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"include_archived": {Type: "boolean", Description: "Also list archived workspaces (default false)."},
+				},
+				Required: []string{},
+			},
+		},
+		{
+			Name:        "workspace_archive",
+			Description: "Archive a named workspace when its project or run is finished: its files and history are packed into a .tar.gz kept alongside the workspaces, and the live workspace is removed so the name can be reused.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"name": {Type: "string", Description: "Workspace to archive."},
+				},
+				Required: []string{"name"},
+			},
+		},
+		{
+			Name:        "workspace_delete",
+			Description: "Permanently delete a named workspace and all its files, or, with archive, one of its archives. This cannot be undone.",
+			InputSchema: mcp.JSONSchema{
+				Type: "object",
+				Properties: map[string]mcp.Property{
+					"name":    {Type: "string", Description: "Workspace to delete."},
+					"archive": {Type: "string", Description: "Archive file name from workspace_list to delete instead of the live workspace."},
+				},
+				Required: []string{"name"},
+			},
+		},
+	}
+}